@@ -23,9 +23,11 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fxamacker/cbor/v2"
+	"github.com/khulnasoft/velocity/internal/keyring"
 	"github.com/khulnasoft/velocity/log"
 	"github.com/khulnasoft/velocity/utils"
 	"github.com/valyala/fasthttp"
@@ -127,6 +129,10 @@ type App struct {
 	routesCount uint32
 	// Amount of registered handlers
 	handlersCount uint32
+	// Indicates if ShutdownWithContext has been called
+	shuttingDown atomic.Bool
+	// Key ring of the signed and encrypted cookies
+	cookieRing *keyring.Ring
 	// contains the information if the route stack has been changed to build the optimized tree
 	routesRefreshed bool
 }
@@ -408,6 +414,22 @@ type Config struct { //nolint:govet // Aligning the struct fields is not necessa
 	//
 	// Optional. Default: false
 	EnableSplittingOnParsers bool `json:"enable_splitting_on_parsers"`
+
+	// CookieKeys is the ordered key ring of base64-encoded keys, from the newest
+	// to the oldest, which protects the cookies of c.SignedCookie and
+	// c.EncryptedCookie. The first key signs and encrypts, all keys verify and
+	// decrypt, so a new key is added in front and the old key is removed once
+	// the cookies it protected have expired.
+	//
+	// Optional. Default: nil
+	CookieKeys []string `json:"-"`
+
+	// CookieAlgorithm is the AEAD which encrypts the cookies of c.EncryptedCookie,
+	// CookieAlgorithmAESGCM (16, 24 or 32 byte keys) or
+	// CookieAlgorithmXChaCha20Poly1305 (32 byte keys).
+	//
+	// Optional. Default: CookieAlgorithmAESGCM
+	CookieAlgorithm string `json:"cookie_algorithm"`
 }
 
 // Default TrustProxyConfig
@@ -574,6 +596,17 @@ func New(config ...Config) *App {
 		app.config.RequestMethods = DefaultMethods
 	}
 
+	if app.config.CookieAlgorithm == "" {
+		app.config.CookieAlgorithm = CookieAlgorithmAESGCM
+	}
+	if len(app.config.CookieKeys) > 0 {
+		ring, err := keyring.NewBase64(keyring.Algorithm(app.config.CookieAlgorithm), app.config.CookieKeys...)
+		if err != nil {
+			panic(fmt.Sprintf("velocity: invalid CookieKeys: %v", err))
+		}
+		app.cookieRing = ring
+	}
+
 	app.config.TrustProxyConfig.ips = make(map[string]struct{}, len(app.config.TrustProxyConfig.Proxies))
 	for _, ipAddress := range app.config.TrustProxyConfig.Proxies {
 		app.handleTrustedProxy(ipAddress)
@@ -918,6 +951,8 @@ func (app *App) ShutdownWithTimeout(timeout time.Duration) error {
 //
 // ShutdownWithContext does not close keepalive connections so its recommended to set ReadTimeout to something else than 0.
 func (app *App) ShutdownWithContext(ctx context.Context) error {
+	app.shuttingDown.Store(true)
+
 	if app.hooks != nil {
 		// TODO: check should be defered?
 		app.hooks.executeOnShutdownHooks()
@@ -931,6 +966,13 @@ func (app *App) ShutdownWithContext(ctx context.Context) error {
 	return app.server.ShutdownWithContext(ctx)
 }
 
+// IsShuttingDown reports whether the shutdown of the server has begun. From then on,
+// no new connections are accepted and the open ones are drained, so readiness
+// probes should report the app as unavailable.
+func (app *App) IsShuttingDown() bool {
+	return app.shuttingDown.Load()
+}
+
 // Server returns the underlying fasthttp server
 func (app *App) Server() *fasthttp.Server {
 	return app.server
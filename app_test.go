@@ -909,7 +909,9 @@ func Test_App_Shutdown(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		t.Parallel()
 		app := New()
+		require.False(t, app.IsShuttingDown())
 		require.NoError(t, app.Shutdown())
+		require.True(t, app.IsShuttingDown())
 	})
 
 	t.Run("no server", func(t *testing.T) {
@@ -29,6 +29,7 @@ const (
 	MIMEApplicationForm       = "application/x-www-form-urlencoded"
 	MIMEOctetStream           = "application/octet-stream"
 	MIMEMultipartForm         = "multipart/form-data"
+	MIMEMultipartByteRanges   = "multipart/byteranges"
 
 	MIMETextXMLCharsetUTF8         = "text/xml; charset=utf-8"
 	MIMETextHTMLCharsetUTF8        = "text/html; charset=utf-8"
@@ -313,6 +314,12 @@ const (
 	CookieSameSiteNoneMode   = "none"
 )
 
+// Cookie encryption algorithms of Config.CookieAlgorithm
+const (
+	CookieAlgorithmAESGCM            = "AES-GCM"
+	CookieAlgorithmXChaCha20Poly1305 = "XChaCha20-Poly1305"
+)
+
 // Route Constraints
 const (
 	ConstraintInt             = "int"
@@ -13,9 +13,11 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"mime"
 	"mime/multipart"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -65,6 +67,8 @@ type DefaultCtx struct {
 	pathBuffer          []byte               // HTTP path buffer
 	detectionPathBuffer []byte               // HTTP detectionPath buffer
 	flashMessages       redirectionMsgs      // Flash messages
+	streamEncoder       StreamEncoder        // Encoder applied to streamed response bodies
+	streamEncoding      string               // Content-Encoding token of streamEncoder
 	indexRoute          int                  // Index of the current route
 	indexHandler        int                  // Index of the current handler
 	methodINT           int                  // HTTP method INT equivalent
@@ -87,7 +91,8 @@ type SendFile struct {
 	// Optional. Default: false
 	Compress bool `json:"compress"`
 
-	// When set to true, enables byte range requests.
+	// When set to true, enables byte range requests, including multiple
+	// ranges (multipart/byteranges) and If-Range preconditions.
 	//
 	// Optional. Default: false
 	ByteRange bool `json:"byte_range"`
@@ -110,6 +115,12 @@ type SendFile struct {
 	MaxAge int `json:"max_age"`
 }
 
+// StreamEncoder wraps w with a content encoder for streamed response bodies.
+// If the encoder implements Flush() error, SendStreamWriter flushes it whenever
+// the handler's writer writes to it, so that a Flush of the handler's writer
+// also reaches the client.
+type StreamEncoder func(w io.Writer) io.WriteCloser
+
 // sendFileStore is used to keep the SendFile configuration and the handler.
 type sendFileStore struct {
 	handler           fasthttp.RequestHandler
@@ -451,6 +462,103 @@ func (c *DefaultCtx) Cookies(key string, defaultValue ...string) string {
 	return defaultString(c.app.getString(c.fasthttp.Request.Header.Cookie(key)), defaultValue)
 }
 
+// SignedCookie sets a cookie whose value is signed with HMAC-SHA256 by the
+// first key of Config.CookieKeys. The value stays readable by the client,
+// but it is bound to the cookie name and its expiration, so changes are
+// detected by SignedCookies.
+func (c *DefaultCtx) SignedCookie(cookie *Cookie) error {
+	return c.protectedCookie(cookie, false)
+}
+
+// SignedCookies returns the value of a cookie set by SignedCookie. It returns
+// ErrCookieNotFound, ErrCookieTampered or ErrCookieExpired if the cookie is
+// missing, was changed or has expired.
+func (c *DefaultCtx) SignedCookies(key string) (string, error) {
+	return c.protectedCookies(key, false)
+}
+
+// EncryptedCookie sets a cookie whose value is encrypted with the first key
+// of Config.CookieKeys, using Config.CookieAlgorithm. The value is bound to
+// the cookie name and its expiration.
+func (c *DefaultCtx) EncryptedCookie(cookie *Cookie) error {
+	return c.protectedCookie(cookie, true)
+}
+
+// EncryptedCookies returns the decrypted value of a cookie set by
+// EncryptedCookie. It returns ErrCookieNotFound, ErrCookieTampered or
+// ErrCookieExpired if the cookie is missing, was changed or has expired.
+func (c *DefaultCtx) EncryptedCookies(key string) (string, error) {
+	return c.protectedCookies(key, true)
+}
+
+// protectedCookie signs or encrypts the value of the cookie with its expiration and sets it
+func (c *DefaultCtx) protectedCookie(cookie *Cookie, encrypt bool) error {
+	ring := c.app.cookieRing
+	if ring == nil {
+		return ErrCookieKeysNotSet
+	}
+
+	// The expiration is protected, so the client cannot extend the lifetime of the value
+	var expires int64
+	switch {
+	case cookie.SessionOnly:
+	case cookie.MaxAge > 0:
+		expires = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second).Unix()
+	case !cookie.Expires.IsZero():
+		expires = cookie.Expires.Unix()
+	}
+	payload := strconv.FormatInt(expires, 10) + "|" + cookie.Value
+
+	protected := *cookie
+	if encrypt {
+		value, err := ring.Encrypt(cookie.Name, payload)
+		if err != nil {
+			return fmt.Errorf("cookie: failed to encrypt: %w", err)
+		}
+		protected.Value = value
+	} else {
+		protected.Value = ring.Sign(cookie.Name, payload)
+	}
+	c.Cookie(&protected)
+	return nil
+}
+
+// protectedCookies verifies or decrypts the value of the cookie and checks its expiration
+func (c *DefaultCtx) protectedCookies(key string, encrypt bool) (string, error) {
+	ring := c.app.cookieRing
+	if ring == nil {
+		return "", ErrCookieKeysNotSet
+	}
+	raw := c.fasthttp.Request.Header.Cookie(key)
+	if len(raw) == 0 {
+		return "", ErrCookieNotFound
+	}
+
+	var payload string
+	var err error
+	if encrypt {
+		payload, err = ring.Decrypt(key, string(raw))
+	} else {
+		payload, err = ring.Verify(key, string(raw))
+	}
+	if err != nil {
+		return "", ErrCookieTampered
+	}
+
+	expires, value, found := strings.Cut(payload, "|")
+	if !found {
+		return "", ErrCookieTampered
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", ErrCookieTampered
+	}
+	if unix != 0 && time.Now().Unix() >= unix {
+		return "", ErrCookieExpired
+	}
+	return value, nil
+}
+
 // Download transfers the file from path as an attachment.
 // Typically, browsers will prompt the user for download.
 // By default, the Content-Disposition header filename= parameter is the filepath (this typically appears in the browser dialog).
@@ -1270,6 +1378,8 @@ func (c *DefaultCtx) Range(size int) (Range, error) {
 		} else {
 			moreRanges = ""
 		}
+		// Ranges may be separated by optional whitespace
+		singleRange = utils.Trim(singleRange, ' ')
 
 		var (
 			startStr, endStr string
@@ -1286,6 +1396,9 @@ func (c *DefaultCtx) Range(size int) (Range, error) {
 		if startErr != nil { // -nnn
 			start = size - end
 			end = size - 1
+			if endErr == nil && start < 0 { // a longer suffix selects the whole representation
+				start = 0
+			}
 		} else if endErr != nil { // nnn-
 			end = size - 1
 		}
@@ -1478,6 +1591,48 @@ func (c *DefaultCtx) Route() *Route {
 	return c.route
 }
 
+// Matched reports whether a route registered for the request method matched,
+// as opposed to only middlewares registered with Use.
+// Like Route, it is only meaningful in middlewares after calling c.Next().
+func (c *DefaultCtx) Matched() bool {
+	return c.matched
+}
+
+// ResolveRoute returns the route registered for the request method which will
+// handle the request and the values of its Params, or nil if no route matches.
+// If no such route matches, the handlers mounted with Use after the current
+// route are assumed to handle the request, e.g. a static file server, and the
+// one with the longest matching prefix is returned. Unlike Route and Params,
+// it is meaningful in middlewares before calling c.Next().
+func (c *DefaultCtx) ResolveRoute() (*Route, []string) {
+	tree, ok := c.app.treeStack[c.methodINT][c.treePath]
+	if !ok {
+		tree = c.app.treeStack[c.methodINT][""]
+	}
+
+	var values, useValues [maxParams]string
+	var use *Route
+	for i, route := range tree {
+		if route.mount {
+			continue
+		}
+		if route.use {
+			if i > c.indexRoute && (use == nil || len(route.Path) >= len(use.Path)) &&
+				route.match(c.detectionPath, c.path, &values) {
+				use, useValues = route, values
+			}
+			continue
+		}
+		if route.match(c.detectionPath, c.path, &values) {
+			return route, append([]string(nil), values[:len(route.Params)]...)
+		}
+	}
+	if use != nil {
+		return use, append([]string(nil), useValues[:len(use.Params)]...)
+	}
+	return nil, nil
+}
+
 // SaveFile saves any multipart file to disk.
 func (*DefaultCtx) SaveFile(fileheader *multipart.FileHeader, path string) error {
 	return fasthttp.SaveMultipartFile(fileheader, path)
@@ -1590,6 +1745,23 @@ func (c *DefaultCtx) SendFile(file string, config ...SendFile) error {
 	// Keep original path for mutable params
 	c.pathOriginal = utils.CopyString(c.pathOriginal)
 
+	// Answer range requests, including multipart/byteranges and If-Range
+	if cfg.ByteRange && len(c.fasthttp.Request.Header.Peek(HeaderRange)) > 0 {
+		handled, err := c.sendFileRanges(file, cfg)
+		if err != nil {
+			return err
+		}
+		if handled {
+			if cfg.Download {
+				c.Attachment(filename)
+			}
+			if len(cacheControlValue) > 0 {
+				c.fasthttp.Response.Header.Set(HeaderCacheControl, cacheControlValue)
+			}
+			return nil
+		}
+	}
+
 	// Delete the Accept-Encoding header if compression is disabled
 	if !cfg.Compress {
 		// https://github.com/valyala/fasthttp/blob/7cc6f4c513f9e0d3686142e0a1a5aa2f76b3194a/fs.go#L55
@@ -1681,6 +1853,34 @@ func (c *DefaultCtx) SendString(body string) error {
 
 // SendStream sets response body stream and optional body size.
 func (c *DefaultCtx) SendStream(stream io.Reader, size ...int) error {
+	// Seekable streams can answer range requests
+	if content, ok := stream.(io.ReadSeeker); ok && len(c.fasthttp.Request.Header.Peek(HeaderRange)) > 0 {
+		contentSize := int64(-1)
+		if len(size) > 0 && size[0] >= 0 {
+			contentSize = int64(size[0])
+		} else if end, err := content.Seek(0, io.SeekEnd); err == nil {
+			if _, err := content.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to rewind stream: %w", err)
+			}
+			contentSize = end
+		}
+		if contentSize >= 0 && c.sendRanges(content, contentSize) {
+			return nil
+		}
+	}
+
+	if encoder := c.acquireStreamEncoder(); encoder != nil {
+		c.fasthttp.Response.SetBodyStreamWriter(func(w *bufio.Writer) {
+			enc := encoder(&streamFlushWriter{w: w})
+			_, _ = io.Copy(enc, stream) //nolint:errcheck // client disconnects surface as write errors
+			_ = enc.Close()             //nolint:errcheck // nothing left to report to
+			if closer, ok := stream.(io.Closer); ok {
+				_ = closer.Close() //nolint:errcheck // nothing left to report to
+			}
+		})
+		return nil
+	}
+
 	if len(size) > 0 && size[0] >= 0 {
 		c.fasthttp.Response.SetBodyStream(stream, size[0])
 	} else {
@@ -1692,11 +1892,256 @@ func (c *DefaultCtx) SendStream(stream io.Reader, size ...int) error {
 
 // SendStreamWriter sets response body stream writer
 func (c *DefaultCtx) SendStreamWriter(streamWriter func(*bufio.Writer)) error {
+	if encoder := c.acquireStreamEncoder(); encoder != nil {
+		c.fasthttp.Response.SetBodyStreamWriter(func(w *bufio.Writer) {
+			enc := encoder(&streamFlushWriter{w: w})
+			bw := bufio.NewWriter(&encoderFlushWriter{enc: enc})
+			streamWriter(bw)
+			_ = bw.Flush()  //nolint:errcheck // client disconnects surface as write errors
+			_ = enc.Close() //nolint:errcheck // nothing left to report to
+		})
+		return nil
+	}
+
 	c.fasthttp.Response.SetBodyStreamWriter(fasthttp.StreamWriter(streamWriter))
 
 	return nil
 }
 
+// SetStreamEncoder registers an encoder that SendStream and SendStreamWriter
+// apply to the response body, announcing it with the given Content-Encoding.
+// The encoder is skipped if the response already has a Content-Encoding or
+// its Content-Type is not compressible, e.g. images other than SVG and icons.
+// Passing a nil encoder removes a previously registered one.
+func (c *DefaultCtx) SetStreamEncoder(encoding string, encoder StreamEncoder) {
+	c.streamEncoding = encoding
+	c.streamEncoder = encoder
+}
+
+// acquireStreamEncoder returns the registered stream encoder and sets the
+// Content-Encoding header, or returns nil if the body must be sent as-is.
+func (c *DefaultCtx) acquireStreamEncoder() StreamEncoder {
+	header := &c.fasthttp.Response.Header
+	if c.streamEncoder == nil || len(header.ContentEncoding()) > 0 || !isCompressibleContentType(header.ContentType()) {
+		return nil
+	}
+	c.fasthttp.Response.Header.SetContentEncoding(c.streamEncoding)
+	return c.streamEncoder
+}
+
+// maxRangeCount is the maximum number of ranges answered in a single
+// multipart/byteranges response. Requests with more ranges are answered with
+// the full content, which RFC 9110 explicitly permits.
+const maxRangeCount = 32
+
+// sendRanges answers a range request for content of the given size with
+// 206 Partial Content or 416 Range Not Satisfiable (RFC 9110, 14).
+// It reports whether the request was answered; otherwise the full content
+// must be sent. If it was answered, the content is closed, either by the
+// response body or right away for 416. ETag and Last-Modified must be set
+// before for If-Range.
+func (c *DefaultCtx) sendRanges(content io.ReadSeeker, size int64) bool {
+	c.setCanonical(HeaderAcceptRanges, "bytes")
+
+	if c.Method() != MethodGet || !c.ifRangeMatches() {
+		return false
+	}
+
+	ranges, err := c.Range(int(size))
+	if ranges.Type != "bytes" {
+		return false
+	}
+	if errors.Is(err, ErrRangeUnsatisfiable) {
+		c.fasthttp.Response.ResetBody()
+		c.fasthttp.Response.SetStatusCode(StatusRequestedRangeNotSatisfiable)
+		c.setCanonical(HeaderContentRange, "bytes */"+strconv.FormatInt(size, 10))
+		if closer, ok := content.(io.Closer); ok {
+			_ = closer.Close() //nolint:errcheck // nothing left to report to
+		}
+		return true
+	}
+	if err != nil || len(ranges.Ranges) > maxRangeCount {
+		return false
+	}
+
+	body := &rangeBody{content: content}
+	var length int64
+
+	if len(ranges.Ranges) == 1 {
+		r := ranges.Ranges[0]
+		length = int64(r.End - r.Start + 1)
+		body.Reader = &rangeSection{r: content, off: int64(r.Start), n: length}
+		c.setCanonical(HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size))
+	} else {
+		contentType := string(c.fasthttp.Response.Header.ContentType())
+		boundary := multipart.NewWriter(nil).Boundary()
+		readers := make([]io.Reader, 0, 2*len(ranges.Ranges)+1)
+		for i, r := range ranges.Ranges {
+			header := fmt.Sprintf("--%s\r\n%s: %s\r\n%s: bytes %d-%d/%d\r\n\r\n",
+				boundary, HeaderContentType, contentType, HeaderContentRange, r.Start, r.End, size)
+			if i > 0 {
+				header = "\r\n" + header
+			}
+			n := int64(r.End - r.Start + 1)
+			readers = append(readers, strings.NewReader(header), &rangeSection{r: content, off: int64(r.Start), n: n})
+			length += int64(len(header)) + n
+		}
+		trailer := "\r\n--" + boundary + "--\r\n"
+		readers = append(readers, strings.NewReader(trailer))
+		length += int64(len(trailer))
+
+		body.Reader = io.MultiReader(readers...)
+		c.fasthttp.Response.Header.SetContentType(MIMEMultipartByteRanges + "; boundary=" + boundary)
+	}
+
+	c.fasthttp.Response.SetStatusCode(StatusPartialContent)
+	c.fasthttp.Response.SetBodyStream(body, int(length))
+	return true
+}
+
+// ifRangeMatches evaluates the If-Range precondition against the ETag and
+// Last-Modified response headers. Only strong validators match (RFC 9110, 13.1.5).
+func (c *DefaultCtx) ifRangeMatches() bool {
+	ifRange := c.Get(HeaderIfRange)
+	if ifRange == "" {
+		return true
+	}
+
+	if strings.HasPrefix(ifRange, `"`) {
+		etag := c.GetRespHeader(HeaderETag)
+		return etag == ifRange
+	}
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+
+	lastModified, err := fasthttp.ParseHTTPDate(c.fasthttp.Response.Header.Peek(HeaderLastModified))
+	if err != nil {
+		return false
+	}
+	date, err := fasthttp.ParseHTTPDate(utils.UnsafeBytes(ifRange))
+	return err == nil && date.Equal(lastModified)
+}
+
+// sendFileRanges answers a range request for file, see sendRanges. If the
+// request is not answered, the Range header is removed, so that the file
+// handler sends the full content.
+func (c *DefaultCtx) sendFileRanges(file string, cfg SendFile) (bool, error) {
+	var (
+		f   fs.File
+		err error
+	)
+	if cfg.FS != nil {
+		f, err = cfg.FS.Open(strings.TrimPrefix(filepath.ToSlash(file), "/"))
+	} else {
+		f, err = os.Open(filepath.Clean(file))
+	}
+	if err != nil {
+		// Let the file handler report the missing file
+		return false, nil //nolint:nilerr // handled by the file handler
+	}
+
+	stat, err := f.Stat()
+	content, seekable := f.(io.ReadSeeker)
+	if err != nil || stat.IsDir() || !seekable {
+		_ = f.Close() //nolint:errcheck // the file handler serves the request
+		c.fasthttp.Request.Header.Del(HeaderRange)
+		return false, nil //nolint:nilerr // handled by the file handler
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(stat.Name()))
+	if contentType == "" {
+		contentType = MIMEOctetStream
+	}
+	c.fasthttp.Response.Header.SetContentType(contentType)
+	if modTime := stat.ModTime(); !modTime.IsZero() {
+		c.fasthttp.Response.Header.SetLastModified(modTime)
+	}
+
+	if c.sendRanges(content, stat.Size()) {
+		return true, nil
+	}
+
+	_ = f.Close() //nolint:errcheck // the file handler serves the request
+	c.fasthttp.Request.Header.Del(HeaderRange)
+	return false, nil
+}
+
+// rangeSection reads n bytes at offset off of r. It seeks on the first read,
+// so that several sections of the same reader can be read one after another.
+type rangeSection struct {
+	r      io.ReadSeeker
+	off    int64
+	n      int64
+	seeked bool
+}
+
+func (s *rangeSection) Read(p []byte) (int, error) {
+	if !s.seeked {
+		if _, err := s.r.Seek(s.off, io.SeekStart); err != nil {
+			return 0, fmt.Errorf("failed to seek range: %w", err)
+		}
+		s.seeked = true
+	}
+	if s.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.n {
+		p = p[:s.n]
+	}
+	n, err := s.r.Read(p)
+	s.n -= int64(n)
+	if errors.Is(err, io.EOF) && s.n > 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err //nolint:wrapcheck // io.Reader errors must not be wrapped
+}
+
+// rangeBody is the body stream of a range response, closing the content when done.
+type rangeBody struct {
+	io.Reader
+	content io.ReadSeeker
+}
+
+func (b *rangeBody) Close() error {
+	if closer, ok := b.content.(io.Closer); ok {
+		return closer.Close() //nolint:wrapcheck // passed on as is
+	}
+	return nil
+}
+
+// encoderFlushWriter flushes the stream encoder after every write. It sits
+// below the buffered writer of SendStreamWriter, which only writes when the
+// handler flushes it or its buffer is full.
+type encoderFlushWriter struct {
+	enc io.WriteCloser
+}
+
+func (w *encoderFlushWriter) Write(p []byte) (int, error) {
+	n, err := w.enc.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if flusher, ok := w.enc.(interface{ Flush() error }); ok {
+		return n, flusher.Flush()
+	}
+	return n, nil
+}
+
+// streamFlushWriter flushes the connection writer after every write, so that
+// encoded stream chunks are sent to the client immediately.
+type streamFlushWriter struct {
+	w *bufio.Writer
+}
+
+func (w *streamFlushWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, w.w.Flush()
+}
+
 // Set sets the response's HTTP header field to the specified key, value.
 func (c *DefaultCtx) Set(key, val string) {
 	c.fasthttp.Response.Header.Set(key, val)
@@ -1920,6 +2365,8 @@ func (c *DefaultCtx) release() {
 	c.bind = nil
 	c.flashMessages = c.flashMessages[:0]
 	c.viewBindMap = sync.Map{}
+	c.streamEncoder = nil
+	c.streamEncoding = ""
 	if c.redirect != nil {
 		ReleaseRedirect(c.redirect)
 		c.redirect = nil
@@ -62,6 +62,23 @@ type Ctx interface {
 	// The returned value is only valid within the handler. Do not store any references.
 	// Make copies or use the Immutable setting to use the value outside the Handler.
 	Cookies(key string, defaultValue ...string) string
+	// SignedCookie sets a cookie whose value is signed with HMAC-SHA256 by the
+	// first key of Config.CookieKeys. The value stays readable by the client,
+	// but it is bound to the cookie name and its expiration, so changes are
+	// detected by SignedCookies.
+	SignedCookie(cookie *Cookie) error
+	// SignedCookies returns the value of a cookie set by SignedCookie. It returns
+	// ErrCookieNotFound, ErrCookieTampered or ErrCookieExpired if the cookie is
+	// missing, was changed or has expired.
+	SignedCookies(key string) (string, error)
+	// EncryptedCookie sets a cookie whose value is encrypted with the first key
+	// of Config.CookieKeys, using Config.CookieAlgorithm. The value is bound to
+	// the cookie name and its expiration.
+	EncryptedCookie(cookie *Cookie) error
+	// EncryptedCookies returns the decrypted value of a cookie set by
+	// EncryptedCookie. It returns ErrCookieNotFound, ErrCookieTampered or
+	// ErrCookieExpired if the cookie is missing, was changed or has expired.
+	EncryptedCookies(key string) (string, error)
 	// Download transfers the file from path as an attachment.
 	// Typically, browsers will prompt the user for download.
 	// By default, the Content-Disposition header filename= parameter is the filepath (this typically appears in the browser dialog).
@@ -267,6 +284,17 @@ type Ctx interface {
 	renderExtensions(bind any)
 	// Route returns the matched Route struct.
 	Route() *Route
+	// Matched reports whether a route registered for the request method matched,
+	// as opposed to only middlewares registered with Use.
+	// Like Route, it is only meaningful in middlewares after calling c.Next().
+	Matched() bool
+	// ResolveRoute returns the route registered for the request method which will
+	// handle the request and the values of its Params, or nil if no route matches.
+	// If no such route matches, the handlers mounted with Use after the current
+	// route are assumed to handle the request, e.g. a static file server, and the
+	// one with the longest matching prefix is returned. Unlike Route and Params,
+	// it is meaningful in middlewares before calling c.Next().
+	ResolveRoute() (*Route, []string)
 	// SaveFile saves any multipart file to disk.
 	SaveFile(fileheader *multipart.FileHeader, path string) error
 	// SaveFileToStorage saves any multipart file to an external storage system.
@@ -291,6 +319,29 @@ type Ctx interface {
 	SendStream(stream io.Reader, size ...int) error
 	// SendStreamWriter sets response body stream writer
 	SendStreamWriter(streamWriter func(*bufio.Writer)) error
+	// SetStreamEncoder registers an encoder that SendStream and SendStreamWriter
+	// apply to the response body, announcing it with the given Content-Encoding.
+	// The encoder is skipped if the response already has a Content-Encoding or
+	// its Content-Type is not compressible, e.g. images other than SVG and icons.
+	// Passing a nil encoder removes a previously registered one.
+	SetStreamEncoder(encoding string, encoder StreamEncoder)
+	// acquireStreamEncoder returns the registered stream encoder and sets the
+	// Content-Encoding header, or returns nil if the body must be sent as-is.
+	acquireStreamEncoder() StreamEncoder
+	// sendRanges answers a range request for content of the given size with
+	// 206 Partial Content or 416 Range Not Satisfiable (RFC 9110, 14).
+	// It reports whether the request was answered; otherwise the full content
+	// must be sent. If it was answered, the content is closed, either by the
+	// response body or right away for 416. ETag and Last-Modified must be set
+	// before for If-Range.
+	sendRanges(content io.ReadSeeker, size int64) bool
+	// ifRangeMatches evaluates the If-Range precondition against the ETag and
+	// Last-Modified response headers. Only strong validators match (RFC 9110, 13.1.5).
+	ifRangeMatches() bool
+	// sendFileRanges answers a range request for file, see sendRanges. If the
+	// request is not answered, the Range header is removed, so that the file
+	// handler sends the full content.
+	sendFileRanges(file string, cfg SendFile) (bool, error)
 	// Set sets the response's HTTP header field to the specified key, value.
 	Set(key, val string)
 	setCanonical(key, val string)
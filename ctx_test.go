@@ -12,12 +12,15 @@ import (
 	"context"
 	"crypto/tls"
 	"embed"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math"
+	"mime"
 	"mime/multipart"
 	"net"
 	"net/http/httptest"
@@ -25,7 +28,9 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"text/template"
 	"time"
 
@@ -1037,6 +1042,125 @@ func Test_Ctx_Cookies(t *testing.T) {
 	require.Equal(t, "default", c.Cookies("unknown", "default"))
 }
 
+// protectedCookieValue returns the value of the cookie set in the response
+func protectedCookieValue(t *testing.T, c Ctx, name string) string {
+	t.Helper()
+
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey(name)
+	require.True(t, c.Response().Header.Cookie(cookie))
+	return string(cookie.Value())
+}
+
+// go test -run Test_Ctx_SignedCookie
+func Test_Ctx_SignedCookie(t *testing.T) {
+	t.Parallel()
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	app := New(Config{CookieKeys: []string{key}})
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+
+	require.NoError(t, c.SignedCookie(&Cookie{Name: "user", Value: "john", MaxAge: 60}))
+	value := protectedCookieValue(t, c, "user")
+	require.Contains(t, value, "|john.")
+
+	c.Request().Header.SetCookie("user", value)
+	user, err := c.SignedCookies("user")
+	require.NoError(t, err)
+	require.Equal(t, "john", user)
+
+	// The unsigned value is still available through Cookies
+	require.Equal(t, value, c.Cookies("user"))
+
+	// Tampered values and values moved to another cookie are rejected
+	c.Request().Header.SetCookie("user", strings.Replace(value, "john", "jane", 1))
+	_, err = c.SignedCookies("user")
+	require.ErrorIs(t, err, ErrCookieTampered)
+
+	c.Request().Header.SetCookie("admin", value)
+	_, err = c.SignedCookies("admin")
+	require.ErrorIs(t, err, ErrCookieTampered)
+
+	_, err = c.SignedCookies("unknown")
+	require.ErrorIs(t, err, ErrCookieNotFound)
+
+	// The expiration is signed
+	require.NoError(t, c.SignedCookie(&Cookie{Name: "expired", Value: "john", Expires: time.Now().Add(-time.Second)}))
+	c.Request().Header.SetCookie("expired", protectedCookieValue(t, c, "expired"))
+	_, err = c.SignedCookies("expired")
+	require.ErrorIs(t, err, ErrCookieExpired)
+
+	// Session cookies do not expire
+	require.NoError(t, c.SignedCookie(&Cookie{Name: "session", Value: "john", MaxAge: 60, SessionOnly: true}))
+	c.Request().Header.SetCookie("session", protectedCookieValue(t, c, "session"))
+	user, err = c.SignedCookies("session")
+	require.NoError(t, err)
+	require.Equal(t, "john", user)
+}
+
+// go test -run Test_Ctx_EncryptedCookie
+func Test_Ctx_EncryptedCookie(t *testing.T) {
+	t.Parallel()
+	oldKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	newKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	for _, algorithm := range []string{CookieAlgorithmAESGCM, CookieAlgorithmXChaCha20Poly1305} {
+		t.Run(algorithm, func(t *testing.T) {
+			t.Parallel()
+			app := New(Config{CookieKeys: []string{oldKey}, CookieAlgorithm: algorithm})
+			c := app.AcquireCtx(&fasthttp.RequestCtx{})
+
+			require.NoError(t, c.EncryptedCookie(&Cookie{Name: "user", Value: "john"}))
+			value := protectedCookieValue(t, c, "user")
+			require.NotContains(t, value, "john")
+
+			c.Request().Header.SetCookie("user", value)
+			user, err := c.EncryptedCookies("user")
+			require.NoError(t, err)
+			require.Equal(t, "john", user)
+
+			// Signed values are not accepted as encrypted ones
+			require.NoError(t, c.SignedCookie(&Cookie{Name: "user", Value: "john"}))
+			c.Request().Header.SetCookie("user", protectedCookieValue(t, c, "user"))
+			_, err = c.EncryptedCookies("user")
+			require.ErrorIs(t, err, ErrCookieTampered)
+
+			// The rotated key ring still decrypts the value of the old key
+			rotated := New(Config{CookieKeys: []string{newKey, oldKey}, CookieAlgorithm: algorithm})
+			rc := rotated.AcquireCtx(&fasthttp.RequestCtx{})
+			rc.Request().Header.SetCookie("user", value)
+			user, err = rc.EncryptedCookies("user")
+			require.NoError(t, err)
+			require.Equal(t, "john", user)
+
+			removed := New(Config{CookieKeys: []string{newKey}, CookieAlgorithm: algorithm})
+			rc = removed.AcquireCtx(&fasthttp.RequestCtx{})
+			rc.Request().Header.SetCookie("user", value)
+			_, err = rc.EncryptedCookies("user")
+			require.ErrorIs(t, err, ErrCookieTampered)
+		})
+	}
+}
+
+// go test -run Test_Ctx_SignedCookie_NoKeys
+func Test_Ctx_SignedCookie_NoKeys(t *testing.T) {
+	t.Parallel()
+	app := New()
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+
+	require.ErrorIs(t, c.SignedCookie(&Cookie{Name: "user", Value: "john"}), ErrCookieKeysNotSet)
+	require.ErrorIs(t, c.EncryptedCookie(&Cookie{Name: "user", Value: "john"}), ErrCookieKeysNotSet)
+	_, err := c.SignedCookies("user")
+	require.ErrorIs(t, err, ErrCookieKeysNotSet)
+	_, err = c.EncryptedCookies("user")
+	require.ErrorIs(t, err, ErrCookieKeysNotSet)
+	require.Empty(t, c.Response().Header.PeekCookie("user"))
+
+	require.PanicsWithValue(t, "velocity: invalid CookieKeys: keyring: AES-GCM keys must be 16, 24 or 32 bytes long", func() {
+		New(Config{CookieKeys: []string{base64.StdEncoding.EncodeToString([]byte("short"))}})
+	})
+}
+
 // go test -run Test_Ctx_Format
 func Test_Ctx_Format(t *testing.T) {
 	t.Parallel()
@@ -2863,6 +2987,10 @@ func Test_Ctx_Range(t *testing.T) {
 	testRange("bytes=0-0,2-1000", RangeSet{Start: 0, End: 0}, RangeSet{Start: 2, End: 999})
 	testRange("bytes=0-99,450-549,-100", RangeSet{Start: 0, End: 99}, RangeSet{Start: 450, End: 549}, RangeSet{Start: 900, End: 999})
 	testRange("bytes=500-700,601-999", RangeSet{Start: 500, End: 700}, RangeSet{Start: 601, End: 999})
+	// A suffix longer than the representation selects all of it
+	testRange("bytes=-1500", RangeSet{Start: 0, End: 999})
+	testRange("bytes=-1000", RangeSet{Start: 0, End: 999})
+	testRange("bytes=-0")
 }
 
 // go test -v -run=^$ -bench=Benchmark_Ctx_Range -benchmem -count=4
@@ -2932,6 +3060,76 @@ func Test_Ctx_RouteNormalized(t *testing.T) {
 	require.Equal(t, StatusNotFound, resp.StatusCode, "Status code")
 }
 
+// go test -run Test_Ctx_Matched
+func Test_Ctx_Matched(t *testing.T) {
+	t.Parallel()
+	app := New()
+
+	var matched bool
+	app.Use(func(c Ctx) error {
+		err := c.Next()
+		matched = c.Matched()
+		return err
+	})
+	app.Get("/test", func(c Ctx) error {
+		return c.SendStatus(StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/test", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, StatusOK, resp.StatusCode, "Status code")
+	require.True(t, matched)
+
+	resp, err = app.Test(httptest.NewRequest(MethodGet, "/missing", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, StatusNotFound, resp.StatusCode, "Status code")
+	require.False(t, matched)
+}
+
+// go test -run Test_Ctx_ResolveRoute
+func Test_Ctx_ResolveRoute(t *testing.T) {
+	t.Parallel()
+	app := New()
+
+	var resolved *Route
+	var values []string
+	app.Use(func(c Ctx) error {
+		resolved, values = c.ResolveRoute()
+		return c.Next()
+	})
+	app.Use("/users", func(c Ctx) error {
+		return c.Next()
+	})
+	app.Get("/users/:id", func(c Ctx) error {
+		return c.SendString(c.Params("id"))
+	}).Name("user")
+
+	resp, err := app.Test(httptest.NewRequest(MethodGet, "/users/42", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, StatusOK, resp.StatusCode, "Status code")
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "42", string(body), "params are not touched")
+	require.NotNil(t, resolved)
+	require.Equal(t, "user", resolved.Name)
+	require.Equal(t, "/users/:id", resolved.Path)
+	require.Equal(t, []string{"42"}, values)
+
+	// Without a route, the handler mounted with Use handles the request
+	resp, err = app.Test(httptest.NewRequest(MethodPost, "/users/42", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, StatusMethodNotAllowed, resp.StatusCode, "Status code")
+	require.NotNil(t, resolved)
+	require.Equal(t, "/users", resolved.Path)
+	require.Empty(t, values)
+
+	resp, err = app.Test(httptest.NewRequest(MethodGet, "/posts/42", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, StatusNotFound, resp.StatusCode, "Status code")
+	require.Nil(t, resolved)
+	require.Nil(t, values)
+}
+
 // go test -run Test_Ctx_SaveFile
 func Test_Ctx_SaveFile(t *testing.T) {
 	// TODO We should clean this up
@@ -4571,6 +4769,241 @@ func Test_Ctx_SendStream(t *testing.T) {
 	require.Equal(t, "Hello bufio", string(c.Response().Body()))
 }
 
+// go test -run Test_Ctx_SendStream_Range
+func Test_Ctx_SendStream_Range(t *testing.T) {
+	t.Parallel()
+	const content = "0123456789abcdefghij"
+
+	app := New()
+	app.Get("/", func(c Ctx) error {
+		c.Set(HeaderContentType, MIMETextPlain)
+		c.Set(HeaderETag, `"v1"`)
+		c.Set(HeaderLastModified, "Mon, 01 Jan 2024 00:00:00 GMT")
+		return c.SendStream(strings.NewReader(content))
+	})
+
+	testCases := []struct {
+		name         string
+		rangeHeader  string
+		ifRange      string
+		status       int
+		contentRange string
+		body         string
+	}{
+		{name: "no range", status: StatusOK, body: content},
+		{name: "single", rangeHeader: "bytes=2-5", status: StatusPartialContent, contentRange: "bytes 2-5/20", body: "2345"},
+		{name: "suffix", rangeHeader: "bytes=-3", status: StatusPartialContent, contentRange: "bytes 17-19/20", body: "hij"},
+		{name: "open end", rangeHeader: "bytes=18-", status: StatusPartialContent, contentRange: "bytes 18-19/20", body: "ij"},
+		{name: "long suffix", rangeHeader: "bytes=-500", status: StatusPartialContent, contentRange: "bytes 0-19/20", body: content},
+		{name: "unsatisfiable", rangeHeader: "bytes=30-40", status: StatusRequestedRangeNotSatisfiable, contentRange: "bytes */20", body: ""},
+		{name: "malformed", rangeHeader: "bytes", status: StatusOK, body: content},
+		{name: "other unit", rangeHeader: "items=1-2", status: StatusOK, body: content},
+		{name: "if-range etag", rangeHeader: "bytes=0-1", ifRange: `"v1"`, status: StatusPartialContent, contentRange: "bytes 0-1/20", body: "01"},
+		{name: "if-range stale etag", rangeHeader: "bytes=0-1", ifRange: `"v0"`, status: StatusOK, body: content},
+		{name: "if-range weak etag", rangeHeader: "bytes=0-1", ifRange: `W/"v1"`, status: StatusOK, body: content},
+		{name: "if-range date", rangeHeader: "bytes=0-1", ifRange: "Mon, 01 Jan 2024 00:00:00 GMT", status: StatusPartialContent, contentRange: "bytes 0-1/20", body: "01"},
+		{name: "if-range stale date", rangeHeader: "bytes=0-1", ifRange: "Sun, 31 Dec 2023 00:00:00 GMT", status: StatusOK, body: content},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(MethodGet, "/", nil)
+			if tc.rangeHeader != "" {
+				req.Header.Set(HeaderRange, tc.rangeHeader)
+			}
+			if tc.ifRange != "" {
+				req.Header.Set(HeaderIfRange, tc.ifRange)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tc.status, resp.StatusCode)
+			require.Equal(t, tc.contentRange, resp.Header.Get(HeaderContentRange))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tc.body, string(body))
+		})
+	}
+}
+
+// go test -run Test_Ctx_SendStream_MultiRange
+func Test_Ctx_SendStream_MultiRange(t *testing.T) {
+	t.Parallel()
+	const content = "0123456789abcdefghij"
+
+	app := New()
+	app.Get("/", func(c Ctx) error {
+		c.Set(HeaderContentType, MIMETextPlain)
+		return c.SendStream(strings.NewReader(content))
+	})
+
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	req.Header.Set(HeaderRange, "bytes=0-1, 5-7, -2")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "bytes", resp.Header.Get(HeaderAcceptRanges))
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get(HeaderContentType))
+	require.NoError(t, err)
+	require.Equal(t, MIMEMultipartByteRanges, mediaType)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, strconv.Itoa(len(body)), resp.Header.Get(HeaderContentLength))
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	expected := []struct {
+		contentRange string
+		body         string
+	}{
+		{contentRange: "bytes 0-1/20", body: "01"},
+		{contentRange: "bytes 5-7/20", body: "567"},
+		{contentRange: "bytes 18-19/20", body: "ij"},
+	}
+	for _, part := range expected {
+		p, err := reader.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, MIMETextPlain, p.Header.Get(HeaderContentType))
+		require.Equal(t, part.contentRange, p.Header.Get(HeaderContentRange))
+
+		partBody, err := io.ReadAll(p)
+		require.NoError(t, err)
+		require.Equal(t, part.body, string(partBody))
+	}
+	_, err = reader.NextPart()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+// go test -run Test_Ctx_SendFile_MultiRange
+func Test_Ctx_SendFile_MultiRange(t *testing.T) {
+	t.Parallel()
+
+	expected, err := os.ReadFile("ctx.go")
+	require.NoError(t, err)
+
+	app := New()
+	app.Get("/", func(c Ctx) error {
+		return c.SendFile("ctx.go", SendFile{ByteRange: true})
+	})
+	app.Get("/fs", func(c Ctx) error {
+		return c.SendFile("ctx.go", SendFile{ByteRange: true, FS: os.DirFS(".")})
+	})
+
+	for _, path := range []string{"/", "/fs"} {
+		req := httptest.NewRequest(MethodGet, path, nil)
+		req.Header.Set(HeaderRange, "bytes=0-9,100-109")
+
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, StatusPartialContent, resp.StatusCode)
+		require.NotEmpty(t, resp.Header.Get(HeaderLastModified))
+
+		_, params, err := mime.ParseMediaType(resp.Header.Get(HeaderContentType))
+		require.NoError(t, err)
+
+		reader := multipart.NewReader(resp.Body, params["boundary"])
+		for _, r := range []RangeSet{{Start: 0, End: 9}, {Start: 100, End: 109}} {
+			p, err := reader.NextPart()
+			require.NoError(t, err)
+			require.Equal(t, "text/x-go; charset=utf-8", p.Header.Get(HeaderContentType))
+
+			partBody, err := io.ReadAll(p)
+			require.NoError(t, err)
+			require.Equal(t, expected[r.Start:r.End+1], partBody)
+		}
+	}
+
+	// Single ranges and a stale If-Range work as well
+	req := httptest.NewRequest(MethodGet, "/", nil)
+	req.Header.Set(HeaderRange, "bytes=0-9")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, StatusPartialContent, resp.StatusCode)
+	require.Equal(t, fmt.Sprintf("bytes 0-9/%d", len(expected)), resp.Header.Get(HeaderContentRange))
+
+	req = httptest.NewRequest(MethodGet, "/", nil)
+	req.Header.Set(HeaderRange, "bytes=0-9")
+	req.Header.Set(HeaderIfRange, "Mon, 01 Jan 2001 00:00:00 GMT")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, expected, body)
+}
+
+// openFilesFS counts the files of the FS which are not closed
+type openFilesFS struct {
+	fs.FS
+	open atomic.Int32
+}
+
+type countedFile struct {
+	fs.File
+	open *atomic.Int32
+}
+
+func (f *openFilesFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // passed on as is
+	}
+	f.open.Add(1)
+	return &countedFile{File: file, open: &f.open}, nil
+}
+
+func (f *countedFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence) //nolint:forcetypeassert,wrapcheck // fstest files are seekable
+}
+
+func (f *countedFile) Close() error {
+	f.open.Add(-1)
+	return f.File.Close() //nolint:wrapcheck // passed on as is
+}
+
+// go test -run Test_Ctx_SendRanges_Close
+func Test_Ctx_SendRanges_Close(t *testing.T) {
+	t.Parallel()
+
+	fsys := &openFilesFS{FS: fstest.MapFS{"file.txt": {Data: []byte("0123456789")}}}
+	app := New()
+	app.Get("/file", func(c Ctx) error {
+		return c.SendFile("file.txt", SendFile{ByteRange: true, FS: fsys})
+	})
+	app.Get("/stream", func(c Ctx) error {
+		file, err := fsys.Open("file.txt")
+		if err != nil {
+			return err
+		}
+		return c.SendStream(file.(io.Reader), 10) //nolint:forcetypeassert // countedFile is a reader
+	})
+
+	for _, path := range []string{"/file", "/stream"} {
+		for _, tc := range []struct {
+			rangeHeader string
+			status      int
+		}{
+			{rangeHeader: "bytes=50-60", status: StatusRequestedRangeNotSatisfiable},
+			{rangeHeader: "bytes=0-1", status: StatusPartialContent},
+			{rangeHeader: "bytes=0-1,4-5", status: StatusPartialContent},
+		} {
+			req := httptest.NewRequest(MethodGet, path, nil)
+			req.Header.Set(HeaderRange, tc.rangeHeader)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tc.status, resp.StatusCode, "%s %s", path, tc.rangeHeader)
+			_, err = io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Zero(t, fsys.open.Load(), "%s %s leaks the file", path, tc.rangeHeader)
+		}
+	}
+}
+
 // go test -run Test_Ctx_SendStreamWriter
 func Test_Ctx_SendStreamWriter(t *testing.T) {
 	t.Parallel()
@@ -4636,6 +5069,102 @@ func Test_Ctx_SendStreamWriter_Interrupted(t *testing.T) {
 	require.Equal(t, "Line 1\nLine 2\nLine 3\n", string(body))
 }
 
+// upperEncoder is a test StreamEncoder which upper-cases the stream.
+type upperEncoder struct {
+	w       io.Writer
+	flushes *atomic.Int32
+}
+
+func (e *upperEncoder) Write(p []byte) (int, error) {
+	return e.w.Write(bytes.ToUpper(p))
+}
+
+func (e *upperEncoder) Flush() error {
+	if e.flushes != nil {
+		e.flushes.Add(1)
+	}
+	return nil
+}
+
+func (*upperEncoder) Close() error {
+	return nil
+}
+
+// go test -run Test_Ctx_SetStreamEncoder
+func Test_Ctx_SetStreamEncoder(t *testing.T) {
+	t.Parallel()
+	var writerFlushes, streamFlushes atomic.Int32
+	app := New()
+	app.Get("/writer", func(c Ctx) error {
+		c.SetStreamEncoder("upper", func(w io.Writer) io.WriteCloser {
+			return &upperEncoder{w: w, flushes: &writerFlushes}
+		})
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			for lineNum := 1; lineNum <= 3; lineNum++ {
+				fmt.Fprintf(w, "line %d\n", lineNum) //nolint:errcheck // It is fine to ignore the error
+				if err := w.Flush(); err != nil {
+					t.Errorf("unexpected error: %s", err)
+					return
+				}
+			}
+		})
+	})
+	app.Get("/stream", func(c Ctx) error {
+		c.SetStreamEncoder("upper", func(w io.Writer) io.WriteCloser {
+			return &upperEncoder{w: w, flushes: &streamFlushes}
+		})
+		return c.SendStream(strings.NewReader("hello stream"), 12)
+	})
+	app.Get("/image", func(c Ctx) error {
+		c.SetStreamEncoder("upper", func(w io.Writer) io.WriteCloser {
+			return &upperEncoder{w: w}
+		})
+		c.Set(HeaderContentType, "image/png")
+		return c.SendStream(strings.NewReader("png"))
+	})
+	app.Get("/encoded", func(c Ctx) error {
+		c.SetStreamEncoder("upper", func(w io.Writer) io.WriteCloser {
+			return &upperEncoder{w: w}
+		})
+		c.Set(HeaderContentEncoding, "identity")
+		return c.SendStream(strings.NewReader("already encoded"))
+	})
+	app.Get("/removed", func(c Ctx) error {
+		c.SetStreamEncoder("upper", func(w io.Writer) io.WriteCloser {
+			return &upperEncoder{w: w}
+		})
+		c.SetStreamEncoder("", nil)
+		return c.SendStream(strings.NewReader("plain"))
+	})
+
+	testCases := []struct {
+		path     string
+		body     string
+		encoding string
+	}{
+		{path: "/writer", body: "LINE 1\nLINE 2\nLINE 3\n", encoding: "upper"},
+		{path: "/stream", body: "HELLO STREAM", encoding: "upper"},
+		{path: "/encoded", body: "already encoded", encoding: "identity"},
+		{path: "/image", body: "png", encoding: ""},
+		{path: "/removed", body: "plain", encoding: ""},
+	}
+
+	for _, tc := range testCases {
+		resp, err := app.Test(httptest.NewRequest(MethodGet, tc.path, nil))
+		require.NoError(t, err)
+		require.Equal(t, StatusOK, resp.StatusCode)
+		require.Equal(t, tc.encoding, resp.Header.Get(HeaderContentEncoding))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, tc.body, string(body))
+	}
+
+	// The encoder is only flushed where the stream writer is flushed
+	require.Equal(t, int32(3), writerFlushes.Load())
+	require.Zero(t, streamFlushes.Load())
+}
+
 // go test -run Test_Ctx_Set
 func Test_Ctx_Set(t *testing.T) {
 	t.Parallel()
@@ -31,6 +31,18 @@ var (
 	ErrRangeUnsatisfiable = errors.New("range: unsatisfiable range")
 )
 
+// Cookie errors
+var (
+	// ErrCookieKeysNotSet is returned by the signed and encrypted cookie methods when Config.CookieKeys is empty.
+	ErrCookieKeysNotSet = errors.New("cookie: Config.CookieKeys is not set")
+	// ErrCookieNotFound is returned when the signed or encrypted cookie does not exist.
+	ErrCookieNotFound = errors.New("cookie: cookie not found")
+	// ErrCookieTampered is returned when the signed or encrypted cookie was tampered with or protected by an unknown key.
+	ErrCookieTampered = errors.New("cookie: cookie was tampered with")
+	// ErrCookieExpired is returned when the signed or encrypted cookie has expired.
+	ErrCookieExpired = errors.New("cookie: cookie has expired")
+)
+
 // Binder errors
 var ErrCustomBinderNotFound = errors.New("binder: custom binder not found, please be sure to enter the right name")
 
@@ -652,6 +652,27 @@ func (*testConn) SetDeadline(_ time.Time) error      { return nil }
 func (*testConn) SetReadDeadline(_ time.Time) error  { return nil }
 func (*testConn) SetWriteDeadline(_ time.Time) error { return nil }
 
+// compressibleContentTypes are the prefixes of the content types worth
+// compressing, the same as those of the compression of fasthttp
+var compressibleContentTypes = [][]byte{
+	[]byte("text/"),
+	[]byte("application/"),
+	[]byte("image/svg"),
+	[]byte("image/x-icon"),
+	[]byte("font/"),
+	[]byte("multipart/"),
+}
+
+// isCompressibleContentType reports whether bodies of the content type are worth compressing
+func isCompressibleContentType(contentType []byte) bool {
+	for _, prefix := range compressibleContentTypes {
+		if bytes.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func getStringImmutable(b []byte) string {
 	return string(b)
 }
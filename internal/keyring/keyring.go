@@ -0,0 +1,204 @@
+// Package keyring encrypts and signs cookie values with an ordered ring of
+// keys. The first key encrypts and signs, all keys decrypt and verify, so
+// keys can be rotated without invalidating the values of the previous keys.
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Algorithm is the AEAD which encrypts the values.
+type Algorithm string
+
+const (
+	// AESGCM encrypts with AES-GCM, using 16, 24 or 32 byte keys.
+	AESGCM Algorithm = "AES-GCM"
+	// XChaCha20Poly1305 encrypts with XChaCha20-Poly1305, using 32 byte keys.
+	XChaCha20Poly1305 Algorithm = "XChaCha20-Poly1305"
+)
+
+var (
+	// ErrInvalidValue is returned when a value is malformed, was tampered
+	// with or was encrypted or signed with a key which is not in the ring.
+	ErrInvalidValue = errors.New("keyring: invalid value")
+	// ErrNoKeys is returned when a ring is created without keys.
+	ErrNoKeys = errors.New("keyring: at least one key is required")
+)
+
+const (
+	// idSize is the size of the key ID prefixing the encrypted and signed values
+	idSize = 4
+
+	// signatureSeparator separates the value from the signature of signed values
+	signatureSeparator = "."
+)
+
+// key is a key of the ring
+type key struct {
+	aead cipher.AEAD
+	mac  []byte
+	id   [idSize]byte
+}
+
+// Ring encrypts and signs values with its first key,
+// and decrypts and verifies them with any key.
+type Ring struct {
+	keys []key
+}
+
+// New creates a ring of the raw keys, ordered from the newest to the oldest.
+func New(algorithm Algorithm, keys ...[]byte) (*Ring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if algorithm == "" {
+		algorithm = AESGCM
+	}
+
+	r := &Ring{keys: make([]key, len(keys))}
+	for i, raw := range keys {
+		aead, err := newAEAD(algorithm, raw)
+		if err != nil {
+			return nil, err
+		}
+		r.keys[i] = key{
+			aead: aead,
+			mac:  derive(raw, "velocity cookie signature"),
+		}
+		copy(r.keys[i].id[:], derive(raw, "velocity cookie key id"))
+	}
+	return r, nil
+}
+
+// NewBase64 creates a ring of the standard base64-encoded keys.
+func NewBase64(algorithm Algorithm, keys ...string) (*Ring, error) {
+	raw := make([][]byte, len(keys))
+	for i, k := range keys {
+		decoded, err := base64.StdEncoding.DecodeString(k)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: failed to base64-decode key: %w", err)
+		}
+		raw[i] = decoded
+	}
+	return New(algorithm, raw...)
+}
+
+func newAEAD(algorithm Algorithm, raw []byte) (cipher.AEAD, error) {
+	switch algorithm {
+	case AESGCM:
+		if len(raw) != 16 && len(raw) != 24 && len(raw) != 32 {
+			return nil, errors.New("keyring: AES-GCM keys must be 16, 24 or 32 bytes long")
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: failed to create AES cipher: %w", err)
+		}
+		return cipher.NewGCM(block)
+	case XChaCha20Poly1305:
+		if len(raw) != chacha20poly1305.KeySize {
+			return nil, errors.New("keyring: XChaCha20-Poly1305 keys must be 32 bytes long")
+		}
+		return chacha20poly1305.NewX(raw)
+	default:
+		return nil, fmt.Errorf("keyring: unknown algorithm %q", algorithm)
+	}
+}
+
+// derive derives a subkey for the purpose, so a key is never used
+// by different primitives
+func derive(raw []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, raw)
+	_, _ = mac.Write([]byte(purpose)) //nolint:errcheck // Never fails
+	return mac.Sum(nil)
+}
+
+// Encrypt encrypts the value with the first key. The name is bound as
+// associated data, so the value cannot be moved to another name.
+// The result is base64url-encoded: key ID, nonce, ciphertext.
+func (r *Ring) Encrypt(name, value string) (string, error) {
+	k := r.keys[0]
+	nonceSize := k.aead.NonceSize()
+
+	out := make([]byte, idSize+nonceSize, idSize+nonceSize+len(value)+k.aead.Overhead())
+	copy(out, k.id[:])
+	if _, err := rand.Read(out[idSize:]); err != nil {
+		return "", fmt.Errorf("keyring: failed to read nonce: %w", err)
+	}
+	out = k.aead.Seal(out, out[idSize:], []byte(value), []byte(name))
+	return base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// Decrypt decrypts a value encrypted by Encrypt under the same name,
+// using the key named by the embedded key ID.
+func (r *Ring) Decrypt(name, value string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) < idSize {
+		return "", ErrInvalidValue
+	}
+	for _, k := range r.keys {
+		if !hmac.Equal(k.id[:], raw[:idSize]) {
+			continue
+		}
+		nonceSize := k.aead.NonceSize()
+		if len(raw) < idSize+nonceSize {
+			return "", ErrInvalidValue
+		}
+		plain, err := k.aead.Open(nil, raw[idSize:idSize+nonceSize], raw[idSize+nonceSize:], []byte(name))
+		if err == nil {
+			return string(plain), nil
+		}
+	}
+	return "", ErrInvalidValue
+}
+
+// Sign signs the value with the first key and binds it to the name. The
+// value stays readable: the result is the value, a dot and the
+// base64url-encoded key ID and HMAC-SHA256 signature.
+func (r *Ring) Sign(name, value string) string {
+	k := r.keys[0]
+	signature := make([]byte, 0, idSize+sha256.Size)
+	signature = append(signature, k.id[:]...)
+	signature = append(signature, k.sign(name, value)...)
+	return value + signatureSeparator + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// Verify verifies a value signed by Sign under the same name and returns
+// the value without the signature.
+func (r *Ring) Verify(name, signed string) (string, error) {
+	i := strings.LastIndex(signed, signatureSeparator)
+	if i < 0 {
+		return "", ErrInvalidValue
+	}
+	value := signed[:i]
+	signature, err := base64.RawURLEncoding.DecodeString(signed[i+1:])
+	if err != nil || len(signature) != idSize+sha256.Size {
+		return "", ErrInvalidValue
+	}
+	for _, k := range r.keys {
+		if hmac.Equal(k.id[:], signature[:idSize]) && hmac.Equal(k.sign(name, value), signature[idSize:]) {
+			return value, nil
+		}
+	}
+	return "", ErrInvalidValue
+}
+
+// sign computes the signature of the value, prefixing the name with its
+// length, so the boundary between name and value cannot be shifted
+func (k *key) sign(name, value string) []byte {
+	mac := hmac.New(sha256.New, k.mac)
+	_, _ = mac.Write(binary.AppendUvarint(nil, uint64(len(name)))) //nolint:errcheck // Never fails
+	_, _ = mac.Write([]byte(name))                                 //nolint:errcheck // Never fails
+	_, _ = mac.Write([]byte(value))                                //nolint:errcheck // Never fails
+	return mac.Sum(nil)
+}
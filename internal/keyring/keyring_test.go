@@ -0,0 +1,136 @@
+package keyring
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newKey returns a random key of the size
+func newKey(t *testing.T, size int) []byte {
+	t.Helper()
+
+	key := make([]byte, size)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func Test_Ring_Encrypt(t *testing.T) {
+	t.Parallel()
+
+	for _, algorithm := range []Algorithm{AESGCM, XChaCha20Poly1305} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			t.Parallel()
+
+			ring, err := New(algorithm, newKey(t, 32))
+			require.NoError(t, err)
+
+			value, err := ring.Encrypt("name", "value")
+			require.NoError(t, err)
+			require.NotContains(t, value, "value")
+
+			decrypted, err := ring.Decrypt("name", value)
+			require.NoError(t, err)
+			require.Equal(t, "value", decrypted)
+
+			// Every encryption uses a new nonce
+			other, err := ring.Encrypt("name", "value")
+			require.NoError(t, err)
+			require.NotEqual(t, value, other)
+
+			_, err = ring.Decrypt("other", value)
+			require.ErrorIs(t, err, ErrInvalidValue)
+
+			raw, err := base64.RawURLEncoding.DecodeString(value)
+			require.NoError(t, err)
+			raw[len(raw)-1] ^= 1
+			_, err = ring.Decrypt("name", base64.RawURLEncoding.EncodeToString(raw))
+			require.ErrorIs(t, err, ErrInvalidValue)
+
+			for _, invalid := range []string{"", "!", "AAAA", base64.RawURLEncoding.EncodeToString(raw[:idSize+1])} {
+				_, err = ring.Decrypt("name", invalid)
+				require.ErrorIs(t, err, ErrInvalidValue, invalid)
+			}
+		})
+	}
+}
+
+func Test_Ring_Sign(t *testing.T) {
+	t.Parallel()
+
+	ring, err := New(AESGCM, newKey(t, 16))
+	require.NoError(t, err)
+
+	signed := ring.Sign("name", "a.b")
+	value, err := ring.Verify("name", signed)
+	require.NoError(t, err)
+	require.Equal(t, "a.b", value)
+
+	for _, invalid := range []string{"a.b", "a.c" + signed[3:], "", "."} {
+		_, err = ring.Verify("name", invalid)
+		require.ErrorIs(t, err, ErrInvalidValue, invalid)
+	}
+	_, err = ring.Verify("other", signed)
+	require.ErrorIs(t, err, ErrInvalidValue)
+
+	// The length of the name is signed, so the boundary cannot be shifted
+	_, err = ring.Verify("nam", ring.Sign("name", "value")[1:])
+	require.ErrorIs(t, err, ErrInvalidValue)
+}
+
+func Test_Ring_Rotation(t *testing.T) {
+	t.Parallel()
+
+	oldKey, newKey := newKey(t, 32), newKey(t, 32)
+	oldRing, err := New(AESGCM, oldKey)
+	require.NoError(t, err)
+	rotated, err := New(AESGCM, newKey, oldKey)
+	require.NoError(t, err)
+	newRing, err := New(AESGCM, newKey)
+	require.NoError(t, err)
+
+	encrypted, err := oldRing.Encrypt("name", "value")
+	require.NoError(t, err)
+	signed := oldRing.Sign("name", "value")
+
+	// The old values are still accepted by the rotated ring
+	value, err := rotated.Decrypt("name", encrypted)
+	require.NoError(t, err)
+	require.Equal(t, "value", value)
+	value, err = rotated.Verify("name", signed)
+	require.NoError(t, err)
+	require.Equal(t, "value", value)
+
+	// The rotated ring uses the new key
+	encrypted, err = rotated.Encrypt("name", "value")
+	require.NoError(t, err)
+	_, err = newRing.Decrypt("name", encrypted)
+	require.NoError(t, err)
+	_, err = oldRing.Decrypt("name", encrypted)
+	require.ErrorIs(t, err, ErrInvalidValue)
+	_, err = newRing.Verify("name", rotated.Sign("name", "value"))
+	require.NoError(t, err)
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(AESGCM)
+	require.ErrorIs(t, err, ErrNoKeys)
+	_, err = New(AESGCM, newKey(t, 20))
+	require.ErrorContains(t, err, "16, 24 or 32 bytes")
+	_, err = New(XChaCha20Poly1305, newKey(t, 16))
+	require.ErrorContains(t, err, "32 bytes")
+	_, err = New("ROT13", newKey(t, 32))
+	require.ErrorContains(t, err, "unknown algorithm")
+	_, err = NewBase64(AESGCM, "not base64!")
+	require.ErrorContains(t, err, "base64-decode")
+
+	// The default algorithm is AES-GCM
+	ring, err := NewBase64("", base64.StdEncoding.EncodeToString(newKey(t, 24)))
+	require.NoError(t, err)
+	require.Equal(t, 12, ring.keys[0].aead.NonceSize())
+}
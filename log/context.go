@@ -0,0 +1,210 @@
+package log
+
+import (
+	"context"
+	"fmt"
+)
+
+// ContextExtractor returns a key-value pair for the log messages of a context,
+// e.g. the request ID or the trace ID. ok reports whether the context holds a value.
+type ContextExtractor func(ctx context.Context) (key string, value any, ok bool)
+
+// FieldLogger is a logger which derives child loggers carrying key-value pairs.
+type FieldLogger interface {
+	CommonLogger
+
+	// With returns a child logger which adds the key-value pairs to every message.
+	With(keysAndValues ...any) FieldLogger
+}
+
+// contextProvider is implemented by velocity.Ctx
+type contextProvider interface {
+	Context() context.Context
+}
+
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey int
+
+// The keys for the values in context
+const (
+	loggerKey contextKey = iota
+)
+
+var extractors []ContextExtractor
+
+// RegisterContextExtractor registers an extractor whose key-value pair is added to
+// the loggers returned by WithContext and FromContext.
+// Note that this method is not concurrent-safe and must be called before logging.
+func RegisterContextExtractor(extractor ContextExtractor) {
+	extractors = append(extractors, extractor)
+}
+
+// extractFields runs all registered extractors on the context.
+func extractFields(ctx context.Context) []any {
+	if ctx == nil {
+		return nil
+	}
+	var fields []any
+	for _, extractor := range extractors {
+		if key, value, ok := extractor(ctx); ok {
+			fields = append(fields, key, value)
+		}
+	}
+	return fields
+}
+
+// With returns a child of the default logger which adds the key-value pairs to every message.
+func With(keysAndValues ...any) FieldLogger {
+	return withFields(logger, keysAndValues)
+}
+
+// NewContext returns a copy of the context which carries the logger.
+// The logger is returned by FromContext for this context.
+func NewContext(ctx context.Context, l CommonLogger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the request-scoped logger.
+// If no logger was stored with NewContext, the default logger
+// carrying the fields of the registered context extractors is returned.
+// Supported context types:
+// - velocity.Ctx: Retrieves the logger from c.Context()
+// - context.Context: Retrieves the logger from context values
+func FromContext(c any) FieldLogger {
+	var ctx context.Context
+	switch v := c.(type) {
+	case contextProvider:
+		ctx = v.Context()
+	case context.Context:
+		ctx = v
+	}
+	if ctx == nil {
+		return withFields(logger, nil)
+	}
+
+	if l, ok := ctx.Value(loggerKey).(CommonLogger); ok {
+		return withFields(l, nil)
+	}
+	return withFields(logger.WithContext(ctx), nil)
+}
+
+// withFields returns a child of the logger carrying the key-value pairs.
+// Loggers which do not implement FieldLogger are wrapped.
+func withFields(l CommonLogger, keysAndValues []any) FieldLogger {
+	if fl, ok := l.(FieldLogger); ok {
+		if len(keysAndValues) == 0 {
+			return fl
+		}
+		return fl.With(keysAndValues...)
+	}
+	return &fieldLogger{logger: l, fields: keysAndValues}
+}
+
+// fieldLogger adds key-value pairs to the messages of a logger
+// which does not support fields itself.
+type fieldLogger struct {
+	logger CommonLogger
+	fields []any
+}
+
+func (l *fieldLogger) With(keysAndValues ...any) FieldLogger {
+	fields := make([]any, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+	return &fieldLogger{logger: l.logger, fields: fields}
+}
+
+// join appends the key-value pairs of the message to the fields.
+func (l *fieldLogger) join(keysAndValues []any) []any {
+	if len(keysAndValues) == 0 {
+		return l.fields
+	}
+	fields := make([]any, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	return append(fields, keysAndValues...)
+}
+
+func (l *fieldLogger) Trace(v ...any) {
+	l.logger.Tracew(fmt.Sprint(v...), l.fields...)
+}
+
+func (l *fieldLogger) Debug(v ...any) {
+	l.logger.Debugw(fmt.Sprint(v...), l.fields...)
+}
+
+func (l *fieldLogger) Info(v ...any) {
+	l.logger.Infow(fmt.Sprint(v...), l.fields...)
+}
+
+func (l *fieldLogger) Warn(v ...any) {
+	l.logger.Warnw(fmt.Sprint(v...), l.fields...)
+}
+
+func (l *fieldLogger) Error(v ...any) {
+	l.logger.Errorw(fmt.Sprint(v...), l.fields...)
+}
+
+func (l *fieldLogger) Fatal(v ...any) {
+	l.logger.Fatalw(fmt.Sprint(v...), l.fields...)
+}
+
+func (l *fieldLogger) Panic(v ...any) {
+	l.logger.Panicw(fmt.Sprint(v...), l.fields...)
+}
+
+func (l *fieldLogger) Tracef(format string, v ...any) {
+	l.logger.Tracew(fmt.Sprintf(format, v...), l.fields...)
+}
+
+func (l *fieldLogger) Debugf(format string, v ...any) {
+	l.logger.Debugw(fmt.Sprintf(format, v...), l.fields...)
+}
+
+func (l *fieldLogger) Infof(format string, v ...any) {
+	l.logger.Infow(fmt.Sprintf(format, v...), l.fields...)
+}
+
+func (l *fieldLogger) Warnf(format string, v ...any) {
+	l.logger.Warnw(fmt.Sprintf(format, v...), l.fields...)
+}
+
+func (l *fieldLogger) Errorf(format string, v ...any) {
+	l.logger.Errorw(fmt.Sprintf(format, v...), l.fields...)
+}
+
+func (l *fieldLogger) Fatalf(format string, v ...any) {
+	l.logger.Fatalw(fmt.Sprintf(format, v...), l.fields...)
+}
+
+func (l *fieldLogger) Panicf(format string, v ...any) {
+	l.logger.Panicw(fmt.Sprintf(format, v...), l.fields...)
+}
+
+func (l *fieldLogger) Tracew(msg string, keysAndValues ...any) {
+	l.logger.Tracew(msg, l.join(keysAndValues)...)
+}
+
+func (l *fieldLogger) Debugw(msg string, keysAndValues ...any) {
+	l.logger.Debugw(msg, l.join(keysAndValues)...)
+}
+
+func (l *fieldLogger) Infow(msg string, keysAndValues ...any) {
+	l.logger.Infow(msg, l.join(keysAndValues)...)
+}
+
+func (l *fieldLogger) Warnw(msg string, keysAndValues ...any) {
+	l.logger.Warnw(msg, l.join(keysAndValues)...)
+}
+
+func (l *fieldLogger) Errorw(msg string, keysAndValues ...any) {
+	l.logger.Errorw(msg, l.join(keysAndValues)...)
+}
+
+func (l *fieldLogger) Fatalw(msg string, keysAndValues ...any) {
+	l.logger.Fatalw(msg, l.join(keysAndValues)...)
+}
+
+func (l *fieldLogger) Panicw(msg string, keysAndValues ...any) {
+	l.logger.Panicw(msg, l.join(keysAndValues)...)
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type ctxKey string
+
+// fakeCtx mimics velocity.Ctx, which cannot be imported here
+type fakeCtx struct {
+	ctx context.Context //nolint:containedctx // velocity.Ctx carries a context as well
+}
+
+func (c *fakeCtx) Context() context.Context {
+	return c.ctx
+}
+
+func Test_WithContext_Extractors(t *testing.T) {
+	initDefaultLogger()
+	defer func() { extractors = nil }()
+
+	RegisterContextExtractor(func(ctx context.Context) (string, any, bool) {
+		rid, ok := ctx.Value(ctxKey("rid")).(string)
+		return "request-id", rid, ok
+	})
+	RegisterContextExtractor(func(ctx context.Context) (string, any, bool) {
+		uid, ok := ctx.Value(ctxKey("uid")).(int)
+		return "user-id", uid, ok
+	})
+
+	var w byteSliceWriter
+	SetOutput(&w)
+
+	ctx := context.WithValue(context.Background(), ctxKey("rid"), "abc")
+	WithContext(ctx).Info("starting work")
+	WithContext(context.WithValue(ctx, ctxKey("uid"), 42)).Infof("%s done", work)
+	WithContext(context.Background()).Infow("no fields", "k", "v")
+
+	require.Equal(t, "[Info] starting work request-id=abc\n"+
+		"[Info] work done request-id=abc user-id=42\n"+
+		"[Info] no fields k=v\n", string(w.b))
+}
+
+func Test_With(t *testing.T) {
+	initDefaultLogger()
+
+	var w byteSliceWriter
+	SetOutput(&w)
+
+	child := With("service", "billing")
+	child.Info("starting work")
+	child.With("attempt", 2).Warnw("work may fail", "err", "timeout")
+	child.Debugw("")
+	Info("parent")
+
+	require.Equal(t, "[Info] starting work service=billing\n"+
+		"[Warn] work may fail service=billing attempt=2 err=timeout\n"+
+		"[Debug] service=billing\n"+
+		"[Info] parent\n", string(w.b))
+}
+
+func Test_WithCaller(t *testing.T) {
+	logger = &defaultLogger{
+		stdlog: log.New(os.Stderr, "", log.Lshortfile),
+		depth:  4,
+	}
+
+	var w byteSliceWriter
+	SetOutput(&w)
+
+	With("a", 1).With("b", 2).Info("")
+	FromContext(context.TODO()).Info("")
+
+	require.Equal(t, "context_test.go:76: [Info] a=1 b=2\ncontext_test.go:77: [Info] \n", string(w.b))
+}
+
+func Test_FromContext(t *testing.T) {
+	initDefaultLogger()
+	defer func() { extractors = nil }()
+
+	RegisterContextExtractor(func(ctx context.Context) (string, any, bool) {
+		rid, ok := ctx.Value(ctxKey("rid")).(string)
+		return "request-id", rid, ok
+	})
+
+	var w byteSliceWriter
+	SetOutput(&w)
+
+	ctx := context.WithValue(context.Background(), ctxKey("rid"), "abc")
+	FromContext(&fakeCtx{ctx: ctx}).Info("from ctx")
+	FromContext(ctx).Info("from context")
+	FromContext(NewContext(ctx, With("user", "bob"))).Info("stored")
+	FromContext(nil).Info("unsupported")
+
+	require.Equal(t, "[Info] from ctx request-id=abc\n"+
+		"[Info] from context request-id=abc\n"+
+		"[Info] stored user=bob\n"+
+		"[Info] unsupported\n", string(w.b))
+}
+
+// plainLogger records the w calls of a logger without field support
+type plainLogger struct {
+	AllLogger
+	msgs []string
+	kvs  [][]any
+}
+
+func (l *plainLogger) Infow(msg string, keysAndValues ...any) {
+	l.msgs = append(l.msgs, msg)
+	l.kvs = append(l.kvs, keysAndValues)
+}
+
+func Test_FromContext_CustomLogger(t *testing.T) {
+	l := &plainLogger{}
+
+	fl := FromContext(NewContext(context.Background(), l)).With("a", 1)
+	fl.Info("hello ", "world")
+	fl.Infof("%d items", 3)
+	fl.With("b", 2).Infow("msg", "c", 3)
+
+	require.Equal(t, []string{"hello world", "3 items", "msg"}, l.msgs)
+	require.Equal(t, [][]any{{"a", 1}, {"a", 1}, {"a", 1, "b", 2, "c", 3}}, l.kvs)
+}
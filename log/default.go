@@ -14,9 +14,11 @@ import (
 var _ AllLogger = (*defaultLogger)(nil)
 
 type defaultLogger struct {
-	stdlog *log.Logger
-	level  Level
-	depth  int
+	stdlog  *log.Logger
+	fields  []any
+	level   Level
+	depth   int
+	derived bool
 }
 
 // privateLog logs a message at a given level log the default logger.
@@ -29,6 +31,7 @@ func (l *defaultLogger) privateLog(lv Level, fmtArgs []any) {
 	buf := bytebufferpool.Get()
 	buf.WriteString(level)
 	buf.WriteString(fmt.Sprint(fmtArgs...))
+	writeKeysAndValues(buf, l.fields, buf.Len() > len(level))
 
 	_ = l.stdlog.Output(l.depth, buf.String()) //nolint:errcheck // It is fine to ignore the error
 	if lv == LevelPanic {
@@ -57,6 +60,7 @@ func (l *defaultLogger) privateLogf(lv Level, format string, fmtArgs []any) {
 	} else {
 		_, _ = fmt.Fprint(buf, fmtArgs...) //nolint: errcheck // It is fine to ignore the error
 	}
+	writeKeysAndValues(buf, l.fields, buf.Len() > len(level))
 
 	_ = l.stdlog.Output(l.depth, buf.String()) //nolint:errcheck // It is fine to ignore the error
 	if lv == LevelPanic {
@@ -72,6 +76,11 @@ func (l *defaultLogger) privateLogf(lv Level, format string, fmtArgs []any) {
 // privateLogw logs a message at a given level log the default logger.
 // when the level is fatal, it will exit the program.
 func (l *defaultLogger) privateLogw(lv Level, format string, keysAndValues []any) {
+	l.privateLogwDepth(lv, l.depth+1, format, keysAndValues)
+}
+
+// privateLogwDepth is privateLogw with an explicit call depth of the output.
+func (l *defaultLogger) privateLogwDepth(lv Level, depth int, format string, keysAndValues []any) {
 	if l.level > lv {
 		return
 	}
@@ -83,23 +92,11 @@ func (l *defaultLogger) privateLogw(lv Level, format string, keysAndValues []any
 	if format != "" {
 		buf.WriteString(format)
 	}
-	// Write keys and values privateLog buffer
-	if len(keysAndValues) > 0 {
-		if (len(keysAndValues) & 1) == 1 {
-			keysAndValues = append(keysAndValues, "KEYVALS UNPAIRED")
-		}
-
-		for i := 0; i < len(keysAndValues); i += 2 {
-			if i > 0 || format != "" {
-				buf.WriteByte(' ')
-			}
-			buf.WriteString(keysAndValues[i].(string)) //nolint:forcetypeassert,errcheck // Keys must be strings
-			buf.WriteByte('=')
-			buf.WriteString(utils.ToString(keysAndValues[i+1]))
-		}
-	}
+	// Write fields and keys and values privateLog buffer
+	writeKeysAndValues(buf, l.fields, format != "")
+	writeKeysAndValues(buf, keysAndValues, format != "" || len(l.fields) > 0)
 
-	_ = l.stdlog.Output(l.depth, buf.String()) //nolint:errcheck // It is fine to ignore the error
+	_ = l.stdlog.Output(depth, buf.String()) //nolint:errcheck // It is fine to ignore the error
 	if lv == LevelPanic {
 		panic(buf.String())
 	}
@@ -194,11 +191,54 @@ func (l *defaultLogger) Panicw(msg string, keysAndValues ...any) {
 	l.privateLogw(LevelPanic, msg, keysAndValues)
 }
 
-func (l *defaultLogger) WithContext(_ context.Context) CommonLogger {
+// writeKeysAndValues writes the key-value pairs separated by spaces.
+// sep reports whether the buffer already holds a message.
+func writeKeysAndValues(buf *bytebufferpool.ByteBuffer, keysAndValues []any, sep bool) {
+	if len(keysAndValues) == 0 {
+		return
+	}
+	if (len(keysAndValues) & 1) == 1 {
+		keysAndValues = append(keysAndValues, "KEYVALS UNPAIRED")
+	}
+
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i > 0 || sep {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(utils.ToString(keysAndValues[i]))
+		buf.WriteByte('=')
+		buf.WriteString(utils.ToString(keysAndValues[i+1]))
+	}
+}
+
+// WithContext returns a logger carrying the fields of all registered context extractors.
+func (l *defaultLogger) WithContext(ctx context.Context) CommonLogger {
+	return l.derive(extractFields(ctx))
+}
+
+// With returns a child logger which adds the key-value pairs to every message.
+func (l *defaultLogger) With(keysAndValues ...any) FieldLogger {
+	return l.derive(keysAndValues)
+}
+
+// derive returns a copy of the logger carrying the additional fields.
+// Derived loggers are called directly instead of through the package
+// functions, so the caller is one frame closer.
+func (l *defaultLogger) derive(keysAndValues []any) *defaultLogger {
+	depth := l.depth
+	if !l.derived {
+		depth--
+	}
+	fields := make([]any, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+
 	return &defaultLogger{
-		stdlog: l.stdlog,
-		level:  l.level,
-		depth:  l.depth - 1,
+		stdlog:  l.stdlog,
+		fields:  fields,
+		level:   l.level,
+		depth:   depth,
+		derived: true,
 	}
 }
 
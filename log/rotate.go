@@ -0,0 +1,405 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// backupTimeFormat is the timestamp in the names of rotated files
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// envPreforkChild is set by velocity for the child processes of prefork
+const envPreforkChild = "VELOCITY_PREFORK_CHILD"
+
+// RotateConfig defines the config for RotatingFile.
+type RotateConfig struct {
+	// Filename is the file to write logs to. The placeholder "{pid}" is replaced
+	// by the process ID. Prefork children always write distinct files: without
+	// the placeholder, their process ID is inserted before the file extension.
+	//
+	// Required.
+	Filename string
+
+	// MaxSize is the size in bytes after which the file is rotated.
+	// A negative value disables size based rotation.
+	//
+	// Optional. Default: 100 MiB
+	MaxSize int64
+
+	// Interval rotates the file whenever the wall clock crosses a multiple of
+	// the interval, e.g. every day at midnight UTC for 24 * time.Hour.
+	//
+	// Optional. Default: 0 (disabled)
+	Interval time.Duration
+
+	// MaxAge is the duration after which rotated files are removed.
+	//
+	// Optional. Default: 0 (keep all)
+	MaxAge time.Duration
+
+	// MaxBackups is the number of rotated files which are kept.
+	//
+	// Optional. Default: 0 (keep all)
+	MaxBackups int
+
+	// FileMode is used to create new log files.
+	//
+	// Optional. Default: 0o644
+	FileMode os.FileMode
+
+	// Compress gzips rotated files in the background.
+	//
+	// Optional. Default: false
+	Compress bool
+
+	// ReopenOnSIGHUP reopens the file when the process receives SIGHUP,
+	// so external tools like logrotate can move the file away.
+	//
+	// Optional. Default: false
+	ReopenOnSIGHUP bool
+
+	// now returns the current time
+	now func() time.Time
+}
+
+// RotateConfigDefault is the default config of RotatingFile.
+var RotateConfigDefault = RotateConfig{
+	MaxSize:  100 << 20,
+	FileMode: 0o644,
+	now:      time.Now,
+}
+
+// rotateConfigDefault sets the config values if they are not set.
+func rotateConfigDefault(cfg RotateConfig) RotateConfig {
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = RotateConfigDefault.MaxSize
+	}
+	if cfg.FileMode == 0 {
+		cfg.FileMode = RotateConfigDefault.FileMode
+	}
+	if cfg.now == nil {
+		cfg.now = RotateConfigDefault.now
+	}
+	return cfg
+}
+
+// RotatingFile is an io.WriteCloser which writes to a file and rotates it by
+// size and time. It is safe for concurrent use and can be passed to SetOutput
+// and the Output of the logger middleware.
+type RotatingFile struct {
+	file     *os.File
+	signals  chan os.Signal
+	done     chan struct{}
+	next     time.Time
+	filename string
+	cfg      RotateConfig
+	size     int64
+	millWg   sync.WaitGroup
+	mu       sync.Mutex
+	millMu   sync.Mutex
+	closed   bool
+}
+
+// NewRotatingFile opens the file of the config for appending.
+func NewRotatingFile(config RotateConfig) (*RotatingFile, error) {
+	if config.Filename == "" {
+		return nil, errors.New("log: rotating file requires a filename")
+	}
+	cfg := rotateConfigDefault(config)
+
+	f := &RotatingFile{
+		cfg:      cfg,
+		filename: processFilename(cfg.Filename),
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	if cfg.ReopenOnSIGHUP {
+		f.signals = make(chan os.Signal, 1)
+		f.done = make(chan struct{})
+		signal.Notify(f.signals, syscall.SIGHUP)
+		go f.watchSignals()
+	}
+
+	return f, nil
+}
+
+// processFilename returns the filename of the current process.
+func processFilename(filename string) string {
+	pid := strconv.Itoa(os.Getpid())
+	if strings.Contains(filename, "{pid}") {
+		return strings.ReplaceAll(filename, "{pid}", pid)
+	}
+	if os.Getenv(envPreforkChild) == "1" {
+		ext := filepath.Ext(filename)
+		return strings.TrimSuffix(filename, ext) + "." + pid + ext
+	}
+	return filename
+}
+
+// Filename returns the path of the current log file.
+func (f *RotatingFile) Filename() string {
+	return f.filename
+}
+
+// Write implements io.Writer. The file is rotated before p is written if
+// the write would exceed MaxSize or the rotation interval has passed.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	if f.size > 0 && ((f.cfg.MaxSize > 0 && f.size+int64(len(p)) > f.cfg.MaxSize) ||
+		(!f.next.IsZero() && !f.cfg.now().Before(f.next))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err != nil {
+		return n, fmt.Errorf("log: failed to write log file: %w", err)
+	}
+	return n, nil
+}
+
+// Rotate moves the current file to a backup and opens a new file.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Reopen closes and reopens the file, e.g. after it was moved by logrotate.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("log: failed to close log file: %w", err)
+	}
+	return f.open()
+}
+
+// Close closes the file and waits for the compression and removal of rotated files.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	if f.signals != nil {
+		signal.Stop(f.signals)
+		close(f.done)
+	}
+	err := f.file.Close()
+	f.mu.Unlock()
+
+	f.millWg.Wait()
+	if err != nil {
+		return fmt.Errorf("log: failed to close log file: %w", err)
+	}
+	return nil
+}
+
+// watchSignals reopens the file on SIGHUP until the file is closed.
+func (f *RotatingFile) watchSignals() {
+	for {
+		select {
+		case <-f.signals:
+			if err := f.Reopen(); err != nil && !errors.Is(err, os.ErrClosed) {
+				_, _ = fmt.Fprintf(os.Stderr, "log: failed to reopen %s: %v\n", f.filename, err) //nolint:errcheck // It is fine to ignore the error
+			}
+		case <-f.done:
+			return
+		}
+	}
+}
+
+// open opens the file for appending and schedules the next time based rotation.
+func (f *RotatingFile) open() error {
+	if dir := filepath.Dir(f.filename); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:gosec // log directories are world-readable like the default file mode
+			return fmt.Errorf("log: failed to create log directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(f.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, f.cfg.FileMode)
+	if err != nil {
+		return fmt.Errorf("log: failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close() //nolint:errcheck // the stat error is more relevant
+		return fmt.Errorf("log: failed to stat log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.cfg.Interval > 0 {
+		f.next = f.cfg.now().Truncate(f.cfg.Interval).Add(f.cfg.Interval)
+	}
+	return nil
+}
+
+// rotate renames the file to a timestamped backup, opens a new file
+// and processes the backups in the background.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("log: failed to close log file: %w", err)
+	}
+
+	now := f.cfg.now().UTC()
+	backup := f.backupName(now)
+	for i := 1; fileExists(backup) || fileExists(backup+".gz"); i++ {
+		backup = f.backupName(now.Add(time.Duration(i) * time.Millisecond))
+	}
+	if err := os.Rename(f.filename, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("log: failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.millWg.Add(1)
+	go func() {
+		defer f.millWg.Done()
+		f.mill(backup)
+	}()
+	return nil
+}
+
+// backupName returns the name of a backup rotated at t.
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.filename)
+	return strings.TrimSuffix(f.filename, ext) + "-" + t.Format(backupTimeFormat) + ext
+}
+
+// backup is a rotated file
+type backup struct {
+	time time.Time
+	path string
+}
+
+// mill compresses the new backup and removes expired backups.
+func (f *RotatingFile) mill(newBackup string) {
+	f.millMu.Lock()
+	defer f.millMu.Unlock()
+
+	if f.cfg.Compress {
+		if err := compressFile(newBackup, f.cfg.FileMode); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "log: failed to compress %s: %v\n", newBackup, err) //nolint:errcheck // It is fine to ignore the error
+		}
+	}
+	if f.cfg.MaxBackups <= 0 && f.cfg.MaxAge <= 0 {
+		return
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "log: failed to list backups of %s: %v\n", f.filename, err) //nolint:errcheck // It is fine to ignore the error
+		return
+	}
+	cutoff := f.cfg.now().Add(-f.cfg.MaxAge)
+	for i, b := range backups {
+		if (f.cfg.MaxBackups > 0 && i >= f.cfg.MaxBackups) || (f.cfg.MaxAge > 0 && b.time.Before(cutoff)) {
+			if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				_, _ = fmt.Fprintf(os.Stderr, "log: failed to remove %s: %v\n", b.path, err) //nolint:errcheck // It is fine to ignore the error
+			}
+		}
+	}
+}
+
+// backups returns the rotated files, newest first.
+func (f *RotatingFile) backups() ([]backup, error) {
+	dir := filepath.Dir(f.filename)
+	ext := filepath.Ext(f.filename)
+	prefix := strings.TrimSuffix(filepath.Base(f.filename), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz")
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{time: t, path: filepath.Join(dir, name)})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.After(backups[j].time)
+	})
+	return backups, nil
+}
+
+// compressFile gzips the file and removes the original.
+func compressFile(name string, mode os.FileMode) error {
+	src, err := os.Open(filepath.Clean(name))
+	if err != nil {
+		return err
+	}
+	defer src.Close() //nolint:errcheck // the file is only read
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(name + ".gz") //nolint:errcheck // the copy error is more relevant
+		return err
+	}
+
+	if err := src.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+// fileExists reports whether the file exists.
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,252 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock
+type fakeClock struct {
+	now time.Time
+	mu  sync.Mutex
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name) //nolint:gosec // test files
+	require.NoError(t, err)
+	return string(b)
+}
+
+func backupPaths(t *testing.T, f *RotatingFile) []string {
+	t.Helper()
+	backups, err := f.backups()
+	require.NoError(t, err)
+	paths := make([]string, len(backups))
+	for i, b := range backups {
+		paths[i] = filepath.Base(b.path)
+	}
+	return paths
+}
+
+func Test_RotatingFile_Size(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	clock := newFakeClock()
+
+	f, err := NewRotatingFile(RotateConfig{
+		Filename: filepath.Join(dir, "logs", "app.log"),
+		MaxSize:  11,
+		now:      clock.Now,
+	})
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("12345\n"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("6789\n"))
+	require.NoError(t, err)
+	clock.Add(time.Second)
+	// Exceeds the size, rotates before writing
+	_, err = f.Write([]byte("abc\n"))
+	require.NoError(t, err)
+	// Larger than MaxSize, written anyway
+	clock.Add(time.Second)
+	_, err = f.Write([]byte("0123456789abc\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.Equal(t, "0123456789abc\n", readFile(t, filepath.Join(dir, "logs", "app.log")))
+	require.Equal(t, []string{"app-2024-01-02T10-00-02.000.log", "app-2024-01-02T10-00-01.000.log"}, backupPaths(t, f))
+	require.Equal(t, "12345\n6789\n", readFile(t, filepath.Join(dir, "logs", "app-2024-01-02T10-00-01.000.log")))
+	require.Equal(t, "abc\n", readFile(t, filepath.Join(dir, "logs", "app-2024-01-02T10-00-02.000.log")))
+
+	_, err = f.Write([]byte("closed"))
+	require.ErrorIs(t, err, os.ErrClosed)
+}
+
+func Test_RotatingFile_Interval(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	clock := newFakeClock()
+
+	f, err := NewRotatingFile(RotateConfig{
+		Filename: filepath.Join(dir, "app.log"),
+		Interval: time.Hour,
+		now:      clock.Now,
+	})
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck // closed at the end of the test
+
+	_, err = f.Write([]byte("a\n"))
+	require.NoError(t, err)
+	clock.Add(59 * time.Minute)
+	_, err = f.Write([]byte("b\n"))
+	require.NoError(t, err)
+	require.Empty(t, backupPaths(t, f))
+
+	clock.Add(time.Minute)
+	_, err = f.Write([]byte("c\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"app-2024-01-02T11-00-00.000.log"}, backupPaths(t, f))
+	require.Equal(t, "a\nb\n", readFile(t, filepath.Join(dir, "app-2024-01-02T11-00-00.000.log")))
+	require.Equal(t, "c\n", readFile(t, filepath.Join(dir, "app.log")))
+}
+
+func Test_RotatingFile_Retention(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	clock := newFakeClock()
+
+	f, err := NewRotatingFile(RotateConfig{
+		Filename:   filepath.Join(dir, "app.log"),
+		MaxBackups: 2,
+		MaxAge:     90 * time.Minute,
+		Compress:   true,
+		now:        clock.Now,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err = f.Write([]byte("line " + strconv.Itoa(i) + "\n"))
+		require.NoError(t, err)
+		clock.Add(time.Minute)
+		require.NoError(t, f.Rotate())
+	}
+	// Wait for the background compression
+	require.NoError(t, f.Close())
+
+	require.Equal(t, []string{"app-2024-01-02T10-04-00.000.log.gz", "app-2024-01-02T10-03-00.000.log.gz"}, backupPaths(t, f))
+
+	file, err := os.Open(filepath.Join(dir, "app-2024-01-02T10-04-00.000.log.gz"))
+	require.NoError(t, err)
+	defer file.Close() //nolint:errcheck // the file is only read
+	zr, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, "line 3\n", string(b))
+
+	// Backups older than MaxAge are removed as well
+	f, err = NewRotatingFile(RotateConfig{
+		Filename: filepath.Join(dir, "app.log"),
+		MaxAge:   time.Hour + 30*time.Second,
+		now:      func() time.Time { return clock.Now().Add(time.Hour) },
+	})
+	require.NoError(t, err)
+	_, err = f.Write([]byte("x\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Rotate())
+	require.NoError(t, f.Close())
+	require.Equal(t, []string{"app-2024-01-02T11-04-00.000.log", "app-2024-01-02T10-04-00.000.log.gz"}, backupPaths(t, f))
+}
+
+func Test_RotatingFile_Reopen(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+
+	f, err := NewRotatingFile(RotateConfig{Filename: name})
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck // closed at the end of the test
+
+	_, err = f.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	// External rotation, e.g. by logrotate
+	require.NoError(t, os.Rename(name, name+".1"))
+	require.NoError(t, f.Reopen())
+	_, err = f.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	require.Equal(t, "before\n", readFile(t, name+".1"))
+	require.Equal(t, "after\n", readFile(t, name))
+}
+
+func Test_RotatingFile_SIGHUP(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGHUP can't be sent on Windows")
+	}
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+
+	f, err := NewRotatingFile(RotateConfig{Filename: name, ReopenOnSIGHUP: true})
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck // closed at the end of the test
+
+	require.NoError(t, os.Rename(name, name+".1"))
+	p, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, p.Signal(syscall.SIGHUP))
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(name)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_RotatingFile_Filename(t *testing.T) { //nolint:paralleltest // modifies the environment
+	dir := t.TempDir()
+	pid := strconv.Itoa(os.Getpid())
+
+	require.Equal(t, filepath.Join(dir, "app-"+pid+".log"), processFilename(filepath.Join(dir, "app-{pid}.log")))
+	require.Equal(t, filepath.Join(dir, "app.log"), processFilename(filepath.Join(dir, "app.log")))
+
+	t.Setenv(envPreforkChild, "1")
+	require.Equal(t, filepath.Join(dir, "app."+pid+".log"), processFilename(filepath.Join(dir, "app.log")))
+
+	_, err := NewRotatingFile(RotateConfig{})
+	require.Error(t, err)
+}
+
+func Test_RotatingFile_Concurrent(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	f, err := NewRotatingFile(RotateConfig{
+		Filename:   filepath.Join(dir, "app.log"),
+		MaxSize:    64,
+		MaxBackups: 3,
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, err := f.Write([]byte("0123456789\n"))
+				require.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, f.Close())
+	require.Len(t, backupPaths(t, f), 3)
+}
@@ -0,0 +1,341 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+)
+
+// slog levels of the Velocity levels which have no slog counterpart.
+// Use them to configure the level of a slog.Handler.
+const (
+	SlogLevelTrace = slog.Level(-8)
+	SlogLevelFatal = slog.Level(12)
+	SlogLevelPanic = slog.Level(16)
+)
+
+// SlogLevel returns the slog level of the level.
+func (lv Level) SlogLevel() slog.Level {
+	switch lv {
+	case LevelTrace:
+		return SlogLevelTrace
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	case LevelFatal:
+		return SlogLevelFatal
+	default:
+		return SlogLevelPanic
+	}
+}
+
+// levelFromSlog returns the Velocity level of a slog level,
+// rounding down levels between two Velocity levels.
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level >= SlogLevelPanic:
+		return LevelPanic
+	case level >= SlogLevelFatal:
+		return LevelFatal
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarn
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	case level >= slog.LevelDebug:
+		return LevelDebug
+	default:
+		return LevelTrace
+	}
+}
+
+var _ AllLogger = (*slogLogger)(nil)
+
+// slogLogger writes the messages of the Velocity logger interfaces to a slog.Handler
+type slogLogger struct {
+	ctx     context.Context //nolint:containedctx // the context is passed to the handler
+	handler slog.Handler
+	level   Level
+	depth   int
+	derived bool
+}
+
+// NewSlogLogger returns an AllLogger which writes to the slog.Handler, so any
+// slog handler can back SetLogger. The key-value pairs of the *w methods and
+// With become slog attributes, slog.Attr values such as slog.Group are kept as is.
+// Like the default logger, it reports the caller of the package functions.
+func NewSlogLogger(handler slog.Handler) AllLogger {
+	return &slogLogger{
+		ctx:     context.Background(),
+		handler: handler,
+		depth:   4,
+	}
+}
+
+// log emits a record with the caller of the logger method.
+func (l *slogLogger) log(lv Level, msg string, keysAndValues []any) {
+	if l.level > lv {
+		return
+	}
+	level := lv.SlogLevel()
+	if l.handler.Enabled(l.ctx, level) {
+		var pcs [1]uintptr
+		runtime.Callers(l.depth, pcs[:])
+		r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+		r.Add(keysAndValues...)
+		_ = l.handler.Handle(l.ctx, r) //nolint:errcheck // It is fine to ignore the error
+	}
+
+	if lv == LevelPanic {
+		panic(msg)
+	}
+	if lv == LevelFatal {
+		os.Exit(1) //nolint:revive // we want to exit the program when Fatal is called
+	}
+}
+
+func (l *slogLogger) Trace(v ...any) {
+	l.log(LevelTrace, fmt.Sprint(v...), nil)
+}
+
+func (l *slogLogger) Debug(v ...any) {
+	l.log(LevelDebug, fmt.Sprint(v...), nil)
+}
+
+func (l *slogLogger) Info(v ...any) {
+	l.log(LevelInfo, fmt.Sprint(v...), nil)
+}
+
+func (l *slogLogger) Warn(v ...any) {
+	l.log(LevelWarn, fmt.Sprint(v...), nil)
+}
+
+func (l *slogLogger) Error(v ...any) {
+	l.log(LevelError, fmt.Sprint(v...), nil)
+}
+
+func (l *slogLogger) Fatal(v ...any) {
+	l.log(LevelFatal, fmt.Sprint(v...), nil)
+}
+
+func (l *slogLogger) Panic(v ...any) {
+	l.log(LevelPanic, fmt.Sprint(v...), nil)
+}
+
+func (l *slogLogger) Tracef(format string, v ...any) {
+	l.log(LevelTrace, fmt.Sprintf(format, v...), nil)
+}
+
+func (l *slogLogger) Debugf(format string, v ...any) {
+	l.log(LevelDebug, fmt.Sprintf(format, v...), nil)
+}
+
+func (l *slogLogger) Infof(format string, v ...any) {
+	l.log(LevelInfo, fmt.Sprintf(format, v...), nil)
+}
+
+func (l *slogLogger) Warnf(format string, v ...any) {
+	l.log(LevelWarn, fmt.Sprintf(format, v...), nil)
+}
+
+func (l *slogLogger) Errorf(format string, v ...any) {
+	l.log(LevelError, fmt.Sprintf(format, v...), nil)
+}
+
+func (l *slogLogger) Fatalf(format string, v ...any) {
+	l.log(LevelFatal, fmt.Sprintf(format, v...), nil)
+}
+
+func (l *slogLogger) Panicf(format string, v ...any) {
+	l.log(LevelPanic, fmt.Sprintf(format, v...), nil)
+}
+
+func (l *slogLogger) Tracew(msg string, keysAndValues ...any) {
+	l.log(LevelTrace, msg, keysAndValues)
+}
+
+func (l *slogLogger) Debugw(msg string, keysAndValues ...any) {
+	l.log(LevelDebug, msg, keysAndValues)
+}
+
+func (l *slogLogger) Infow(msg string, keysAndValues ...any) {
+	l.log(LevelInfo, msg, keysAndValues)
+}
+
+func (l *slogLogger) Warnw(msg string, keysAndValues ...any) {
+	l.log(LevelWarn, msg, keysAndValues)
+}
+
+func (l *slogLogger) Errorw(msg string, keysAndValues ...any) {
+	l.log(LevelError, msg, keysAndValues)
+}
+
+func (l *slogLogger) Fatalw(msg string, keysAndValues ...any) {
+	l.log(LevelFatal, msg, keysAndValues)
+}
+
+func (l *slogLogger) Panicw(msg string, keysAndValues ...any) {
+	l.log(LevelPanic, msg, keysAndValues)
+}
+
+// WithContext returns a logger carrying the fields of all registered context extractors.
+// The context is passed to the handler.
+func (l *slogLogger) WithContext(ctx context.Context) CommonLogger {
+	child := l.derive(extractFields(ctx))
+	if ctx != nil {
+		child.ctx = ctx
+	}
+	return child
+}
+
+// With returns a child logger which adds the key-value pairs to every message.
+func (l *slogLogger) With(keysAndValues ...any) FieldLogger {
+	return l.derive(keysAndValues)
+}
+
+// derive returns a copy of the logger carrying the additional attributes.
+func (l *slogLogger) derive(keysAndValues []any) *slogLogger {
+	depth := l.depth
+	if !l.derived {
+		depth--
+	}
+	handler := l.handler
+	if len(keysAndValues) > 0 {
+		var r slog.Record
+		r.Add(keysAndValues...)
+		attrs := make([]slog.Attr, 0, r.NumAttrs())
+		r.Attrs(func(attr slog.Attr) bool {
+			attrs = append(attrs, attr)
+			return true
+		})
+		handler = handler.WithAttrs(attrs)
+	}
+
+	return &slogLogger{
+		ctx:     l.ctx,
+		handler: handler,
+		level:   l.level,
+		depth:   depth,
+		derived: true,
+	}
+}
+
+func (l *slogLogger) SetLevel(level Level) {
+	l.level = level
+}
+
+// SetOutput replaces the handler with a slog.TextHandler writing to w.
+func (l *slogLogger) SetOutput(w io.Writer) {
+	l.handler = slog.NewTextHandler(w, &slog.HandlerOptions{Level: SlogLevelTrace})
+}
+
+// Logger returns a *slog.Logger using the handler.
+func (l *slogLogger) Logger() any {
+	return slog.New(l.handler)
+}
+
+// slogCallerDepth is the call depth of the default logger's output
+// when called by a slog.Logger method through slogHandler.Handle.
+const slogCallerDepth = 5
+
+// slogHandler writes slog records through a Velocity logger
+type slogHandler struct {
+	logger CommonLogger
+	attrs  []any
+	group  string
+}
+
+// NewSlogHandler returns a slog.Handler which writes records through the logger,
+// e.g. slog.New(log.NewSlogHandler(log.DefaultLogger())). Attributes are passed
+// as key-value pairs to the *w methods, keys of groups are prefixed with the
+// group name and a dot. The level of the logger decides which records are written.
+func NewSlogHandler(l CommonLogger) slog.Handler {
+	return &slogHandler{logger: l}
+}
+
+// Enabled implements slog.Handler. Records are filtered by the logger itself.
+func (*slogHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler.
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	keysAndValues := make([]any, 0, len(h.attrs)+2*r.NumAttrs())
+	keysAndValues = append(keysAndValues, h.attrs...)
+	r.Attrs(func(attr slog.Attr) bool {
+		keysAndValues = appendAttr(keysAndValues, h.group, attr)
+		return true
+	})
+
+	lv := levelFromSlog(r.Level)
+
+	// Report the caller of the slog.Logger method instead of the handler
+	if dl, ok := h.logger.(*defaultLogger); ok {
+		dl.privateLogwDepth(lv, slogCallerDepth, r.Message, keysAndValues)
+		return nil
+	}
+
+	switch lv {
+	case LevelTrace:
+		h.logger.Tracew(r.Message, keysAndValues...)
+	case LevelDebug:
+		h.logger.Debugw(r.Message, keysAndValues...)
+	case LevelInfo:
+		h.logger.Infow(r.Message, keysAndValues...)
+	case LevelWarn:
+		h.logger.Warnw(r.Message, keysAndValues...)
+	case LevelError:
+		h.logger.Errorw(r.Message, keysAndValues...)
+	case LevelFatal:
+		h.logger.Fatalw(r.Message, keysAndValues...)
+	case LevelPanic:
+		h.logger.Panicw(r.Message, keysAndValues...)
+	}
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	keysAndValues := make([]any, 0, len(h.attrs)+2*len(attrs))
+	keysAndValues = append(keysAndValues, h.attrs...)
+	for _, attr := range attrs {
+		keysAndValues = appendAttr(keysAndValues, h.group, attr)
+	}
+	return &slogHandler{logger: h.logger, attrs: keysAndValues, group: h.group}
+}
+
+// WithGroup implements slog.Handler.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, attrs: h.attrs, group: h.group + name + "."}
+}
+
+// appendAttr appends the attribute as key-value pairs, flattening groups.
+func appendAttr(keysAndValues []any, prefix string, attr slog.Attr) []any {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return keysAndValues
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range attr.Value.Group() {
+			keysAndValues = appendAttr(keysAndValues, prefix, member)
+		}
+		return keysAndValues
+	}
+	return append(keysAndValues, prefix+attr.Key, attr.Value.Any())
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSlogLogger returns a slog backed logger writing text records without time
+func newTestSlogLogger(w *bytes.Buffer, addSource bool) AllLogger {
+	return NewSlogLogger(slog.NewTextHandler(w, &slog.HandlerOptions{
+		AddSource: addSource,
+		Level:     SlogLevelTrace,
+		ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
+			switch attr.Key {
+			case slog.TimeKey:
+				return slog.Attr{}
+			case slog.SourceKey:
+				source, ok := attr.Value.Any().(*slog.Source)
+				if ok {
+					source.File = filepath.Base(source.File)
+				}
+			case slog.LevelKey:
+				if level, ok := attr.Value.Any().(slog.Level); ok && level == SlogLevelTrace {
+					return slog.String(slog.LevelKey, "TRACE")
+				}
+			}
+			return attr
+		},
+	}))
+}
+
+func Test_SlogLogger(t *testing.T) {
+	var w bytes.Buffer
+	l := newTestSlogLogger(&w, false)
+
+	l.Trace("trace ", work)
+	l.Debugf("received %s order", work)
+	l.Infow("starting work", "attempt", 1, slog.Group("job", "id", 7))
+	l.SetLevel(LevelError)
+	l.Warn("work may fail")
+	l.Error("work failed")
+
+	require.Panics(t, func() {
+		l.Panicw("work panic", "k", "v")
+	})
+
+	require.Equal(t, "level=TRACE msg=\"trace work\"\n"+
+		"level=DEBUG msg=\"received work order\"\n"+
+		"level=INFO msg=\"starting work\" attempt=1 job.id=7\n"+
+		"level=ERROR msg=\"work failed\"\n"+
+		"level=ERROR+8 msg=\"work panic\" k=v\n", w.String())
+}
+
+func Test_SlogLogger_With(t *testing.T) {
+	defer func() { extractors = nil }()
+	RegisterContextExtractor(func(ctx context.Context) (string, any, bool) {
+		rid, ok := ctx.Value(ctxKey("rid")).(string)
+		return "request-id", rid, ok
+	})
+
+	var w bytes.Buffer
+	l := newTestSlogLogger(&w, false)
+
+	ctx := context.WithValue(context.Background(), ctxKey("rid"), "abc")
+	l.WithContext(ctx).Info("hello")
+
+	fl, ok := l.(FieldLogger)
+	require.True(t, ok)
+	fl.With("a", 1).With(slog.Group("g", "b", 2)).Warnw("hi", "c", 3)
+
+	sl, ok := l.Logger().(*slog.Logger)
+	require.True(t, ok)
+	sl.Info("direct")
+
+	require.Equal(t, "level=INFO msg=hello request-id=abc\n"+
+		"level=WARN msg=hi a=1 g.b=2 c=3\n"+
+		"level=INFO msg=direct\n", w.String())
+}
+
+func Test_SlogLogger_Caller(t *testing.T) {
+	var w bytes.Buffer
+	SetLogger(newTestSlogLogger(&w, true))
+	defer initDefaultLogger()
+
+	Info("a")
+	WithContext(context.TODO()).Info("b")
+
+	require.Equal(t, "level=INFO source=slog_test.go:93 msg=a\n"+
+		"level=INFO source=slog_test.go:94 msg=b\n", w.String())
+}
+
+func Test_SlogHandler(t *testing.T) {
+	logger = &defaultLogger{
+		stdlog: log.New(os.Stderr, "", log.Lshortfile),
+		depth:  4,
+	}
+	defer initDefaultLogger()
+
+	var w byteSliceWriter
+	SetOutput(&w)
+	SetLevel(LevelDebug)
+
+	sl := slog.New(NewSlogHandler(DefaultLogger()))
+	sl.Log(context.Background(), SlogLevelTrace, "hidden")
+	sl.Debug("received", "order", work)
+	sl.With("a", 1).WithGroup("g").With("b", 2).Warn("may fail", slog.Group("h", "c", 3), "d", 4)
+	sl.Error("failed", slog.Group("", "e", 5), slog.Attr{})
+
+	require.Equal(t, "slog_test.go:113: [Debug] received order=work\n"+
+		"slog_test.go:114: [Warn] may fail a=1 g.b=2 g.h.c=3 g.d=4\n"+
+		"slog_test.go:115: [Error] failed e=5\n", string(w.b))
+}
+
+func Test_SlogHandler_Levels(t *testing.T) {
+	l := &plainLogger{}
+	h := NewSlogHandler(l)
+
+	require.True(t, h.Enabled(context.Background(), SlogLevelTrace))
+	require.NoError(t, h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo+1, "between", 0)))
+	require.Equal(t, []string{"between"}, l.msgs)
+
+	require.Equal(t, LevelTrace, levelFromSlog(SlogLevelTrace))
+	require.Equal(t, LevelDebug, levelFromSlog(slog.LevelDebug))
+	require.Equal(t, LevelWarn, levelFromSlog(slog.LevelWarn))
+	require.Equal(t, LevelError, levelFromSlog(slog.LevelError))
+	require.Equal(t, LevelFatal, levelFromSlog(SlogLevelFatal))
+	require.Equal(t, LevelPanic, levelFromSlog(SlogLevelPanic+4))
+	for lv := LevelTrace; lv <= LevelPanic; lv++ {
+		require.Equal(t, lv, levelFromSlog(lv.SlogLevel()))
+	}
+}
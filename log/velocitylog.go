@@ -117,6 +117,7 @@ func Panicw(msg string, keysAndValues ...any) {
 	logger.Panicw(msg, keysAndValues...)
 }
 
+// WithContext returns a logger carrying the fields of the registered context extractors.
 func WithContext(ctx context.Context) CommonLogger {
 	return logger.WithContext(ctx)
 }
@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/basicauth"
+	"github.com/khulnasoft/velocity/middleware/keyauth"
+	"github.com/khulnasoft/velocity/middleware/requestid"
+	"github.com/khulnasoft/velocity/middleware/session"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// Authentication methods of the default principal
+const (
+	AuthMethodBasic   = "basic"
+	AuthMethodAPIKey  = "apikey"
+	AuthMethodSession = "session"
+)
+
+// unmatchedRoute is the route of requests which did not match any handler route
+const unmatchedRoute = "unmatched"
+
+// Record is an entry of the audit trail.
+type Record struct {
+	Time                  time.Time     `json:"time"`
+	RequestID             string        `json:"request_id,omitempty"`
+	Method                string        `json:"method"`
+	Route                 string        `json:"route"`
+	Path                  string        `json:"path"`
+	IP                    string        `json:"ip"`
+	Principal             string        `json:"principal,omitempty"`
+	AuthMethod            string        `json:"auth_method,omitempty"`
+	Error                 string        `json:"error,omitempty"`
+	RequestBody           string        `json:"request_body,omitempty"`
+	ResponseBody          string        `json:"response_body,omitempty"`
+	Status                int           `json:"status"`
+	Latency               time.Duration `json:"latency_ns"`
+	RequestBodyTruncated  bool          `json:"request_body_truncated,omitempty"`
+	ResponseBodyTruncated bool          `json:"response_body_truncated,omitempty"`
+}
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	methods := make(map[string]struct{}, len(cfg.Methods))
+	for _, method := range cfg.Methods {
+		methods[utils.ToUpper(method)] = struct{}{}
+	}
+
+	red := newRedactor(cfg.Redact)
+	ship := newShipper(cfg)
+
+	// The buffered records are flushed when the app shuts down.
+	// The app is only known once the first request arrives.
+	var registerShutdown sync.Once
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		if _, ok := methods[c.Method()]; !ok {
+			return c.Next()
+		}
+
+		registerShutdown.Do(func() {
+			c.App().Hooks().OnShutdown(func() error {
+				return ship.flush(cfg.ShutdownTimeout)
+			})
+		})
+
+		// The session can only be read if its middleware wraps this one,
+		// otherwise it is already released once the handlers returned
+		sessionAvailable := cfg.SessionKey != "" && session.FromContext(c) != nil
+
+		start := time.Now()
+
+		// Continue stack
+		chainErr := c.Next()
+
+		record := Record{
+			Time:      start,
+			RequestID: requestid.FromContext(c),
+			Method:    utils.CopyString(c.Method()),
+			Route:     unmatchedRoute,
+			Path:      utils.CopyString(c.Path()),
+			IP:        utils.CopyString(c.IP()),
+			Status:    c.Response().StatusCode(),
+			Latency:   time.Since(start),
+		}
+
+		if c.Matched() {
+			record.Route = c.Route().Path
+		}
+
+		if chainErr != nil {
+			record.Error = chainErr.Error()
+			record.Status = velocity.StatusInternalServerError
+			var velocityErr *velocity.Error
+			if errors.As(chainErr, &velocityErr) {
+				record.Status = velocityErr.Code
+			}
+		}
+
+		if cfg.Principal != nil {
+			record.Principal, record.AuthMethod = cfg.Principal(c)
+		} else {
+			record.Principal, record.AuthMethod = defaultPrincipal(c, cfg.SessionKey, sessionAvailable)
+		}
+
+		if !cfg.DisableRequestBody {
+			record.RequestBody, record.RequestBodyTruncated = red.body(
+				c.Request().Body(),
+				string(c.Request().Header.ContentType()),
+				string(c.Request().Header.ContentEncoding()),
+				cfg.MaxBodySize,
+			)
+		}
+
+		if !cfg.DisableResponseBody {
+			if c.Response().IsBodyStream() {
+				record.ResponseBody = "[streamed body]"
+			} else {
+				record.ResponseBody, record.ResponseBodyTruncated = red.body(
+					c.Response().Body(),
+					string(c.Response().Header.ContentType()),
+					string(c.Response().Header.ContentEncoding()),
+					cfg.MaxBodySize,
+				)
+			}
+		}
+
+		ship.enqueue(c.Context(), record)
+
+		return chainErr
+	}
+}
+
+// defaultPrincipal returns the principal authenticated by the basicauth,
+// keyauth or session middleware. API keys are secrets, so only the owner
+// of a key resolved by the keyauth Registry or a fingerprint of the key is recorded.
+func defaultPrincipal(c velocity.Ctx, sessionKey string, sessionAvailable bool) (principal, authMethod string) {
+	if username := basicauth.UsernameFromContext(c); username != "" {
+		return username, AuthMethodBasic
+	}
+	if key := keyauth.KeyFromContext(c); key != nil && key.Owner != "" {
+		return key.Owner, AuthMethodAPIKey
+	}
+	if token := keyauth.TokenFromContext(c); token != "" {
+		return Fingerprint(token), AuthMethodAPIKey
+	}
+	if sessionAvailable {
+		if value := session.FromContext(c).Get(sessionKey); value != nil {
+			return fmt.Sprint(value), AuthMethodSession
+		}
+	}
+	return "", ""
+}
+
+// Fingerprint returns the fingerprint of an API key recorded as principal,
+// the first 16 hex digits of its SHA-256 hash prefixed with "sha256:".
+func Fingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
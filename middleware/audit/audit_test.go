@@ -0,0 +1,565 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/basicauth"
+	"github.com/khulnasoft/velocity/middleware/keyauth"
+	"github.com/khulnasoft/velocity/middleware/session"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// memorySink collects the written records
+type memorySink struct {
+	records []Record
+	mu      sync.Mutex
+}
+
+func (s *memorySink) Write(records []Record) error {
+	s.mu.Lock()
+	s.records = append(s.records, records...)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memorySink) Records() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Record(nil), s.records...)
+}
+
+// go test -run Test_Audit
+func Test_Audit(t *testing.T) {
+	t.Parallel()
+
+	sink := &memorySink{}
+	app := velocity.New()
+	app.Use(New(Config{
+		Sink:          sink,
+		Redact:        []string{"password", "cards.number"},
+		FlushInterval: time.Hour,
+	}))
+	app.Use(keyauth.New(keyauth.Config{
+		Validator: func(_ velocity.Ctx, key string) (bool, error) {
+			return key == "secret-key", nil
+		},
+	}))
+
+	app.Post("/users/:id", func(c velocity.Ctx) error {
+		return c.Status(velocity.StatusCreated).JSON(velocity.Map{"id": c.Params("id"), "password": "hash"})
+	})
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		return c.SendString("read")
+	})
+	app.Delete("/users/:id", func(_ velocity.Ctx) error {
+		return velocity.ErrForbidden
+	})
+
+	body := `{"name":"john","password":"hunter2","cards":[{"number":"4111","cvc":123}]}`
+	req := httptest.NewRequest(velocity.MethodPost, "/users/1", strings.NewReader(body))
+	req.Header.Set(velocity.HeaderContentType, velocity.MIMEApplicationJSON)
+	req.Header.Set(velocity.HeaderAuthorization, "Bearer secret-key")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusCreated, resp.StatusCode)
+
+	// Reading requests are not audited
+	req = httptest.NewRequest(velocity.MethodGet, "/users/1", nil)
+	req.Header.Set(velocity.HeaderAuthorization, "Bearer secret-key")
+	_, err = app.Test(req)
+	require.NoError(t, err)
+
+	req = httptest.NewRequest(velocity.MethodDelete, "/users/1", nil)
+	req.Header.Set(velocity.HeaderAuthorization, "Bearer secret-key")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusForbidden, resp.StatusCode)
+
+	// Unauthenticated requests are audited too
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodPost, "/users/2", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+
+	require.Empty(t, sink.Records())
+	require.NoError(t, app.Shutdown())
+	records := sink.Records()
+	require.Len(t, records, 3)
+
+	created := records[0]
+	require.Equal(t, velocity.MethodPost, created.Method)
+	require.Equal(t, "/users/:id", created.Route)
+	require.Equal(t, "/users/1", created.Path)
+	require.Equal(t, "0.0.0.0", created.IP)
+	require.Equal(t, velocity.StatusCreated, created.Status)
+	require.Equal(t, Fingerprint("secret-key"), created.Principal)
+	require.Equal(t, AuthMethodAPIKey, created.AuthMethod)
+	require.NotContains(t, created.Principal, "secret-key")
+	require.Equal(t, `{"cards":[{"cvc":123,"number":"[REDACTED]"}],"name":"john","password":"[REDACTED]"}`, created.RequestBody)
+	require.Equal(t, `{"id":"1","password":"[REDACTED]"}`, created.ResponseBody)
+	require.Positive(t, created.Latency)
+	require.Empty(t, created.Error)
+
+	deleted := records[1]
+	require.Equal(t, velocity.MethodDelete, deleted.Method)
+	require.Equal(t, velocity.StatusForbidden, deleted.Status)
+	require.Equal(t, velocity.ErrForbidden.Error(), deleted.Error)
+
+	unauthorized := records[2]
+	require.Equal(t, unmatchedRoute, unauthorized.Route)
+	require.Equal(t, velocity.StatusUnauthorized, unauthorized.Status)
+	require.Empty(t, unauthorized.Principal)
+}
+
+// go test -run Test_Audit_Principal
+func Test_Audit_Principal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("basicauth", func(t *testing.T) {
+		t.Parallel()
+
+		sink := &memorySink{}
+		app := velocity.New()
+		app.Use(New(Config{Sink: sink}))
+		app.Use(basicauth.New(basicauth.Config{Users: map[string]string{"john": "doe"}}))
+		app.Post("/", func(c velocity.Ctx) error {
+			return c.SendStatus(velocity.StatusNoContent)
+		})
+
+		req := httptest.NewRequest(velocity.MethodPost, "/", nil)
+		req.Header.Set(velocity.HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte("john:doe")))
+		_, err := app.Test(req)
+		require.NoError(t, err)
+
+		require.NoError(t, app.Shutdown())
+		records := sink.Records()
+		require.Len(t, records, 1)
+		require.Equal(t, "john", records[0].Principal)
+		require.Equal(t, AuthMethodBasic, records[0].AuthMethod)
+	})
+
+	t.Run("keyauth registry", func(t *testing.T) {
+		t.Parallel()
+
+		sink := &memorySink{}
+		app := velocity.New()
+		app.Use(New(Config{Sink: sink}))
+		app.Use(keyauth.New(keyauth.Config{
+			Registry: keyauth.NewMemoryRegistry(&keyauth.Key{Hash: keyauth.HashKey("secret"), Owner: "billing-service"}),
+		}))
+		app.Post("/", func(c velocity.Ctx) error {
+			return c.SendStatus(velocity.StatusNoContent)
+		})
+
+		req := httptest.NewRequest(velocity.MethodPost, "/", nil)
+		req.Header.Set(velocity.HeaderAuthorization, "Bearer secret")
+		_, err := app.Test(req)
+		require.NoError(t, err)
+
+		require.NoError(t, app.Shutdown())
+		records := sink.Records()
+		require.Len(t, records, 1)
+		require.Equal(t, "billing-service", records[0].Principal)
+		require.Equal(t, AuthMethodAPIKey, records[0].AuthMethod)
+	})
+
+	t.Run("session", func(t *testing.T) {
+		t.Parallel()
+
+		sink := &memorySink{}
+		app := velocity.New()
+		app.Use(session.New())
+		app.Use(New(Config{Sink: sink, SessionKey: "user_id"}))
+		app.Post("/login", func(c velocity.Ctx) error {
+			session.FromContext(c).Set("user_id", 42)
+			return c.SendStatus(velocity.StatusNoContent)
+		})
+
+		_, err := app.Test(httptest.NewRequest(velocity.MethodPost, "/login", nil))
+		require.NoError(t, err)
+
+		require.NoError(t, app.Shutdown())
+		records := sink.Records()
+		require.Len(t, records, 1)
+		require.Equal(t, "42", records[0].Principal)
+		require.Equal(t, AuthMethodSession, records[0].AuthMethod)
+	})
+
+	t.Run("session registered after audit", func(t *testing.T) {
+		t.Parallel()
+
+		sink := &memorySink{}
+		app := velocity.New()
+		app.Use(New(Config{Sink: sink, SessionKey: "user_id"}))
+		app.Use(session.New())
+		app.Post("/login", func(c velocity.Ctx) error {
+			session.FromContext(c).Set("user_id", 42)
+			return c.SendStatus(velocity.StatusNoContent)
+		})
+
+		_, err := app.Test(httptest.NewRequest(velocity.MethodPost, "/login", nil))
+		require.NoError(t, err)
+
+		require.NoError(t, app.Shutdown())
+		records := sink.Records()
+		require.Len(t, records, 1)
+		require.Empty(t, records[0].Principal)
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		t.Parallel()
+
+		sink := &memorySink{}
+		app := velocity.New()
+		app.Use(New(Config{
+			Sink: sink,
+			Principal: func(c velocity.Ctx) (string, string) {
+				return c.Get("X-User"), "header"
+			},
+		}))
+		app.Put("/", func(c velocity.Ctx) error {
+			return c.SendStatus(velocity.StatusNoContent)
+		})
+
+		req := httptest.NewRequest(velocity.MethodPut, "/", nil)
+		req.Header.Set("X-User", "jane")
+		_, err := app.Test(req)
+		require.NoError(t, err)
+
+		require.NoError(t, app.Shutdown())
+		records := sink.Records()
+		require.Len(t, records, 1)
+		require.Equal(t, "jane", records[0].Principal)
+		require.Equal(t, "header", records[0].AuthMethod)
+	})
+}
+
+// go test -run Test_Audit_Bodies
+func Test_Audit_Bodies(t *testing.T) {
+	t.Parallel()
+
+	sink := &memorySink{}
+	app := velocity.New()
+	app.Use(New(Config{
+		Sink:                sink,
+		MaxBodySize:         7,
+		DisableResponseBody: true,
+	}))
+	app.Post("/", func(c velocity.Ctx) error {
+		return c.SendString("response")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodPost, "/", strings.NewReader("äöüäöü")))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	require.NoError(t, app.Shutdown())
+	records := sink.Records()
+	require.Len(t, records, 1)
+	require.Equal(t, "äöü", records[0].RequestBody)
+	require.True(t, records[0].RequestBodyTruncated)
+	require.Empty(t, records[0].ResponseBody)
+}
+
+// go test -run Test_Audit_Next
+func Test_Audit_Next(t *testing.T) {
+	t.Parallel()
+
+	sink := &memorySink{}
+	app := velocity.New()
+	app.Use(New(Config{
+		Sink:    sink,
+		Methods: []string{"get"},
+		Next: func(c velocity.Ctx) bool {
+			return c.Path() == "/health"
+		},
+	}))
+	app.Get("/*", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	for _, path := range []string{"/", "/health"} {
+		_, err := app.Test(httptest.NewRequest(velocity.MethodGet, path, nil))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, app.Shutdown())
+	records := sink.Records()
+	require.Len(t, records, 1)
+	require.Equal(t, "/", records[0].Path)
+}
+
+// go test -run Test_Redactor_Body
+func Test_Redactor_Body(t *testing.T) {
+	t.Parallel()
+
+	r := newRedactor([]string{"$.token", "users.*.secret", "*.pin"})
+
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		encoding    string
+		want        string
+		maxSize     int
+		truncated   bool
+	}{
+		{
+			name:        "json",
+			body:        `{"token":"a","users":[{"secret":"b","name":"<c>"}],"card":{"pin":1234},"big":12345678901234567890}`,
+			contentType: "application/json; charset=utf-8",
+			want:        `{"big":12345678901234567890,"card":{"pin":"[REDACTED]"},"token":"[REDACTED]","users":[{"name":"<c>","secret":"[REDACTED]"}]}`,
+		},
+		{
+			name:        "problem json",
+			body:        `{"token":"a"}`,
+			contentType: "application/problem+json",
+			want:        `{"token":"[REDACTED]"}`,
+		},
+		{
+			name:        "invalid json",
+			body:        `{"token":"a"`,
+			contentType: velocity.MIMEApplicationJSON,
+			want:        "[invalid JSON body, 12 bytes]",
+		},
+		{
+			name:        "form",
+			body:        "token=a&name=b&token=c",
+			contentType: velocity.MIMEApplicationForm,
+			want:        "name=b&token=%5BREDACTED%5D&token=%5BREDACTED%5D",
+		},
+		{
+			name:        "text",
+			body:        "token=a",
+			contentType: velocity.MIMETextPlain,
+			want:        "token=a",
+		},
+		{
+			name:        "multipart",
+			body:        "--boundary",
+			contentType: velocity.MIMEMultipartForm + "; boundary=boundary",
+			want:        "[multipart/form-data body, 10 bytes]",
+		},
+		{
+			name:        "binary",
+			body:        "\xff\xfe",
+			contentType: velocity.MIMEOctetStream,
+			want:        "[binary body, 2 bytes]",
+		},
+		{
+			name:        "encoded",
+			body:        `{"token":"a"}`,
+			contentType: velocity.MIMEApplicationJSON,
+			encoding:    "gzip",
+			want:        "[gzip encoded body, 13 bytes]",
+		},
+		{
+			name:        "truncated",
+			body:        `{"token":"a","name":"abcdefghijklmnopqrstuvwxyz"}`,
+			contentType: velocity.MIMEApplicationJSON,
+			want:        `{"name":"abcdefghijklmnopqrstuvwxyz","to`,
+			maxSize:     40,
+			truncated:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			maxSize := tt.maxSize
+			if maxSize == 0 {
+				maxSize = ConfigDefault.MaxBodySize
+			}
+			got, truncated := r.body([]byte(tt.body), tt.contentType, tt.encoding, maxSize)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.truncated, truncated)
+		})
+	}
+}
+
+// go test -run Test_Shipper_Backpressure
+func Test_Shipper_Backpressure(t *testing.T) {
+	t.Parallel()
+
+	newBlocked := func(policy Backpressure, onDrop func(Record)) (*shipper, *memorySink, chan struct{}) {
+		sink := &memorySink{}
+		release := make(chan struct{})
+		blocking := SinkFunc(func(records []Record) error {
+			<-release
+			return sink.Write(records)
+		})
+		s := newShipper(Config{
+			Sink:          blocking,
+			OnDrop:        onDrop,
+			BufferSize:    2,
+			BatchSize:     1,
+			FlushInterval: time.Hour,
+			BlockTimeout:  time.Hour,
+			Backpressure:  policy,
+		})
+		// The first record blocks the sink, the next two fill the buffer
+		s.enqueue(context.Background(), Record{Path: "/1"})
+		require.Eventually(t, func() bool {
+			return len(s.queue) == 0
+		}, time.Second, time.Millisecond)
+		s.enqueue(context.Background(), Record{Path: "/2"})
+		s.enqueue(context.Background(), Record{Path: "/3"})
+		require.Len(t, s.queue, 2)
+		return s, sink, release
+	}
+
+	paths := func(records []Record) []string {
+		out := make([]string, len(records))
+		for i, record := range records {
+			out[i] = record.Path
+		}
+		return out
+	}
+
+	t.Run("drop newest", func(t *testing.T) {
+		t.Parallel()
+
+		var dropped []string
+		s, sink, release := newBlocked(BackpressureDropNewest, func(r Record) {
+			dropped = append(dropped, r.Path)
+		})
+		s.enqueue(context.Background(), Record{Path: "/4"})
+		require.Equal(t, []string{"/4"}, dropped)
+		require.Equal(t, uint64(1), s.dropped.Load())
+
+		close(release)
+		require.NoError(t, s.flush(time.Second))
+		require.Equal(t, []string{"/1", "/2", "/3"}, paths(sink.Records()))
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		t.Parallel()
+
+		var dropped []string
+		s, sink, release := newBlocked(BackpressureDropOldest, func(r Record) {
+			dropped = append(dropped, r.Path)
+		})
+		s.enqueue(context.Background(), Record{Path: "/4"})
+		require.Equal(t, []string{"/2"}, dropped)
+
+		close(release)
+		require.NoError(t, s.flush(time.Second))
+		require.Equal(t, []string{"/1", "/3", "/4"}, paths(sink.Records()))
+	})
+
+	t.Run("block", func(t *testing.T) {
+		t.Parallel()
+
+		s, sink, release := newBlocked(BackpressureBlock, nil)
+		enqueued := make(chan struct{})
+		go func() {
+			s.enqueue(context.Background(), Record{Path: "/4"})
+			close(enqueued)
+		}()
+
+		select {
+		case <-enqueued:
+			t.Fatal("enqueue did not block")
+		case <-time.After(50 * time.Millisecond):
+		}
+		require.ErrorIs(t, s.flush(10*time.Millisecond), ErrFlushTimeout)
+
+		close(release)
+		<-enqueued
+		require.NoError(t, s.flush(time.Second))
+		require.Equal(t, []string{"/1", "/2", "/3", "/4"}, paths(sink.Records()))
+	})
+
+	t.Run("block timeout", func(t *testing.T) {
+		t.Parallel()
+
+		var dropped []string
+		s, sink, release := newBlocked(BackpressureBlock, func(r Record) {
+			dropped = append(dropped, r.Path)
+		})
+		s.blockTimeout = 10 * time.Millisecond
+		s.enqueue(context.Background(), Record{Path: "/4"})
+		require.Equal(t, []string{"/4"}, dropped)
+		require.Equal(t, uint64(1), s.dropped.Load())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s.blockTimeout = time.Hour
+		s.enqueue(ctx, Record{Path: "/5"})
+		require.Equal(t, []string{"/4", "/5"}, dropped)
+
+		close(release)
+		require.NoError(t, s.flush(time.Second))
+		require.Equal(t, []string{"/1", "/2", "/3"}, paths(sink.Records()))
+	})
+}
+
+// go test -run Test_Shipper_Interval
+func Test_Shipper_Interval(t *testing.T) {
+	t.Parallel()
+
+	sink := &memorySink{}
+	s := newShipper(Config{
+		Sink:          sink,
+		BufferSize:    10,
+		BatchSize:     10,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	s.enqueue(context.Background(), Record{Path: "/"})
+
+	require.Eventually(t, func() bool {
+		return len(sink.Records()) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+// go test -run Test_WriterSink
+func Test_WriterSink(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+	require.NoError(t, sink.Write([]Record{
+		{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Method: velocity.MethodPost, Route: "/", Path: "/", Status: 201, Latency: time.Millisecond},
+		{Time: time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC), Method: velocity.MethodDelete, Route: "/", Path: "/", Status: 204, Principal: "john", AuthMethod: AuthMethodBasic},
+	}))
+
+	require.Equal(t,
+		`{"time":"2024-01-02T03:04:05Z","method":"POST","route":"/","path":"/","ip":"","status":201,"latency_ns":1000000}`+"\n"+
+			`{"time":"2024-01-02T03:04:06Z","method":"DELETE","route":"/","path":"/","ip":"","principal":"john","auth_method":"basic","status":204,"latency_ns":0}`+"\n",
+		buf.String())
+}
+
+// go test -v -run=^$ -bench=Benchmark_Audit -benchmem -count=4
+func Benchmark_Audit(b *testing.B) {
+	app := velocity.New()
+	app.Use(New(Config{
+		Sink: SinkFunc(func([]Record) error {
+			return nil
+		}),
+		Redact: []string{"password"},
+	}))
+	app.Post("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusNoContent)
+	})
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod(velocity.MethodPost)
+	fctx.Request.Header.SetContentType(velocity.MIMEApplicationJSON)
+	fctx.Request.SetRequestURI("/")
+	fctx.Request.SetBodyString(`{"name":"john","password":"secret"}`)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		h(fctx)
+	}
+}
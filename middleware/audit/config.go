@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"os"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// Backpressure defines what happens to a record when the buffer is full.
+type Backpressure int
+
+const (
+	// BackpressureBlock blocks the request until the record fits into the
+	// buffer, so no record is lost while the sink is briefly slower than the
+	// traffic. The record is dropped after the BlockTimeout or once the
+	// context of the request is done.
+	BackpressureBlock Backpressure = iota
+	// BackpressureDropNewest drops the new record.
+	BackpressureDropNewest
+	// BackpressureDropOldest drops the oldest buffered record in favor of the new one.
+	BackpressureDropOldest
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// Sink receives the records in batches.
+	//
+	// Optional. Default: NewWriterSink(os.Stdout)
+	Sink Sink
+
+	// Principal returns the authenticated principal of the request and the
+	// method it was authenticated with. It is called after the handlers ran.
+	//
+	// Optional. Default: the username of basicauth, the owner or a fingerprint
+	// of the key of keyauth or the SessionKey value of the session, in that order
+	Principal func(c velocity.Ctx) (principal, authMethod string)
+
+	// OnDrop is called with every record dropped by the backpressure policy.
+	//
+	// Optional. Default: nil
+	OnDrop func(record Record)
+
+	// SessionKey is the session key holding the principal, e.g. "user_id".
+	// The session is only read if the session middleware is registered
+	// before this middleware.
+	//
+	// Optional. Default: ""
+	SessionKey string
+
+	// Methods are the HTTP methods of the audited requests.
+	//
+	// Optional. Default: []string{"POST", "PUT", "PATCH", "DELETE"}
+	Methods []string
+
+	// Redact lists the paths of the JSON and form fields whose values are
+	// replaced in the recorded bodies, e.g. "password", "user.token" or
+	// "cards.*.number". Arrays are traversed implicitly.
+	//
+	// Optional. Default: nil
+	Redact []string
+
+	// MaxBodySize is the maximum size of a recorded body in bytes,
+	// longer bodies are truncated.
+	//
+	// Optional. Default: 4096
+	MaxBodySize int
+
+	// BufferSize is the number of records buffered for the sink.
+	//
+	// Optional. Default: 1024
+	BufferSize int
+
+	// BatchSize is the maximum number of records passed to the sink at once.
+	//
+	// Optional. Default: 100
+	BatchSize int
+
+	// FlushInterval is the interval in which incomplete batches are passed to the sink.
+	//
+	// Optional. Default: 1 * time.Second
+	FlushInterval time.Duration
+
+	// ShutdownTimeout limits flushing the buffered records on shutdown.
+	//
+	// Optional. Default: 10 * time.Second
+	ShutdownTimeout time.Duration
+
+	// Backpressure defines what happens to a record when the buffer is full.
+	//
+	// Optional. Default: BackpressureBlock
+	Backpressure Backpressure
+
+	// BlockTimeout limits how long BackpressureBlock blocks a request,
+	// afterwards the record is dropped.
+	//
+	// Optional. Default: 1 * time.Second
+	BlockTimeout time.Duration
+
+	// DisableRequestBody disables recording the request body.
+	//
+	// Optional. Default: false
+	DisableRequestBody bool
+
+	// DisableResponseBody disables recording the response body.
+	//
+	// Optional. Default: false
+	DisableResponseBody bool
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:            nil,
+	Methods:         []string{velocity.MethodPost, velocity.MethodPut, velocity.MethodPatch, velocity.MethodDelete},
+	MaxBodySize:     4096,
+	BufferSize:      1024,
+	BatchSize:       100,
+	FlushInterval:   1 * time.Second,
+	ShutdownTimeout: 10 * time.Second,
+	Backpressure:    BackpressureBlock,
+	BlockTimeout:    1 * time.Second,
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		cfg := ConfigDefault
+		cfg.Sink = NewWriterSink(os.Stdout)
+		return cfg
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Sink == nil {
+		cfg.Sink = NewWriterSink(os.Stdout)
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = ConfigDefault.Methods
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = ConfigDefault.MaxBodySize
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = ConfigDefault.BufferSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = ConfigDefault.BatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = ConfigDefault.FlushInterval
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = ConfigDefault.ShutdownTimeout
+	}
+	if cfg.BlockTimeout <= 0 {
+		cfg.BlockTimeout = ConfigDefault.BlockTimeout
+	}
+	return cfg
+}
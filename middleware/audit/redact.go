@@ -0,0 +1,151 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redacted replaces the values of redacted fields
+const Redacted = "[REDACTED]"
+
+// redactor replaces the values of fields in JSON and form bodies
+type redactor struct {
+	paths [][]string
+}
+
+func newRedactor(paths []string) *redactor {
+	r := &redactor{paths: make([][]string, 0, len(paths))}
+	for _, path := range paths {
+		path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+		if path != "" {
+			r.paths = append(r.paths, strings.Split(path, "."))
+		}
+	}
+	return r
+}
+
+// body returns the recorded text of a body. Bodies which are not JSON,
+// forms or UTF-8 text are replaced by a description.
+func (r *redactor) body(body []byte, contentType, contentEncoding string, maxSize int) (string, bool) {
+	if len(body) == 0 {
+		return "", false
+	}
+
+	mime, _, _ := strings.Cut(contentType, ";")
+	mime = strings.ToLower(strings.TrimSpace(mime))
+
+	switch {
+	case contentEncoding != "":
+		return "[" + contentEncoding + " encoded body, " + strconv.Itoa(len(body)) + " bytes]", false
+	case strings.HasPrefix(mime, "multipart/"):
+		return "[" + mime + " body, " + strconv.Itoa(len(body)) + " bytes]", false
+	case strings.Contains(mime, "json"):
+		if len(r.paths) > 0 {
+			redacted, ok := r.json(body)
+			if !ok {
+				return "[invalid JSON body, " + strconv.Itoa(len(body)) + " bytes]", false
+			}
+			body = redacted
+		}
+	case mime == "application/x-www-form-urlencoded":
+		if len(r.paths) > 0 {
+			redacted, ok := r.form(body)
+			if !ok {
+				return "[invalid form body, " + strconv.Itoa(len(body)) + " bytes]", false
+			}
+			body = redacted
+		}
+	case !utf8.Valid(body):
+		return "[binary body, " + strconv.Itoa(len(body)) + " bytes]", false
+	}
+
+	return truncate(body, maxSize)
+}
+
+// json redacts a JSON body
+func (r *redactor) json(body []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return nil, false
+	}
+	for _, path := range r.paths {
+		v = redact(v, path)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), true
+}
+
+// redact replaces the values at the path. A "*" matches any key or array
+// element, other arrays are traversed without consuming the path.
+func redact(v any, path []string) any {
+	if len(path) == 0 {
+		return Redacted
+	}
+
+	switch node := v.(type) {
+	case map[string]any:
+		if path[0] == "*" {
+			for key, child := range node {
+				node[key] = redact(child, path[1:])
+			}
+		} else if child, ok := node[path[0]]; ok {
+			node[path[0]] = redact(child, path[1:])
+		}
+	case []any:
+		rest := path
+		if path[0] == "*" {
+			rest = path[1:]
+		}
+		for i, child := range node {
+			node[i] = redact(child, rest)
+		}
+	}
+	return v
+}
+
+// form redacts the top level fields of a form body
+func (r *redactor) form(body []byte) ([]byte, bool) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, false
+	}
+	for _, path := range r.paths {
+		if len(path) != 1 {
+			continue
+		}
+		for key, vals := range values {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			for i := range vals {
+				vals[i] = Redacted
+			}
+		}
+	}
+	return []byte(values.Encode()), true
+}
+
+// truncate cuts the body to maxSize bytes without splitting a character
+func truncate(body []byte, maxSize int) (string, bool) {
+	if len(body) <= maxSize {
+		return string(body), false
+	}
+	end := maxSize
+	for end > 0 && !utf8.RuneStart(body[end]) {
+		end--
+	}
+	return string(body[:end]), true
+}
@@ -0,0 +1,216 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/khulnasoft/velocity/log"
+)
+
+// ErrFlushTimeout is returned when the buffered records could not be
+// passed to the sink within the ShutdownTimeout.
+var ErrFlushTimeout = errors.New("audit: flushing the records timed out")
+
+// Sink receives the audit records. Write is only called by one goroutine
+// at a time and must not retain the slice.
+type Sink interface {
+	Write(records []Record) error
+}
+
+// SinkFunc is an adapter to use a function as Sink.
+type SinkFunc func(records []Record) error
+
+// Write calls f(records).
+func (f SinkFunc) Write(records []Record) error {
+	return f(records)
+}
+
+// writerSink writes the records as JSON lines
+type writerSink struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriterSink returns a sink writing one JSON object per record and line,
+// e.g. to a file or a log.RotatingFile.
+func NewWriterSink(w io.Writer) Sink {
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) Write(records []Record) error {
+	s.buf = s.buf[:0]
+	for i := range records {
+		line, err := json.Marshal(&records[i])
+		if err != nil {
+			return fmt.Errorf("audit: failed to encode record: %w", err)
+		}
+		s.buf = append(s.buf, line...)
+		s.buf = append(s.buf, '\n')
+	}
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+// shipper buffers the records and passes them to the sink in batches
+type shipper struct {
+	sink    Sink
+	onDrop  func(Record)
+	queue   chan Record
+	flushes chan chan error
+	batch   []Record
+
+	interval     time.Duration
+	blockTimeout time.Duration
+	policy       Backpressure
+	batchSize    int
+	dropped      atomic.Uint64
+}
+
+func newShipper(cfg Config) *shipper {
+	s := &shipper{
+		sink:         cfg.Sink,
+		onDrop:       cfg.OnDrop,
+		queue:        make(chan Record, cfg.BufferSize),
+		flushes:      make(chan chan error),
+		batch:        make([]Record, 0, cfg.BatchSize),
+		interval:     cfg.FlushInterval,
+		blockTimeout: cfg.BlockTimeout,
+		policy:       cfg.Backpressure,
+		batchSize:    cfg.BatchSize,
+	}
+	go s.run()
+	return s
+}
+
+// enqueue buffers the record according to the backpressure policy.
+// A blocked record is dropped after the BlockTimeout or once ctx is done.
+func (s *shipper) enqueue(ctx context.Context, record Record) {
+	switch s.policy {
+	case BackpressureDropNewest:
+		select {
+		case s.queue <- record:
+		default:
+			s.drop(record)
+		}
+	case BackpressureDropOldest:
+		for {
+			select {
+			case s.queue <- record:
+				return
+			default:
+			}
+			select {
+			case oldest := <-s.queue:
+				s.drop(oldest)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.queue <- record:
+			return
+		default:
+		}
+		timer := time.NewTimer(s.blockTimeout)
+		defer timer.Stop()
+		select {
+		case s.queue <- record:
+		case <-timer.C:
+			s.drop(record)
+		case <-ctx.Done():
+			s.drop(record)
+		}
+	}
+}
+
+func (s *shipper) drop(record Record) {
+	s.dropped.Add(1)
+	if s.onDrop != nil {
+		s.onDrop(record)
+	}
+}
+
+// flush passes all buffered records to the sink and waits for it
+func (s *shipper) flush(timeout time.Duration) error {
+	done := make(chan error, 1)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case s.flushes <- done:
+	case <-timer.C:
+		return ErrFlushTimeout
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrFlushTimeout
+	}
+}
+
+func (s *shipper) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case record := <-s.queue:
+			s.add(record)
+		case <-ticker.C:
+			if err := s.write(); err != nil {
+				log.Errorf("audit: failed to write records: %v", err)
+			}
+			if dropped := s.dropped.Swap(0); dropped > 0 {
+				log.Warnf("audit: dropped %d records, the buffer was full", dropped)
+			}
+		case done := <-s.flushes:
+			done <- s.drain()
+		}
+	}
+}
+
+// add adds the record to the batch and writes full batches
+func (s *shipper) add(record Record) {
+	s.batch = append(s.batch, record)
+	if len(s.batch) < s.batchSize {
+		return
+	}
+	if err := s.write(); err != nil {
+		log.Errorf("audit: failed to write records: %v", err)
+	}
+}
+
+// drain writes the batch and all records in the buffer
+func (s *shipper) drain() error {
+	var errs []error
+	for {
+		select {
+		case record := <-s.queue:
+			s.batch = append(s.batch, record)
+			if len(s.batch) >= s.batchSize {
+				errs = append(errs, s.write())
+			}
+		default:
+			errs = append(errs, s.write())
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// write passes the batch to the sink. Failed batches are dropped,
+// so a failing sink doesn't block the buffer.
+func (s *shipper) write() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	err := s.sink.Write(s.batch)
+	clear(s.batch)
+	s.batch = s.batch[:0]
+	return err
+}
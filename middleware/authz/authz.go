@@ -0,0 +1,100 @@
+// Package authz provides role and attribute-based authorization of the routes.
+package authz
+
+import (
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/basicauth"
+	"github.com/khulnasoft/velocity/middleware/keyauth"
+)
+
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey int
+
+// The keys for the values in context
+const (
+	principalKey contextKey = iota
+)
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Requests without a route are denied like routes without a policy,
+		// handlers mounted with Use are authorized by their prefix
+		principal := cfg.Principal(c)
+		route, values := c.ResolveRoute()
+		if route == nil {
+			return cfg.Denied(c, Decision{Reason: ReasonNoPolicy, Authenticated: principal != nil})
+		}
+
+		in := &Input{
+			Principal: principal,
+			Method:    route.Method,
+			Name:      route.Name,
+			Route:     route.Path,
+		}
+		if len(route.Params) > 0 {
+			in.Params = make(map[string]string, len(route.Params))
+			for i, param := range route.Params {
+				in.Params[param] = values[i]
+			}
+		}
+		if cfg.Attributes != nil {
+			in.Attributes = cfg.Attributes(c, in)
+		}
+
+		decision := cfg.Authorizer.decide(cfg.Authorizer.routePolicies(c.App(), route), in)
+		if !decision.Allowed {
+			return cfg.Denied(c, decision)
+		}
+
+		if in.Principal != nil {
+			c.Locals(principalKey, in.Principal)
+		}
+		return c.Next()
+	}
+}
+
+// SetPrincipal sets the principal of the request for the middleware, e.g.
+// in a custom authentication middleware registered before it.
+func SetPrincipal(c velocity.Ctx, principal *Principal) {
+	c.Locals(principalKey, principal)
+}
+
+// PrincipalFromContext returns the principal of the request authorized by the middleware.
+// returns nil if there is none
+func PrincipalFromContext(c velocity.Ctx) *Principal {
+	principal, ok := c.Locals(principalKey).(*Principal)
+	if !ok {
+		return nil
+	}
+	return principal
+}
+
+// defaultPrincipal returns the principal set with SetPrincipal or
+// authenticated by the keyauth or basicauth middleware
+func defaultPrincipal(c velocity.Ctx) *Principal {
+	if principal := PrincipalFromContext(c); principal != nil {
+		return principal
+	}
+	if key := keyauth.KeyFromContext(c); key != nil {
+		id := key.Owner
+		if id == "" {
+			id = key.ID
+		}
+		return &Principal{ID: id, Permissions: key.Scopes}
+	}
+	if username := basicauth.UsernameFromContext(c); username != "" {
+		return &Principal{ID: username}
+	}
+	return nil
+}
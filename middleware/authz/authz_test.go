@@ -0,0 +1,387 @@
+package authz
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/keyauth"
+	"github.com/khulnasoft/velocity/middleware/static"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// testPrincipals are authenticated by the X-User header
+var testPrincipals = map[string]*Principal{
+	"alice": {ID: "alice", Roles: []string{"admin"}},
+	"bob":   {ID: "bob", Roles: []string{"editor"}},
+	"carol": {ID: "carol", Permissions: []string{"posts:read"}},
+}
+
+// testAuthorizer attaches policies to a group by name and to routes by path
+func testAuthorizer() *Authorizer {
+	return NewAuthorizer(
+		map[string][]string{
+			"admin":  {"*"},
+			"editor": {"posts:*"},
+		},
+		Rule{Path: "/", Policy: Policy{Name: "home", Public: true}},
+		Rule{Name: "admin.*", Policy: Policy{Name: "admins", Roles: []string{"admin"}}},
+		Rule{Path: "/posts", Methods: []string{"get"}, Policy: Policy{Permissions: []string{"posts:read"}}},
+		Rule{Path: "/posts/:id", Methods: []string{velocity.MethodDelete}, Policy: Policy{Permissions: []string{"posts:delete"}}},
+		Rule{Path: "/users/:id", Policy: Policy{
+			Name: "self",
+			Condition: func(in *Input) bool {
+				return in.Principal.ID == in.Params["id"] || in.Principal.HasRole("admin")
+			},
+		}},
+	)
+}
+
+// testApp authenticates by the X-User header and authorizes by the authorizer
+func testApp(az *Authorizer) *velocity.App {
+	app := velocity.New()
+	app.Use(func(c velocity.Ctx) error {
+		if principal, ok := testPrincipals[c.Get("X-User")]; ok {
+			SetPrincipal(c, principal)
+		}
+		return c.Next()
+	})
+	app.Use(New(Config{Authorizer: az}))
+
+	ok := func(c velocity.Ctx) error {
+		return c.SendString(c.Route().Path)
+	}
+	app.Get("/", ok)
+	app.Get("/posts", ok)
+	app.Post("/posts", ok)
+	app.Delete("/posts/:id", ok)
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		return c.SendString(PrincipalFromContext(c).ID)
+	})
+	admin := app.Group("/admin").Name("admin.")
+	admin.Get("/stats", ok).Name("stats")
+	return app
+}
+
+// status returns the status code of the request of the user
+func status(t *testing.T, app *velocity.App, method, path, user string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, nil)
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp.StatusCode
+}
+
+// go test -run Test_Authz
+func Test_Authz(t *testing.T) {
+	t.Parallel()
+
+	app := testApp(testAuthorizer())
+
+	for _, tc := range []struct {
+		method, path, user string
+		status             int
+	}{
+		// Public routes
+		{velocity.MethodGet, "/", "", velocity.StatusOK},
+		{velocity.MethodGet, "/", "carol", velocity.StatusOK},
+		// Permissions granted directly and by roles, with wildcards
+		{velocity.MethodGet, "/posts", "", velocity.StatusUnauthorized},
+		{velocity.MethodGet, "/posts", "carol", velocity.StatusOK},
+		{velocity.MethodGet, "/posts", "bob", velocity.StatusOK},
+		{velocity.MethodGet, "/posts", "alice", velocity.StatusOK},
+		{velocity.MethodDelete, "/posts/1", "carol", velocity.StatusForbidden},
+		{velocity.MethodDelete, "/posts/1", "bob", velocity.StatusOK},
+		// Routes without a policy are denied by default
+		{velocity.MethodPost, "/posts", "alice", velocity.StatusForbidden},
+		{velocity.MethodPost, "/posts", "", velocity.StatusUnauthorized},
+		// Groups by the route name
+		{velocity.MethodGet, "/admin/stats", "bob", velocity.StatusForbidden},
+		{velocity.MethodGet, "/admin/stats", "alice", velocity.StatusOK},
+		// Conditions on the route params
+		{velocity.MethodGet, "/users/bob", "bob", velocity.StatusOK},
+		{velocity.MethodGet, "/users/bob", "carol", velocity.StatusForbidden},
+		{velocity.MethodGet, "/users/bob", "alice", velocity.StatusOK},
+		// Requests without a route are denied as well
+		{velocity.MethodGet, "/missing", "", velocity.StatusUnauthorized},
+		{velocity.MethodGet, "/missing", "alice", velocity.StatusForbidden},
+		{velocity.MethodPut, "/posts", "", velocity.StatusUnauthorized},
+	} {
+		require.Equal(t, tc.status, status(t, app, tc.method, tc.path, tc.user), "%s %s as %q", tc.method, tc.path, tc.user)
+	}
+}
+
+// go test -run Test_Authz_PrincipalFromContext
+func Test_Authz_PrincipalFromContext(t *testing.T) {
+	t.Parallel()
+
+	app := testApp(testAuthorizer())
+
+	req := httptest.NewRequest(velocity.MethodGet, "/users/bob", nil)
+	req.Header.Set("X-User", "bob")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "bob", string(body))
+}
+
+// go test -run Test_Authz_Keyauth
+func Test_Authz_Keyauth(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(keyauth.New(keyauth.Config{
+		Registry: keyauth.NewMemoryRegistry(&keyauth.Key{Hash: keyauth.HashKey("secret"), Owner: "ci-bot", Scopes: []string{"posts:read"}}),
+	}))
+	app.Use(New(Config{
+		Authorizer: NewAuthorizer(nil,
+			Rule{Path: "/posts", Methods: []string{velocity.MethodGet}, Policy: Policy{Permissions: []string{"posts:read"}}},
+			Rule{Path: "/posts", Methods: []string{velocity.MethodPost}, Policy: Policy{Permissions: []string{"posts:write"}}},
+		),
+	}))
+	app.Get("/posts", func(c velocity.Ctx) error {
+		return c.SendString(PrincipalFromContext(c).ID)
+	})
+	app.Post("/posts", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusCreated)
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/posts", nil)
+	req.Header.Set(velocity.HeaderAuthorization, "Bearer secret")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "ci-bot", string(body))
+
+	req = httptest.NewRequest(velocity.MethodPost, "/posts", nil)
+	req.Header.Set(velocity.HeaderAuthorization, "Bearer secret")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusForbidden, resp.StatusCode)
+}
+
+// go test -run Test_Authz_Attributes
+func Test_Authz_Attributes(t *testing.T) {
+	t.Parallel()
+
+	authors := map[string]string{"1": "bob", "2": "carol"}
+	app := velocity.New()
+	app.Use(func(c velocity.Ctx) error {
+		SetPrincipal(c, testPrincipals[c.Get("X-User")])
+		return c.Next()
+	})
+	app.Use(New(Config{
+		Authorizer: NewAuthorizer(nil, Rule{Path: "/posts/:id", Policy: Policy{
+			Condition: func(in *Input) bool {
+				return in.Attributes["author"] == in.Principal.ID
+			},
+		}}),
+		Attributes: func(_ velocity.Ctx, in *Input) map[string]any {
+			return map[string]any{"author": authors[in.Params["id"]]}
+		},
+	}))
+	app.Put("/posts/:id", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusNoContent)
+	})
+
+	require.Equal(t, velocity.StatusNoContent, status(t, app, velocity.MethodPut, "/posts/1", "bob"))
+	require.Equal(t, velocity.StatusForbidden, status(t, app, velocity.MethodPut, "/posts/2", "bob"))
+	require.Equal(t, velocity.StatusNoContent, status(t, app, velocity.MethodPut, "/posts/2", "carol"))
+}
+
+// go test -run Test_Authz_Denied
+func Test_Authz_Denied(t *testing.T) {
+	t.Parallel()
+
+	var decision Decision
+	app := velocity.New()
+	app.Use(New(Config{
+		Authorizer: NewAuthorizer(nil, Rule{Path: "/", Policy: Policy{Roles: []string{"admin"}}}),
+		Principal: func(velocity.Ctx) *Principal {
+			return &Principal{ID: "bob"}
+		},
+		Denied: func(c velocity.Ctx, d Decision) error {
+			decision = d
+			return c.Status(velocity.StatusNotFound).SendString("Not Found")
+		},
+	}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("admin")
+	})
+
+	require.Equal(t, velocity.StatusNotFound, status(t, app, velocity.MethodGet, "/", ""))
+	require.Equal(t, Decision{Policy: "/", Reason: `requires one of the roles ["admin"]`, Authenticated: true}, decision)
+}
+
+// go test -run Test_Authz_Next
+func Test_Authz_Next(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{
+		Authorizer: NewAuthorizer(nil),
+		Next: func(c velocity.Ctx) bool {
+			return c.Path() == "/health"
+		},
+	}))
+	app.Get("/health", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusNoContent)
+	})
+
+	require.Equal(t, velocity.StatusNoContent, status(t, app, velocity.MethodGet, "/health", ""))
+}
+
+// go test -run Test_Authorizer_Decide
+func Test_Authorizer_Decide(t *testing.T) {
+	t.Parallel()
+
+	az := testAuthorizer()
+
+	decision := az.Decide(&Input{Method: velocity.MethodDelete, Route: "/posts/:id", Principal: testPrincipals["carol"]})
+	require.Equal(t, Decision{Policy: "/posts/:id", Reason: `requires the permission "posts:delete"`, Authenticated: true}, decision)
+
+	decision = az.Decide(&Input{Method: velocity.MethodDelete, Route: "/posts/:id", Principal: testPrincipals["bob"]})
+	require.Equal(t, Decision{Allowed: true, Authenticated: true}, decision)
+
+	decision = az.Decide(&Input{Method: velocity.MethodGet, Name: "admin.stats", Route: "/admin/stats"})
+	require.Equal(t, Decision{Policy: "admins", Reason: ReasonUnauthenticated}, decision)
+
+	decision = az.Decide(&Input{Method: velocity.MethodGet, Route: "/users/:id", Params: map[string]string{"id": "bob"}, Principal: testPrincipals["carol"]})
+	require.Equal(t, Decision{Policy: "self", Reason: "condition not met", Authenticated: true}, decision)
+
+	decision = az.Decide(&Input{Method: velocity.MethodPatch, Route: "/posts/:id", Principal: testPrincipals["alice"]})
+	require.Equal(t, Decision{Reason: ReasonNoPolicy, Authenticated: true}, decision)
+
+	require.True(t, az.Can(testPrincipals["alice"], "users:delete"))
+	require.True(t, az.Can(testPrincipals["bob"], "posts:delete"))
+	require.False(t, az.Can(testPrincipals["bob"], "postsdelete"))
+	require.False(t, az.Can(testPrincipals["carol"], "posts:write"))
+	require.False(t, az.Can(nil, "posts:read"))
+}
+
+// go test -run Test_Authorizer_AllRules
+func Test_Authorizer_AllRules(t *testing.T) {
+	t.Parallel()
+
+	// A public rule of a route does not relax the rule of its group
+	az := NewAuthorizer(nil,
+		Rule{Path: "/admin/*", Policy: Policy{Roles: []string{"admin"}}},
+		Rule{Path: "/admin/login", Policy: Policy{Public: true}},
+	)
+	require.Equal(t, ReasonUnauthenticated, az.Decide(&Input{Method: velocity.MethodGet, Route: "/admin/login"}).Reason)
+	require.True(t, az.Decide(&Input{Method: velocity.MethodGet, Route: "/admin/login", Principal: testPrincipals["alice"]}).Allowed)
+}
+
+// go test -run Test_Authorizer_Routes
+func Test_Authorizer_Routes(t *testing.T) {
+	t.Parallel()
+
+	az := testAuthorizer()
+	app := testApp(az)
+
+	routes := make(map[string]RoutePolicies)
+	mounts := make(map[string]RoutePolicies)
+	for _, route := range az.Routes(app) {
+		if route.Use {
+			mounts[route.Method+" "+route.Path] = route
+			continue
+		}
+		routes[route.Method+" "+route.Path] = route
+	}
+
+	require.Len(t, routes, 6)
+	// The middlewares mounted on "/" are listed once per method
+	require.Len(t, mounts, len(app.Config().RequestMethods))
+	require.True(t, mounts["GET /"].Policies[0].Public)
+	require.Equal(t, []string{"posts:read"}, routes["GET /posts"].Permissions)
+	require.Equal(t, []string{"posts:delete"}, routes["DELETE /posts/:id"].Permissions)
+	require.True(t, routes["POST /posts"].Denied)
+	require.True(t, routes["GET /"].Policies[0].Public)
+	require.Equal(t, "admin.stats", routes["GET /admin/stats"].Name)
+	require.Equal(t, "admins", routes["GET /admin/stats"].Policies[0].Name)
+	require.Equal(t, "self", routes["GET /users/:id"].Policies[0].Name)
+	require.False(t, routes["GET /users/:id"].Denied)
+}
+
+// go test -run Test_Authz_Use
+func Test_Authz_Use(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "logo.txt"), []byte("logo"), 0o600))
+
+	app := velocity.New()
+	app.Use(func(c velocity.Ctx) error {
+		if principal, ok := testPrincipals[c.Get("X-User")]; ok {
+			SetPrincipal(c, principal)
+		}
+		return c.Next()
+	})
+	app.Use(New(Config{Authorizer: NewAuthorizer(
+		map[string][]string{"admin": {"*"}},
+		Rule{Path: "/public", Policy: Policy{Public: true}},
+		Rule{Path: "/private", Policy: Policy{Roles: []string{"admin"}}},
+	)}))
+	app.Use("/public", static.New(dir))
+	app.Use("/private", static.New(dir))
+	app.Use("/internal", static.New(dir))
+
+	// Handlers mounted with Use are authorized by their prefix
+	require.Equal(t, velocity.StatusOK, status(t, app, velocity.MethodGet, "/public/logo.txt", ""))
+	require.Equal(t, velocity.StatusUnauthorized, status(t, app, velocity.MethodGet, "/private/secret.txt", ""))
+	require.Equal(t, velocity.StatusForbidden, status(t, app, velocity.MethodGet, "/private/secret.txt", "bob"))
+	require.Equal(t, velocity.StatusOK, status(t, app, velocity.MethodGet, "/private/secret.txt", "alice"))
+	// and denied without a policy
+	require.Equal(t, velocity.StatusForbidden, status(t, app, velocity.MethodGet, "/internal/secret.txt", "alice"))
+
+	routes := make(map[string]RoutePolicies)
+	for _, route := range NewAuthorizer(nil).Routes(app) {
+		routes[route.Method+" "+route.Path] = route
+	}
+	require.True(t, routes["GET /internal"].Use)
+	require.True(t, routes["GET /internal"].Denied)
+}
+
+// go test -run Test_Authz_Panics
+func Test_Authz_Panics(t *testing.T) {
+	t.Parallel()
+
+	require.PanicsWithValue(t, "velocity: authz middleware requires an Authorizer", func() {
+		New()
+	})
+	require.PanicsWithValue(t, "velocity: authz middleware requires an Authorizer", func() {
+		New(Config{})
+	})
+	require.PanicsWithValue(t, "authz: rule 0 requires a Name or Path", func() {
+		NewAuthorizer(nil, Rule{Policy: Policy{Public: true}})
+	})
+}
+
+// go test -v -run=^$ -bench=Benchmark_Authz -benchmem -count=4
+func Benchmark_Authz(b *testing.B) {
+	app := testApp(testAuthorizer())
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod(velocity.MethodGet)
+	fctx.Request.SetRequestURI("/users/bob")
+	fctx.Request.Header.Set("X-User", "bob")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		h(fctx)
+	}
+}
@@ -0,0 +1,77 @@
+package authz
+
+import (
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// Authorizer decides whether the principal may access the route.
+	//
+	// Required.
+	Authorizer *Authorizer
+
+	// Principal returns the principal of the request, nil if unauthenticated.
+	// The authentication middlewares need to be registered before this middleware.
+	//
+	// Optional. Default: the principal set with SetPrincipal, the owner and
+	// scopes of the key of keyauth or the username of basicauth, in that order
+	Principal func(c velocity.Ctx) *Principal
+
+	// Attributes returns the attributes of the requested resource for the
+	// conditions of the policies. The request is not routed yet, so the
+	// route params are only available in the input.
+	//
+	// Optional. Default: nil
+	Attributes func(c velocity.Ctx, in *Input) map[string]any
+
+	// Denied is called with the decision when a request is denied.
+	//
+	// Optional. Default: returns velocity.ErrUnauthorized for unauthenticated
+	// requests and velocity.ErrForbidden otherwise
+	Denied func(c velocity.Ctx, decision Decision) error
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:      nil,
+	Principal: defaultPrincipal,
+	Denied:    defaultDenied,
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		panic("velocity: authz middleware requires an Authorizer")
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Authorizer == nil {
+		panic("velocity: authz middleware requires an Authorizer")
+	}
+	if cfg.Principal == nil {
+		cfg.Principal = ConfigDefault.Principal
+	}
+	if cfg.Denied == nil {
+		cfg.Denied = ConfigDefault.Denied
+	}
+
+	return cfg
+}
+
+// defaultDenied distinguishes unauthenticated from forbidden requests
+func defaultDenied(_ velocity.Ctx, decision Decision) error {
+	if !decision.Authenticated {
+		return velocity.ErrUnauthorized
+	}
+	return velocity.ErrForbidden
+}
@@ -0,0 +1,298 @@
+package authz
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// Reasons of the decisions without a denying policy
+const (
+	ReasonNoPolicy        = "no policy"
+	ReasonUnauthenticated = "unauthenticated"
+)
+
+// Principal is the subject of an authorization decision.
+type Principal struct {
+	// Attributes are the attributes of the principal for the conditions
+	// of the policies, e.g. the tenant or the department.
+	Attributes map[string]any
+	// ID identifies the principal.
+	ID string
+	// Roles are the roles of the principal, which grant the permissions
+	// of the roles of the Authorizer.
+	Roles []string
+	// Permissions are granted to the principal directly, e.g. the scopes of an API key.
+	Permissions []string
+}
+
+// HasRole reports whether the principal has the role.
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// Input is the input of an authorization decision. It does not depend on
+// the HTTP request, so decisions can be tested and made outside of handlers.
+type Input struct {
+	// Principal is the subject of the decision, nil if unauthenticated.
+	Principal *Principal
+	// Params are the route parameters of the request, e.g. "id".
+	Params map[string]string
+	// Attributes are the attributes of the requested resource, see Config.Attributes.
+	Attributes map[string]any
+	// Method is the HTTP method of the route.
+	Method string
+	// Name is the name of the route.
+	Name string
+	// Route is the registered path of the route, e.g. "/users/:id".
+	Route string
+}
+
+// Policy defines the requirements of the routes of a Rule.
+type Policy struct {
+	// Condition is an attribute-based check of the input, e.g. whether the
+	// principal owns the requested resource.
+	Condition func(in *Input) bool `json:"-"`
+	// Name identifies the policy in the decisions and the introspection.
+	// Default: the Name or Path of the rule
+	Name string `json:"name"`
+	// Roles are the roles of which the principal needs at least one.
+	Roles []string `json:"roles,omitempty"`
+	// Permissions are the permissions the principal needs all of.
+	Permissions []string `json:"permissions,omitempty"`
+	// Public allows the requests of everyone, including unauthenticated ones.
+	Public bool `json:"public,omitempty"`
+}
+
+// Rule attaches a policy to the routes matching its Name or Path.
+// Rules match groups by the prefix of the route names or by a wildcard path.
+type Rule struct {
+	// Policy is the policy of the matched routes.
+	Policy Policy
+	// Name matches the route name. A trailing "*" matches the names by
+	// prefix, e.g. "admin.*" matches the routes of a group named "admin.".
+	Name string
+	// Path matches the registered route path with velocity.RoutePatternMatch,
+	// e.g. "/admin/*" or "/users/:id".
+	Path string
+	// Methods restricts the rule to the HTTP methods, empty matches all.
+	Methods []string
+}
+
+// matches reports whether the rule applies to the route
+func (r *Rule) matches(method, name, path string, cfg ...velocity.Config) bool {
+	if len(r.Methods) > 0 && !slices.Contains(r.Methods, utils.ToUpper(method)) {
+		return false
+	}
+	if r.Name != "" {
+		prefix, ok := strings.CutSuffix(r.Name, "*")
+		if (!ok && name != r.Name) || (ok && !strings.HasPrefix(name, prefix)) {
+			return false
+		}
+	}
+	return r.Path == "" || velocity.RoutePatternMatch(path, r.Path, cfg...)
+}
+
+// Decision is the result of an authorization decision.
+type Decision struct {
+	// Policy is the name of the policy which denied the request.
+	Policy string
+	// Reason explains why the request was denied.
+	Reason string
+	// Allowed reports whether the request is allowed.
+	Allowed bool
+	// Authenticated reports whether the input had a principal.
+	Authenticated bool
+}
+
+// RoutePolicies lists the policies of a route, see Authorizer.Routes.
+type RoutePolicies struct {
+	Method   string   `json:"method"`
+	Name     string   `json:"name"`
+	Path     string   `json:"path"`
+	Policies []Policy `json:"policies"`
+	// Permissions are the permissions required by all policies together.
+	Permissions []string `json:"permissions"`
+	// Use reports whether the route is mounted with Use and matches the path prefix.
+	Use bool `json:"use"`
+	// Denied reports whether the route is denied to everyone, because no policy applies.
+	Denied bool `json:"denied"`
+}
+
+// Authorizer decides whether principals may access routes. Routes without
+// a matching rule are denied by default, and all matching rules must allow
+// a request, so a rule of a group cannot be relaxed by a rule of a route.
+type Authorizer struct {
+	roles map[string][]string
+	rules []Rule
+	// routes caches the policies of the routes of the apps
+	routes sync.Map
+}
+
+// NewAuthorizer creates an authorizer of the roles, which map the role names
+// to their permissions, and the rules. A granted permission ending with "*"
+// grants all permissions with its prefix, e.g. "users:*" or "*".
+func NewAuthorizer(roles map[string][]string, rules ...Rule) *Authorizer {
+	a := &Authorizer{roles: roles, rules: make([]Rule, len(rules))}
+	for i, rule := range rules {
+		if rule.Name == "" && rule.Path == "" {
+			panic(fmt.Sprintf("authz: rule %d requires a Name or Path", i))
+		}
+		methods := make([]string, len(rule.Methods))
+		for j, method := range rule.Methods {
+			methods[j] = utils.ToUpper(method)
+		}
+		rule.Methods = methods
+		if rule.Policy.Name == "" {
+			rule.Policy.Name = rule.Name
+			if rule.Policy.Name == "" {
+				rule.Policy.Name = rule.Path
+			}
+		}
+		a.rules[i] = rule
+	}
+	return a
+}
+
+// Can reports whether the principal was granted the permission, directly
+// or by one of its roles.
+func (a *Authorizer) Can(principal *Principal, permission string) bool {
+	if principal == nil {
+		return false
+	}
+	for _, granted := range principal.Permissions {
+		if grants(granted, permission) {
+			return true
+		}
+	}
+	for _, role := range principal.Roles {
+		for _, granted := range a.roles[role] {
+			if grants(granted, permission) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// grants reports whether the granted permission includes the required one
+func grants(granted, required string) bool {
+	if prefix, ok := strings.CutSuffix(granted, "*"); ok {
+		return strings.HasPrefix(required, prefix)
+	}
+	return granted == required
+}
+
+// Policies returns the policies of the rules matching the route.
+func (a *Authorizer) Policies(method, name, path string) []Policy {
+	return a.match(method, name, path)
+}
+
+// match returns the policies of the rules matching the route
+func (a *Authorizer) match(method, name, path string, cfg ...velocity.Config) []Policy {
+	var policies []Policy
+	for i := range a.rules {
+		if a.rules[i].matches(method, name, path, cfg...) {
+			policies = append(policies, a.rules[i].Policy)
+		}
+	}
+	return policies
+}
+
+// Decide decides whether the principal of the input may access the route of the input.
+func (a *Authorizer) Decide(in *Input) Decision {
+	return a.decide(a.Policies(in.Method, in.Name, in.Route), in)
+}
+
+// decide evaluates the policies of a route, which all need to allow the input
+func (a *Authorizer) decide(policies []Policy, in *Input) Decision {
+	decision := Decision{Authenticated: in.Principal != nil}
+	if len(policies) == 0 {
+		decision.Reason = ReasonNoPolicy
+		return decision
+	}
+
+	for i := range policies {
+		policy := &policies[i]
+		if policy.Public {
+			continue
+		}
+		decision.Policy = policy.Name
+		if in.Principal == nil {
+			decision.Reason = ReasonUnauthenticated
+			return decision
+		}
+		if len(policy.Roles) > 0 && !slices.ContainsFunc(policy.Roles, in.Principal.HasRole) {
+			decision.Reason = fmt.Sprintf("requires one of the roles %q", policy.Roles)
+			return decision
+		}
+		for _, permission := range policy.Permissions {
+			if !a.Can(in.Principal, permission) {
+				decision.Reason = fmt.Sprintf("requires the permission %q", permission)
+				return decision
+			}
+		}
+		if policy.Condition != nil && !policy.Condition(in) {
+			decision.Reason = "condition not met"
+			return decision
+		}
+	}
+
+	decision.Policy = ""
+	decision.Allowed = true
+	return decision
+}
+
+// routePolicies returns the policies of the route of the app, cached by the route
+func (a *Authorizer) routePolicies(app *velocity.App, route *velocity.Route) []Policy {
+	if policies, ok := a.routes.Load(route); ok {
+		return policies.([]Policy) //nolint:forcetypeassert,errcheck // Only stored below
+	}
+
+	policies := a.match(route.Method, route.Name, route.Path, app.Config())
+	a.routes.Store(route, policies)
+	return policies
+}
+
+// Routes lists the routes of the app with their policies and the permissions
+// they require, e.g. to review them or to serve them to an admin UI. The
+// handlers mounted with Use, like static file servers, are listed by their
+// prefix, as they are authorized by it.
+func (a *Authorizer) Routes(app *velocity.App) []RoutePolicies {
+	routes := app.GetRoutes()
+	list := make([]RoutePolicies, 0, len(routes))
+	seen := make(map[string]struct{}, len(routes))
+	for _, route := range routes {
+		entry := RoutePolicies{
+			Method:   route.Method,
+			Name:     route.Name,
+			Path:     route.Path,
+			Policies: a.match(route.Method, route.Name, route.Path, app.Config()),
+			Use:      route.IsUse(),
+		}
+		if entry.Use {
+			// Middlewares mounted on the same prefix are listed once
+			key := entry.Method + " " + entry.Path
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+		}
+		entry.Denied = len(entry.Policies) == 0
+		for _, policy := range entry.Policies {
+			for _, permission := range policy.Permissions {
+				if !slices.Contains(entry.Permissions, permission) {
+					entry.Permissions = append(entry.Permissions, permission)
+				}
+			}
+		}
+		sort.Strings(entry.Permissions)
+		list = append(list, entry)
+	}
+	return list
+}
@@ -23,6 +23,14 @@ func New(config Config) velocity.Handler {
 	// Set default config
 	cfg := configDefault(config)
 
+	creds := newCredentials(&cfg)
+	if cfg.Authorizer == nil {
+		cfg.Authorizer = creds.authorize
+	}
+	if cfg.Digest {
+		return newDigestHandler(&cfg, newDigestAuth(&cfg, creds))
+	}
+
 	// Return new handler
 	return func(c velocity.Ctx) error {
 		// Don't execute middleware if Next returns true
@@ -69,6 +77,34 @@ func New(config Config) velocity.Handler {
 	}
 }
 
+// newDigestHandler creates the handler of the Digest authentication
+func newDigestHandler(cfg *Config, digest *digestAuth) velocity.Handler {
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Check if the header contains content besides "digest".
+		auth := c.Get(velocity.HeaderAuthorization)
+		stale := false
+		if len(auth) > 7 && utils.EqualFold(auth[:7], "digest ") {
+			var username string
+			var ok bool
+			if username, ok, stale = digest.authenticate(c, auth[7:]); ok {
+				c.Locals(usernameKey, utils.CopyString(username))
+				return c.Next()
+			}
+		}
+
+		// Authentication failed, challenge the client with a new nonce
+		if err := digest.challenge(c, stale); err != nil {
+			return err
+		}
+		return cfg.Unauthorized(c)
+	}
+}
+
 // UsernameFromContext returns the username found in the context
 // returns an empty string if the username does not exist
 func UsernameFromContext(c velocity.Ctx) string {
@@ -80,7 +116,8 @@ func UsernameFromContext(c velocity.Ctx) string {
 }
 
 // PasswordFromContext returns the password found in the context
-// returns an empty string if the password does not exist
+// returns an empty string if the password does not exist,
+// which is always the case for the Digest authentication
 func PasswordFromContext(c velocity.Ctx) string {
 	password, ok := c.Locals(passwordKey).(string)
 	if !ok {
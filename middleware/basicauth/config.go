@@ -1,10 +1,10 @@
 package basicauth
 
 import (
-	"crypto/subtle"
+	"time"
 
 	"github.com/khulnasoft/velocity"
-	"github.com/khulnasoft/velocity/utils"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
 )
 
 // Config defines the config for middleware.
@@ -14,9 +14,11 @@ type Config struct {
 	// Optional. Default: nil
 	Next func(c velocity.Ctx) bool
 
-	// Users defines the allowed credentials
+	// Users defines the allowed credentials. The passwords may be plaintext
+	// or hashes, which are detected from their prefix: bcrypt ($2a$, $2b$,
+	// $2y$), argon2id ($argon2id$), scrypt ($scrypt$) and SHA-256-crypt ($5$).
 	//
-	// Required. Default: map[string]string{}
+	// Required if HtpasswdFile is empty. Default: map[string]string{}
 	Users map[string]string
 
 	// Authorizer defines a function you can pass
@@ -34,23 +36,77 @@ type Config struct {
 	// Optional. Default: nil
 	Unauthorized velocity.Handler
 
+	// Storage stores the nonce counts of the Digest authentication, which
+	// are written only for verified Digest responses.
+	//
+	// Optional. Default: memory.New()
+	Storage velocity.Storage
+
 	// Realm is a string to define realm attribute of BasicAuth.
 	// the realm identifies the system to authenticate against
 	// and can be used by clients to save credentials
 	//
 	// Optional. Default: "Restricted".
 	Realm string
+
+	// HtpasswdFile is the path of an htpasswd file with additional
+	// credentials, one "username:password" line per user, supporting the
+	// same hashes as Users. The Users take precedence over the file.
+	// The file is reloaded when it changes.
+	//
+	// Optional. Default: ""
+	HtpasswdFile string
+
+	// DigestAlgorithm is the hash algorithm of the Digest authentication, "SHA-256" or "MD5".
+	//
+	// Optional. Default: "SHA-256"
+	DigestAlgorithm string
+
+	// NonceSecret signs the nonces of the Digest authentication. Instances
+	// sharing the Storage need the same secret to accept each other's nonces.
+	//
+	// Optional. Default: 32 random bytes
+	NonceSecret []byte
+
+	// ReloadInterval is the minimum interval between two checks
+	// whether the HtpasswdFile changed.
+	//
+	// Optional. Default: 10 * time.Second
+	ReloadInterval time.Duration
+
+	// NonceExpiration is the lifetime of the nonces of the Digest authentication.
+	// Requests with an expired nonce are challenged with a new one.
+	//
+	// Optional. Default: 5 * time.Minute
+	NonceExpiration time.Duration
+
+	// Digest enables the Digest authentication of RFC 7616 instead of the
+	// Basic authentication. The server needs the plaintext passwords to
+	// verify Digest responses, so users with hashed passwords cannot
+	// authenticate, and the Authorizer is not used.
+	//
+	// Optional. Default: false
+	Digest bool
 }
 
 // ConfigDefault is the default config
 var ConfigDefault = Config{
-	Next:         nil,
-	Users:        map[string]string{},
-	Realm:        "Restricted",
-	Authorizer:   nil,
-	Unauthorized: nil,
+	Next:            nil,
+	Users:           map[string]string{},
+	Realm:           "Restricted",
+	Authorizer:      nil,
+	Unauthorized:    nil,
+	DigestAlgorithm: DigestSHA256,
+	ReloadInterval:  10 * time.Second,
+	NonceExpiration: 5 * time.Minute,
 }
 
+// Hash algorithms of the Digest authentication
+const (
+	DigestSHA256 = "SHA-256"
+	DigestMD5    = "MD5"
+)
+
 // Helper function to set default values
 func configDefault(config ...Config) Config {
 	// Return default config if nothing provided
@@ -71,16 +127,34 @@ func configDefault(config ...Config) Config {
 	if cfg.Realm == "" {
 		cfg.Realm = ConfigDefault.Realm
 	}
-	if cfg.Authorizer == nil {
-		cfg.Authorizer = func(user, pass string) bool {
-			userPwd, exist := cfg.Users[user]
-			return exist && subtle.ConstantTimeCompare(utils.UnsafeBytes(userPwd), utils.UnsafeBytes(pass)) == 1
-		}
+	if cfg.ReloadInterval <= 0 {
+		cfg.ReloadInterval = ConfigDefault.ReloadInterval
+	}
+	if cfg.NonceExpiration <= 0 {
+		cfg.NonceExpiration = ConfigDefault.NonceExpiration
+	}
+	if cfg.DigestAlgorithm == "" {
+		cfg.DigestAlgorithm = ConfigDefault.DigestAlgorithm
+	}
+	if cfg.DigestAlgorithm != DigestSHA256 && cfg.DigestAlgorithm != DigestMD5 {
+		panic("[basicauth] DigestAlgorithm must be SHA-256 or MD5")
+	}
+	if cfg.Digest && cfg.Storage == nil {
+		cfg.Storage = memory.New(memory.Config{
+			GCInterval: cfg.NonceExpiration,
+		})
 	}
 	if cfg.Unauthorized == nil {
-		cfg.Unauthorized = func(c velocity.Ctx) error {
-			c.Set(velocity.HeaderWWWAuthenticate, "basic realm="+cfg.Realm)
-			return c.SendStatus(velocity.StatusUnauthorized)
+		if cfg.Digest {
+			// The challenge is set by the middleware, as it requires a nonce
+			cfg.Unauthorized = func(c velocity.Ctx) error {
+				return c.SendStatus(velocity.StatusUnauthorized)
+			}
+		} else {
+			cfg.Unauthorized = func(c velocity.Ctx) error {
+				c.Set(velocity.HeaderWWWAuthenticate, "basic realm="+cfg.Realm)
+				return c.SendStatus(velocity.StatusUnauthorized)
+			}
 		}
 	}
 	return cfg
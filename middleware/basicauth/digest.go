@@ -0,0 +1,218 @@
+package basicauth
+
+import (
+	"crypto/hmac"
+	"crypto/md5" //nolint:gosec // MD5 is defined by RFC 7616 for compatibility
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// nonceKeyPrefix prefixes the storage keys of the Digest nonce counts
+const nonceKeyPrefix = "basicauth_nonce_"
+
+// The lengths of the parts of a nonce: the issue time, random bytes and the signature
+const (
+	nonceTimeLen      = 8
+	nonceRandomLen    = 8
+	nonceSignatureLen = 16
+)
+
+// digestAuth verifies the Digest authentication of RFC 7616 with qop "auth".
+// The nonces are stateless: they carry their issue time and are signed with
+// the NonceSecret, so unauthenticated requests never write to the storage.
+// The last nonce count of a nonce is stored once a response was verified,
+// so replayed requests are rejected.
+type digestAuth struct {
+	cfg     *Config
+	creds   *credentials
+	newHash func() hash.Hash
+	secret  []byte
+	mu      sync.Mutex
+}
+
+func newDigestAuth(cfg *Config, creds *credentials) *digestAuth {
+	d := &digestAuth{
+		cfg:     cfg,
+		creds:   creds,
+		newHash: sha256.New,
+		secret:  cfg.NonceSecret,
+	}
+	if cfg.DigestAlgorithm == DigestMD5 {
+		d.newHash = md5.New
+	}
+	if len(d.secret) == 0 {
+		d.secret = make([]byte, 32)
+		if _, err := rand.Read(d.secret); err != nil {
+			panic(err)
+		}
+	}
+	return d
+}
+
+// challenge sets the WWW-Authenticate header with a new nonce.
+// stale tells the client to retry with the new nonce without prompting the user.
+func (d *digestAuth) challenge(c velocity.Ctx, stale bool) error {
+	raw := make([]byte, nonceTimeLen+nonceRandomLen, nonceTimeLen+nonceRandomLen+nonceSignatureLen)
+	binary.BigEndian.PutUint64(raw, uint64(time.Now().UnixNano())) //nolint:gosec // Positive for the foreseeable future
+	if _, err := rand.Read(raw[nonceTimeLen:]); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(append(raw, d.sign(raw)...))
+
+	header := `Digest realm="` + quoteEscape(d.cfg.Realm) + `", qop="auth", algorithm=` + d.cfg.DigestAlgorithm + `, nonce="` + nonce + `"`
+	if stale {
+		header += ", stale=true"
+	}
+	c.Set(velocity.HeaderWWWAuthenticate, header)
+	return nil
+}
+
+// authenticate verifies the Digest credentials of the request.
+//
+// Returns:
+//   - string: The username, if the credentials are valid.
+//   - bool: Whether the credentials are valid.
+//   - bool: Whether the credentials are valid, but the nonce is invalid, expired or replayed.
+func (d *digestAuth) authenticate(c velocity.Ctx, auth string) (string, bool, bool) {
+	params, ok := parseDigestParams(auth)
+	if !ok {
+		return "", false, false
+	}
+
+	algorithm := params["algorithm"]
+	if algorithm == "" {
+		algorithm = DigestMD5 // RFC 7616, section 3.4.3
+	}
+	nc, err := strconv.ParseUint(params["nc"], 16, 32)
+	if err != nil || params["qop"] != "auth" || params["realm"] != d.cfg.Realm ||
+		!utils.EqualFold(algorithm, d.cfg.DigestAlgorithm) || params["nonce"] == "" || params["cnonce"] == "" ||
+		params["uri"] != c.OriginalURL() {
+		return "", false, false
+	}
+
+	username := params["username"]
+	cred, ok := d.creds.lookup(username)
+	if !ok || cred.hashed {
+		return "", false, false
+	}
+
+	ha1 := d.h(username + ":" + d.cfg.Realm + ":" + cred.plaintext)
+	ha2 := d.h(c.Method() + ":" + params["uri"])
+	expected := d.h(ha1 + ":" + params["nonce"] + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(params["response"]))) != 1 {
+		return "", false, false
+	}
+
+	lifetime, ok := d.verifyNonce(params["nonce"])
+	if !ok || !d.useNonce(params["nonce"], uint32(nc), lifetime) {
+		return "", false, true
+	}
+	return username, true, false
+}
+
+// verifyNonce reports whether the nonce was issued by the middleware and
+// has not expired, and returns its remaining lifetime
+func (d *digestAuth) verifyNonce(nonce string) (time.Duration, bool) {
+	raw, err := hex.DecodeString(nonce)
+	if err != nil || len(raw) != nonceTimeLen+nonceRandomLen+nonceSignatureLen {
+		return 0, false
+	}
+	data := raw[:nonceTimeLen+nonceRandomLen]
+	if !hmac.Equal(raw[len(data):], d.sign(data)) {
+		return 0, false
+	}
+	issued := time.Unix(0, int64(binary.BigEndian.Uint64(data))) //nolint:gosec // Signed by the middleware
+	lifetime := time.Until(issued.Add(d.cfg.NonceExpiration))
+	return lifetime, lifetime > 0 && lifetime <= d.cfg.NonceExpiration
+}
+
+// sign returns the truncated HMAC-SHA256 signature of the nonce data
+func (d *digestAuth) sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write(data) //nolint:errcheck // Never fails
+	return mac.Sum(nil)[:nonceSignatureLen]
+}
+
+// useNonce reports whether the nonce was not used with the nonce count
+// before, and records the nonce count for the remaining lifetime of the nonce
+func (d *digestAuth) useNonce(nonce string, nc uint32, lifetime time.Duration) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := nonceKeyPrefix + nonce
+	raw, err := d.cfg.Storage.Get(key)
+	if err != nil || (raw != nil && (len(raw) != 4 || nc <= binary.BigEndian.Uint32(raw))) {
+		return false
+	}
+	// Round up, as storages may expire values with a precision of seconds
+	return d.cfg.Storage.Set(key, binary.BigEndian.AppendUint32(nil, nc), lifetime.Truncate(time.Second)+time.Second) == nil
+}
+
+// h returns the hex-encoded hash of the data
+func (d *digestAuth) h(data string) string {
+	hash := d.newHash()
+	hash.Write([]byte(data)) //nolint:errcheck // Never fails
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// parseDigestParams parses the comma-separated parameters of a Digest
+// Authorization header, whose values are tokens or quoted strings
+func parseDigestParams(auth string) (map[string]string, bool) {
+	params := make(map[string]string, 10)
+	for auth = strings.TrimSpace(auth); auth != ""; {
+		eq := strings.IndexByte(auth, '=')
+		if eq <= 0 {
+			return nil, false
+		}
+		name := utils.ToLower(strings.TrimSpace(auth[:eq]))
+		auth = strings.TrimLeft(auth[eq+1:], " \t")
+
+		var value string
+		if strings.HasPrefix(auth, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(auth) && auth[i] != '"'; i++ {
+				if auth[i] == '\\' && i+1 < len(auth) {
+					i++
+				}
+				b.WriteByte(auth[i])
+			}
+			if i == len(auth) {
+				return nil, false
+			}
+			value, auth = b.String(), auth[i+1:]
+		} else {
+			end := strings.IndexByte(auth, ',')
+			if end < 0 {
+				end = len(auth)
+			}
+			value, auth = strings.TrimSpace(auth[:end]), auth[end:]
+		}
+		params[name] = value
+
+		auth = strings.TrimLeft(auth, " \t")
+		if auth != "" {
+			if auth[0] != ',' {
+				return nil, false
+			}
+			auth = strings.TrimLeft(auth[1:], " \t")
+		}
+	}
+	return params, true
+}
+
+// quoteEscape escapes a value for a quoted string
+func quoteEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,221 @@
+package basicauth
+
+import (
+	"crypto/md5" //nolint:gosec // Required by the Digest authentication
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// digestClient computes the Digest responses of a client
+type digestClient struct {
+	newHash   func() hash.Hash
+	algorithm string
+	nonce     string
+	nc        int
+}
+
+func (d *digestClient) h(data string) string {
+	hash := d.newHash()
+	hash.Write([]byte(data)) //nolint:errcheck // Never fails
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// authorization returns the Authorization header for the request with the next nonce count
+func (d *digestClient) authorization(username, password, method, uri string) string {
+	d.nc++
+	nc := fmt.Sprintf("%08x", d.nc)
+	ha1 := d.h(username + ":Restricted:" + password)
+	ha2 := d.h(method + ":" + uri)
+	response := d.h(ha1 + ":" + d.nonce + ":" + nc + ":cnonce:auth:" + ha2)
+	return fmt.Sprintf(`Digest username="%s", realm="Restricted", nonce="%s", uri="%s", algorithm=%s, qop=auth, nc=%s, cnonce="cnonce", response="%s"`,
+		username, d.nonce, uri, d.algorithm, nc, response)
+}
+
+// digestRequest sends a request with the Authorization header
+func digestRequest(t *testing.T, app *velocity.App, uri, authorization string) *http.Response {
+	t.Helper()
+
+	req := httptest.NewRequest(velocity.MethodGet, uri, nil)
+	if authorization != "" {
+		req.Header.Set(velocity.HeaderAuthorization, authorization)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// challenge returns the nonce of the challenge of the response
+func challenge(t *testing.T, resp *http.Response) (string, bool) {
+	t.Helper()
+
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+	header := resp.Header.Get(velocity.HeaderWWWAuthenticate)
+	require.Regexp(t, `^Digest realm="Restricted", qop="auth", algorithm=[A-Z0-9-]+, nonce="[0-9a-f]{64}"`, header)
+	params, ok := parseDigestParams(header[len("Digest "):])
+	require.True(t, ok)
+	return params["nonce"], params["stale"] == "true"
+}
+
+// go test -run Test_BasicAuth_Digest
+func Test_BasicAuth_Digest(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		newHash   func() hash.Hash
+		algorithm string
+	}{
+		{newHash: sha256.New, algorithm: DigestSHA256},
+		{newHash: md5.New, algorithm: DigestMD5},
+	} {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			t.Parallel()
+
+			app := velocity.New()
+			app.Use(New(Config{
+				Users: map[string]string{
+					"john":  "doe",
+					"admin": sha256Crypt("123456", "salt", 5000, false),
+				},
+				Digest:          true,
+				DigestAlgorithm: tc.algorithm,
+			}))
+			app.Get("/", func(c velocity.Ctx) error {
+				return c.SendString(UsernameFromContext(c) + PasswordFromContext(c))
+			})
+
+			nonce, stale := challenge(t, digestRequest(t, app, "/?page=1", ""))
+			require.False(t, stale)
+			client := &digestClient{newHash: tc.newHash, algorithm: tc.algorithm, nonce: nonce}
+
+			resp := digestRequest(t, app, "/?page=1", client.authorization("john", "doe", velocity.MethodGet, "/?page=1"))
+			require.Equal(t, velocity.StatusOK, resp.StatusCode)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "john", string(body))
+
+			// The nonce can be used again with a higher nonce count
+			resp = digestRequest(t, app, "/", client.authorization("john", "doe", velocity.MethodGet, "/"))
+			require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+			// Replayed requests are challenged with a new nonce
+			client.nc--
+			_, stale = challenge(t, digestRequest(t, app, "/", client.authorization("john", "doe", velocity.MethodGet, "/")))
+			require.True(t, stale)
+
+			// Unknown nonces as well
+			unknown := &digestClient{newHash: tc.newHash, algorithm: tc.algorithm, nonce: strings.Repeat("0", 64)}
+			_, stale = challenge(t, digestRequest(t, app, "/", unknown.authorization("john", "doe", velocity.MethodGet, "/")))
+			require.True(t, stale)
+
+			// Invalid credentials
+			_, stale = challenge(t, digestRequest(t, app, "/", client.authorization("john", "wrong", velocity.MethodGet, "/")))
+			require.False(t, stale)
+			_, stale = challenge(t, digestRequest(t, app, "/", client.authorization("admin", "123456", velocity.MethodGet, "/")))
+			require.False(t, stale)
+			_, stale = challenge(t, digestRequest(t, app, "/other", client.authorization("john", "doe", velocity.MethodGet, "/")))
+			require.False(t, stale)
+			_, stale = challenge(t, digestRequest(t, app, "/", client.authorization("john", "doe", velocity.MethodPost, "/")))
+			require.False(t, stale)
+			_, stale = challenge(t, digestRequest(t, app, "/", "Digest invalid"))
+			require.False(t, stale)
+		})
+	}
+}
+
+// countingStorage counts the writes to the storage
+type countingStorage struct {
+	velocity.Storage
+	sets atomic.Int32
+}
+
+func (s *countingStorage) Set(key string, val []byte, exp time.Duration) error {
+	s.sets.Add(1)
+	return s.Storage.Set(key, val, exp) //nolint:wrapcheck // passed on as is
+}
+
+// go test -run Test_BasicAuth_Digest_Nonces
+func Test_BasicAuth_Digest_Nonces(t *testing.T) {
+	t.Parallel()
+
+	storage := &countingStorage{Storage: memory.New()}
+	secret := []byte("secret")
+	newApp := func() *velocity.App {
+		app := velocity.New()
+		app.Use(New(Config{
+			Users:           map[string]string{"john": "doe"},
+			Digest:          true,
+			Storage:         storage,
+			NonceSecret:     secret,
+			NonceExpiration: 100 * time.Millisecond,
+		}))
+		app.Get("/", func(c velocity.Ctx) error {
+			return c.SendString(UsernameFromContext(c))
+		})
+		return app
+	}
+	app, other := newApp(), newApp()
+
+	// Challenges do not write to the storage
+	for i := 0; i < 10; i++ {
+		challenge(t, digestRequest(t, app, "/", ""))
+	}
+	require.Zero(t, storage.sets.Load())
+
+	// Nonces are accepted by instances with the same secret, and the nonce
+	// count is stored once the response is verified
+	nonce, _ := challenge(t, digestRequest(t, app, "/", ""))
+	client := &digestClient{newHash: sha256.New, algorithm: DigestSHA256, nonce: nonce}
+	resp := digestRequest(t, other, "/", client.authorization("john", "doe", velocity.MethodGet, "/"))
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, int32(1), storage.sets.Load())
+
+	client.nc--
+	_, stale := challenge(t, digestRequest(t, app, "/", client.authorization("john", "doe", velocity.MethodGet, "/")))
+	require.True(t, stale)
+
+	// Nonces signed with another secret are rejected
+	foreign := velocity.New()
+	foreign.Use(New(Config{Users: map[string]string{"john": "doe"}, Digest: true, Storage: storage}))
+	nonce, _ = challenge(t, digestRequest(t, foreign, "/", ""))
+	forged := &digestClient{newHash: sha256.New, algorithm: DigestSHA256, nonce: nonce}
+	_, stale = challenge(t, digestRequest(t, app, "/", forged.authorization("john", "doe", velocity.MethodGet, "/")))
+	require.True(t, stale)
+
+	// Expired nonces are rejected
+	time.Sleep(150 * time.Millisecond)
+	_, stale = challenge(t, digestRequest(t, app, "/", client.authorization("john", "doe", velocity.MethodGet, "/")))
+	require.True(t, stale)
+	require.Equal(t, int32(1), storage.sets.Load())
+}
+
+// go test -run Test_parseDigestParams
+func Test_parseDigestParams(t *testing.T) {
+	t.Parallel()
+
+	params, ok := parseDigestParams(`username="jo\"hn", realm="a, b",nc=00000001 ,  qop=auth`)
+	require.True(t, ok)
+	require.Equal(t, map[string]string{
+		"username": `jo"hn`,
+		"realm":    "a, b",
+		"nc":       "00000001",
+		"qop":      "auth",
+	}, params)
+
+	for _, invalid := range []string{`username`, `username="john`, `username="john" realm="a"`, `=john`} {
+		_, ok := parseDigestParams(invalid)
+		require.False(t, ok, invalid)
+	}
+}
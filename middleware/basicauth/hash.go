@@ -0,0 +1,295 @@
+package basicauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/khulnasoft/velocity/utils"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrUnsupportedHash is returned for password hashes which are recognized but not supported.
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+// credential holds the stored password of a user
+type credential struct {
+	verify func(password string) bool
+	// plaintext is the password if it is not hashed, used by Digest authentication
+	plaintext string
+	hashed    bool
+}
+
+// parseCredential detects the hash algorithm of the stored password from its
+// prefix. Passwords without a known prefix are compared as plaintext.
+func parseCredential(stored string) (credential, error) {
+	var verify func(password string) bool
+	var err error
+	switch {
+	case strings.HasPrefix(stored, "$2a$"), strings.HasPrefix(stored, "$2b$"), strings.HasPrefix(stored, "$2y$"):
+		verify, err = parseBcrypt(stored)
+	case strings.HasPrefix(stored, "$argon2id$"):
+		verify, err = parseArgon2id(stored)
+	case strings.HasPrefix(stored, "$scrypt$"):
+		verify, err = parseScrypt(stored)
+	case strings.HasPrefix(stored, "$5$"):
+		verify, err = parseSHA256Crypt(stored)
+	case strings.HasPrefix(stored, "$apr1$"), strings.HasPrefix(stored, "$1$"),
+		strings.HasPrefix(stored, "$6$"), strings.HasPrefix(stored, "{SHA}"):
+		return credential{}, ErrUnsupportedHash
+	default:
+		return credential{
+			verify: func(password string) bool {
+				return subtle.ConstantTimeCompare(utils.UnsafeBytes(stored), utils.UnsafeBytes(password)) == 1
+			},
+			plaintext: stored,
+		}, nil
+	}
+	if err != nil {
+		return credential{}, err
+	}
+	return credential{verify: verify, hashed: true}, nil
+}
+
+// parseBcrypt parses a bcrypt hash: $2y$<cost>$<salt and hash>
+func parseBcrypt(stored string) (func(string) bool, error) {
+	hash := []byte(stored)
+	if _, err := bcrypt.Cost(hash); err != nil {
+		return nil, fmt.Errorf("invalid bcrypt hash: %w", err)
+	}
+	return func(password string) bool {
+		return bcrypt.CompareHashAndPassword(hash, utils.UnsafeBytes(password)) == nil
+	}, nil
+}
+
+// maxHashMemory is the maximum memory cost of argon2id and scrypt hashes in bytes, 1 GiB
+const maxHashMemory = 1 << 30
+
+// argon2MaxMemory is the maximum memory cost of argon2id hashes in KiB
+const argon2MaxMemory = maxHashMemory >> 10
+
+// parseArgon2id parses an argon2id hash in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>
+func parseArgon2id(stored string) (func(string) bool, error) {
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 || parts[2] != "v=19" {
+		return nil, errors.New("invalid argon2id hash")
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	// Reject the parameters argon2.IDKey panics on, and memory costs no request should allocate
+	if time < 1 || threads < 1 {
+		return nil, errors.New("invalid argon2id parameters: t and p must be at least 1")
+	}
+	if memory < 8*uint32(threads) || memory > argon2MaxMemory {
+		return nil, errors.New("invalid argon2id parameters: m must be between 8*p and 1048576")
+	}
+	salt, hash, err := decodeSaltAndHash(parts[4], parts[5])
+	if err != nil {
+		return nil, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+	return func(password string) bool {
+		key := argon2.IDKey(utils.UnsafeBytes(password), salt, time, memory, threads, uint32(len(hash))) //nolint:gosec // Hash length is small
+		return subtle.ConstantTimeCompare(key, hash) == 1
+	}, nil
+}
+
+// parseScrypt parses a scrypt hash in the PHC string format:
+// $scrypt$ln=<log2 of N>,r=<block size>,p=<parallelism>$<salt>$<hash>
+func parseScrypt(stored string) (func(string) bool, error) {
+	parts := strings.Split(stored, "$")
+	if len(parts) != 5 {
+		return nil, errors.New("invalid scrypt hash")
+	}
+	var ln, r, p int
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &ln, &r, &p); err != nil {
+		return nil, fmt.Errorf("invalid scrypt parameters: %w", err)
+	}
+	if ln < 1 || ln > 30 {
+		return nil, errors.New("invalid scrypt parameters: ln must be between 1 and 30")
+	}
+	// scrypt allocates 128*r*N and 128*r*p bytes, check them before validating
+	if r < 1 || p < 1 || r > maxHashMemory/128 ||
+		128*int64(r)<<ln > maxHashMemory || int64(p) > maxHashMemory/(128*int64(r)) {
+		return nil, errors.New("invalid scrypt parameters: r and p must be at least 1, and 128*r*N and 128*r*p at most 1 GiB")
+	}
+	salt, hash, err := decodeSaltAndHash(parts[3], parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid scrypt hash: %w", err)
+	}
+	// Validate the parameters once, so verifying only fails for wrong passwords
+	if _, err := scrypt.Key(nil, salt, 1<<ln, r, p, len(hash)); err != nil {
+		return nil, fmt.Errorf("invalid scrypt parameters: %w", err)
+	}
+	return func(password string) bool {
+		key, err := scrypt.Key(utils.UnsafeBytes(password), salt, 1<<ln, r, p, len(hash))
+		return err == nil && subtle.ConstantTimeCompare(key, hash) == 1
+	}, nil
+}
+
+// decodeSaltAndHash decodes the salt and hash of the PHC string format,
+// which are base64-encoded without padding
+func decodeSaltAndHash(encodedSalt, encodedHash string) ([]byte, []byte, error) {
+	salt, err := base64.RawStdEncoding.DecodeString(encodedSalt)
+	if err != nil {
+		return nil, nil, err
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(encodedHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(hash) == 0 {
+		return nil, nil, errors.New("empty hash")
+	}
+	return salt, hash, nil
+}
+
+const (
+	sha256CryptDefaultRounds = 5000
+	sha256CryptMinRounds     = 1000
+	sha256CryptMaxRounds     = 999999999
+	sha256CryptMaxSaltSize   = 16
+)
+
+// parseSHA256Crypt parses a SHA-256-crypt hash: $5$[rounds=<rounds>$]<salt>$<hash>
+func parseSHA256Crypt(stored string) (func(string) bool, error) {
+	parts := strings.Split(stored, "$")
+	rounds := sha256CryptDefaultRounds
+	explicit := false
+	if len(parts) == 5 && strings.HasPrefix(parts[2], "rounds=") {
+		n, err := strconv.Atoi(strings.TrimPrefix(parts[2], "rounds="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SHA-256-crypt rounds: %w", err)
+		}
+		rounds, explicit = min(max(n, sha256CryptMinRounds), sha256CryptMaxRounds), true
+		parts = append(parts[:2], parts[3:]...)
+	}
+	if len(parts) != 4 || len(parts[3]) != 43 {
+		return nil, errors.New("invalid SHA-256-crypt hash")
+	}
+	salt := parts[2]
+	if len(salt) > sha256CryptMaxSaltSize {
+		salt = salt[:sha256CryptMaxSaltSize]
+	}
+	// The full hash is compared, so the rounds and salt must be written like sha256Crypt does
+	prefix := "$5$" + salt + "$"
+	if explicit {
+		prefix = "$5$rounds=" + strconv.Itoa(rounds) + "$" + salt + "$"
+	}
+	if stored != prefix+parts[3] {
+		return nil, errors.New("invalid SHA-256-crypt rounds or salt")
+	}
+	return func(password string) bool {
+		return subtle.ConstantTimeCompare([]byte(sha256Crypt(password, salt, rounds, explicit)), utils.UnsafeBytes(stored)) == 1
+	}, nil
+}
+
+// cryptAlphabet is the base64 alphabet of crypt(3)
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// sha256Crypt implements SHA-256-crypt as specified by Ulrich Drepper,
+// see https://www.akkadia.org/drepper/SHA-crypt.txt
+func sha256Crypt(password, salt string, rounds int, explicitRounds bool) string {
+	p, s := []byte(password), []byte(salt)
+
+	// Digest B
+	b := sha256.New()
+	b.Write(p) //nolint:errcheck // Never fails
+	b.Write(s) //nolint:errcheck // Never fails
+	b.Write(p) //nolint:errcheck // Never fails
+	sumB := b.Sum(nil)
+
+	// Digest A
+	a := sha256.New()
+	a.Write(p)                    //nolint:errcheck // Never fails
+	a.Write(s)                    //nolint:errcheck // Never fails
+	a.Write(repeat(sumB, len(p))) //nolint:errcheck // Never fails
+	for n := len(p); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			a.Write(sumB) //nolint:errcheck // Never fails
+		} else {
+			a.Write(p) //nolint:errcheck // Never fails
+		}
+	}
+	sumA := a.Sum(nil)
+
+	// Byte sequence P
+	dp := sha256.New()
+	for range len(p) {
+		dp.Write(p) //nolint:errcheck // Never fails
+	}
+	seqP := repeat(dp.Sum(nil), len(p))
+
+	// Byte sequence S
+	ds := sha256.New()
+	for range 16 + int(sumA[0]) {
+		ds.Write(s) //nolint:errcheck // Never fails
+	}
+	seqS := repeat(ds.Sum(nil), len(s))
+
+	sum := sumA
+	for i := range rounds {
+		c := sha256.New()
+		if i&1 != 0 {
+			c.Write(seqP) //nolint:errcheck // Never fails
+		} else {
+			c.Write(sum) //nolint:errcheck // Never fails
+		}
+		if i%3 != 0 {
+			c.Write(seqS) //nolint:errcheck // Never fails
+		}
+		if i%7 != 0 {
+			c.Write(seqP) //nolint:errcheck // Never fails
+		}
+		if i&1 != 0 {
+			c.Write(sum) //nolint:errcheck // Never fails
+		} else {
+			c.Write(seqP) //nolint:errcheck // Never fails
+		}
+		sum = c.Sum(sum[:0])
+	}
+
+	out := make([]byte, 0, 80)
+	out = append(out, "$5$"...)
+	if explicitRounds {
+		out = append(out, "rounds="...)
+		out = strconv.AppendInt(out, int64(rounds), 10)
+		out = append(out, '$')
+	}
+	out = append(out, s...)
+	out = append(out, '$')
+	for _, group := range [...][3]int{
+		{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+		{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+	} {
+		out = appendCrypt64(out, uint(sum[group[0]])<<16|uint(sum[group[1]])<<8|uint(sum[group[2]]), 4)
+	}
+	return string(appendCrypt64(out, uint(sum[31])<<8|uint(sum[30]), 3))
+}
+
+// repeat repeats the digest up to the length
+func repeat(digest []byte, length int) []byte {
+	out := make([]byte, 0, length)
+	for len(out) < length {
+		out = append(out, digest[:min(len(digest), length-len(out))]...)
+	}
+	return out
+}
+
+// appendCrypt64 appends n characters of the crypt(3) base64 encoding of w
+func appendCrypt64(dst []byte, w uint, n int) []byte {
+	for range n {
+		dst = append(dst, cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+	return dst
+}
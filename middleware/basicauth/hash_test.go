@@ -0,0 +1,97 @@
+package basicauth
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// go test -run Test_sha256Crypt
+func Test_sha256Crypt(t *testing.T) {
+	t.Parallel()
+
+	// Generated with openssl passwd -5
+	for _, tc := range []struct {
+		password, salt, expected string
+		rounds                   int
+		explicit                 bool
+	}{
+		{"Hello world!", "saltstring", "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5", 5000, false},
+		{"Hello world!", "saltstringsaltst", "$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA", 10000, true},
+		{"This is just a test", "roundstoolow", "$5$rounds=1000$roundstoolow$sNem/vUW2kw2NlR66SxQ3VpxErxIi/LoTfPKSmol2tD", 1000, true},
+		{"we have a short salt string but not a short password", "short", "$5$rounds=77777$short$JiO1O3ZpDAxGJeaDIuqCoEFysAe1mZNJRs3pw0KQRd/", 77777, true},
+	} {
+		require.Equal(t, tc.expected, sha256Crypt(tc.password, tc.salt, tc.rounds, tc.explicit))
+
+		cred, err := parseCredential(tc.expected)
+		require.NoError(t, err)
+		require.True(t, cred.hashed)
+		require.True(t, cred.verify(tc.password))
+		require.False(t, cred.verify(tc.password+"!"))
+	}
+}
+
+// go test -run Test_parseCredential
+func Test_parseCredential(t *testing.T) {
+	t.Parallel()
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("doe"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	salt := []byte("0123456789abcdef")
+	argon2Hash := "$argon2id$v=19$m=1024,t=1,p=1$" + base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("doe"), salt, 1, 1024, 1, 32))
+
+	scryptKey, err := scrypt.Key([]byte("doe"), salt, 1<<10, 8, 1, 32)
+	require.NoError(t, err)
+	scryptHash := "$scrypt$ln=10,r=8,p=1$" + base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(scryptKey)
+
+	for name, stored := range map[string]string{
+		"plaintext": "doe",
+		"bcrypt":    string(bcryptHash),
+		"argon2id":  argon2Hash,
+		"scrypt":    scryptHash,
+		"sha256":    sha256Crypt("doe", "saltstring", 5000, false),
+	} {
+		cred, err := parseCredential(stored)
+		require.NoError(t, err, name)
+		require.Equal(t, name != "plaintext", cred.hashed, name)
+		require.True(t, cred.verify("doe"), name)
+		require.False(t, cred.verify("do"), name)
+		require.False(t, cred.verify(""), name)
+	}
+
+	for name, stored := range map[string]string{
+		"bcrypt":          "$2y$10$short",
+		"argon2id":        "$argon2id$v=19$m=1024,t=1,p=1$salt",
+		"argon2id params": "$argon2id$v=19$m=x$c2FsdA$aGFzaA",
+		"argon2id base64": "$argon2id$v=19$m=1024,t=1,p=1$!$aGFzaA",
+		"argon2id t":      "$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$aGFzaA",
+		"argon2id p":      "$argon2id$v=19$m=1024,t=1,p=0$c2FsdA$aGFzaA",
+		"argon2id m":      "$argon2id$v=19$m=4,t=1,p=1$c2FsdA$aGFzaA",
+		"argon2id m max":  "$argon2id$v=19$m=4194304,t=1,p=1$c2FsdA$aGFzaA",
+		"scrypt":          "$scrypt$ln=10,r=8,p=1$c2FsdA",
+		"scrypt ln":       "$scrypt$ln=31,r=8,p=1$c2FsdA$aGFzaA",
+		"scrypt r":        "$scrypt$ln=10,r=0,p=1$c2FsdA$aGFzaA",
+		"scrypt r max":    "$scrypt$ln=1,r=9223372036854775807,p=1$c2FsdA$aGFzaA",
+		"scrypt N max":    "$scrypt$ln=30,r=8,p=1$c2FsdA$aGFzaA",
+		"scrypt p max":    "$scrypt$ln=10,r=8,p=1048577$c2FsdA$aGFzaA",
+		"scrypt empty":    "$scrypt$ln=10,r=8,p=1$c2FsdA$",
+		"sha256":          "$5$salt$short",
+		"sha256 rounds":   "$5$rounds=x$salt$" + sha256Crypt("doe", "salt", 5000, false)[9:],
+		"sha256 clamped":  "$5$rounds=10$salt$" + sha256Crypt("doe", "salt", 1000, true)[20:],
+	} {
+		_, err := parseCredential(stored)
+		require.Error(t, err, name)
+	}
+
+	for _, stored := range []string{"$apr1$salt$hash", "$1$salt$hash", "$6$salt$hash", "{SHA}hash"} {
+		_, err := parseCredential(stored)
+		require.ErrorIs(t, err, ErrUnsupportedHash, stored)
+	}
+}
@@ -0,0 +1,140 @@
+package basicauth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khulnasoft/velocity/log"
+)
+
+// credentials looks up the credentials of the Users and of the htpasswd file,
+// which is reloaded when it changes
+type credentials struct {
+	modTime   time.Time
+	users     map[string]credential
+	fileUsers map[string]credential
+	file      string
+	size      int64
+	interval  time.Duration
+	checked   atomic.Int64 // Unix time in nanoseconds of the last check of the file
+	mu        sync.RWMutex
+}
+
+func newCredentials(cfg *Config) *credentials {
+	cs := &credentials{
+		users:    make(map[string]credential, len(cfg.Users)),
+		file:     cfg.HtpasswdFile,
+		interval: cfg.ReloadInterval,
+	}
+	for username, password := range cfg.Users {
+		cred, err := parseCredential(password)
+		if err != nil {
+			panic(fmt.Sprintf("[basicauth] invalid password of user %q: %v", username, err))
+		}
+		cs.users[username] = cred
+	}
+	if cs.file != "" {
+		if err := cs.load(); err != nil {
+			panic(fmt.Sprintf("[basicauth] failed to load HtpasswdFile: %v", err))
+		}
+		cs.checked.Store(time.Now().UnixNano())
+	}
+	return cs
+}
+
+// lookup returns the credential of the user. The Users take precedence over the htpasswd file.
+func (cs *credentials) lookup(username string) (credential, bool) {
+	if cred, ok := cs.users[username]; ok {
+		return cred, true
+	}
+	if cs.file == "" {
+		return credential{}, false
+	}
+	cs.reload()
+	cs.mu.RLock()
+	cred, ok := cs.fileUsers[username]
+	cs.mu.RUnlock()
+	return cred, ok
+}
+
+// authorize is the default Authorizer
+func (cs *credentials) authorize(username, password string) bool {
+	cred, ok := cs.lookup(username)
+	return ok && cred.verify(password)
+}
+
+// reload reloads the htpasswd file if the ReloadInterval passed since the
+// last check and the file changed. The previous credentials are kept if the
+// file cannot be loaded.
+func (cs *credentials) reload() {
+	now := time.Now().UnixNano()
+	last := cs.checked.Load()
+	if now-last < int64(cs.interval) || !cs.checked.CompareAndSwap(last, now) {
+		return
+	}
+
+	info, err := os.Stat(cs.file)
+	if err != nil {
+		log.Warnf("[basicauth] failed to reload HtpasswdFile: %v", err)
+		return
+	}
+	cs.mu.RLock()
+	unchanged := info.ModTime().Equal(cs.modTime) && info.Size() == cs.size
+	cs.mu.RUnlock()
+	if unchanged {
+		return
+	}
+	if err := cs.load(); err != nil {
+		log.Warnf("[basicauth] failed to reload HtpasswdFile: %v", err)
+	}
+}
+
+// load loads the htpasswd file
+func (cs *credentials) load() error {
+	f, err := os.Open(cs.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck // Read only
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	users, err := parseHtpasswd(bufio.NewScanner(f))
+	if err != nil {
+		return err
+	}
+
+	cs.mu.Lock()
+	cs.fileUsers, cs.modTime, cs.size = users, info.ModTime(), info.Size()
+	cs.mu.Unlock()
+	return nil
+}
+
+// parseHtpasswd parses the "username:password" lines of an htpasswd file.
+// Empty lines and lines starting with # are ignored.
+func parseHtpasswd(scanner *bufio.Scanner) (map[string]credential, error) {
+	users := make(map[string]credential)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		username, password, found := strings.Cut(text, ":")
+		if !found || username == "" {
+			return nil, fmt.Errorf("line %d: expected username:password", line)
+		}
+		cred, err := parseCredential(password)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		users[username] = cred
+	}
+	return users, scanner.Err()
+}
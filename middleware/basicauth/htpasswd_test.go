@@ -0,0 +1,94 @@
+package basicauth
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basicStatus returns the status code of a request with the credentials
+func basicStatus(t *testing.T, app *velocity.App, username, password string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set(velocity.HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	return resp.StatusCode
+}
+
+// writeHtpasswd writes the htpasswd file and moves its modification time forward
+func writeHtpasswd(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// go test -run Test_BasicAuth_Htpasswd
+func Test_BasicAuth_Htpasswd(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("doe"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	modTime := time.Now()
+	writeHtpasswd(t, path, "# users\njohn:"+string(hash)+"\n\nadmin:"+sha256Crypt("123456", "salt", 5000, false)+"\n", modTime)
+
+	app := velocity.New()
+	app.Use(New(Config{
+		Users:          map[string]string{"static": "secret", "admin": "static"},
+		HtpasswdFile:   path,
+		ReloadInterval: time.Millisecond,
+	}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString(UsernameFromContext(c))
+	})
+
+	require.Equal(t, velocity.StatusOK, basicStatus(t, app, "john", "doe"))
+	require.Equal(t, velocity.StatusOK, basicStatus(t, app, "static", "secret"))
+	require.Equal(t, velocity.StatusUnauthorized, basicStatus(t, app, "john", "wrong"))
+
+	// The Users take precedence over the file
+	require.Equal(t, velocity.StatusOK, basicStatus(t, app, "admin", "static"))
+	require.Equal(t, velocity.StatusUnauthorized, basicStatus(t, app, "admin", "123456"))
+
+	// The file is reloaded when it changes
+	writeHtpasswd(t, path, "jane:doe\n", modTime.Add(time.Second))
+	time.Sleep(5 * time.Millisecond)
+	require.Equal(t, velocity.StatusOK, basicStatus(t, app, "jane", "doe"))
+	require.Equal(t, velocity.StatusUnauthorized, basicStatus(t, app, "john", "doe"))
+
+	// Invalid files are not loaded
+	writeHtpasswd(t, path, "john\n", modTime.Add(2*time.Second))
+	time.Sleep(5 * time.Millisecond)
+	require.Equal(t, velocity.StatusOK, basicStatus(t, app, "jane", "doe"))
+}
+
+// go test -run Test_BasicAuth_Htpasswd_Invalid
+func Test_BasicAuth_Htpasswd_Invalid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.PanicsWithValue(t, "[basicauth] failed to load HtpasswdFile: open "+filepath.Join(dir, "missing")+": no such file or directory", func() {
+		New(Config{HtpasswdFile: filepath.Join(dir, "missing")})
+	})
+
+	path := filepath.Join(dir, ".htpasswd")
+	writeHtpasswd(t, path, "john:doe\njane:$apr1$salt$hash\n", time.Now())
+	require.PanicsWithValue(t, "[basicauth] failed to load HtpasswdFile: line 2: unsupported password hash", func() {
+		New(Config{HtpasswdFile: path})
+	})
+
+	require.PanicsWithValue(t, `[basicauth] invalid password of user "john": invalid SHA-256-crypt hash`, func() {
+		New(Config{Users: map[string]string{"john": "$5$salt$hash"}})
+	})
+}
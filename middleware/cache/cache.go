@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/servertiming"
 	"github.com/khulnasoft/velocity/utils"
 )
 
@@ -124,6 +125,7 @@ func New(config ...Config) velocity.Handler {
 		key := cfg.KeyGenerator(c) + "_" + requestMethod
 
 		// Get entry from pool
+		lookup := time.Now()
 		e := manager.get(key)
 
 		// Lock entry
@@ -169,6 +171,7 @@ func New(config ...Config) velocity.Handler {
 				}
 
 				c.Set(cfg.CacheHeader, cacheHit)
+				servertiming.Record(c, "cache", time.Since(lookup), cacheHit)
 
 				mux.Unlock()
 
@@ -180,6 +183,8 @@ func New(config ...Config) velocity.Handler {
 		// make sure we're not blocking concurrent requests - do unlock
 		mux.Unlock()
 
+		servertiming.Record(c, "cache", time.Since(lookup), cacheMiss)
+
 		// Continue stack, return err to Velocity if exist
 		if err := c.Next(); err != nil {
 			return err
@@ -16,6 +16,7 @@ import (
 	"github.com/khulnasoft/velocity"
 	"github.com/khulnasoft/velocity/internal/storage/memory"
 	"github.com/khulnasoft/velocity/middleware/etag"
+	"github.com/khulnasoft/velocity/middleware/servertiming"
 	"github.com/khulnasoft/velocity/utils"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
@@ -1117,3 +1118,23 @@ func Benchmark_Cache_MaxSize(b *testing.B) {
 		})
 	}
 }
+
+// go test -run Test_Cache_ServerTiming
+func Test_Cache_ServerTiming(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(servertiming.New(servertiming.Config{AllowIPs: []string{"0.0.0.0"}, DisableTotal: true}))
+	app.Use(New())
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("cached")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Contains(t, resp.Header.Get(servertiming.HeaderServerTiming), `desc="miss"`)
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Contains(t, resp.Header.Get(servertiming.HeaderServerTiming), `desc="hit"`)
+}
@@ -43,6 +43,12 @@ func New(config ...Config) velocity.Handler {
 		}
 	}
 
+	// Setup stream encoders
+	streamEncoders := make(map[string]velocity.StreamEncoder, 4)
+	for _, encoding := range []string{encodingBrotli, encodingGzip, encodingDeflate, encodingZstd} {
+		streamEncoders[encoding] = newStreamEncoder(encoding, cfg.Level)
+	}
+
 	// Return new handler
 	return func(c velocity.Ctx) error {
 		// Don't execute middleware if Next returns true
@@ -50,11 +56,30 @@ func New(config ...Config) velocity.Handler {
 			return c.Next()
 		}
 
+		// Let SendStream and SendStreamWriter encode streamed bodies
+		// on the fly instead of buffering them
+		encoding := negotiateEncoding(c)
+		if encoding != "" {
+			c.SetStreamEncoder(encoding, streamEncoders[encoding])
+		}
+
 		// Continue stack
 		if err := c.Next(); err != nil {
 			return err
 		}
 
+		// Partial content refers to the unencoded representation
+		if c.Response().StatusCode() == velocity.StatusPartialContent {
+			return nil
+		}
+
+		// Streamed body was already encoded
+		if encoding != "" && c.Response().IsBodyStream() &&
+			string(c.Response().Header.ContentEncoding()) == encoding {
+			c.Vary(velocity.HeaderAcceptEncoding)
+			return nil
+		}
+
 		// Compress response
 		compressor(c.RequestCtx())
 
@@ -1,6 +1,10 @@
 package compress
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"errors"
 	"fmt"
 	"io"
@@ -9,7 +13,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/khulnasoft/velocity"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
 )
@@ -360,6 +366,206 @@ func Benchmark_Compress_Parallel(b *testing.B) {
 	}
 }
 
+func decodeBody(t *testing.T, encoding string, body io.Reader) ([]byte, error) {
+	t.Helper()
+	var (
+		r   io.Reader
+		err error
+	)
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(body)
+	case "deflate":
+		r, err = zlib.NewReader(body)
+	case "br":
+		r = brotli.NewReader(body)
+	case "zstd":
+		var zr *zstd.Decoder
+		zr, err = zstd.NewReader(body)
+		if zr != nil {
+			defer zr.Close()
+		}
+		r = zr
+	default:
+		r = body
+	}
+	require.NoError(t, err)
+	return io.ReadAll(r)
+}
+
+// go test -run Test_Compress_SendStreamWriter
+func Test_Compress_SendStreamWriter(t *testing.T) {
+	t.Parallel()
+	for _, algo := range []string{"gzip", "deflate", "br", "zstd"} {
+		t.Run(algo, func(t *testing.T) {
+			t.Parallel()
+			app := velocity.New()
+
+			app.Use(New())
+
+			app.Get("/", func(c velocity.Ctx) error {
+				c.Set(velocity.HeaderContentType, "text/event-stream")
+				return c.SendStreamWriter(func(w *bufio.Writer) {
+					for i := 0; i < 5; i++ {
+						fmt.Fprintf(w, "data: %d\n\n", i) //nolint:errcheck // It is fine to ignore the error
+						if err := w.Flush(); err != nil {
+							return
+						}
+					}
+				})
+			})
+
+			req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", algo)
+
+			resp, err := app.Test(req, testConfig)
+			require.NoError(t, err, "app.Test(req)")
+			require.Equal(t, 200, resp.StatusCode, "Status code")
+			require.Equal(t, algo, resp.Header.Get(velocity.HeaderContentEncoding))
+			require.Equal(t, "Accept-Encoding", resp.Header.Get(velocity.HeaderVary))
+
+			body, err := decodeBody(t, algo, resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "data: 0\n\ndata: 1\n\ndata: 2\n\ndata: 3\n\ndata: 4\n\n", string(body))
+		})
+	}
+}
+
+// go test -run Test_Compress_SendStreamWriter_Flush
+func Test_Compress_SendStreamWriter_Flush(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStreamWriter(func(w *bufio.Writer) {
+			for lineNum := 1; lineNum <= 5; lineNum++ {
+				fmt.Fprintf(w, "Line %d\n", lineNum) //nolint:errcheck // It is fine to ignore the error
+				if err := w.Flush(); err != nil {
+					return
+				}
+				time.Sleep(400 * time.Millisecond)
+			}
+		})
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := app.Test(req, velocity.TestConfig{
+		Timeout:       1 * time.Second,
+		FailOnTimeout: false,
+	})
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, "gzip", resp.Header.Get(velocity.HeaderContentEncoding))
+
+	// Every flushed line must be decodable although the stream was cut off
+	body, err := decodeBody(t, "gzip", resp.Body)
+	require.Error(t, err)
+	require.Equal(t, "Line 1\nLine 2\nLine 3\n", string(body))
+}
+
+// go test -run Test_Compress_SendStream
+func Test_Compress_SendStream(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderContentType, velocity.MIMEApplicationJSON)
+		return c.SendStream(bytes.NewReader(filedata), len(filedata))
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "zstd")
+
+	resp, err := app.Test(req, testConfig)
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, 200, resp.StatusCode, "Status code")
+	require.Equal(t, "zstd", resp.Header.Get(velocity.HeaderContentEncoding))
+	require.Empty(t, resp.Header.Get(velocity.HeaderContentLength))
+
+	body, err := decodeBody(t, "zstd", resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, filedata, body)
+}
+
+// go test -run Test_Compress_SendStream_Incompressible
+func Test_Compress_SendStream_Incompressible(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderContentType, "image/png")
+		return c.SendStream(bytes.NewReader(filedata))
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := app.Test(req, testConfig)
+	require.NoError(t, err, "app.Test(req)")
+	require.Empty(t, resp.Header.Get(velocity.HeaderContentEncoding))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, filedata, body)
+}
+
+// go test -run Test_Compress_SendStream_AlreadyEncoded
+func Test_Compress_SendStream_AlreadyEncoded(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderContentEncoding, "gzip")
+		return c.SendStream(bytes.NewReader([]byte("not really gzip")))
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := app.Test(req, testConfig)
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, "gzip", resp.Header.Get(velocity.HeaderContentEncoding))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "not really gzip", string(body))
+}
+
+// go test -run Test_Compress_PartialContent
+func Test_Compress_PartialContent(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New())
+
+	app.Get("/", func(c velocity.Ctx) error {
+		c.Set(velocity.HeaderContentType, velocity.MIMETextPlainCharsetUTF8)
+		return c.SendStream(bytes.NewReader(filedata))
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set(velocity.HeaderRange, "bytes=0-299")
+
+	resp, err := app.Test(req, testConfig)
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, velocity.StatusPartialContent, resp.StatusCode, "Status code")
+	require.Empty(t, resp.Header.Get(velocity.HeaderContentEncoding))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, filedata[:300], body)
+}
+
 // go test -bench=Benchmark_Compress_Levels_Parallel
 func Benchmark_Compress_Levels_Parallel(b *testing.B) {
 	tests := []struct {
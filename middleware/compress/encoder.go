@@ -0,0 +1,93 @@
+package compress
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/khulnasoft/velocity"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/fasthttp"
+)
+
+// Content-Encoding tokens supported for streamed responses
+const (
+	encodingBrotli  = "br"
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+	encodingZstd    = "zstd"
+)
+
+// negotiateEncoding picks the stream encoding accepted by the client, using
+// the same order of preference as the buffered compressor.
+func negotiateEncoding(c velocity.Ctx) string {
+	header := &c.Request().Header
+	for _, encoding := range []string{encodingBrotli, encodingGzip, encodingDeflate, encodingZstd} {
+		if header.HasAcceptEncoding(encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// newStreamEncoder returns a velocity.StreamEncoder for the given encoding and level.
+func newStreamEncoder(encoding string, level Level) velocity.StreamEncoder {
+	switch encoding {
+	case encodingBrotli:
+		brotliLevel := brotliLevels[level]
+		return func(w io.Writer) io.WriteCloser {
+			return brotli.NewWriterLevel(w, brotliLevel)
+		}
+	case encodingGzip:
+		flateLevel := flateLevels[level]
+		return func(w io.Writer) io.WriteCloser {
+			enc, err := gzip.NewWriterLevel(w, flateLevel)
+			if err != nil {
+				// Levels are validated by configDefault
+				panic(err)
+			}
+			return enc
+		}
+	case encodingDeflate:
+		flateLevel := flateLevels[level]
+		return func(w io.Writer) io.WriteCloser {
+			enc, err := zlib.NewWriterLevel(w, flateLevel)
+			if err != nil {
+				// Levels are validated by configDefault
+				panic(err)
+			}
+			return enc
+		}
+	case encodingZstd:
+		zstdLevel := zstdLevels[level]
+		return func(w io.Writer) io.WriteCloser {
+			enc, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel), zstd.WithEncoderConcurrency(1))
+			if err != nil {
+				// Options are static and always valid
+				panic(err)
+			}
+			return enc
+		}
+	default:
+		return nil
+	}
+}
+
+var (
+	brotliLevels = map[Level]int{
+		LevelDefault:         fasthttp.CompressBrotliDefaultCompression,
+		LevelBestSpeed:       fasthttp.CompressBrotliBestSpeed,
+		LevelBestCompression: fasthttp.CompressBrotliBestCompression,
+	}
+	flateLevels = map[Level]int{
+		LevelDefault:         fasthttp.CompressDefaultCompression,
+		LevelBestSpeed:       fasthttp.CompressBestSpeed,
+		LevelBestCompression: fasthttp.CompressBestCompression,
+	}
+	zstdLevels = map[Level]zstd.EncoderLevel{
+		LevelDefault:         zstd.SpeedDefault,
+		LevelBestSpeed:       zstd.SpeedFastest,
+		LevelBestCompression: zstd.SpeedBestCompression,
+	}
+)
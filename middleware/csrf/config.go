@@ -91,6 +91,15 @@ type Config struct {
 	// Optional. Default: []
 	TrustedOrigins []string
 
+	// SigningKeys enables stateless tokens. The tokens are signed with
+	// HMAC-SHA256 and embed their expiration, so they are verified without a
+	// round-trip to the Storage or the session. If Session is set, the tokens
+	// are bound to the session ID. The first key signs, all keys verify, so
+	// keys are rotated by prepending a new key. Keys must be at least 32 bytes.
+	//
+	// Optional. Default: nil
+	SigningKeys [][]byte
+
 	// IdleTimeout is the duration of time the CSRF token is valid.
 	//
 	// Optional. Default: 30 * time.Minute
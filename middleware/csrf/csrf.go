@@ -27,6 +27,7 @@ var (
 type Handler struct {
 	sessionManager *sessionManager
 	storageManager *storageManager
+	signedManager  *signedManager
 	config         Config
 }
 
@@ -48,9 +49,15 @@ func New(config ...Config) velocity.Handler {
 	// Create manager to simplify storage operations ( see *_manager.go )
 	var sessionManager *sessionManager
 	var storageManager *storageManager
-	if cfg.Session != nil {
+	var signedManager *signedManager
+	switch {
+	case len(cfg.SigningKeys) > 0:
+		// The storage only records the used single-use tokens
+		signedManager = newSignedManager(cfg.SigningKeys, cfg.Session, cfg.IdleTimeout)
+		storageManager = newStorageManager(cfg.Storage)
+	case cfg.Session != nil:
 		sessionManager = newSessionManager(cfg.Session)
-	} else {
+	default:
 		storageManager = newStorageManager(cfg.Storage)
 	}
 
@@ -82,6 +89,7 @@ func New(config ...Config) velocity.Handler {
 		config:         cfg,
 		sessionManager: sessionManager,
 		storageManager: storageManager,
+		signedManager:  signedManager,
 	}
 
 	// Return new handler
@@ -102,9 +110,11 @@ func New(config ...Config) velocity.Handler {
 			cookieToken := c.Cookies(cfg.CookieName)
 
 			if cookieToken != "" {
-				raw := getRawFromStorage(c, cookieToken, cfg, sessionManager, storageManager)
-
-				if raw != nil {
+				if signedManager != nil {
+					if expires, _, ok := signedManager.verify(c, cookieToken); ok && !signedManager.renew(expires) {
+						token = cookieToken // Token is valid, safe to set it
+					}
+				} else if raw := getRawFromStorage(c, cookieToken, cfg, sessionManager, storageManager); raw != nil {
 					token = cookieToken // Token is valid, safe to set it
 				}
 			}
@@ -139,6 +149,11 @@ func New(config ...Config) velocity.Handler {
 				return cfg.ErrorHandler(c, ErrTokenNotFound)
 			}
 
+			// Signed tokens are masked on every request
+			if signedManager != nil {
+				extractedToken = unmaskToken(extractedToken)
+			}
+
 			// If not using FromCookie extractor, check that the token matches the cookie
 			// This is to prevent CSRF attacks by using a Double Submit Cookie method
 			// Useful when we do not have access to the users Session
@@ -146,6 +161,21 @@ func New(config ...Config) velocity.Handler {
 				return cfg.ErrorHandler(c, ErrTokenInvalid)
 			}
 
+			if signedManager != nil {
+				expires, nonce, ok := signedManager.verify(c, extractedToken)
+				if !ok || (cfg.SingleUseToken && storageManager.getRaw(usedTokenPrefix+nonce) != nil) {
+					expireCSRFCookie(c, cfg)
+					return cfg.ErrorHandler(c, ErrTokenInvalid)
+				}
+				if cfg.SingleUseToken {
+					// Signed tokens cannot be deleted, so the used token is recorded until it expires
+					storageManager.setRaw(usedTokenPrefix+nonce, dummyValue, time.Until(expires))
+				} else if !signedManager.renew(expires) {
+					token = extractedToken // Token is valid, safe to set it
+				}
+				break
+			}
+
 			raw := getRawFromStorage(c, extractedToken, cfg, sessionManager, storageManager)
 
 			if raw == nil {
@@ -165,11 +195,17 @@ func New(config ...Config) velocity.Handler {
 		// Generate CSRF token if not exist
 		if token == "" {
 			// And generate a new token
-			token = cfg.KeyGenerator()
+			if signedManager != nil {
+				token = signedManager.generate(c)
+			} else {
+				token = cfg.KeyGenerator()
+			}
 		}
 
 		// Create or extend the token in the storage
-		createOrExtendTokenInStorage(c, token, cfg, sessionManager, storageManager)
+		if signedManager == nil {
+			createOrExtendTokenInStorage(c, token, cfg, sessionManager, storageManager)
+		}
 
 		// Update the CSRF cookie
 		updateCSRFCookie(c, cfg, token)
@@ -177,8 +213,12 @@ func New(config ...Config) velocity.Handler {
 		// Tell the browser that a new header value is generated
 		c.Vary(velocity.HeaderCookie)
 
-		// Store the token in the context
-		c.Locals(tokenKey, token)
+		// Store the token in the context, signed tokens are masked
+		if signedManager != nil {
+			c.Locals(tokenKey, maskToken(token))
+		} else {
+			c.Locals(tokenKey, token)
+		}
 
 		// Continue stack
 		return c.Next()
@@ -267,7 +307,14 @@ func (handler *Handler) DeleteToken(c velocity.Ctx) error {
 		return handler.config.ErrorHandler(c, ErrTokenNotFound)
 	}
 	// Remove the token from storage
-	deleteTokenFromStorage(c, cookieToken, handler.config, handler.sessionManager, handler.storageManager)
+	if handler.signedManager != nil {
+		// Signed tokens cannot be deleted, single-use tokens are recorded as used
+		if expires, nonce, ok := handler.signedManager.verify(c, cookieToken); ok && handler.config.SingleUseToken {
+			handler.storageManager.setRaw(usedTokenPrefix+nonce, dummyValue, time.Until(expires))
+		}
+	} else {
+		deleteTokenFromStorage(c, cookieToken, handler.config, handler.sessionManager, handler.storageManager)
+	}
 	// Expire the cookie
 	expireCSRFCookie(c, handler.config)
 	return nil
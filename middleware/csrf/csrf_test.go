@@ -3,10 +3,12 @@ package csrf
 import (
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
 	"github.com/khulnasoft/velocity/middleware/session"
 	"github.com/khulnasoft/velocity/utils"
 	"github.com/stretchr/testify/require"
@@ -1564,3 +1566,264 @@ func Test_CSRF_FromContextMethods_Invalid(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, velocity.StatusOK, resp.StatusCode)
 }
+
+// signingKey returns a signing key for the tests
+func signingKey(b byte) []byte {
+	return []byte(strings.Repeat(string(b), minSigningKeySize))
+}
+
+// csrfCookie returns the value of the CSRF cookie of the response
+func csrfCookie(ctx *fasthttp.RequestCtx) string {
+	return string(ctx.Response.Header.PeekCookie(ConfigDefault.CookieName))
+}
+
+// go test -run Test_CSRF_Signed
+func Test_CSRF_Signed(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	storage := &countingStorage{Storage: memory.New()}
+	app.Use(New(Config{
+		SigningKeys: [][]byte{signingKey('a')},
+		Storage:     storage,
+	}))
+
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString(TokenFromContext(c))
+	})
+	app.Post("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	h := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	// Generate CSRF token
+	ctx.Request.Header.SetMethod(velocity.MethodGet)
+	h(ctx)
+	cookie := &fasthttp.Cookie{}
+	require.NoError(t, cookie.Parse(csrfCookie(ctx)))
+	token := string(cookie.Value())
+	masked := string(ctx.Response.Body())
+	require.NotEmpty(t, token)
+	require.NotEqual(t, token, masked)
+
+	// The token is kept, but masked differently on every request
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod(velocity.MethodGet)
+	ctx.Request.Header.SetCookie(ConfigDefault.CookieName, token)
+	h(ctx)
+	require.Contains(t, csrfCookie(ctx), token)
+	require.NotEqual(t, masked, string(ctx.Response.Body()))
+	require.Equal(t, token, unmaskToken(string(ctx.Response.Body())))
+
+	// Both the masked and the unmasked token are accepted
+	for _, submitted := range []string{masked, token} {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.Header.SetMethod(velocity.MethodPost)
+		ctx.Request.Header.Set(HeaderName, submitted)
+		ctx.Request.Header.SetCookie(ConfigDefault.CookieName, token)
+		h(ctx)
+		require.Equal(t, 200, ctx.Response.StatusCode())
+	}
+
+	// Token which does not match the cookie
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod(velocity.MethodPost)
+	ctx.Request.Header.Set(HeaderName, masked)
+	ctx.Request.Header.SetCookie(ConfigDefault.CookieName, "johndoe")
+	h(ctx)
+	require.Equal(t, 403, ctx.Response.StatusCode())
+
+	// Tampered token
+	raw := []byte(token)
+	raw[0] ^= 1
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod(velocity.MethodPost)
+	ctx.Request.Header.Set(HeaderName, string(raw))
+	ctx.Request.Header.SetCookie(ConfigDefault.CookieName, string(raw))
+	h(ctx)
+	require.Equal(t, 403, ctx.Response.StatusCode())
+
+	// The storage is not used
+	require.Zero(t, storage.calls.Load())
+}
+
+// go test -run Test_CSRF_Signed_KeyRotation
+func Test_CSRF_Signed_KeyRotation(t *testing.T) {
+	t.Parallel()
+
+	issue := func(keys ...[]byte) string {
+		return newSignedManager(keys, nil, time.Hour).generate(nil)
+	}
+	verify := func(token string, keys ...[]byte) bool {
+		_, _, ok := newSignedManager(keys, nil, time.Hour).verify(nil, token)
+		return ok
+	}
+
+	oldKey, newKey := signingKey('a'), signingKey('b')
+	token := issue(oldKey)
+	require.True(t, verify(token, oldKey))
+	require.True(t, verify(token, newKey, oldKey))
+	require.False(t, verify(token, newKey))
+
+	// Expired tokens are rejected
+	require.False(t, verify(newSignedManager([][]byte{oldKey}, nil, -time.Second).generate(nil), oldKey))
+
+	// Tokens are renewed when less than half of their lifetime is left
+	m := newSignedManager([][]byte{oldKey}, nil, time.Hour)
+	require.False(t, m.renew(time.Now().Add(time.Hour)))
+	require.True(t, m.renew(time.Now().Add(20*time.Minute)))
+
+	require.PanicsWithValue(t, "[CSRF] SigningKeys must be at least 32 bytes long", func() {
+		newSignedManager([][]byte{[]byte("short")}, nil, time.Hour)
+	})
+}
+
+// go test -run Test_CSRF_Signed_SingleUseToken
+func Test_CSRF_Signed_SingleUseToken(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New(Config{
+		SigningKeys:    [][]byte{signingKey('a')},
+		SingleUseToken: true,
+	}))
+
+	app.Post("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	h := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	// Generate CSRF token
+	ctx.Request.Header.SetMethod(velocity.MethodGet)
+	h(ctx)
+	token := strings.Split(strings.Split(csrfCookie(ctx), ";")[0], "=")[1]
+
+	// Use the CSRF token
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod(velocity.MethodPost)
+	ctx.Request.Header.Set(HeaderName, token)
+	ctx.Request.Header.SetCookie(ConfigDefault.CookieName, token)
+	h(ctx)
+	require.Equal(t, 200, ctx.Response.StatusCode())
+	newToken := strings.Split(strings.Split(csrfCookie(ctx), ";")[0], "=")[1]
+	require.NotEqual(t, token, newToken)
+
+	// Use the CSRF token again
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod(velocity.MethodPost)
+	ctx.Request.Header.Set(HeaderName, token)
+	ctx.Request.Header.SetCookie(ConfigDefault.CookieName, token)
+	h(ctx)
+	require.Equal(t, 403, ctx.Response.StatusCode())
+}
+
+// go test -run Test_CSRF_Signed_WithSession
+func Test_CSRF_Signed_WithSession(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	smh, sstore := session.NewWithStore()
+	app.Use(smh)
+	app.Use(New(Config{
+		SigningKeys: [][]byte{signingKey('a')},
+		Session:     sstore,
+	}))
+
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString(TokenFromContext(c))
+	})
+	app.Post("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	h := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	// Generate CSRF token and session_id
+	ctx.Request.Header.SetMethod(velocity.MethodGet)
+	h(ctx)
+	token := string(ctx.Response.Body())
+	sessionID := string(ctx.Response.Header.PeekCookie("session_id"))
+	sessionID = strings.Split(strings.Split(sessionID, ";")[0], "=")[1]
+	cookie := strings.Split(strings.Split(csrfCookie(ctx), ";")[0], "=")[1]
+
+	// Use the CSRF token and session_id
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod(velocity.MethodPost)
+	ctx.Request.Header.Set(HeaderName, token)
+	ctx.Request.Header.SetCookie(ConfigDefault.CookieName, cookie)
+	ctx.Request.Header.SetCookie("session_id", sessionID)
+	h(ctx)
+	require.Equal(t, 200, ctx.Response.StatusCode())
+
+	// The token is bound to the session
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(velocity.MethodPost)
+	ctx.Request.Header.Set(HeaderName, token)
+	ctx.Request.Header.SetCookie(ConfigDefault.CookieName, cookie)
+	h(ctx)
+	require.Equal(t, 403, ctx.Response.StatusCode())
+}
+
+// go test -run Test_CSRF_Signed_WithSessionStore
+func Test_CSRF_Signed_WithSessionStore(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	sstore := session.NewStore()
+	app.Use(New(Config{
+		SigningKeys: [][]byte{signingKey('a')},
+		Session:     sstore,
+	}))
+
+	app.Post("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	h := app.Handler()
+	ctx := &fasthttp.RequestCtx{}
+
+	// Issuing a token saves the fresh session it is bound to
+	ctx.Request.Header.SetMethod(velocity.MethodGet)
+	h(ctx)
+	sessionID := string(ctx.Response.Header.PeekCookie("session_id"))
+	require.NotEmpty(t, sessionID)
+	sessionID = strings.Split(strings.Split(sessionID, ";")[0], "=")[1]
+	token := strings.Split(strings.Split(csrfCookie(ctx), ";")[0], "=")[1]
+
+	ctx.Request.Reset()
+	ctx.Response.Reset()
+	ctx.Request.Header.SetMethod(velocity.MethodPost)
+	ctx.Request.Header.Set(HeaderName, token)
+	ctx.Request.Header.SetCookie(ConfigDefault.CookieName, token)
+	ctx.Request.Header.SetCookie("session_id", sessionID)
+	h(ctx)
+	require.Equal(t, 200, ctx.Response.StatusCode())
+}
+
+// countingStorage counts the calls of Get and Set
+type countingStorage struct {
+	velocity.Storage
+	calls atomic.Int64
+}
+
+func (s *countingStorage) Get(key string) ([]byte, error) {
+	s.calls.Add(1)
+	return s.Storage.Get(key)
+}
+
+func (s *countingStorage) Set(key string, val []byte, exp time.Duration) error {
+	s.calls.Add(1)
+	return s.Storage.Set(key, val, exp)
+}
@@ -0,0 +1,152 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/log"
+	"github.com/khulnasoft/velocity/middleware/session"
+)
+
+const (
+	signedNonceSize = 16
+	signedTokenSize = signedNonceSize + 8 + sha256.Size // nonce, expiration, signature
+
+	// minSigningKeySize is the minimum size of the signing keys
+	minSigningKeySize = 32
+
+	// usedTokenPrefix prefixes the storage keys of the used single-use tokens
+	usedTokenPrefix = "csrf_used_"
+)
+
+// signedManager issues and verifies HMAC-signed tokens,
+// which embed their expiration and are bound to the session ID
+type signedManager struct {
+	session     *session.Store
+	keys        [][]byte
+	idleTimeout time.Duration
+}
+
+func newSignedManager(keys [][]byte, s *session.Store, idleTimeout time.Duration) *signedManager {
+	for _, key := range keys {
+		if len(key) < minSigningKeySize {
+			panic("[CSRF] SigningKeys must be at least 32 bytes long")
+		}
+	}
+	return &signedManager{
+		keys:        keys,
+		session:     s,
+		idleTimeout: idleTimeout,
+	}
+}
+
+// generate issues a new token, signed with the first key
+func (m *signedManager) generate(c velocity.Ctx) string {
+	token := make([]byte, signedNonceSize, signedTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		panic(err)
+	}
+	token = binary.BigEndian.AppendUint64(token, uint64(time.Now().Add(m.idleTimeout).Unix())) //nolint:gosec // Not negative
+	token = append(token, m.sign(m.keys[0], token, m.sessionID(c, true))...)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// verify checks the signature, the expiration and the session binding of the token.
+//
+// Returns:
+//   - time.Time: The expiration of the token.
+//   - string: The nonce of the token, which identifies it.
+//   - bool: Whether the token is valid.
+func (m *signedManager) verify(c velocity.Ctx, token string) (time.Time, string, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != signedTokenSize {
+		return time.Time{}, "", false
+	}
+
+	payload, signature := raw[:signedTokenSize-sha256.Size], raw[signedTokenSize-sha256.Size:]
+	expires := time.Unix(int64(binary.BigEndian.Uint64(payload[signedNonceSize:])), 0) //nolint:gosec // Written by generate
+	if !time.Now().Before(expires) {
+		return time.Time{}, "", false
+	}
+
+	sessionID := m.sessionID(c, false)
+	for _, key := range m.keys {
+		if hmac.Equal(signature, m.sign(key, payload, sessionID)) {
+			return expires, hex.EncodeToString(payload[:signedNonceSize]), true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// renew reports whether a token expiring at expires should be replaced,
+// because less than half of the IdleTimeout is left
+func (m *signedManager) renew(expires time.Time) bool {
+	return time.Until(expires) < m.idleTimeout/2
+}
+
+func (*signedManager) sign(key, payload []byte, sessionID string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(payload)           //nolint:errcheck // Never fails
+	_, _ = mac.Write([]byte(sessionID)) //nolint:errcheck // Never fails
+	return mac.Sum(nil)
+}
+
+// sessionID returns the ID of the session the tokens are bound to. If the
+// session middleware is not used and the session is fresh, issuing a token
+// saves it, so the token stays bound to the same session.
+func (m *signedManager) sessionID(c velocity.Ctx, issue bool) string {
+	if m.session == nil {
+		return ""
+	}
+	if sess := session.FromContext(c); sess != nil {
+		return sess.Session.ID()
+	}
+	sess, err := m.session.Get(c)
+	if err != nil {
+		return ""
+	}
+	defer sess.Release()
+	if issue && sess.Fresh() {
+		if err := sess.Save(); err != nil {
+			log.Warn("csrf: failed to save session: ", err)
+		}
+	}
+	return sess.ID()
+}
+
+// maskToken masks the token with a random one-time pad, so the token
+// sent in a compressed response differs on every request (BREACH).
+func maskToken(token string) string {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return token
+	}
+	masked := make([]byte, 2*len(raw))
+	if _, err := rand.Read(masked[:len(raw)]); err != nil {
+		panic(err)
+	}
+	for i, b := range raw {
+		masked[len(raw)+i] = b ^ masked[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// unmaskToken reverses maskToken. Tokens which are not masked,
+// e.g. the token in the cookie, are returned unchanged.
+func unmaskToken(token string) string {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 2*signedTokenSize {
+		return token
+	}
+	pad, masked := raw[:signedTokenSize], raw[signedTokenSize:]
+	for i := range masked {
+		masked[i] ^= pad[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
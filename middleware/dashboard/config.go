@@ -0,0 +1,81 @@
+package dashboard
+
+import (
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	// Use it to restrict the access to the dashboard, requests skipped
+	// by Next are neither served the dashboard nor counted.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// Path is the path where the HTML dashboard is served.
+	// The JSON statistics are served at Path + "/api".
+	//
+	// Optional. Default: "/dashboard"
+	Path string
+
+	// Title is the title of the HTML dashboard.
+	//
+	// Optional. Default: "Velocity Dashboard"
+	Title string
+
+	// Refresh is the interval in which the HTML dashboard reloads the
+	// statistics. It can be overridden with the refresh query parameter,
+	// e.g. /dashboard?refresh=10s.
+	//
+	// Optional. Default: 3 * time.Second
+	Refresh time.Duration
+
+	// Window is the period over which the request and error rates
+	// are calculated. It is rounded to seconds.
+	//
+	// Optional. Default: 1 * time.Minute
+	Window time.Duration
+
+	// APIOnly disables the HTML dashboard and only serves the JSON statistics.
+	//
+	// Optional. Default: false
+	APIOnly bool
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:    nil,
+	Path:    "/dashboard",
+	Title:   "Velocity Dashboard",
+	Refresh: 3 * time.Second,
+	Window:  1 * time.Minute,
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Path == "" {
+		cfg.Path = ConfigDefault.Path
+	}
+	if cfg.Title == "" {
+		cfg.Title = ConfigDefault.Title
+	}
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = ConfigDefault.Refresh
+	}
+	if cfg.Window < time.Second {
+		cfg.Window = ConfigDefault.Window
+	}
+	return cfg
+}
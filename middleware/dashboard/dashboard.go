@@ -0,0 +1,129 @@
+package dashboard
+
+import (
+	"errors"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// unmatchedRoute is the route of requests which did not match any handler route
+const unmatchedRoute = "unmatched"
+
+// Stats are the statistics served as JSON at Path + "/api".
+type Stats struct {
+	Process     Process        `json:"process"`
+	Routes      []Route        `json:"routes"`
+	Latency     []RouteLatency `json:"latency"`
+	Requests    Requests       `json:"requests"`
+	Uptime      float64        `json:"uptime_seconds"`
+	Window      float64        `json:"window_seconds"`
+	Refresh     float64        `json:"refresh_seconds"`
+	Connections int32          `json:"open_connections"`
+}
+
+// Route is a registered route in the statistics.
+type Route struct {
+	Method string `json:"method"`
+	Name   string `json:"name,omitempty"`
+	Path   string `json:"path"`
+}
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	rec := newRecorder(cfg.Window)
+	cpu := newSampler()
+	apiPath := cfg.Path + "/api"
+
+	page := strings.NewReplacer(
+		"{{title}}", html.EscapeString(cfg.Title),
+		"{{api}}", html.EscapeString(apiPath),
+		"{{refresh}}", strconv.FormatInt(cfg.Refresh.Milliseconds(), 10),
+	).Replace(dashboardHTML)
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Serve the dashboard
+		if c.Method() == velocity.MethodGet || c.Method() == velocity.MethodHead {
+			switch c.Path() {
+			case apiPath:
+				now := time.Now()
+				c.Set(velocity.HeaderCacheControl, "no-store")
+				return c.JSON(Stats{
+					Process:     cpu.process(now),
+					Routes:      routes(c.App()),
+					Latency:     rec.latencies(),
+					Requests:    rec.requests(now),
+					Uptime:      now.Sub(rec.started).Seconds(),
+					Window:      cfg.Window.Truncate(time.Second).Seconds(),
+					Refresh:     cfg.Refresh.Seconds(),
+					Connections: openConnections(c.App()),
+				})
+			case cfg.Path:
+				if !cfg.APIOnly {
+					c.Set(velocity.HeaderContentType, velocity.MIMETextHTMLCharsetUTF8)
+					return c.SendString(page)
+				}
+			}
+		}
+
+		method := utils.CopyString(c.Method())
+		rec.begin()
+		start := time.Now()
+
+		// Continue stack
+		err := c.Next()
+
+		now := time.Now()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = velocity.StatusInternalServerError
+			var velocityErr *velocity.Error
+			if errors.As(err, &velocityErr) {
+				status = velocityErr.Code
+			}
+		}
+
+		// Requests which only passed middlewares share one route,
+		// so unknown paths don't grow the statistics
+		route := unmatchedRoute
+		if c.Matched() {
+			route = c.Route().Path
+		}
+
+		rec.end(routeKey{Method: method, Route: route}, now, now.Sub(start), status >= velocity.StatusInternalServerError)
+
+		return err
+	}
+}
+
+// routes returns the handler routes of the app
+func routes(app *velocity.App) []Route {
+	registered := app.GetRoutes(true)
+	out := make([]Route, len(registered))
+	for i, route := range registered {
+		out[i] = Route{Method: route.Method, Name: route.Name, Path: route.Path}
+	}
+	return out
+}
+
+// openConnections returns the number of connections open to the server
+func openConnections(app *velocity.App) int32 {
+	if server := app.Server(); server != nil {
+		return server.GetOpenConnectionsCount()
+	}
+	return 0
+}
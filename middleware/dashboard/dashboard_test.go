@@ -0,0 +1,251 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func fetchStats(t *testing.T, app *velocity.App, path string) Stats {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, path, nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, velocity.MIMEApplicationJSON, resp.Header.Get(velocity.HeaderContentType))
+	require.Equal(t, "no-store", resp.Header.Get(velocity.HeaderCacheControl))
+
+	var stats Stats
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	return stats
+}
+
+// go test -run Test_Dashboard
+func Test_Dashboard(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{Refresh: 5 * time.Second, Window: 10 * time.Second}))
+
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		return c.SendString("hello")
+	}).Name("user")
+	app.Get("/fail", func(_ velocity.Ctx) error {
+		return velocity.ErrServiceUnavailable
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/fail", "/missing"} {
+		_, err := app.Test(httptest.NewRequest(velocity.MethodGet, path, nil))
+		require.NoError(t, err)
+	}
+
+	stats := fetchStats(t, app, "/dashboard/api")
+
+	require.Equal(t, uint64(4), stats.Requests.Total)
+	require.Equal(t, uint64(1), stats.Requests.Errors)
+	require.Zero(t, stats.Requests.InFlight)
+	require.Positive(t, stats.Requests.Rate)
+	require.Positive(t, stats.Requests.ErrorRate)
+	require.InDelta(t, 5.0, stats.Refresh, 0)
+	require.InDelta(t, 10.0, stats.Window, 0)
+	require.Positive(t, stats.Uptime)
+
+	require.Len(t, stats.Latency, 3)
+	require.Equal(t, "/fail", stats.Latency[0].Route)
+	require.Equal(t, uint64(1), stats.Latency[0].Errors)
+	require.Equal(t, "/users/:id", stats.Latency[1].Route)
+	require.Equal(t, velocity.MethodGet, stats.Latency[1].Method)
+	require.Equal(t, uint64(2), stats.Latency[1].Count)
+	require.Zero(t, stats.Latency[1].Errors)
+	require.LessOrEqual(t, stats.Latency[1].P99, stats.Latency[1].Max)
+	require.Equal(t, unmatchedRoute, stats.Latency[2].Route)
+
+	require.Contains(t, stats.Routes, Route{Method: velocity.MethodGet, Name: "user", Path: "/users/:id"})
+	require.Contains(t, stats.Routes, Route{Method: velocity.MethodGet, Path: "/fail"})
+
+	require.Equal(t, runtime.NumGoroutine() > 0, stats.Process.Goroutines > 0)
+	require.Positive(t, stats.Process.PID)
+	require.Positive(t, stats.Process.HeapAlloc)
+	if runtime.GOOS == "linux" {
+		require.Positive(t, stats.Process.RSS)
+		require.Positive(t, stats.Process.OpenFDs)
+	} else {
+		require.Equal(t, int64(-1), stats.Process.RSS)
+		require.Equal(t, -1, stats.Process.OpenFDs)
+	}
+
+	// Requests of the dashboard are not counted
+	require.Equal(t, uint64(4), fetchStats(t, app, "/dashboard/api").Requests.Total)
+}
+
+// go test -run Test_Dashboard_HTML
+func Test_Dashboard_HTML(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{Path: "/status", Title: "<Shop>"}))
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/status", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, velocity.MIMETextHTMLCharsetUTF8, resp.Header.Get(velocity.HeaderContentType))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "<title>&lt;Shop&gt;</title>")
+	require.Contains(t, string(body), `data-api="/status/api" data-refresh="3000"`)
+
+	fetchStats(t, app, "/status/api")
+}
+
+// go test -run Test_Dashboard_APIOnly
+func Test_Dashboard_APIOnly(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{APIOnly: true}))
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/dashboard", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusNotFound, resp.StatusCode)
+
+	fetchStats(t, app, "/dashboard/api")
+}
+
+// go test -run Test_Dashboard_Next
+func Test_Dashboard_Next(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{
+		Next: func(c velocity.Ctx) bool {
+			return c.Get("X-Admin") != "yes"
+		},
+	}))
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/dashboard/api", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusNotFound, resp.StatusCode)
+
+	req := httptest.NewRequest(velocity.MethodGet, "/dashboard/api", nil)
+	req.Header.Set("X-Admin", "yes")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+}
+
+// go test -run Test_Dashboard_Connections
+func Test_Dashboard_Connections(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New())
+
+	ln, err := net.Listen(velocity.NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		assert.NoError(t, app.Listener(ln, velocity.ListenConfig{DisableStartupMessage: true}))
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, app.Shutdown())
+	})
+
+	// The connection requesting the statistics is open
+	resp, err := client.Get("http://" + ln.Addr().String() + "/dashboard/api")
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode())
+
+	var stats Stats
+	require.NoError(t, resp.JSON(&stats))
+	require.Equal(t, int32(1), stats.Connections)
+}
+
+// go test -run Test_Recorder_Window
+func Test_Recorder_Window(t *testing.T) {
+	t.Parallel()
+
+	rec := newRecorder(10 * time.Second)
+	rec.started = time.Unix(1000, 0)
+	key := routeKey{Method: velocity.MethodGet, Route: "/"}
+
+	for sec := int64(1000); sec < 1020; sec++ {
+		rec.begin()
+		rec.end(key, time.Unix(sec, 0), time.Millisecond, sec%2 == 0)
+	}
+
+	// Only the last 10 seconds are counted
+	stats := rec.requests(time.Unix(1019, 0))
+	require.Equal(t, uint64(20), stats.Total)
+	require.Equal(t, uint64(10), stats.Errors)
+	require.InDelta(t, 1.0, stats.Rate, 0.001)
+	require.InDelta(t, 0.5, stats.ErrorRate, 0.001)
+
+	// Rates drop once the window passed
+	stats = rec.requests(time.Unix(1100, 0))
+	require.Zero(t, stats.Rate)
+	require.Zero(t, stats.ErrorRate)
+
+	// A younger app averages over its uptime
+	rec = newRecorder(time.Minute)
+	rec.started = time.Unix(1000, 0)
+	for i := 0; i < 4; i++ {
+		rec.begin()
+		rec.end(key, time.Unix(1001, 0), time.Millisecond, false)
+	}
+	require.InDelta(t, 2.0, rec.requests(time.Unix(1002, 0)).Rate, 0.001)
+}
+
+// go test -run Test_Recorder_Latency
+func Test_Recorder_Latency(t *testing.T) {
+	t.Parallel()
+
+	rec := newRecorder(time.Minute)
+	key := routeKey{Method: velocity.MethodGet, Route: "/"}
+
+	now := time.Now()
+	for i := 0; i < 98; i++ {
+		rec.end(key, now, time.Millisecond, false)
+	}
+	rec.end(key, now, time.Second, false)
+	rec.end(key, now, time.Second, false)
+
+	latency := rec.latencies()
+	require.Len(t, latency, 1)
+	require.Equal(t, uint64(100), latency[0].Count)
+	require.InDelta(t, 20.98, latency[0].Mean, 0.001)
+	require.InDelta(t, 1000.0, latency[0].Max, 0)
+	// 1ms falls into the bucket up to 1.6ms
+	require.InDelta(t, 1.6, latency[0].P50, 0)
+	require.InDelta(t, 1.6, latency[0].P95, 0)
+	require.InDelta(t, 1000.0, latency[0].P99, 0)
+}
+
+// go test -v -run=^$ -bench=Benchmark_Dashboard -benchmem -count=4
+func Benchmark_Dashboard(b *testing.B) {
+	app := velocity.New()
+	app.Use(New())
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod(velocity.MethodGet)
+	fctx.Request.SetRequestURI("/")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		h(fctx)
+	}
+}
@@ -0,0 +1,182 @@
+package dashboard
+
+// dashboardHTML is the HTML dashboard. It has no external dependencies,
+// so it also works in networks without internet access.
+const dashboardHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{title}}</title>
+<style>
+body { margin: 0; padding: 24px; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: #f5f6f8; color: #1f2328; }
+h1 { margin: 0 0 4px; font-size: 22px; }
+h2 { margin: 32px 0 12px; font-size: 16px; }
+.meta { color: #6e7781; font-size: 13px; }
+.meta.error { color: #cf222e; }
+.cards { display: grid; grid-template-columns: repeat(auto-fill, minmax(170px, 1fr)); gap: 12px; margin-top: 20px; }
+.card { background: #fff; border-radius: 8px; padding: 14px 16px; box-shadow: 0 1px 2px rgba(0,0,0,.08); }
+.card .label { color: #6e7781; font-size: 12px; text-transform: uppercase; letter-spacing: .04em; }
+.card .value { margin-top: 6px; font-size: 24px; font-weight: 600; font-variant-numeric: tabular-nums; }
+canvas { width: 100%; height: 120px; background: #fff; border-radius: 8px; box-shadow: 0 1px 2px rgba(0,0,0,.08); }
+table { width: 100%; border-collapse: collapse; background: #fff; border-radius: 8px; overflow: hidden; box-shadow: 0 1px 2px rgba(0,0,0,.08); font-size: 13px; }
+th, td { padding: 8px 12px; text-align: left; border-bottom: 1px solid #eaeef2; }
+th { background: #f6f8fa; font-weight: 600; }
+td.num, th.num { text-align: right; font-variant-numeric: tabular-nums; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; }
+</style>
+</head>
+<body data-api="{{api}}" data-refresh="{{refresh}}">
+<h1>{{title}}</h1>
+<div id="status" class="meta">Loading…</div>
+
+<div class="cards">
+  <div class="card"><div class="label">Requests / s</div><div class="value" id="rate">-</div></div>
+  <div class="card"><div class="label">Errors / s</div><div class="value" id="error_rate">-</div></div>
+  <div class="card"><div class="label">In flight</div><div class="value" id="in_flight">-</div></div>
+  <div class="card"><div class="label">Connections</div><div class="value" id="connections">-</div></div>
+  <div class="card"><div class="label">CPU</div><div class="value" id="cpu">-</div></div>
+  <div class="card"><div class="label">RSS</div><div class="value" id="rss">-</div></div>
+  <div class="card"><div class="label">Heap</div><div class="value" id="heap">-</div></div>
+  <div class="card"><div class="label">Goroutines</div><div class="value" id="goroutines">-</div></div>
+  <div class="card"><div class="label">Open FDs</div><div class="value" id="fds">-</div></div>
+  <div class="card"><div class="label">Uptime</div><div class="value" id="uptime">-</div></div>
+</div>
+
+<h2>Request and error rate</h2>
+<canvas id="chart" height="120"></canvas>
+
+<h2>Latency by route</h2>
+<table>
+  <thead><tr><th>Method</th><th>Route</th><th class="num">Requests</th><th class="num">Errors</th><th class="num">Mean</th><th class="num">p50</th><th class="num">p95</th><th class="num">p99</th><th class="num">Max</th></tr></thead>
+  <tbody id="latency"></tbody>
+</table>
+
+<h2>Routes</h2>
+<table>
+  <thead><tr><th>Method</th><th>Path</th><th>Name</th></tr></thead>
+  <tbody id="routes"></tbody>
+</table>
+
+<script>
+(function () {
+  var api = document.body.dataset.api;
+  var refresh = Number(document.body.dataset.refresh);
+  var history = [];
+
+  // The refresh query parameter overrides the interval, e.g. ?refresh=10s
+  var param = /^(\d+(?:\.\d+)?)(ms|s|m)?$/.exec(new URLSearchParams(location.search).get("refresh") || "");
+  if (param) {
+    var factor = { ms: 1, s: 1000, m: 60000 }[param[2] || "s"];
+    refresh = Math.max(Number(param[1]) * factor, 250);
+  }
+
+  function text(id, value) {
+    document.getElementById(id).textContent = value;
+  }
+
+  function bytes(n) {
+    if (n < 0) return "n/a";
+    var units = ["B", "KiB", "MiB", "GiB", "TiB"], i = 0;
+    while (n >= 1024 && i < units.length - 1) { n /= 1024; i++; }
+    return n.toFixed(i ? 1 : 0) + " " + units[i];
+  }
+
+  function duration(s) {
+    var d = Math.floor(s / 86400), h = Math.floor(s % 86400 / 3600), m = Math.floor(s % 3600 / 60);
+    if (d) return d + "d " + h + "h";
+    if (h) return h + "h " + m + "m";
+    if (m) return m + "m " + Math.floor(s % 60) + "s";
+    return Math.floor(s) + "s";
+  }
+
+  function ms(n) {
+    return n.toFixed(n < 10 ? 2 : 0) + " ms";
+  }
+
+  function rows(id, items, cells) {
+    var body = document.getElementById(id);
+    body.textContent = "";
+    items.forEach(function (item) {
+      var tr = document.createElement("tr");
+      cells(item).forEach(function (cell) {
+        var td = document.createElement("td");
+        if (typeof cell === "number") td.className = "num";
+        td.textContent = cell;
+        tr.appendChild(td);
+      });
+      body.appendChild(tr);
+    });
+  }
+
+  function draw() {
+    var canvas = document.getElementById("chart");
+    var ctx = canvas.getContext("2d");
+    canvas.width = canvas.clientWidth;
+    ctx.clearRect(0, 0, canvas.width, canvas.height);
+    var top = Math.max.apply(null, history.map(function (p) { return p.rate; }).concat([1]));
+    [["rate", "#0969da"], ["error_rate", "#cf222e"]].forEach(function (line) {
+      ctx.beginPath();
+      ctx.strokeStyle = line[1];
+      ctx.lineWidth = 2;
+      history.forEach(function (p, i) {
+        var x = canvas.width - (history.length - 1 - i) * (canvas.width / 59);
+        var y = canvas.height - 8 - p[line[0]] / top * (canvas.height - 16);
+        i ? ctx.lineTo(x, y) : ctx.moveTo(x, y);
+      });
+      ctx.stroke();
+    });
+  }
+
+  function render(stats) {
+    var r = stats.requests, p = stats.process;
+    text("rate", r.rate.toFixed(2));
+    text("error_rate", r.error_rate.toFixed(2));
+    text("in_flight", r.in_flight);
+    text("connections", stats.open_connections);
+    text("cpu", p.cpu_percent < 0 ? "n/a" : p.cpu_percent.toFixed(1) + " %");
+    text("rss", bytes(p.rss_bytes));
+    text("heap", bytes(p.heap_alloc_bytes));
+    text("goroutines", p.goroutines);
+    text("fds", p.open_fds < 0 ? "n/a" : p.open_fds);
+    text("uptime", duration(stats.uptime_seconds));
+
+    rows("latency", stats.latency, function (l) {
+      return [l.method, l.route, l.count, l.errors, ms(l.mean_ms), ms(l.p50_ms), ms(l.p95_ms), ms(l.p99_ms), ms(l.max_ms)];
+    });
+    rows("routes", stats.routes, function (route) {
+      return [route.method, route.path, route.name || ""];
+    });
+
+    history.push({ rate: r.rate, error_rate: r.error_rate });
+    if (history.length > 60) history.shift();
+    draw();
+  }
+
+  function update() {
+    var status = document.getElementById("status");
+    fetch(api, { headers: { Accept: "application/json" }, cache: "no-store" })
+      .then(function (resp) {
+        if (!resp.ok) throw new Error("HTTP " + resp.status);
+        return resp.json();
+      })
+      .then(function (stats) {
+        render(stats);
+        status.className = "meta";
+        status.textContent = "PID " + stats.process.pid + " · rates over " + stats.window_seconds + "s · updated " + new Date().toLocaleTimeString() + " · every " + refresh / 1000 + "s";
+      })
+      .catch(function (err) {
+        status.className = "meta error";
+        status.textContent = "Update failed: " + err.message;
+      })
+      .then(function () {
+        setTimeout(update, refresh);
+      });
+  }
+
+  update();
+})();
+</script>
+</body>
+</html>
+`
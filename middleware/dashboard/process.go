@@ -0,0 +1,72 @@
+package dashboard
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Process are the statistics of the process. Values which are not
+// available on the platform are reported as -1.
+type Process struct {
+	PID        int     `json:"pid"`
+	CPUPercent float64 `json:"cpu_percent"`
+	RSS        int64   `json:"rss_bytes"`
+	OpenFDs    int     `json:"open_fds"`
+	Goroutines int     `json:"goroutines"`
+	HeapAlloc  uint64  `json:"heap_alloc_bytes"`
+	NumGC      uint32  `json:"gc_runs"`
+}
+
+// minCPUInterval is the minimum interval between the CPU samples,
+// shorter intervals return the previous utilization
+const minCPUInterval = 500 * time.Millisecond
+
+// sampler calculates the CPU utilization between two requests of the statistics
+type sampler struct {
+	wall    time.Time
+	cpu     time.Duration
+	percent float64
+	mu      sync.Mutex
+}
+
+func newSampler() *sampler {
+	return &sampler{wall: time.Now(), cpu: cpuTime()}
+}
+
+// cpuPercent returns the CPU utilization of the process since the previous
+// sample, where 100 means one fully used core
+func (s *sampler) cpuPercent(now time.Time) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := now.Sub(s.wall)
+	if elapsed < minCPUInterval {
+		return s.percent
+	}
+
+	cpu := cpuTime()
+	if cpu < 0 {
+		s.percent = -1
+		return s.percent
+	}
+	s.percent = float64(cpu-s.cpu) / float64(elapsed) * 100
+	s.wall, s.cpu = now, cpu
+	return s.percent
+}
+
+func (s *sampler) process(now time.Time) Process {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return Process{
+		PID:        os.Getpid(),
+		CPUPercent: s.cpuPercent(now),
+		RSS:        residentMemory(),
+		OpenFDs:    openFDs(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  mem.HeapAlloc,
+		NumGC:      mem.NumGC,
+	}
+}
@@ -0,0 +1,52 @@
+package dashboard
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// cpuTime returns the user and system CPU time consumed by the process
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return -1
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// residentMemory returns the resident set size of the process in bytes
+func residentMemory() int64 {
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return -1
+	}
+	// The second field is the number of resident pages
+	fields := bytes.Fields(statm)
+	if len(fields) < 2 {
+		return -1
+	}
+	pages, err := strconv.ParseInt(string(fields[1]), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return pages * int64(os.Getpagesize())
+}
+
+// openFDs returns the number of file descriptors opened by the process
+func openFDs() int {
+	dir, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	defer dir.Close() //nolint:errcheck // the directory is only read
+
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return -1
+	}
+	// Don't count the descriptor used to read the directory
+	return len(names) - 1
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package dashboard
+
+import (
+	"time"
+)
+
+// cpuTime is not available on this platform
+func cpuTime() time.Duration {
+	return -1
+}
+
+// residentMemory is not available on this platform
+func residentMemory() int64 {
+	return -1
+}
+
+// openFDs is not available on this platform
+func openFDs() int {
+	return -1
+}
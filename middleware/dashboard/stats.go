@@ -0,0 +1,207 @@
+package dashboard
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the latency histogram used to
+// approximate the percentiles, doubling from 100µs to about 105s.
+var latencyBuckets = func() []time.Duration {
+	buckets := make([]time.Duration, 21)
+	for i := range buckets {
+		buckets[i] = 100 * time.Microsecond << i
+	}
+	return buckets
+}()
+
+// routeKey identifies the statistics of a route
+type routeKey struct {
+	Method string
+	Route  string
+}
+
+// routeStats holds the latency statistics of a route
+type routeStats struct {
+	buckets []uint64
+	total   time.Duration
+	max     time.Duration
+	count   uint64
+	errors  uint64
+}
+
+// RouteLatency is the latency of a route in the statistics. Durations are in
+// milliseconds, the percentiles are approximated by a histogram.
+type RouteLatency struct {
+	Method string  `json:"method"`
+	Route  string  `json:"route"`
+	Count  uint64  `json:"count"`
+	Errors uint64  `json:"errors"`
+	Mean   float64 `json:"mean_ms"`
+	Max    float64 `json:"max_ms"`
+	P50    float64 `json:"p50_ms"`
+	P95    float64 `json:"p95_ms"`
+	P99    float64 `json:"p99_ms"`
+}
+
+// window counts requests and errors in one-second slots of a ring,
+// so the rates cover the last seconds without keeping every request
+type window struct {
+	seconds  []int64
+	requests []uint64
+	errors   []uint64
+}
+
+// recorder collects the request statistics
+type recorder struct {
+	started time.Time
+	routes  map[routeKey]*routeStats
+	window  window
+	total   uint64
+	errors  uint64
+	active  int64
+	mu      sync.Mutex
+}
+
+func newRecorder(size time.Duration) *recorder {
+	n := int(size / time.Second)
+	return &recorder{
+		started: time.Now(),
+		routes:  make(map[routeKey]*routeStats),
+		window: window{
+			seconds:  make([]int64, n),
+			requests: make([]uint64, n),
+			errors:   make([]uint64, n),
+		},
+	}
+}
+
+func (r *recorder) begin() {
+	r.mu.Lock()
+	r.active++
+	r.mu.Unlock()
+}
+
+func (r *recorder) end(key routeKey, now time.Time, latency time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.active--
+	r.total++
+
+	stats, ok := r.routes[key]
+	if !ok {
+		stats = &routeStats{buckets: make([]uint64, len(latencyBuckets)+1)}
+		r.routes[key] = stats
+	}
+	stats.count++
+	stats.total += latency
+	stats.max = max(stats.max, latency)
+	stats.buckets[sort.Search(len(latencyBuckets), func(i int) bool {
+		return latency <= latencyBuckets[i]
+	})]++
+
+	sec := now.Unix()
+	slot := int(sec % int64(len(r.window.seconds)))
+	if r.window.seconds[slot] != sec {
+		r.window.seconds[slot] = sec
+		r.window.requests[slot] = 0
+		r.window.errors[slot] = 0
+	}
+	r.window.requests[slot]++
+
+	if failed {
+		r.errors++
+		stats.errors++
+		r.window.errors[slot]++
+	}
+}
+
+// Requests are the request statistics of the app
+type Requests struct {
+	Total     uint64  `json:"total"`
+	Errors    uint64  `json:"errors"`
+	InFlight  int64   `json:"in_flight"`
+	Rate      float64 `json:"rate"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// requests returns the totals and the rates per second within the window
+func (r *recorder) requests(now time.Time) Requests {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := Requests{
+		Total:    r.total,
+		Errors:   r.errors,
+		InFlight: r.active,
+	}
+
+	sec := now.Unix()
+	size := int64(len(r.window.seconds))
+	var requests, errors uint64
+	for i, s := range r.window.seconds {
+		if sec-s < size {
+			requests += r.window.requests[i]
+			errors += r.window.errors[i]
+		}
+	}
+
+	// Average over the time the app ran if it is shorter than the window
+	period := min(max(now.Sub(r.started).Seconds(), 1), float64(size))
+	stats.Rate = float64(requests) / period
+	stats.ErrorRate = float64(errors) / period
+
+	return stats
+}
+
+// latencies returns the latency statistics of all routes, sorted by route
+func (r *recorder) latencies() []RouteLatency {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]RouteLatency, 0, len(r.routes))
+	for key, stats := range r.routes {
+		out = append(out, RouteLatency{
+			Method: key.Method,
+			Route:  key.Route,
+			Count:  stats.count,
+			Errors: stats.errors,
+			Mean:   milliseconds(stats.total / time.Duration(stats.count)),
+			Max:    milliseconds(stats.max),
+			P50:    milliseconds(stats.percentile(0.5)),
+			P95:    milliseconds(stats.percentile(0.95)),
+			P99:    milliseconds(stats.percentile(0.99)),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Route != out[j].Route {
+			return out[i].Route < out[j].Route
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
+
+// percentile returns the upper bound of the bucket containing the
+// percentile, capped by the maximum latency
+func (s *routeStats) percentile(q float64) time.Duration {
+	rank := uint64(math.Ceil(q * float64(s.count)))
+	var seen uint64
+	for i, n := range s.buckets {
+		seen += n
+		if seen >= rank {
+			if i < len(latencyBuckets) {
+				return min(latencyBuckets[i], s.max)
+			}
+			break
+		}
+	}
+	return s.max
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
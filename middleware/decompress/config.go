@@ -0,0 +1,54 @@
+package decompress
+
+import (
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// MaxDecompressedSize is the maximum size in bytes of the decoded
+	// request body. Larger bodies are rejected with 413 Request Entity Too Large.
+	//
+	// Optional. Default: the app's Config.BodyLimit
+	MaxDecompressedSize int
+
+	// MaxRatio is the maximum ratio between the decoded and the encoded body
+	// size. Bodies that expand further are treated as decompression bombs and
+	// are rejected with 413 Request Entity Too Large. Set to -1 to disable the check.
+	//
+	// Optional. Default: 100
+	MaxRatio int
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:                nil,
+	MaxDecompressedSize: 0,
+	MaxRatio:            100,
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.MaxDecompressedSize < 0 {
+		cfg.MaxDecompressedSize = ConfigDefault.MaxDecompressedSize
+	}
+	if cfg.MaxRatio == 0 {
+		cfg.MaxRatio = ConfigDefault.MaxRatio
+	}
+
+	return cfg
+}
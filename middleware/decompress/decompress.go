@@ -0,0 +1,170 @@
+package decompress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/khulnasoft/velocity"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/bytebufferpool"
+)
+
+// supportedEncodings is sent in the Accept-Encoding response header when a
+// request is rejected because of an unknown content coding (RFC 7694).
+const supportedEncodings = "gzip, deflate, br, zstd"
+
+// Errors returned by the middleware. They are *velocity.Error values and are
+// therefore answered with their status code by the default error handler.
+var (
+	ErrBodyTooLarge = velocity.NewError(velocity.StatusRequestEntityTooLarge,
+		"Request Entity Too Large: decompressed body exceeds the size limit")
+	ErrRatioExceeded = velocity.NewError(velocity.StatusRequestEntityTooLarge,
+		"Request Entity Too Large: decompressed body exceeds the compression ratio limit")
+	ErrUnsupportedEncoding = velocity.NewError(velocity.StatusUnsupportedMediaType,
+		"Unsupported Media Type: unknown Content-Encoding")
+	ErrMalformedBody = velocity.NewError(velocity.StatusBadRequest,
+		"Bad Request: malformed compressed body")
+)
+
+var errLimitExceeded = errors.New("decompress: limit exceeded")
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Continue stack if the body is not encoded
+		header := c.Request().Header.ContentEncoding()
+		if len(header) == 0 {
+			return c.Next()
+		}
+
+		encodings := strings.Split(string(header), ",")
+		for i := range encodings {
+			encodings[i] = strings.ToLower(strings.TrimSpace(encodings[i]))
+			if !isSupported(encodings[i]) {
+				c.Set(velocity.HeaderAcceptEncoding, supportedEncodings)
+				return ErrUnsupportedEncoding
+			}
+		}
+
+		body := c.Request().Body()
+		if len(body) == 0 {
+			c.Request().Header.Del(velocity.HeaderContentEncoding)
+			return c.Next()
+		}
+		maxSize := limit(c, cfg, len(body))
+
+		buf := bytebufferpool.Get()
+		defer bytebufferpool.Put(buf)
+
+		// Codings are listed in the order they were applied, so undo them in reverse
+		// https://www.rfc-editor.org/rfc/rfc9110#section-8.4-5
+		for i := len(encodings) - 1; i >= 0; i-- {
+			if encodings[i] == "identity" {
+				continue
+			}
+			buf.Reset()
+			if err := decode(buf, encodings[i], body, maxSize); err != nil {
+				if errors.Is(err, errLimitExceeded) {
+					if maxSize < sizeLimit(c, cfg) {
+						return ErrRatioExceeded
+					}
+					return ErrBodyTooLarge
+				}
+				return ErrMalformedBody
+			}
+			body = append(body[:0:0], buf.B...)
+		}
+
+		// Replace the encoded body, so Body() and Bind() see the plain payload
+		c.Request().SetBody(body)
+		c.Request().Header.Del(velocity.HeaderContentEncoding)
+		c.Request().Header.SetContentLength(len(body))
+
+		return c.Next()
+	}
+}
+
+// sizeLimit returns the configured maximum decoded body size.
+func sizeLimit(c velocity.Ctx, cfg Config) int {
+	if cfg.MaxDecompressedSize > 0 {
+		return cfg.MaxDecompressedSize
+	}
+	return c.App().Config().BodyLimit
+}
+
+// limit returns the maximum decoded size allowed for an encoded body of the given length.
+func limit(c velocity.Ctx, cfg Config, encodedLen int) int {
+	maxSize := sizeLimit(c, cfg)
+	if cfg.MaxRatio > 0 {
+		if ratioLimit := encodedLen * cfg.MaxRatio; ratioLimit < maxSize {
+			return ratioLimit
+		}
+	}
+	return maxSize
+}
+
+func isSupported(encoding string) bool {
+	switch encoding {
+	case velocity.StrGzip, velocity.StrDeflate, velocity.StrBr, velocity.StrBrotli, velocity.StrZstd, "identity":
+		return true
+	default:
+		return false
+	}
+}
+
+// decode writes the decoded body to w, failing with errLimitExceeded
+// as soon as more than maxSize bytes have been produced.
+func decode(w io.Writer, encoding string, body []byte, maxSize int) error {
+	var (
+		r   io.Reader
+		err error
+	)
+	src := bytes.NewReader(body)
+	switch encoding {
+	case velocity.StrGzip:
+		r, err = gzip.NewReader(src)
+	case velocity.StrDeflate:
+		r, err = zlib.NewReader(src)
+	case velocity.StrBr, velocity.StrBrotli:
+		r = brotli.NewReader(src)
+	case velocity.StrZstd:
+		var zr *zstd.Decoder
+		zr, err = zstd.NewReader(src,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(maxSize)+1), //nolint:gosec // maxSize is never negative
+		)
+		if err == nil {
+			defer zr.Close()
+		}
+		r = zr
+	}
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(w, io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+			return errLimitExceeded
+		}
+		return err
+	}
+	if n > int64(maxSize) {
+		return errLimitExceeded
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package decompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/khulnasoft/velocity"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+		require.NoError(t, err)
+	}
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func newApp(config ...Config) *velocity.App {
+	app := velocity.New(velocity.Config{BodyLimit: 64 * 1024})
+	app.Use(New(config...))
+	app.Post("/", func(c velocity.Ctx) error {
+		var payload struct {
+			Name string `json:"name"`
+		}
+		if err := c.Bind().JSON(&payload); err != nil {
+			return err
+		}
+		return c.SendString(payload.Name + ":" + c.Get(velocity.HeaderContentEncoding))
+	})
+	return app
+}
+
+// go test -run Test_Decompress
+func Test_Decompress(t *testing.T) {
+	t.Parallel()
+	payload := []byte(`{"name":"velocity"}`)
+
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			t.Parallel()
+			app := newApp()
+
+			req := httptest.NewRequest(velocity.MethodPost, "/", bytes.NewReader(encode(t, encoding, payload)))
+			req.Header.Set(velocity.HeaderContentType, velocity.MIMEApplicationJSON)
+			req.Header.Set(velocity.HeaderContentEncoding, encoding)
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "velocity:", string(body))
+		})
+	}
+}
+
+// go test -run Test_Decompress_Multiple
+func Test_Decompress_Multiple(t *testing.T) {
+	t.Parallel()
+	app := newApp()
+
+	// gzip was applied first, then br
+	encoded := encode(t, "br", encode(t, "gzip", []byte(`{"name":"layered"}`)))
+	req := httptest.NewRequest(velocity.MethodPost, "/", bytes.NewReader(encoded))
+	req.Header.Set(velocity.HeaderContentType, velocity.MIMEApplicationJSON)
+	req.Header.Set(velocity.HeaderContentEncoding, "gzip, identity, br")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "layered:", string(body))
+}
+
+// go test -run Test_Decompress_Plain
+func Test_Decompress_Plain(t *testing.T) {
+	t.Parallel()
+	app := newApp()
+
+	req := httptest.NewRequest(velocity.MethodPost, "/", strings.NewReader(`{"name":"plain"}`))
+	req.Header.Set(velocity.HeaderContentType, velocity.MIMEApplicationJSON)
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "plain:", string(body))
+}
+
+// go test -run Test_Decompress_Limits
+func Test_Decompress_Limits(t *testing.T) {
+	t.Parallel()
+	large := []byte(`{"name":"` + strings.Repeat("a", 100*1024) + `"}`)
+	medium := []byte(`{"name":"` + strings.Repeat("b", 8*1024) + `"}`)
+
+	testCases := []struct {
+		name    string
+		config  []Config
+		payload []byte
+		status  int
+	}{
+		{name: "body limit", payload: large, config: []Config{{MaxRatio: -1}}, status: velocity.StatusRequestEntityTooLarge},
+		{name: "max size", payload: medium, config: []Config{{MaxDecompressedSize: 1024, MaxRatio: -1}}, status: velocity.StatusRequestEntityTooLarge},
+		{name: "ratio", payload: medium, config: []Config{{MaxRatio: 10}}, status: velocity.StatusRequestEntityTooLarge},
+		{name: "ratio disabled", payload: medium, config: []Config{{MaxRatio: -1}}, status: velocity.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			app := newApp(tc.config...)
+
+			req := httptest.NewRequest(velocity.MethodPost, "/", bytes.NewReader(encode(t, "gzip", tc.payload)))
+			req.Header.Set(velocity.HeaderContentType, velocity.MIMEApplicationJSON)
+			req.Header.Set(velocity.HeaderContentEncoding, "gzip")
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tc.status, resp.StatusCode)
+		})
+	}
+}
+
+// go test -run Test_Decompress_Errors
+func Test_Decompress_Errors(t *testing.T) {
+	t.Parallel()
+	app := newApp()
+
+	req := httptest.NewRequest(velocity.MethodPost, "/", strings.NewReader("data"))
+	req.Header.Set(velocity.HeaderContentEncoding, "compress")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusUnsupportedMediaType, resp.StatusCode)
+	require.Equal(t, supportedEncodings, resp.Header.Get(velocity.HeaderAcceptEncoding))
+
+	req = httptest.NewRequest(velocity.MethodPost, "/", strings.NewReader("not gzip at all"))
+	req.Header.Set(velocity.HeaderContentEncoding, "gzip")
+
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusBadRequest, resp.StatusCode)
+}
+
+// go test -run Test_Decompress_Next
+func Test_Decompress_Next(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+	app.Use(New(Config{
+		Next: func(_ velocity.Ctx) bool {
+			return true
+		},
+	}))
+	app.Post("/", func(c velocity.Ctx) error {
+		return c.SendString(c.Get(velocity.HeaderContentEncoding))
+	})
+
+	req := httptest.NewRequest(velocity.MethodPost, "/", strings.NewReader("data"))
+	req.Header.Set(velocity.HeaderContentEncoding, "compress")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "compress", string(body))
+}
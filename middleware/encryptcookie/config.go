@@ -2,6 +2,7 @@ package encryptcookie
 
 import (
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/keyring"
 )
 
 // Config defines the config for middleware.
@@ -11,36 +12,75 @@ type Config struct {
 	// Optional. Default: nil
 	Next func(c velocity.Ctx) bool
 
-	// Custom function to encrypt cookies.
+	// Custom function to encrypt cookies. It is called with the first key.
+	// Custom functions replace the key ring, so the values carry no key ID
+	// and are not bound to the cookie name.
 	//
-	// Optional. Default: EncryptCookie (using AES-GCM)
+	// Optional. Default: nil (the key ring encrypts)
 	Encryptor func(decryptedString, key string) (string, error)
 
-	// Custom function to decrypt cookies.
+	// Custom function to decrypt cookies. It is called with every key
+	// until one succeeds.
 	//
-	// Optional. Default: DecryptCookie (using AES-GCM)
+	// Optional. Default: nil (the key ring decrypts)
 	Decryptor func(encryptedString, key string) (string, error)
 
 	// Base64 encoded unique key to encode & decode cookies.
+	// It is a shorthand for a key ring of a single key.
 	//
-	// Required. Key length should be 16, 24, or 32 bytes when decoded
-	// if using the default EncryptCookie and DecryptCookie functions.
+	// Required if Keys is empty. Key length should be 16, 24, or 32 bytes
+	// when decoded, 32 bytes for XChaCha20-Poly1305.
 	// You may use `encryptcookie.GenerateKey(length)` to generate a new key.
 	Key string
 
+	// AEAD which encrypts the cookies, AlgorithmAESGCM or AlgorithmXChaCha20Poly1305.
+	//
+	// Optional. Default: AlgorithmAESGCM
+	Algorithm string
+
+	// Ordered key ring of base64 encoded keys, from the newest to the oldest.
+	// The first key encrypts and signs, all keys decrypt and verify, so a
+	// new key is added in front and the old key is removed once the cookies
+	// it encrypted have expired. Takes precedence over Key.
+	//
+	// Optional. Default: nil
+	Keys []string
+
 	// Array of cookie keys that should not be encrypted.
 	//
 	// Optional. Default: []
 	Except []string
+
+	// Array of cookie keys that are signed with HMAC-SHA256 instead of
+	// encrypted, so they stay readable by the client but cannot be tampered with.
+	//
+	// Optional. Default: []
+	SignOnly []string
+
+	// AllowLegacy also decrypts cookies encrypted with EncryptCookie by
+	// earlier versions of the middleware, without the key ID and the cookie
+	// name as associated data. Only AES-GCM key rings decrypt them, and they
+	// are encrypted with the key ring when they are set again. Enable it
+	// while migrating, and disable it once the legacy cookies have expired.
+	//
+	// Optional. Default: false
+	AllowLegacy bool
 }
 
+const (
+	// AlgorithmAESGCM encrypts the cookies with AES-GCM.
+	AlgorithmAESGCM = string(keyring.AESGCM)
+	// AlgorithmXChaCha20Poly1305 encrypts the cookies with XChaCha20-Poly1305.
+	AlgorithmXChaCha20Poly1305 = string(keyring.XChaCha20Poly1305)
+)
+
 // ConfigDefault is the default config
 var ConfigDefault = Config{
 	Next:      nil,
 	Except:    []string{},
+	SignOnly:  []string{},
 	Key:       "",
-	Encryptor: EncryptCookie,
-	Decryptor: DecryptCookie,
+	Algorithm: AlgorithmAESGCM,
 }
 
 // Helper function to set default values
@@ -61,16 +101,29 @@ func configDefault(config ...Config) Config {
 			cfg.Except = ConfigDefault.Except
 		}
 
-		if cfg.Encryptor == nil {
-			cfg.Encryptor = ConfigDefault.Encryptor
+		if cfg.SignOnly == nil {
+			cfg.SignOnly = ConfigDefault.SignOnly
+		}
+
+		if cfg.Algorithm == "" {
+			cfg.Algorithm = ConfigDefault.Algorithm
 		}
 
-		if cfg.Decryptor == nil {
-			cfg.Decryptor = ConfigDefault.Decryptor
+		// A custom function is paired with the default of the other one
+		if cfg.Encryptor != nil && cfg.Decryptor == nil {
+			cfg.Decryptor = DecryptCookie
 		}
+
+		if cfg.Decryptor != nil && cfg.Encryptor == nil {
+			cfg.Encryptor = EncryptCookie
+		}
+	}
+
+	if len(cfg.Keys) == 0 && cfg.Key != "" {
+		cfg.Keys = []string{cfg.Key}
 	}
 
-	if cfg.Key == "" {
+	if len(cfg.Keys) == 0 {
 		panic("velocity: encrypt cookie middleware requires key")
 	}
 
@@ -2,6 +2,7 @@ package encryptcookie
 
 import (
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/keyring"
 	"github.com/valyala/fasthttp"
 )
 
@@ -10,6 +11,14 @@ func New(config ...Config) velocity.Handler {
 	// Set default config
 	cfg := configDefault(config...)
 
+	var ring *keyring.Ring
+	if cfg.Encryptor == nil {
+		var err error
+		if ring, err = keyring.NewBase64(keyring.Algorithm(cfg.Algorithm), cfg.Keys...); err != nil {
+			panic("velocity: encrypt cookie middleware: " + err.Error())
+		}
+	}
+
 	// Return new handler
 	return func(c velocity.Ctx) error {
 		// Don't execute middleware if Next returns true
@@ -21,11 +30,11 @@ func New(config ...Config) velocity.Handler {
 		c.Request().Header.VisitAllCookie(func(key, value []byte) {
 			keyString := string(key)
 			if !isDisabled(keyString, cfg.Except) {
-				decryptedValue, err := cfg.Decryptor(string(value), cfg.Key)
+				decryptedValue, err := decrypt(&cfg, ring, keyString, string(value))
 				if err != nil {
 					c.Request().Header.SetCookieBytesKV(key, nil)
 				} else {
-					c.Request().Header.SetCookie(string(key), decryptedValue)
+					c.Request().Header.SetCookie(keyString, decryptedValue)
 				}
 			}
 		})
@@ -40,7 +49,7 @@ func New(config ...Config) velocity.Handler {
 				cookieValue := fasthttp.Cookie{}
 				cookieValue.SetKeyBytes(key)
 				if c.Response().Header.Cookie(&cookieValue) {
-					encryptedValue, err := cfg.Encryptor(string(cookieValue.Value()), cfg.Key)
+					encryptedValue, err := encrypt(&cfg, ring, keyString, string(cookieValue.Value()))
 					if err != nil {
 						panic(err)
 					}
@@ -54,3 +63,44 @@ func New(config ...Config) velocity.Handler {
 		return err
 	}
 }
+
+// encrypt encrypts or signs the value of the named cookie
+func encrypt(cfg *Config, ring *keyring.Ring, name, value string) (string, error) {
+	if ring == nil {
+		return cfg.Encryptor(value, cfg.Keys[0])
+	}
+	if isDisabled(name, cfg.SignOnly) {
+		return ring.Sign(name, value), nil
+	}
+	return ring.Encrypt(name, value)
+}
+
+// decrypt decrypts or verifies the value of the named cookie
+func decrypt(cfg *Config, ring *keyring.Ring, name, value string) (string, error) {
+	if ring == nil {
+		return decryptWithKeys(cfg.Decryptor, cfg.Keys, value)
+	}
+	if isDisabled(name, cfg.SignOnly) {
+		return ring.Verify(name, value)
+	}
+	decryptedValue, err := ring.Decrypt(name, value)
+	if err != nil && cfg.AllowLegacy && cfg.Algorithm == AlgorithmAESGCM {
+		// Cookies encrypted by EncryptCookie, before the key ring was introduced
+		if legacyValue, legacyErr := decryptWithKeys(DecryptCookie, cfg.Keys, value); legacyErr == nil {
+			return legacyValue, nil
+		}
+	}
+	return decryptedValue, err
+}
+
+// decryptWithKeys tries every key until the value is decrypted
+func decryptWithKeys(decryptor func(encryptedString, key string) (string, error), keys []string, value string) (string, error) {
+	var err error
+	for _, key := range keys {
+		var decryptedValue string
+		if decryptedValue, err = decryptor(value, key); err == nil {
+			return decryptedValue, nil
+		}
+	}
+	return "", err
+}
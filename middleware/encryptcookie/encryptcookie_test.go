@@ -5,13 +5,26 @@ import (
 	"encoding/base64"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/keyring"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
 )
 
+// decryptValue decrypts the value of the named cookie with the key
+func decryptValue(t *testing.T, key, name, value string) string {
+	t.Helper()
+
+	ring, err := keyring.NewBase64(keyring.AESGCM, key)
+	require.NoError(t, err)
+	decryptedValue, err := ring.Decrypt(name, value)
+	require.NoError(t, err)
+	return decryptedValue
+}
+
 func Test_Middleware_Panics(t *testing.T) {
 	t.Parallel()
 
@@ -31,6 +44,20 @@ func Test_Middleware_Panics(t *testing.T) {
 			GenerateKey(11)
 		})
 	})
+
+	t.Run("Invalid Key Length", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() {
+			New(Config{Keys: []string{GenerateKey(16)}, Algorithm: AlgorithmXChaCha20Poly1305})
+		})
+	})
+
+	t.Run("Unknown Algorithm", func(t *testing.T) {
+		t.Parallel()
+		require.Panics(t, func() {
+			New(Config{Key: GenerateKey(32), Algorithm: "ROT13"})
+		})
+	})
 }
 
 func Test_Middleware_InvalidKeys(t *testing.T) {
@@ -144,9 +171,7 @@ func Test_Middleware_Encrypt_Cookie(t *testing.T) {
 	encryptedCookie := fasthttp.Cookie{}
 	encryptedCookie.SetKey("test")
 	require.True(t, ctx.Response.Header.Cookie(&encryptedCookie), "Get cookie value")
-	decryptedCookieValue, err := DecryptCookie(string(encryptedCookie.Value()), testKey)
-	require.NoError(t, err)
-	require.Equal(t, "SomeThing", decryptedCookieValue)
+	require.Equal(t, "SomeThing", decryptValue(t, testKey, "test", string(encryptedCookie.Value())))
 
 	ctx = &fasthttp.RequestCtx{}
 	ctx.Request.Header.SetMethod(velocity.MethodGet)
@@ -221,9 +246,7 @@ func Test_Encrypt_Cookie_Except(t *testing.T) {
 	encryptedCookie := fasthttp.Cookie{}
 	encryptedCookie.SetKey("test2")
 	require.True(t, ctx.Response.Header.Cookie(&encryptedCookie), "Get cookie value")
-	decryptedCookieValue, err := DecryptCookie(string(encryptedCookie.Value()), testKey)
-	require.NoError(t, err)
-	require.Equal(t, "SomeThing", decryptedCookieValue)
+	require.Equal(t, "SomeThing", decryptValue(t, testKey, "test2", string(encryptedCookie.Value())))
 }
 
 func Test_Encrypt_Cookie_Custom_Encryptor(t *testing.T) {
@@ -680,3 +703,131 @@ func Benchmark_GenerateKey_Parallel(b *testing.B) {
 		})
 	}
 }
+
+// cookieApp returns the handler of an app which sets and echoes the cookie "test"
+func cookieApp(config Config) fasthttp.RequestHandler {
+	app := velocity.New()
+	app.Use(New(config))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("value=" + c.Cookies("test"))
+	})
+	app.Post("/", func(c velocity.Ctx) error {
+		c.Cookie(&velocity.Cookie{
+			Name:  "test",
+			Value: "SomeThing",
+		})
+		return nil
+	})
+	return app.Handler()
+}
+
+// setCookie returns the value of the cookie "test" set by the handler
+func setCookie(t *testing.T, h fasthttp.RequestHandler) string {
+	t.Helper()
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(velocity.MethodPost)
+	h(ctx)
+	require.Equal(t, 200, ctx.Response.StatusCode())
+
+	cookie := fasthttp.Cookie{}
+	cookie.SetKey("test")
+	require.True(t, ctx.Response.Header.Cookie(&cookie), "Get cookie value")
+	return string(cookie.Value())
+}
+
+// readCookie returns the value of the cookie "test" read by the handler
+func readCookie(t *testing.T, h fasthttp.RequestHandler, name, value string) string {
+	t.Helper()
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(velocity.MethodGet)
+	ctx.Request.Header.SetCookie(name, value)
+	h(ctx)
+	require.Equal(t, 200, ctx.Response.StatusCode())
+	return string(ctx.Response.Body())
+}
+
+func Test_Encrypt_Cookie_Key_Rotation(t *testing.T) {
+	t.Parallel()
+	oldKey, newKey := GenerateKey(32), GenerateKey(32)
+
+	oldValue := setCookie(t, cookieApp(Config{Key: oldKey}))
+
+	// The new key encrypts, the old key still decrypts
+	rotated := cookieApp(Config{Keys: []string{newKey, oldKey}})
+	require.Equal(t, "value=SomeThing", readCookie(t, rotated, "test", oldValue))
+	newValue := setCookie(t, rotated)
+	require.Equal(t, "SomeThing", decryptValue(t, newKey, "test", newValue))
+
+	// The old key is removed
+	removed := cookieApp(Config{Keys: []string{newKey}})
+	require.Equal(t, "value=SomeThing", readCookie(t, removed, "test", newValue))
+	require.Equal(t, "value=", readCookie(t, removed, "test", oldValue))
+}
+
+func Test_Encrypt_Cookie_XChaCha20Poly1305(t *testing.T) {
+	t.Parallel()
+	testKey := GenerateKey(32)
+
+	h := cookieApp(Config{Key: testKey, Algorithm: AlgorithmXChaCha20Poly1305})
+	value := setCookie(t, h)
+	require.Equal(t, "value=SomeThing", readCookie(t, h, "test", value))
+
+	// AES-GCM cannot decrypt it with the same key
+	require.Equal(t, "value=", readCookie(t, cookieApp(Config{Key: testKey}), "test", value))
+}
+
+func Test_Encrypt_Cookie_Name_Binding(t *testing.T) {
+	t.Parallel()
+	testKey := GenerateKey(32)
+
+	// otherApp returns the handler of an app which echoes the cookie "other"
+	otherApp := func(config Config) fasthttp.RequestHandler {
+		app := velocity.New()
+		app.Use(New(config))
+		app.Get("/", func(c velocity.Ctx) error {
+			return c.SendString("value=" + c.Cookies("other"))
+		})
+		return app.Handler()
+	}
+
+	// Values cannot be swapped between cookies
+	encrypted := setCookie(t, cookieApp(Config{Key: testKey}))
+	require.Equal(t, "value=", readCookie(t, otherApp(Config{Key: testKey}), "other", encrypted))
+
+	signed := setCookie(t, cookieApp(Config{Key: testKey, SignOnly: []string{"test"}}))
+	require.Equal(t, "value=", readCookie(t, otherApp(Config{Key: testKey, SignOnly: []string{"other"}}), "other", signed))
+}
+
+func Test_Encrypt_Cookie_SignOnly(t *testing.T) {
+	t.Parallel()
+	testKey := GenerateKey(32)
+
+	h := cookieApp(Config{Key: testKey, SignOnly: []string{"test"}})
+	value := setCookie(t, h)
+
+	// The value stays readable
+	require.True(t, strings.HasPrefix(value, "SomeThing."), value)
+	require.Equal(t, "value=SomeThing", readCookie(t, h, "test", value))
+
+	// Tampered values and values without signature are rejected
+	require.Equal(t, "value=", readCookie(t, h, "test", "Other"+strings.TrimPrefix(value, "SomeThing")))
+	require.Equal(t, "value=", readCookie(t, h, "test", "SomeThing"))
+
+	// Signed values are not accepted as encrypted ones and vice versa
+	require.Equal(t, "value=", readCookie(t, cookieApp(Config{Key: testKey}), "test", value))
+	require.Equal(t, "value=", readCookie(t, h, "test", setCookie(t, cookieApp(Config{Key: testKey}))))
+}
+
+func Test_Encrypt_Cookie_Legacy(t *testing.T) {
+	t.Parallel()
+	testKey := GenerateKey(32)
+
+	// Cookies encrypted before the key ring was introduced are decrypted if allowed
+	legacyValue, err := EncryptCookie("SomeThing", testKey)
+	require.NoError(t, err)
+	require.Equal(t, "value=SomeThing", readCookie(t, cookieApp(Config{Keys: []string{GenerateKey(32), testKey}, AllowLegacy: true}), "test", legacyValue))
+	require.Equal(t, "value=", readCookie(t, cookieApp(Config{Keys: []string{GenerateKey(32), testKey}}), "test", legacyValue))
+	require.Equal(t, "value=", readCookie(t, cookieApp(Config{Key: testKey, Algorithm: AlgorithmXChaCha20Poly1305, AllowLegacy: true}), "test", legacyValue))
+}
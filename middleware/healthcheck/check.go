@@ -0,0 +1,205 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/client"
+)
+
+// CheckFunc checks a dependency and returns nil if it is healthy.
+// It should return once the context is done.
+type CheckFunc func(ctx context.Context) error
+
+// Check is a named check of a dependency.
+type Check struct {
+	// Func checks the dependency.
+	Func CheckFunc
+
+	// Name identifies the check in the detailed response.
+	Name string
+
+	// Timeout limits the duration of the check.
+	//
+	// Optional. Default: Config.Timeout
+	Timeout time.Duration
+
+	// NonCritical checks only degrade the endpoint when they fail,
+	// it still reports the app as healthy.
+	//
+	// Optional. Default: false
+	NonCritical bool
+}
+
+// Statuses of the detailed response
+const (
+	StatusOK           = "ok"
+	StatusDegraded     = "degraded"
+	StatusUnhealthy    = "unhealthy"
+	StatusShuttingDown = "shutting_down"
+	StatusFailed       = "failed"
+)
+
+// Report is the detailed response, returned as JSON when the
+// endpoint is requested with the verbose query parameter.
+type Report struct {
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+	Status string                 `json:"status"`
+}
+
+// CheckResult is the result of a check in the detailed response.
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+	Critical bool   `json:"critical"`
+	Cached   bool   `json:"cached,omitempty"`
+}
+
+// checkState holds the cached result of a check
+type checkState struct {
+	expires time.Time
+	running *checkRun
+	result  CheckResult
+	check   Check
+	mu      sync.Mutex
+}
+
+// checkRun is a running check, whose result is shared with the
+// requests arriving while it runs once done is closed
+type checkRun struct {
+	done   chan struct{}
+	result CheckResult
+}
+
+// runChecks runs all checks concurrently and returns their results
+func runChecks(ctx context.Context, states []*checkState, cacheDuration time.Duration) map[string]CheckResult {
+	results := make([]CheckResult, len(states))
+
+	var wg sync.WaitGroup
+	for i, state := range states {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = state.run(ctx, cacheDuration)
+		}()
+	}
+	wg.Wait()
+
+	out := make(map[string]CheckResult, len(states))
+	for i, state := range states {
+		out[state.check.Name] = results[i]
+	}
+	return out
+}
+
+// run returns the cached result or runs the check. Concurrent
+// requests wait for the running check and share its result
+// instead of starting another.
+func (s *checkState) run(ctx context.Context, cacheDuration time.Duration) CheckResult {
+	s.mu.Lock()
+	if cacheDuration > 0 && time.Now().Before(s.expires) {
+		result := s.result
+		s.mu.Unlock()
+		result.Cached = true
+		return result
+	}
+	if run := s.running; run != nil {
+		s.mu.Unlock()
+		<-run.done
+		return run.result
+	}
+	run := &checkRun{done: make(chan struct{})}
+	s.running = run
+	s.mu.Unlock()
+
+	// The result is shared, so it must not depend on this request being canceled
+	start := time.Now()
+	err := execute(context.WithoutCancel(ctx), s.check)
+	run.result = CheckResult{
+		Status:   StatusOK,
+		Duration: time.Since(start).String(),
+		Critical: !s.check.NonCritical,
+	}
+	if err != nil {
+		run.result.Status = StatusFailed
+		run.result.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.result = run.result
+	s.expires = time.Now().Add(cacheDuration)
+	s.running = nil
+	s.mu.Unlock()
+	close(run.done)
+
+	return run.result
+}
+
+// execute runs the check with its timeout. A check ignoring its context is
+// abandoned when the timeout is exceeded.
+func execute(ctx context.Context, check Check) error {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("healthcheck: check panicked: %v", r)
+			}
+		}()
+		done <- check.Func(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("healthcheck: check timed out after %s: %w", check.Timeout, ctx.Err())
+	}
+}
+
+// Pinger is implemented by database handles such as *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingCheck returns a check pinging a database, e.g. a *sql.DB.
+func PingCheck(db Pinger) CheckFunc {
+	return db.PingContext
+}
+
+// StorageCheck returns a check reading a key from a storage, e.g. a cache.
+// Reading a missing key succeeds, so only failures of the storage are reported.
+func StorageCheck(storage velocity.Storage) CheckFunc {
+	return func(context.Context) error {
+		_, err := storage.Get("healthcheck")
+		return err
+	}
+}
+
+// HTTPCheck returns a check requesting a downstream service, which is healthy
+// if it responds with a 2xx status code. The default client is used if none is given.
+func HTTPCheck(url string, cc ...*client.Client) CheckFunc {
+	c := client.C()
+	if len(cc) > 0 && cc[0] != nil {
+		c = cc[0]
+	}
+
+	return func(ctx context.Context) error {
+		resp, err := c.R().SetContext(ctx).Get(url)
+		if err != nil {
+			return fmt.Errorf("healthcheck: request failed: %w", err)
+		}
+		defer resp.Close()
+
+		if status := resp.StatusCode(); status < velocity.StatusOK || status >= velocity.StatusMultipleChoices {
+			return fmt.Errorf("healthcheck: unexpected status code %d", status)
+		}
+		return nil
+	}
+}
@@ -1,6 +1,8 @@
 package healthcheck
 
 import (
+	"time"
+
 	"github.com/khulnasoft/velocity"
 )
 
@@ -19,6 +21,31 @@ type Config struct {
 	//
 	// Optional. Default: func(c velocity.Ctx) bool { return true }
 	Probe HealthChecker
+
+	// Checks are named checks of dependencies, e.g. a database, a cache or a downstream
+	// service. They run concurrently after the Probe succeeded. A failing critical check
+	// makes the endpoint unhealthy, a failing non-critical check only degrades it.
+	//
+	// Optional. Default: nil
+	Checks []Check
+
+	// Timeout limits the duration of each check, unless the check sets its own timeout.
+	//
+	// Optional. Default: 5 * time.Second
+	Timeout time.Duration
+
+	// CacheDuration is the time the result of a check is reused before it runs again.
+	// Concurrent requests always share a running check.
+	//
+	// Optional. Default: 0
+	CacheDuration time.Duration
+
+	// Readiness marks the endpoint as a readiness probe, which reports the app as
+	// unhealthy as soon as the shutdown with ShutdownWithContext begins draining.
+	// Liveness probes must not set it, or the app may be restarted while draining.
+	//
+	// Optional. Default: false
+	Readiness bool
 }
 
 const (
@@ -27,12 +54,16 @@ const (
 	DefaultStartupEndpoint   = "/startupz"
 )
 
+// defaultTimeout is the default timeout of a check
+const defaultTimeout = 5 * time.Second
+
 func defaultProbe(velocity.Ctx) bool { return true }
 
 func defaultConfigV3(config ...Config) Config {
 	if len(config) < 1 {
 		return Config{
-			Probe: defaultProbe,
+			Probe:   defaultProbe,
+			Timeout: defaultTimeout,
 		}
 	}
 
@@ -41,6 +72,9 @@ func defaultConfigV3(config ...Config) Config {
 	if cfg.Probe == nil {
 		cfg.Probe = defaultProbe
 	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
 
 	return cfg
 }
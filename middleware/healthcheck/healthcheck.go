@@ -1,15 +1,36 @@
 package healthcheck
 
 import (
+	"fmt"
+
 	"github.com/khulnasoft/velocity"
 )
 
 // HealthChecker defines a function to check liveness or readiness of the application
 type HealthChecker func(velocity.Ctx) bool
 
+// verboseParam is the query parameter requesting the detailed response
+const verboseParam = "verbose"
+
 func NewHealthChecker(config ...Config) velocity.Handler {
 	cfg := defaultConfigV3(config...)
 
+	states := make([]*checkState, len(cfg.Checks))
+	names := make(map[string]struct{}, len(cfg.Checks))
+	for i, check := range cfg.Checks {
+		if check.Func == nil {
+			panic(fmt.Sprintf("healthcheck: check %q has no Func", check.Name))
+		}
+		if _, ok := names[check.Name]; ok {
+			panic(fmt.Sprintf("healthcheck: duplicate check %q", check.Name))
+		}
+		names[check.Name] = struct{}{}
+		if check.Timeout <= 0 {
+			check.Timeout = cfg.Timeout
+		}
+		states[i] = &checkState{check: check}
+	}
+
 	return func(c velocity.Ctx) error {
 		// Don't execute middleware if Next returns true
 		if cfg.Next != nil && cfg.Next(c) {
@@ -20,10 +41,34 @@ func NewHealthChecker(config ...Config) velocity.Handler {
 			return c.Next()
 		}
 
-		if cfg.Probe(c) {
-			return c.SendStatus(velocity.StatusOK)
+		report := Report{Status: StatusOK}
+		switch {
+		case cfg.Readiness && c.App().IsShuttingDown():
+			report.Status = StatusShuttingDown
+		case !cfg.Probe(c):
+			report.Status = StatusUnhealthy
+		case len(states) > 0:
+			report.Checks = runChecks(c.Context(), states, cfg.CacheDuration)
+			for _, result := range report.Checks {
+				if result.Status == StatusOK {
+					continue
+				}
+				if result.Critical {
+					report.Status = StatusUnhealthy
+					break
+				}
+				report.Status = StatusDegraded
+			}
 		}
 
-		return c.SendStatus(velocity.StatusServiceUnavailable)
+		status := velocity.StatusOK
+		if report.Status == StatusUnhealthy || report.Status == StatusShuttingDown {
+			status = velocity.StatusServiceUnavailable
+		}
+
+		if c.RequestCtx().QueryArgs().Has(verboseParam) {
+			return c.Status(status).JSON(report)
+		}
+		return c.SendStatus(status)
 	}
 }
@@ -1,11 +1,21 @@
 package healthcheck
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
 	"net/http/httptest"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/client"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
 )
@@ -181,6 +191,266 @@ func Test_HealthCheck_Next(t *testing.T) {
 	shouldGiveNotFound(t, app, "/startupz")
 }
 
+func getReport(t *testing.T, app *velocity.App, path string, expectedStatus int) Report {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, path+"?verbose", nil))
+	require.NoError(t, err)
+	require.Equal(t, expectedStatus, resp.StatusCode)
+	require.Equal(t, velocity.MIMEApplicationJSON, resp.Header.Get(velocity.HeaderContentType))
+
+	var report Report
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	return report
+}
+
+func Test_HealthCheck_Checks(t *testing.T) {
+	t.Parallel()
+
+	var cacheFails, dbFails atomic.Bool
+	app := velocity.New()
+	app.Get(DefaultReadinessEndpoint, NewHealthChecker(Config{
+		Checks: []Check{
+			{
+				Name: "database",
+				Func: func(context.Context) error {
+					if dbFails.Load() {
+						return errors.New("connection refused")
+					}
+					return nil
+				},
+			},
+			{
+				Name:        "cache",
+				NonCritical: true,
+				Func: func(context.Context) error {
+					if cacheFails.Load() {
+						return errors.New("cache down")
+					}
+					return nil
+				},
+			},
+		},
+	}))
+
+	shouldGiveOK(t, app, DefaultReadinessEndpoint)
+	report := getReport(t, app, DefaultReadinessEndpoint, velocity.StatusOK)
+	require.Equal(t, StatusOK, report.Status)
+	require.Len(t, report.Checks, 2)
+	require.Equal(t, StatusOK, report.Checks["database"].Status)
+	require.True(t, report.Checks["database"].Critical)
+	require.False(t, report.Checks["cache"].Critical)
+	require.NotEmpty(t, report.Checks["cache"].Duration)
+
+	// A failing non-critical check only degrades the endpoint
+	cacheFails.Store(true)
+	shouldGiveOK(t, app, DefaultReadinessEndpoint)
+	report = getReport(t, app, DefaultReadinessEndpoint, velocity.StatusOK)
+	require.Equal(t, StatusDegraded, report.Status)
+	require.Equal(t, StatusFailed, report.Checks["cache"].Status)
+	require.Equal(t, "cache down", report.Checks["cache"].Error)
+
+	// A failing critical check makes it unhealthy
+	dbFails.Store(true)
+	shouldGiveStatus(t, app, DefaultReadinessEndpoint, velocity.StatusServiceUnavailable)
+	report = getReport(t, app, DefaultReadinessEndpoint, velocity.StatusServiceUnavailable)
+	require.Equal(t, StatusUnhealthy, report.Status)
+	require.Equal(t, "connection refused", report.Checks["database"].Error)
+}
+
+func Test_HealthCheck_Checks_Concurrent_Timeout(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get(DefaultReadinessEndpoint, NewHealthChecker(Config{
+		Timeout: 50 * time.Millisecond,
+		Checks: []Check{
+			{
+				Name: "slow",
+				Func: func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+			{
+				Name: "ignores-context",
+				Func: func(context.Context) error {
+					time.Sleep(time.Second)
+					return nil
+				},
+			},
+			{
+				Name:    "own-timeout",
+				Timeout: time.Second,
+				Func: func(context.Context) error {
+					time.Sleep(100 * time.Millisecond)
+					return nil
+				},
+			},
+			{
+				Name: "panics",
+				Func: func(context.Context) error {
+					panic("boom")
+				},
+			},
+		},
+	}))
+
+	start := time.Now()
+	report := getReport(t, app, DefaultReadinessEndpoint, velocity.StatusServiceUnavailable)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+
+	require.Equal(t, StatusUnhealthy, report.Status)
+	require.Contains(t, report.Checks["slow"].Error, "timed out after 50ms")
+	require.Contains(t, report.Checks["ignores-context"].Error, "timed out after 50ms")
+	require.Equal(t, StatusOK, report.Checks["own-timeout"].Status)
+	require.Equal(t, "healthcheck: check panicked: boom", report.Checks["panics"].Error)
+}
+
+func Test_HealthCheck_Checks_Cache(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	app := velocity.New()
+	app.Get(DefaultReadinessEndpoint, NewHealthChecker(Config{
+		CacheDuration: time.Hour,
+		Checks: []Check{{
+			Name: "counted",
+			Func: func(context.Context) error {
+				calls.Add(1)
+				return nil
+			},
+		}},
+	}))
+
+	report := getReport(t, app, DefaultReadinessEndpoint, velocity.StatusOK)
+	require.False(t, report.Checks["counted"].Cached)
+	report = getReport(t, app, DefaultReadinessEndpoint, velocity.StatusOK)
+	require.True(t, report.Checks["counted"].Cached)
+	shouldGiveOK(t, app, DefaultReadinessEndpoint)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func Test_checkState_Shared(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	state := &checkState{check: Check{
+		Name:    "slow",
+		Timeout: time.Second,
+		Func: func(context.Context) error {
+			calls.Add(1)
+			time.Sleep(100 * time.Millisecond)
+			return errors.New("down")
+		},
+	}}
+
+	// Concurrent requests share the running check, even without caching
+	var wg sync.WaitGroup
+	results := make([]CheckResult, 5)
+	start := time.Now()
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = state.run(context.Background(), 0)
+		}()
+	}
+	wg.Wait()
+	require.Less(t, time.Since(start), 300*time.Millisecond)
+	require.Equal(t, int32(1), calls.Load())
+	for _, result := range results {
+		require.Equal(t, StatusFailed, result.Status)
+		require.Equal(t, "down", result.Error)
+		require.False(t, result.Cached)
+	}
+
+	// Later requests run the check again
+	state.run(context.Background(), 0)
+	require.Equal(t, int32(2), calls.Load())
+}
+
+func Test_HealthCheck_Checks_Invalid(t *testing.T) {
+	t.Parallel()
+
+	require.PanicsWithValue(t, `healthcheck: check "db" has no Func`, func() {
+		NewHealthChecker(Config{Checks: []Check{{Name: "db"}}})
+	})
+	require.PanicsWithValue(t, `healthcheck: duplicate check "db"`, func() {
+		check := Check{Name: "db", Func: func(context.Context) error { return nil }}
+		NewHealthChecker(Config{Checks: []Check{check, check}})
+	})
+}
+
+func Test_HealthCheck_Probe_Verbose(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get(DefaultLivenessEndpoint, NewHealthChecker(Config{
+		Probe: func(velocity.Ctx) bool { return false },
+	}))
+
+	report := getReport(t, app, DefaultLivenessEndpoint, velocity.StatusServiceUnavailable)
+	require.Equal(t, StatusUnhealthy, report.Status)
+	require.Empty(t, report.Checks)
+}
+
+func Test_HealthCheck_Readiness_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get(DefaultLivenessEndpoint, NewHealthChecker())
+	app.Get(DefaultReadinessEndpoint, NewHealthChecker(Config{Readiness: true}))
+
+	shouldGiveOK(t, app, DefaultReadinessEndpoint)
+
+	require.False(t, app.IsShuttingDown())
+	require.NoError(t, app.ShutdownWithContext(context.Background()))
+	require.True(t, app.IsShuttingDown())
+
+	shouldGiveOK(t, app, DefaultLivenessEndpoint)
+	shouldGiveStatus(t, app, DefaultReadinessEndpoint, velocity.StatusServiceUnavailable)
+	report := getReport(t, app, DefaultReadinessEndpoint, velocity.StatusServiceUnavailable)
+	require.Equal(t, StatusShuttingDown, report.Status)
+}
+
+type fakePinger struct {
+	err error
+}
+
+func (p fakePinger) PingContext(context.Context) error {
+	return p.err
+}
+
+func Test_HealthCheck_Helpers(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, PingCheck(fakePinger{})(context.Background()))
+	require.EqualError(t, PingCheck(fakePinger{err: errors.New("down")})(context.Background()), "down")
+
+	require.NoError(t, StorageCheck(memory.New())(context.Background()))
+
+	downstream := velocity.New()
+	downstream.Get("/ok", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+	downstream.Get("/fail", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusInternalServerError)
+	})
+
+	ln, err := net.Listen(velocity.NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		assert.NoError(t, downstream.Listener(ln, velocity.ListenConfig{DisableStartupMessage: true}))
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, downstream.Shutdown())
+	})
+	addr := "http://" + ln.Addr().String()
+
+	require.NoError(t, HTTPCheck(addr+"/ok")(context.Background()))
+	require.EqualError(t, HTTPCheck(addr+"/fail", client.New())(context.Background()), "healthcheck: unexpected status code 500")
+}
+
 func Benchmark_HealthCheck(b *testing.B) {
 	app := velocity.New()
 
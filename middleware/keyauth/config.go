@@ -2,8 +2,10 @@ package keyauth
 
 import (
 	"errors"
+	"time"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
 )
 
 type KeyLookupFunc func(c velocity.Ctx) (string, error)
@@ -26,8 +28,29 @@ type Config struct {
 	CustomKeyLookup KeyLookupFunc
 
 	// Validator is a function to validate key.
+	// Required if Registry is nil, ignored otherwise.
 	Validator func(velocity.Ctx, string) (bool, error)
 
+	// Registry resolves the keys to their records, which carry the owner,
+	// scopes, expiration and rate limit of the key. The record is available
+	// to the handlers with KeyFromContext.
+	// Optional. Default: nil
+	Registry Registry
+
+	// Storage stores the request counters of the keys with a RateLimit.
+	// Optional. Default: memory.New()
+	Storage velocity.Storage
+
+	// Scopes are required from the keys of the Registry for all routes of the middleware.
+	// Use RequireScopes for the scopes of single routes.
+	// Optional. Default: nil
+	Scopes []string
+
+	// LastUsedInterval is the minimum interval between two updates of the
+	// last used time of a key, to limit the writes to the Registry.
+	// Optional. Default: 1 * time.Minute
+	LastUsedInterval time.Duration
+
 	// KeyLookup is a string in the form of "<source>:<name>" that is used
 	// to extract key from the request.
 	// Optional. Default value "header:Authorization".
@@ -50,14 +73,20 @@ var ConfigDefault = Config{
 		return c.Next()
 	},
 	ErrorHandler: func(c velocity.Ctx, err error) error {
-		if errors.Is(err, ErrMissingOrMalformedAPIKey) {
+		switch {
+		case errors.Is(err, ErrMissingOrMalformedAPIKey):
 			return c.Status(velocity.StatusUnauthorized).SendString(err.Error())
+		case errors.Is(err, ErrInsufficientScope):
+			return c.Status(velocity.StatusForbidden).SendString(ErrInsufficientScope.Message)
+		case errors.Is(err, ErrRateLimitExceeded):
+			return c.Status(velocity.StatusTooManyRequests).SendString(err.Error())
 		}
 		return c.Status(velocity.StatusUnauthorized).SendString("Invalid or expired API Key")
 	},
-	KeyLookup:       "header:" + velocity.HeaderAuthorization,
-	CustomKeyLookup: nil,
-	AuthScheme:      "Bearer",
+	KeyLookup:        "header:" + velocity.HeaderAuthorization,
+	CustomKeyLookup:  nil,
+	AuthScheme:       "Bearer",
+	LastUsedInterval: time.Minute,
 }
 
 // Helper function to set default values
@@ -84,9 +113,15 @@ func configDefault(config ...Config) Config {
 			cfg.AuthScheme = ConfigDefault.AuthScheme
 		}
 	}
-	if cfg.Validator == nil {
+	if cfg.Validator == nil && cfg.Registry == nil {
 		panic("velocity: keyauth middleware requires a validator function")
 	}
+	if cfg.LastUsedInterval <= 0 {
+		cfg.LastUsedInterval = ConfigDefault.LastUsedInterval
+	}
+	if cfg.Registry != nil && cfg.Storage == nil {
+		cfg.Storage = memory.New()
+	}
 
 	return cfg
 }
@@ -4,10 +4,14 @@ package keyauth
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/log"
 )
 
 // The contextKey type is unexported to prevent collisions with context keys defined in
@@ -16,12 +20,32 @@ type contextKey int
 
 // The keys for the values in context
 const (
-	tokenKey contextKey = 0
+	tokenKey contextKey = iota
+	keyKey
 )
 
 // When there is no request of the key thrown ErrMissingOrMalformedAPIKey
 var ErrMissingOrMalformedAPIKey = errors.New("missing or malformed API Key")
 
+// Errors of the keys of the Registry
+var (
+	// ErrInvalidAPIKey is returned when the key is not in the Registry.
+	ErrInvalidAPIKey = errors.New("invalid API Key")
+	// ErrExpiredAPIKey is returned when the key has expired.
+	ErrExpiredAPIKey = errors.New("expired API Key")
+	// ErrInsufficientScope is returned when the key lacks a required scope.
+	ErrInsufficientScope = velocity.NewError(velocity.StatusForbidden, "insufficient API Key scope")
+	// ErrRateLimitExceeded is returned when the key exceeded its RateLimit.
+	ErrRateLimitExceeded = errors.New("API Key rate limit exceeded")
+)
+
+// Rate limit headers, like the limiter middleware
+const (
+	xRateLimitLimit     = "X-RateLimit-Limit"
+	xRateLimitRemaining = "X-RateLimit-Remaining"
+	xRateLimitReset     = "X-RateLimit-Reset"
+)
+
 const (
 	query  = "query"
 	form   = "form"
@@ -43,6 +67,11 @@ func New(config ...Config) velocity.Handler {
 		}
 	}
 
+	var limiter *rateLimiter
+	if cfg.Registry != nil {
+		limiter = &rateLimiter{storage: cfg.Storage}
+	}
+
 	// Return middleware handler
 	return func(c velocity.Ctx) error {
 		// Filter request to skip middleware
@@ -56,6 +85,16 @@ func New(config ...Config) velocity.Handler {
 			return cfg.ErrorHandler(c, err)
 		}
 
+		if cfg.Registry != nil {
+			record, err := resolveKey(c, &cfg, limiter, key)
+			if err != nil {
+				return cfg.ErrorHandler(c, err)
+			}
+			c.Locals(tokenKey, key)
+			c.Locals(keyKey, record)
+			return cfg.SuccessHandler(c)
+		}
+
 		valid, err := cfg.Validator(c, key)
 
 		if err == nil && valid {
@@ -76,6 +115,78 @@ func TokenFromContext(c velocity.Ctx) string {
 	return token
 }
 
+// KeyFromContext returns the record of the key resolved by the Registry.
+// returns nil if the key was not resolved by a Registry
+func KeyFromContext(c velocity.Ctx) *Key {
+	key, ok := c.Locals(keyKey).(*Key)
+	if !ok {
+		return nil
+	}
+	return key
+}
+
+// RequireScopes creates a handler which requires the scopes from the key
+// resolved by the Registry, to protect single routes:
+//
+//	app.Delete("/users/:id", handler, keyauth.RequireScopes("users:write"))
+//
+// It returns ErrInsufficientScope, a 403 Forbidden error, if a scope is missing.
+func RequireScopes(scopes ...string) velocity.Handler {
+	return func(c velocity.Ctx) error {
+		key := KeyFromContext(c)
+		if key == nil {
+			return ErrInsufficientScope
+		}
+		for _, scope := range scopes {
+			if !key.HasScope(scope) {
+				return ErrInsufficientScope
+			}
+		}
+		return c.Next()
+	}
+}
+
+// resolveKey resolves the key by the Registry and checks its expiration,
+// scopes and rate limit
+func resolveKey(c velocity.Ctx, cfg *Config, limiter *rateLimiter, key string) (*Key, error) {
+	record, err := cfg.Registry.Get(HashKey(key))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.Expired() {
+		return nil, ErrExpiredAPIKey
+	}
+	for _, scope := range cfg.Scopes {
+		if !record.HasScope(scope) {
+			return nil, ErrInsufficientScope
+		}
+	}
+
+	if record.RateLimit > 0 {
+		remaining, reset, ok := limiter.allow(record)
+		resetInSec := strconv.FormatInt(int64(math.Ceil(reset.Seconds())), 10)
+		c.Set(xRateLimitLimit, strconv.Itoa(record.RateLimit))
+		c.Set(xRateLimitRemaining, strconv.Itoa(remaining))
+		c.Set(xRateLimitReset, resetInSec)
+		if !ok {
+			c.Set(velocity.HeaderRetryAfter, resetInSec)
+			return nil, ErrRateLimitExceeded
+		}
+	}
+
+	if now := time.Now(); now.Sub(record.LastUsed) >= cfg.LastUsedInterval {
+		if err := cfg.Registry.Touch(record.Hash, now); err != nil {
+			log.Warnf("keyauth: failed to update the last used time of key %q: %v", record.ID, err)
+		} else {
+			record.LastUsed = now
+		}
+	}
+	return record, nil
+}
+
 // MultipleKeySourceLookup creates a CustomKeyLookup function that checks multiple sources until one is found
 // Each element should be specified according to the format used in KeyLookup
 func MultipleKeySourceLookup(keyLookups []string, authScheme string) (KeyLookupFunc, error) {
@@ -0,0 +1,91 @@
+package keyauth
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// rateKeyPrefix prefixes the storage keys of the request counters
+const rateKeyPrefix = "keyauth_rate_"
+
+// defaultRateWindow is the window of the keys without RateWindow
+const defaultRateWindow = time.Minute
+
+// rateLimiter counts the requests of every key in fixed windows,
+// which start with the first request of the key. The counter of a key is
+// only updated atomically within the instance, instances sharing the
+// storage may count concurrent requests once.
+type rateLimiter struct {
+	storage velocity.Storage
+	locks   map[string]*rateLock
+	mu      sync.Mutex
+}
+
+// rateLock serializes the updates of the counter of a key
+type rateLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock locks the counter of the key with the hash and returns the unlock function
+func (l *rateLimiter) lock(hash string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*rateLock)
+	}
+	lock, ok := l.locks[hash]
+	if !ok {
+		lock = new(rateLock)
+		l.locks[hash] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		l.mu.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(l.locks, hash)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// allow counts a request of the key.
+//
+// Returns:
+//   - int: The number of requests left in the window.
+//   - time.Duration: The time until the window resets.
+//   - bool: Whether the request is allowed.
+func (l *rateLimiter) allow(key *Key) (int, time.Duration, bool) {
+	window := key.RateWindow
+	if window <= 0 {
+		window = defaultRateWindow
+	}
+
+	defer l.lock(key.Hash)()
+
+	// The counter holds the reset time in Unix nanoseconds and the number of requests
+	now := time.Now()
+	storageKey := rateKeyPrefix + key.Hash
+	raw, err := l.storage.Get(storageKey)
+	reset, hits := now.Add(window), uint32(0)
+	if err == nil && len(raw) == 12 {
+		if r := time.Unix(0, int64(binary.BigEndian.Uint64(raw))); now.Before(r) { //nolint:gosec // Written below
+			reset, hits = r, binary.BigEndian.Uint32(raw[8:])
+		}
+	}
+	hits++
+
+	raw = binary.BigEndian.AppendUint64(make([]byte, 0, 12), uint64(reset.UnixNano())) //nolint:gosec // Not negative
+	raw = binary.BigEndian.AppendUint32(raw, hits)
+	// Storages may expire in whole seconds, the counter itself holds the exact reset
+	_ = l.storage.Set(storageKey, raw, reset.Sub(now).Truncate(time.Second)+time.Second) //nolint:errcheck // Counting is best effort
+
+	remaining := key.RateLimit - int(hits)
+	return max(remaining, 0), reset.Sub(now), remaining >= 0
+}
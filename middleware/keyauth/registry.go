@@ -0,0 +1,239 @@
+package keyauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// ErrKeyNotFound is returned by the registries when a key does not exist.
+var ErrKeyNotFound = errors.New("keyauth: key not found")
+
+// Key is the record of an API key in a Registry. The key itself is not
+// stored, only its hash, so a leaked registry does not leak usable keys.
+type Key struct {
+	// ExpiresAt is the expiration of the key. The zero time never expires.
+	ExpiresAt time.Time `json:"expires_at"`
+	// LastUsed is the time the key was last used, updated by the middleware.
+	LastUsed time.Time `json:"last_used"`
+	// ID identifies the key, e.g. in logs, without revealing it.
+	ID string `json:"id"`
+	// Hash is the SHA-256 hash of the key, see HashKey.
+	Hash string `json:"hash"`
+	// Owner is the principal the key was issued to.
+	Owner string `json:"owner"`
+	// Scopes are the permissions granted to the key.
+	Scopes []string `json:"scopes"`
+	// RateWindow is the window of the RateLimit. Default: 1 minute
+	RateWindow time.Duration `json:"rate_window"`
+	// RateLimit is the maximum number of requests per RateWindow, 0 disables it.
+	RateLimit int `json:"rate_limit"`
+}
+
+// HasScope reports whether the key was granted the scope.
+func (k *Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the key has expired.
+func (k *Key) Expired() bool {
+	return !k.ExpiresAt.IsZero() && !time.Now().Before(k.ExpiresAt)
+}
+
+// HashKey returns the hex-encoded SHA-256 hash of the key. API keys are
+// random, so a fast hash is sufficient, unlike for passwords.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateKey returns a new random API key with 256 bits of entropy.
+func GenerateKey() string {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Registry stores the records of the API keys by their hash.
+type Registry interface {
+	// Get returns the record of the key with the hash, or ErrKeyNotFound.
+	Get(hash string) (*Key, error)
+	// Set stores the record under its Hash.
+	Set(key *Key) error
+	// Delete revokes the key with the hash.
+	Delete(hash string) error
+	// Touch sets the time the key with the hash was last used.
+	Touch(hash string, lastUsed time.Time) error
+}
+
+// MemoryRegistry is a Registry keeping the records in memory.
+type MemoryRegistry struct {
+	keys map[string]Key
+	mu   sync.RWMutex
+}
+
+// NewMemoryRegistry creates an in-memory registry of the keys.
+func NewMemoryRegistry(keys ...*Key) *MemoryRegistry {
+	r := &MemoryRegistry{keys: make(map[string]Key, len(keys))}
+	for _, key := range keys {
+		r.keys[key.Hash] = *key
+	}
+	return r
+}
+
+// Get returns a copy of the record of the key with the hash.
+func (r *MemoryRegistry) Get(hash string) (*Key, error) {
+	r.mu.RLock()
+	key, ok := r.keys[hash]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return &key, nil
+}
+
+// Set stores a copy of the record.
+func (r *MemoryRegistry) Set(key *Key) error {
+	r.mu.Lock()
+	r.keys[key.Hash] = *key
+	r.mu.Unlock()
+	return nil
+}
+
+// Delete revokes the key with the hash.
+func (r *MemoryRegistry) Delete(hash string) error {
+	r.mu.Lock()
+	delete(r.keys, hash)
+	r.mu.Unlock()
+	return nil
+}
+
+// Touch sets the time the key with the hash was last used.
+func (r *MemoryRegistry) Touch(hash string, lastUsed time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[hash]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	key.LastUsed = lastUsed
+	r.keys[hash] = key
+	return nil
+}
+
+// The prefixes of the storage keys of the records and their last used times
+const (
+	storageKeyPrefix  = "keyauth_key_"
+	storageUsedPrefix = "keyauth_used_"
+)
+
+// StorageRegistry is a Registry keeping the records as JSON in a velocity.Storage,
+// so they can be shared between instances. Expired keys are removed by the storage.
+// The last used time is kept under a separate storage key, so that updating it
+// never rewrites the record, e.g. after the key was revoked by another instance.
+type StorageRegistry struct {
+	storage velocity.Storage
+}
+
+// NewStorageRegistry creates a registry of the keys in the storage.
+func NewStorageRegistry(storage velocity.Storage) *StorageRegistry {
+	return &StorageRegistry{storage: storage}
+}
+
+// Get returns the record of the key with the hash.
+func (r *StorageRegistry) Get(hash string) (*Key, error) {
+	key, err := r.record(hash)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := r.storage.Get(storageUsedPrefix + hash)
+	if err != nil {
+		return nil, err
+	}
+	if raw != nil {
+		var lastUsed time.Time
+		if err := lastUsed.UnmarshalText(raw); err == nil {
+			key.LastUsed = lastUsed
+		}
+	}
+	return key, nil
+}
+
+// record returns the stored record of the key with the hash
+func (r *StorageRegistry) record(hash string) (*Key, error) {
+	raw, err := r.storage.Get(storageKeyPrefix + hash)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrKeyNotFound
+	}
+	key := new(Key)
+	if err := json.Unmarshal(raw, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Set stores the record, until the key expires.
+func (r *StorageRegistry) Set(key *Key) error {
+	raw, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	exp, ok := storageExpiration(key)
+	if !ok {
+		return r.Delete(key.Hash)
+	}
+	return r.storage.Set(storageKeyPrefix+key.Hash, raw, exp)
+}
+
+// Delete revokes the key with the hash.
+func (r *StorageRegistry) Delete(hash string) error {
+	if err := r.storage.Delete(storageKeyPrefix + hash); err != nil {
+		return err
+	}
+	return r.storage.Delete(storageUsedPrefix + hash)
+}
+
+// Touch sets the time the key with the hash was last used. Only the last
+// used time is written, so a concurrent Delete or Set is never undone.
+func (r *StorageRegistry) Touch(hash string, lastUsed time.Time) error {
+	key, err := r.record(hash)
+	if err != nil {
+		return err
+	}
+	exp, ok := storageExpiration(key)
+	if !ok {
+		return nil
+	}
+	raw, err := lastUsed.MarshalText()
+	if err != nil {
+		return err
+	}
+	return r.storage.Set(storageUsedPrefix+hash, raw, exp)
+}
+
+// storageExpiration returns the storage expiration of the key, which is
+// false if the key has expired already
+func storageExpiration(key *Key) (time.Duration, bool) {
+	if key.ExpiresAt.IsZero() {
+		return 0, true
+	}
+	exp := time.Until(key.ExpiresAt)
+	return exp, exp > 0
+}
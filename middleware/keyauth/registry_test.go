@@ -0,0 +1,322 @@
+package keyauth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// keyRequest sends a request with the key as bearer token
+func keyRequest(t *testing.T, app *velocity.App, path, key string) (*http.Response, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(velocity.MethodGet, path, nil)
+	if key != "" {
+		req.Header.Set(velocity.HeaderAuthorization, "Bearer "+key)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+// go test -run Test_Registry
+func Test_Registry(t *testing.T) {
+	t.Parallel()
+
+	key := GenerateKey()
+	expired := GenerateKey()
+	registry := NewMemoryRegistry(
+		&Key{ID: "ci", Hash: HashKey(key), Owner: "ci-bot", Scopes: []string{"users:read"}},
+		&Key{ID: "old", Hash: HashKey(expired), Owner: "ci-bot", ExpiresAt: time.Now().Add(-time.Second)},
+	)
+
+	app := velocity.New()
+	app.Use(New(Config{Registry: registry}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString(KeyFromContext(c).Owner + " " + TokenFromContext(c))
+	})
+	app.Get("/users", func(c velocity.Ctx) error {
+		return c.SendString("users")
+	}, RequireScopes("users:read"))
+	app.Delete("/users", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusNoContent)
+	}, RequireScopes("users:read", "users:write"))
+
+	resp, body := keyRequest(t, app, "/", key)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, "ci-bot "+key, body)
+
+	resp, body = keyRequest(t, app, "/users", key)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, "users", body)
+
+	req := httptest.NewRequest(velocity.MethodDelete, "/users", nil)
+	req.Header.Set(velocity.HeaderAuthorization, "Bearer "+key)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusForbidden, resp.StatusCode)
+
+	resp, body = keyRequest(t, app, "/", expired)
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, "Invalid or expired API Key", body)
+
+	resp, _ = keyRequest(t, app, "/", GenerateKey())
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+
+	resp, body = keyRequest(t, app, "/", "")
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, ErrMissingOrMalformedAPIKey.Error(), body)
+
+	// Revoked keys are rejected
+	require.NoError(t, registry.Delete(HashKey(key)))
+	resp, _ = keyRequest(t, app, "/", key)
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+}
+
+// go test -run Test_Registry_Scopes
+func Test_Registry_Scopes(t *testing.T) {
+	t.Parallel()
+
+	reader, admin := GenerateKey(), GenerateKey()
+	app := velocity.New()
+	app.Use(New(Config{
+		Registry: NewMemoryRegistry(
+			&Key{Hash: HashKey(reader), Scopes: []string{"read"}},
+			&Key{Hash: HashKey(admin), Scopes: []string{"read", "admin"}},
+		),
+		Scopes: []string{"admin"},
+	}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("admin")
+	})
+
+	resp, body := keyRequest(t, app, "/", reader)
+	require.Equal(t, velocity.StatusForbidden, resp.StatusCode)
+	require.Equal(t, ErrInsufficientScope.Message, body)
+
+	resp, _ = keyRequest(t, app, "/", admin)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	// Without a resolved key, RequireScopes rejects the request
+	other := velocity.New()
+	other.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("ok")
+	}, RequireScopes())
+	resp, _ = keyRequest(t, other, "/", admin)
+	require.Equal(t, velocity.StatusForbidden, resp.StatusCode)
+}
+
+// go test -run Test_Registry_RateLimit
+func Test_Registry_RateLimit(t *testing.T) {
+	t.Parallel()
+
+	limited, unlimited := GenerateKey(), GenerateKey()
+	app := velocity.New()
+	app.Use(New(Config{
+		Registry: NewMemoryRegistry(
+			&Key{Hash: HashKey(limited), RateLimit: 2, RateWindow: time.Hour},
+			&Key{Hash: HashKey(unlimited)},
+		),
+	}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		resp, _ := keyRequest(t, app, "/", limited)
+		require.Equal(t, velocity.StatusOK, resp.StatusCode)
+		require.Equal(t, "2", resp.Header.Get(xRateLimitLimit))
+		require.Equal(t, string(rune('0'+remaining)), resp.Header.Get(xRateLimitRemaining))
+		require.Equal(t, "3600", resp.Header.Get(xRateLimitReset))
+	}
+
+	resp, body := keyRequest(t, app, "/", limited)
+	require.Equal(t, velocity.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, ErrRateLimitExceeded.Error(), body)
+	require.Equal(t, "0", resp.Header.Get(xRateLimitRemaining))
+	require.Equal(t, "3600", resp.Header.Get(velocity.HeaderRetryAfter))
+
+	resp, _ = keyRequest(t, app, "/", unlimited)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get(xRateLimitLimit))
+}
+
+// go test -run Test_rateLimiter_Window
+func Test_rateLimiter_Window(t *testing.T) {
+	t.Parallel()
+
+	limiter := &rateLimiter{storage: memory.New()}
+	key := &Key{Hash: HashKey("key"), RateLimit: 1, RateWindow: 50 * time.Millisecond}
+
+	remaining, _, ok := limiter.allow(key)
+	require.True(t, ok)
+	require.Equal(t, 0, remaining)
+	_, _, ok = limiter.allow(key)
+	require.False(t, ok)
+
+	// A new window starts after the reset
+	time.Sleep(60 * time.Millisecond)
+	_, reset, ok := limiter.allow(key)
+	require.True(t, ok)
+	require.Greater(t, reset, 40*time.Millisecond)
+}
+
+// go test -run Test_rateLimiter_Concurrent
+func Test_rateLimiter_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	limiter := &rateLimiter{storage: memory.New()}
+	keys := []*Key{
+		{Hash: HashKey("a"), RateLimit: 50, RateWindow: time.Hour},
+		{Hash: HashKey("b"), RateLimit: 50, RateWindow: time.Hour},
+	}
+
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, ok := limiter.allow(keys[i%2]); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every key is limited on its own, and the locks are released
+	require.Equal(t, int32(100), allowed.Load())
+	require.Empty(t, limiter.locks)
+}
+
+// go test -run Test_Registry_LastUsed
+func Test_Registry_LastUsed(t *testing.T) {
+	t.Parallel()
+
+	key := GenerateKey()
+	registry := NewMemoryRegistry(&Key{Hash: HashKey(key)})
+	app := velocity.New()
+	app.Use(New(Config{Registry: registry, LastUsedInterval: time.Hour}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString(KeyFromContext(c).LastUsed.Format(time.RFC3339Nano))
+	})
+
+	before := time.Now()
+	resp, first := keyRequest(t, app, "/", key)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	record, err := registry.Get(HashKey(key))
+	require.NoError(t, err)
+	require.False(t, record.LastUsed.Before(before))
+	require.Equal(t, record.LastUsed.Format(time.RFC3339Nano), first)
+
+	// Within the interval, the last used time is not written again
+	_, second := keyRequest(t, app, "/", key)
+	require.Equal(t, first, second)
+}
+
+// revokingStorage calls revoke once after the first lookup, like a
+// revocation by another instance racing the request
+type revokingStorage struct {
+	velocity.Storage
+	revoke func()
+}
+
+func (s *revokingStorage) Get(key string) ([]byte, error) {
+	raw, err := s.Storage.Get(key)
+	if revoke := s.revoke; revoke != nil {
+		s.revoke = nil
+		revoke()
+	}
+	return raw, err //nolint:wrapcheck // passed on as is
+}
+
+// go test -run Test_StorageRegistry
+func Test_StorageRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := NewStorageRegistry(memory.New())
+	key := &Key{
+		ID:         "ci",
+		Hash:       HashKey("secret"),
+		Owner:      "ci-bot",
+		Scopes:     []string{"read"},
+		ExpiresAt:  time.Now().Add(time.Hour).Round(0),
+		RateLimit:  10,
+		RateWindow: time.Minute,
+	}
+	require.NoError(t, registry.Set(key))
+
+	stored, err := registry.Get(key.Hash)
+	require.NoError(t, err)
+	require.True(t, key.ExpiresAt.Equal(stored.ExpiresAt))
+	stored.ExpiresAt = key.ExpiresAt
+	require.Equal(t, key, stored)
+
+	lastUsed := time.Now().Round(0)
+	require.NoError(t, registry.Touch(key.Hash, lastUsed))
+	stored, err = registry.Get(key.Hash)
+	require.NoError(t, err)
+	require.True(t, lastUsed.Equal(stored.LastUsed))
+
+	require.NoError(t, registry.Delete(key.Hash))
+	_, err = registry.Get(key.Hash)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	require.ErrorIs(t, registry.Touch(key.Hash, lastUsed), ErrKeyNotFound)
+
+	// A key revoked while the last used time is updated stays revoked
+	revoking := &revokingStorage{Storage: memory.New()}
+	racing := NewStorageRegistry(revoking)
+	require.NoError(t, racing.Set(key))
+	revoking.revoke = func() {
+		require.NoError(t, racing.Delete(key.Hash))
+	}
+	require.NoError(t, racing.Touch(key.Hash, lastUsed))
+	_, err = racing.Get(key.Hash)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	// and changed scopes are kept
+	require.NoError(t, registry.Set(key))
+	_, err = registry.Get(key.Hash)
+	require.NoError(t, err)
+	changed := *key
+	changed.Scopes = []string{"read", "write"}
+	require.NoError(t, registry.Set(&changed))
+	require.NoError(t, registry.Touch(key.Hash, lastUsed))
+	stored, err = registry.Get(key.Hash)
+	require.NoError(t, err)
+	require.Equal(t, []string{"read", "write"}, stored.Scopes)
+	require.True(t, lastUsed.Equal(stored.LastUsed))
+
+	// Expired keys are not stored
+	key.ExpiresAt = time.Now().Add(-time.Second)
+	require.NoError(t, registry.Set(key))
+	_, err = registry.Get(key.Hash)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+// go test -run Test_Key_HasScope
+func Test_Key_HasScope(t *testing.T) {
+	t.Parallel()
+
+	key := &Key{Scopes: []string{"read", "write"}}
+	require.True(t, key.HasScope("read"))
+	require.False(t, key.HasScope("admin"))
+	require.False(t, key.Expired())
+
+	key.ExpiresAt = time.Now()
+	require.True(t, key.Expired())
+
+	require.Len(t, GenerateKey(), 43)
+	require.NotEqual(t, GenerateKey(), GenerateKey())
+	require.Equal(t, "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b", HashKey("secret"))
+}
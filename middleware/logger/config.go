@@ -2,6 +2,7 @@ package logger
 
 import (
 	"io"
+	"log/slog"
 	"os"
 	"time"
 
@@ -45,6 +46,23 @@ type Config struct {
 
 	timeZoneLocation *time.Location
 
+	// Encoding defines how a log entry is rendered. EncodingText renders the
+	// Format template as is, while EncodingJSON and EncodingLogfmt emit every
+	// tag of the Format as a typed and escaped field, keyed by the tag name.
+	// Fixed text and color tags of the Format are ignored by those encodings.
+	//
+	// Optional. Default: EncodingText
+	Encoding string
+
+	// Slog emits every request as a slog record instead of writing to Output.
+	// The tags of the Format become typed attributes like with the structured
+	// encodings. Records are logged at slog.LevelError for status codes of 500
+	// and above, slog.LevelWarn for 400 and above and slog.LevelInfo otherwise.
+	// Done is called with a nil log string.
+	//
+	// Optional. Default: nil
+	Slog *slog.Logger
+
 	// Format defines the logging tags
 	//
 	// Optional. Default: [${time}] ${ip} ${status} - ${latency} ${method} ${path} ${error}
@@ -65,6 +83,34 @@ type Config struct {
 	// Optional. Default: 500 * time.Millisecond
 	TimeInterval time.Duration
 
+	// Redact lists the names of headers, query parameters, form fields and
+	// cookies whose values are replaced by "[REDACTED]" in the log. Names are
+	// matched case-insensitively and may contain path.Match patterns such as
+	// "*token*". Set it to an empty, non-nil slice to log all values.
+	//
+	// Optional. Default: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	Redact []string
+
+	// redact holds the lower-cased Redact patterns
+	redact []string
+
+	// encoder and fields render the structured encodings
+	encoder fieldEncoder
+	fields  []field
+
+	// MaxBodySize limits the number of bytes logged by the body and resBody
+	// tags. Longer bodies are truncated and suffixed with "...".
+	//
+	// Optional. Default: 0 (no limit)
+	MaxBodySize int
+
+	// SampleRate is the fraction of successful requests which are logged,
+	// e.g. 0.1 logs every tenth request. Requests which fail with an error or
+	// a status code of 400 and above are always logged.
+	//
+	// Optional. Default: 0 (log every request)
+	SampleRate float64
+
 	// DisableColors defines if the logs output should be colorized
 	//
 	// Default: false
@@ -74,6 +120,16 @@ type Config struct {
 	enableLatency bool
 }
 
+// Encodings of the default logger
+const (
+	EncodingText   = "text"
+	EncodingJSON   = "json"
+	EncodingLogfmt = "logfmt"
+)
+
+// redactedValue replaces the values listed in Config.Redact
+const redactedValue = "[REDACTED]"
+
 const (
 	startTag       = "${"
 	endTag         = "}"
@@ -99,6 +155,7 @@ type LogFunc func(output Buffer, c velocity.Ctx, data *Data, extraParam string)
 var ConfigDefault = Config{
 	Next:              nil,
 	Done:              nil,
+	Encoding:          EncodingText,
 	Format:            defaultFormat,
 	TimeFormat:        "15:04:05",
 	TimeZone:          "Local",
@@ -106,6 +163,7 @@ var ConfigDefault = Config{
 	Output:            os.Stdout,
 	BeforeHandlerFunc: beforeHandlerFunc,
 	LoggerFunc:        defaultLoggerInstance,
+	Redact:            []string{velocity.HeaderAuthorization, velocity.HeaderProxyAuthorization, velocity.HeaderCookie, velocity.HeaderSetCookie},
 	enableColors:      true,
 }
 
@@ -129,9 +187,15 @@ func configDefault(config ...Config) Config {
 	if cfg.Done == nil {
 		cfg.Done = ConfigDefault.Done
 	}
+	if cfg.Encoding == "" {
+		cfg.Encoding = ConfigDefault.Encoding
+	}
 	if cfg.Format == "" {
 		cfg.Format = ConfigDefault.Format
 	}
+	if cfg.Redact == nil {
+		cfg.Redact = ConfigDefault.Redact
+	}
 	if cfg.TimeZone == "" {
 		cfg.TimeZone = ConfigDefault.TimeZone
 	}
@@ -18,9 +18,35 @@ func defaultLoggerInstance(c velocity.Ctx, data *Data, cfg Config) error {
 	// Alias colors
 	colors := c.App().Config().ColorScheme
 
+	// Emit a slog record with each tag as an attribute
+	if cfg.Slog != nil {
+		writeSlog(c, data, &cfg)
+
+		if cfg.Done != nil {
+			cfg.Done(c, nil)
+		}
+
+		return nil
+	}
+
 	// Get new buffer
 	buf := bytebufferpool.Get()
 
+	// Structured encodings emit each tag as a field
+	if cfg.encoder != nil {
+		writeFields(buf, cfg.encoder, c, data, &cfg)
+
+		writeLog(cfg.Output, buf.Bytes())
+
+		if cfg.Done != nil {
+			cfg.Done(c, buf.Bytes())
+		}
+
+		bytebufferpool.Put(buf)
+
+		return nil
+	}
+
 	// Default output when no custom Format or io.Writer is given
 	if cfg.Format == defaultFormat {
 		// Format error if exist
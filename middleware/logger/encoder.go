@@ -0,0 +1,336 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+	"github.com/valyala/bytebufferpool"
+)
+
+// fieldKind defines how the value of a tag is encoded
+type fieldKind uint8
+
+const (
+	fieldString fieldKind = iota
+	fieldNumber
+	fieldLatency
+	fieldError
+	fieldHeaders
+	fieldQuery
+)
+
+// field is a tag of the Format which is emitted as a structured field
+type field struct {
+	fn    LogFunc
+	key   string
+	param string
+	kind  fieldKind
+}
+
+// keyValue is a member of an object field
+type keyValue struct {
+	key   string
+	value string
+}
+
+// fieldEncoder renders structured fields into a single log line
+type fieldEncoder interface {
+	begin(buf *bytebufferpool.ByteBuffer)
+	appendString(buf *bytebufferpool.ByteBuffer, key string, value []byte)
+	appendNumber(buf *bytebufferpool.ByteBuffer, key string, value []byte)
+	appendNull(buf *bytebufferpool.ByteBuffer, key string)
+	appendObject(buf *bytebufferpool.ByteBuffer, key string, members []keyValue)
+	end(buf *bytebufferpool.ByteBuffer)
+}
+
+// colorTags are skipped by the structured encodings
+var colorTags = map[string]struct{}{
+	TagBlack: {}, TagRed: {}, TagGreen: {}, TagYellow: {}, TagBlue: {},
+	TagMagenta: {}, TagCyan: {}, TagWhite: {}, TagReset: {},
+}
+
+// newFieldEncoder returns the encoder for the configured encoding,
+// or nil for the text encoding.
+func newFieldEncoder(encoding string) (fieldEncoder, error) {
+	switch encoding {
+	case EncodingText:
+		return nil, nil
+	case EncodingJSON:
+		return jsonEncoder{}, nil
+	case EncodingLogfmt:
+		return logfmtEncoder{}, nil
+	default:
+		return nil, errors.New("logger: unknown encoding \"" + encoding + "\"")
+	}
+}
+
+// buildFields collects the tags of the Format which are emitted by the structured encodings
+func buildFields(cfg *Config, tagFunctions map[string]LogFunc) ([]field, error) {
+	var fields []field
+
+	format := cfg.Format
+	for {
+		start := strings.Index(format, startTag)
+		if start < 0 {
+			break
+		}
+		format = format[start+len(startTag):]
+		end := strings.Index(format, endTag)
+		if end < 0 {
+			break
+		}
+		tag := format[:end]
+		format = format[end+len(endTag):]
+
+		name, param := tag, ""
+		if index := strings.Index(tag, paramSeparator); index != -1 {
+			name, param = tag[:index+1], tag[index+1:]
+		}
+		if _, ok := colorTags[name]; ok {
+			continue
+		}
+		fn, ok := tagFunctions[name]
+		if !ok {
+			if param != "" {
+				return nil, errors.New("No parameter found in \"" + tag + "\"")
+			}
+			continue
+		}
+
+		f := field{fn: fn, key: tag, param: param, kind: fieldString}
+		if _, custom := cfg.CustomTags[name]; !custom {
+			switch name {
+			case TagStatus, TagPid, TagBytesSent, TagBytesReceived:
+				f.kind = fieldNumber
+			case TagLatency:
+				f.kind = fieldLatency
+			case TagError:
+				f.kind = fieldError
+			case TagReqHeaders:
+				f.kind = fieldHeaders
+			case TagQueryStringParams:
+				f.kind = fieldQuery
+			}
+		}
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// writeFields encodes all fields of the request into buf
+func writeFields(buf *bytebufferpool.ByteBuffer, enc fieldEncoder, c velocity.Ctx, data *Data, cfg *Config) {
+	scratch := bytebufferpool.Get()
+	defer bytebufferpool.Put(scratch)
+
+	enc.begin(buf)
+	for _, f := range cfg.fields {
+		switch f.kind {
+		case fieldLatency:
+			scratch.SetString(data.Stop.Sub(data.Start).String())
+			enc.appendString(buf, f.key, scratch.B)
+		case fieldError:
+			if data.ChainErr == nil {
+				enc.appendNull(buf, f.key)
+				continue
+			}
+			scratch.SetString(data.ChainErr.Error())
+			enc.appendString(buf, f.key, scratch.B)
+		case fieldHeaders, fieldQuery:
+			enc.appendObject(buf, f.key, objectMembers(c, cfg, f.kind))
+		default:
+			scratch.Reset()
+			if _, err := f.fn(scratch, c, data, f.param); err != nil {
+				scratch.SetString(err.Error())
+				enc.appendString(buf, f.key, scratch.B)
+				continue
+			}
+			if f.kind == fieldNumber {
+				if scratch.Len() == 0 {
+					enc.appendNull(buf, f.key)
+				} else {
+					enc.appendNumber(buf, f.key, scratch.B)
+				}
+				continue
+			}
+			enc.appendString(buf, f.key, scratch.B)
+		}
+	}
+	enc.end(buf)
+}
+
+// objectMembers returns the redacted request headers or query parameters
+func objectMembers(c velocity.Ctx, cfg *Config, kind fieldKind) []keyValue {
+	var members []keyValue
+	visit := func(key, value []byte) {
+		members = appendMember(cfg, members, string(key), string(value))
+	}
+	if kind == fieldHeaders {
+		c.Request().Header.VisitAll(visit)
+	} else {
+		c.Request().URI().QueryArgs().VisitAll(visit)
+	}
+	return members
+}
+
+// appendMember adds a redacted member to the object, joining repeated keys
+func appendMember(cfg *Config, members []keyValue, key, value string) []keyValue {
+	if cfg.isRedacted(key) {
+		value = redactedValue
+	}
+	for i := range members {
+		if members[i].key == key {
+			members[i].value += "," + value
+			return members
+		}
+	}
+	return append(members, keyValue{key: key, value: value})
+}
+
+// jsonEncoder writes one JSON object per line
+type jsonEncoder struct{}
+
+func (jsonEncoder) begin(buf *bytebufferpool.ByteBuffer) {
+	buf.B = append(buf.B, '{')
+}
+
+func (jsonEncoder) key(buf *bytebufferpool.ByteBuffer, key string) {
+	if buf.B[len(buf.B)-1] != '{' {
+		buf.B = append(buf.B, ',')
+	}
+	buf.B = appendJSONString(buf.B, utils.UnsafeBytes(key))
+	buf.B = append(buf.B, ':')
+}
+
+func (e jsonEncoder) appendString(buf *bytebufferpool.ByteBuffer, key string, value []byte) {
+	e.key(buf, key)
+	buf.B = appendJSONString(buf.B, value)
+}
+
+func (e jsonEncoder) appendNumber(buf *bytebufferpool.ByteBuffer, key string, value []byte) {
+	e.key(buf, key)
+	buf.B = append(buf.B, value...)
+}
+
+func (e jsonEncoder) appendNull(buf *bytebufferpool.ByteBuffer, key string) {
+	e.key(buf, key)
+	buf.B = append(buf.B, "null"...)
+}
+
+func (e jsonEncoder) appendObject(buf *bytebufferpool.ByteBuffer, key string, members []keyValue) {
+	e.key(buf, key)
+	buf.B = append(buf.B, '{')
+	for i, member := range members {
+		if i > 0 {
+			buf.B = append(buf.B, ',')
+		}
+		buf.B = appendJSONString(buf.B, utils.UnsafeBytes(member.key))
+		buf.B = append(buf.B, ':')
+		buf.B = appendJSONString(buf.B, utils.UnsafeBytes(member.value))
+	}
+	buf.B = append(buf.B, '}')
+}
+
+func (jsonEncoder) end(buf *bytebufferpool.ByteBuffer) {
+	buf.B = append(buf.B, '}', '\n')
+}
+
+// logfmtEncoder writes space separated key=value pairs per line
+type logfmtEncoder struct{}
+
+func (logfmtEncoder) begin(*bytebufferpool.ByteBuffer) {}
+
+func (logfmtEncoder) key(buf *bytebufferpool.ByteBuffer, key string) {
+	if len(buf.B) > 0 {
+		buf.B = append(buf.B, ' ')
+	}
+	for i := 0; i < len(key); i++ {
+		if b := key[i]; b <= ' ' || b == '=' || b == '"' || b == 0x7f {
+			buf.B = append(buf.B, '_')
+		} else {
+			buf.B = append(buf.B, b)
+		}
+	}
+	buf.B = append(buf.B, '=')
+}
+
+func (e logfmtEncoder) appendString(buf *bytebufferpool.ByteBuffer, key string, value []byte) {
+	e.key(buf, key)
+	buf.B = appendLogfmtValue(buf.B, value)
+}
+
+func (e logfmtEncoder) appendNumber(buf *bytebufferpool.ByteBuffer, key string, value []byte) {
+	e.key(buf, key)
+	buf.B = append(buf.B, value...)
+}
+
+func (e logfmtEncoder) appendNull(buf *bytebufferpool.ByteBuffer, key string) {
+	e.key(buf, key)
+}
+
+// appendObject flattens the members into "key.member=value" pairs
+func (e logfmtEncoder) appendObject(buf *bytebufferpool.ByteBuffer, key string, members []keyValue) {
+	for _, member := range members {
+		e.key(buf, key+"."+member.key)
+		buf.B = appendLogfmtValue(buf.B, utils.UnsafeBytes(member.value))
+	}
+}
+
+func (logfmtEncoder) end(buf *bytebufferpool.ByteBuffer) {
+	buf.B = append(buf.B, '\n')
+}
+
+// appendLogfmtValue appends the value, quoting it if necessary
+func appendLogfmtValue(dst, value []byte) []byte {
+	for i := 0; i < len(value); i++ {
+		if b := value[i]; b <= ' ' || b == '=' || b == '"' || b == 0x7f {
+			return appendJSONString(dst, value)
+		}
+	}
+	if !utf8.Valid(value) {
+		return appendJSONString(dst, value)
+	}
+	return append(dst, value...)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends the value as quoted JSON string, replacing invalid UTF-8
+func appendJSONString(dst, value []byte) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(value); {
+		b := value[i]
+		if b < utf8.RuneSelf {
+			switch {
+			case b == '"' || b == '\\':
+				dst = append(dst, '\\', b)
+			case b == '\n':
+				dst = append(dst, '\\', 'n')
+			case b == '\r':
+				dst = append(dst, '\\', 'r')
+			case b == '\t':
+				dst = append(dst, '\\', 't')
+			case b < ' ' || b == 0x7f:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xf])
+			default:
+				dst = append(dst, b)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(value[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, `\ufffd`...)
+		case r == '\u2028' || r == '\u2029':
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xf])
+		default:
+			dst = append(dst, value[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
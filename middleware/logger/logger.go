@@ -24,6 +24,21 @@ func New(config ...Config) velocity.Handler {
 		cfg.timeZoneLocation = tz
 	}
 
+	// Structured encodings are never colorized
+	cfg.encoder, err = newFieldEncoder(cfg.Encoding)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.encoder != nil || cfg.Slog != nil {
+		cfg.enableColors = false
+	}
+
+	// Prepare redaction patterns
+	cfg.redact = make([]string, len(cfg.Redact))
+	for i, pattern := range cfg.Redact {
+		cfg.redact[i] = strings.ToLower(pattern)
+	}
+
 	// Check if format contains latency
 	cfg.enableLatency = strings.Contains(cfg.Format, "${"+TagLatency+"}")
 
@@ -62,10 +77,19 @@ func New(config ...Config) velocity.Handler {
 	// Logger data
 	// instead of analyzing the template inside(handler) each time, this is done once before
 	// and we create several slices of the same length with the functions to be executed and fixed parts.
-	templateChain, logFunChain, err := buildLogFuncChain(&cfg, createTagMap(&cfg))
+	tagFunctions := createTagMap(&cfg)
+	templateChain, logFunChain, err := buildLogFuncChain(&cfg, tagFunctions)
 	if err != nil {
 		panic(err)
 	}
+	if cfg.encoder != nil || cfg.Slog != nil {
+		if cfg.fields, err = buildFields(&cfg, tagFunctions); err != nil {
+			panic(err)
+		}
+	}
+
+	// Sampling counter for successful requests
+	var sampled atomic.Uint64
 
 	// Return new handler
 	return func(c velocity.Ctx) error {
@@ -122,7 +146,20 @@ func New(config ...Config) velocity.Handler {
 			data.Stop = time.Now()
 		}
 
+		// Only log a share of the successful requests
+		if cfg.SampleRate > 0 && cfg.SampleRate < 1 && chainErr == nil &&
+			c.Response().StatusCode() < velocity.StatusBadRequest && !sample(&sampled, cfg.SampleRate) {
+			return nil
+		}
+
 		// Logger instance & update some logger data fields
 		return cfg.LoggerFunc(c, data, cfg)
 	}
 }
+
+// sample reports whether the current request is logged, so that exactly
+// the given fraction of all sampled requests is selected.
+func sample(counter *atomic.Uint64, rate float64) bool {
+	n := counter.Add(1)
+	return uint64(float64(n)*rate) != uint64(float64(n-1)*rate)
+}
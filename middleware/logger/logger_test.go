@@ -4,16 +4,20 @@ package logger
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -703,6 +707,246 @@ func Test_CustomTags(t *testing.T) {
 	require.Equal(t, customTag, buf.String())
 }
 
+// go test -run Test_Logger_Encoding_JSON
+func Test_Logger_Encoding_JSON(t *testing.T) {
+	t.Parallel()
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	app := velocity.New()
+
+	app.Use(New(Config{
+		Encoding: EncodingJSON,
+		Format:   "${red}${status} ${method} ${path} ${reqHeader:X-Note} ${reqHeaders} ${queryParams} ${pid} ${latency} ${error}",
+		Output:   buf,
+	}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString("Hello velocity!")
+	})
+	req := httptest.NewRequest(velocity.MethodGet, "/?q=a%22b&token=x", nil)
+	req.Header.Set("X-Note", "say \"hi\"\tthere")
+	req.Header.Set(velocity.HeaderAuthorization, "Bearer secret")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.True(t, bytes.HasSuffix(buf.Bytes(), []byte("}\n")))
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.InDelta(t, float64(velocity.StatusOK), entry["status"], 0)
+	require.InDelta(t, float64(os.Getpid()), entry["pid"], 0)
+	require.Equal(t, velocity.MethodGet, entry["method"])
+	require.Equal(t, "/", entry["path"])
+	require.Equal(t, "say \"hi\"\tthere", entry["reqHeader:X-Note"])
+	require.Nil(t, entry["error"])
+	require.NotContains(t, entry, "red")
+	require.IsType(t, "", entry["latency"])
+
+	headers, ok := entry["reqHeaders"].(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, "[REDACTED]", headers[velocity.HeaderAuthorization])
+	require.Equal(t, map[string]any{"q": "a\"b", "token": "x"}, entry["queryParams"])
+	require.NotContains(t, buf.String(), "secret")
+}
+
+// go test -run Test_Logger_Encoding_Logfmt
+func Test_Logger_Encoding_Logfmt(t *testing.T) {
+	t.Parallel()
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	app := velocity.New()
+
+	app.Use(New(Config{
+		Encoding: EncodingLogfmt,
+		Format:   "${status} ${method} ${path} ${reqHeader:X-Note} ${queryParams} ${error}",
+		Output:   buf,
+	}))
+	app.Get("/", func(_ velocity.Ctx) error {
+		return velocity.ErrTeapot
+	})
+	req := httptest.NewRequest(velocity.MethodGet, "/?a=1", nil)
+	req.Header.Set("X-Note", "a=b \"c\"")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusTeapot, resp.StatusCode)
+	require.Equal(t, `status=418 method=GET path=/ reqHeader:X-Note="a=b \"c\"" queryParams.a=1 error="I'm a teapot"`+"\n", buf.String())
+}
+
+// go test -run Test_Logger_Encoding_Invalid
+func Test_Logger_Encoding_Invalid(t *testing.T) {
+	t.Parallel()
+	require.Panics(t, func() {
+		New(Config{Encoding: "xml"})
+	})
+}
+
+// go test -run Test_Logger_Redact
+func Test_Logger_Redact(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		redact []string
+		expect string
+	}{
+		{name: "default", redact: nil, expect: "[REDACTED] abc def session"},
+		{name: "patterns", redact: []string{"*TOKEN*", "sid"}, expect: "Basic dXNlcg== [REDACTED] def [REDACTED]"},
+		{name: "disabled", redact: []string{}, expect: "Basic dXNlcg== abc def session"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			buf := bytebufferpool.Get()
+			defer bytebufferpool.Put(buf)
+
+			app := velocity.New()
+			app.Use(New(Config{
+				Format: "${reqHeader:Authorization} ${query:access_token} ${form:name} ${cookie:sid}",
+				Redact: tc.redact,
+				Output: buf,
+			}))
+			app.Post("/", func(c velocity.Ctx) error {
+				return c.SendStatus(velocity.StatusOK)
+			})
+
+			req := httptest.NewRequest(velocity.MethodPost, "/?access_token=abc", strings.NewReader("name=def"))
+			req.Header.Set(velocity.HeaderContentType, velocity.MIMEApplicationForm)
+			req.Header.Set(velocity.HeaderAuthorization, "Basic dXNlcg==")
+			req.AddCookie(&http.Cookie{Name: "sid", Value: "session"})
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, velocity.StatusOK, resp.StatusCode)
+			require.Equal(t, tc.expect, buf.String())
+		})
+	}
+}
+
+// go test -run Test_Logger_MaxBodySize
+func Test_Logger_MaxBodySize(t *testing.T) {
+	t.Parallel()
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	app := velocity.New()
+	app.Use(New(Config{
+		Format:      "${body}|${resBody}",
+		MaxBodySize: 5,
+		Output:      buf,
+	}))
+	app.Post("/", func(c velocity.Ctx) error {
+		return c.SendString("Sample response body")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodPost, "/", strings.NewReader("abc")))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, "abc|Sampl...", buf.String())
+}
+
+// go test -run Test_Logger_SampleRate
+func Test_Logger_SampleRate(t *testing.T) {
+	t.Parallel()
+
+	var lines atomic.Int32
+	app := velocity.New()
+	app.Use(New(Config{
+		Format:     "${status}\n",
+		SampleRate: 0.25,
+		Output:     io.Discard,
+		Done: func(_ velocity.Ctx, _ []byte) {
+			lines.Add(1)
+		},
+	}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+	app.Get("/fail", func(_ velocity.Ctx) error {
+		return velocity.ErrBadGateway
+	})
+
+	for i := 0; i < 100; i++ {
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+		require.NoError(t, err)
+		require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	}
+	require.Equal(t, int32(25), lines.Load())
+
+	// Errors are always logged
+	for i := 0; i < 10; i++ {
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/fail", nil))
+		require.NoError(t, err)
+		require.Equal(t, velocity.StatusBadGateway, resp.StatusCode)
+	}
+	require.Equal(t, int32(35), lines.Load())
+}
+
+// go test -run Test_Logger_Slog
+func Test_Logger_Slog(t *testing.T) {
+	t.Parallel()
+	buf := bytebufferpool.Get()
+	defer bytebufferpool.Put(buf)
+
+	var done [][]byte
+	app := velocity.New()
+	app.Use(New(Config{
+		Format: "${status} ${method} ${path} ${latency} ${reqHeaders} ${error}",
+		Slog: slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{
+			ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
+				if attr.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return attr
+			},
+		})),
+		Done: func(_ velocity.Ctx, logString []byte) {
+			done = append(done, logString)
+		},
+	}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+	app.Get("/fail", func(_ velocity.Ctx) error {
+		return velocity.ErrServiceUnavailable
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set(velocity.HeaderAuthorization, "Bearer secret")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/fail", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusServiceUnavailable, resp.StatusCode)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	require.Equal(t, [][]byte{nil, nil}, done)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &entry))
+	require.Equal(t, "INFO", entry[slog.LevelKey])
+	require.Equal(t, "request", entry[slog.MessageKey])
+	require.InDelta(t, float64(velocity.StatusOK), entry["status"], 0)
+	require.Equal(t, "/", entry["path"])
+	require.IsType(t, float64(0), entry["latency"])
+	require.NotContains(t, entry, "error")
+	headers, ok := entry["reqHeaders"].(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, "[REDACTED]", headers[velocity.HeaderAuthorization])
+
+	entry = nil
+	require.NoError(t, json.Unmarshal(lines[1], &entry))
+	require.Equal(t, "ERROR", entry[slog.LevelKey])
+	require.InDelta(t, float64(velocity.StatusServiceUnavailable), entry["status"], 0)
+	require.Equal(t, "Service Unavailable", entry["error"])
+}
+
 // go test -run Test_Logger_ByteSent_Streaming
 func Test_Logger_ByteSent_Streaming(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/valyala/bytebufferpool"
+)
+
+// slogMessage is the message of the records emitted to Config.Slog
+const slogMessage = "request"
+
+// writeSlog emits the fields of the request as slog record
+func writeSlog(c velocity.Ctx, data *Data, cfg *Config) {
+	level := slog.LevelInfo
+	switch status := c.Response().StatusCode(); {
+	case status >= velocity.StatusInternalServerError:
+		level = slog.LevelError
+	case status >= velocity.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+
+	ctx := c.Context()
+	if !cfg.Slog.Enabled(ctx, level) {
+		return
+	}
+
+	scratch := bytebufferpool.Get()
+	defer bytebufferpool.Put(scratch)
+
+	attrs := make([]slog.Attr, 0, len(cfg.fields))
+	for _, f := range cfg.fields {
+		switch f.kind {
+		case fieldLatency:
+			attrs = append(attrs, slog.Duration(f.key, data.Stop.Sub(data.Start)))
+		case fieldError:
+			if data.ChainErr != nil {
+				attrs = append(attrs, slog.String(f.key, data.ChainErr.Error()))
+			}
+		case fieldHeaders, fieldQuery:
+			members := objectMembers(c, cfg, f.kind)
+			group := make([]slog.Attr, len(members))
+			for i, member := range members {
+				group[i] = slog.String(member.key, member.value)
+			}
+			attrs = append(attrs, slog.Attr{Key: f.key, Value: slog.GroupValue(group...)})
+		default:
+			scratch.Reset()
+			if _, err := f.fn(scratch, c, data, f.param); err != nil {
+				attrs = append(attrs, slog.String(f.key, err.Error()))
+				continue
+			}
+			if f.kind == fieldNumber {
+				if n, err := strconv.Atoi(scratch.String()); err == nil {
+					attrs = append(attrs, slog.Int(f.key, n))
+					continue
+				}
+			}
+			attrs = append(attrs, slog.String(f.key, scratch.String()))
+		}
+	}
+
+	cfg.Slog.LogAttrs(ctx, level, slogMessage, attrs...)
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
 )
 
 // Logger variables
@@ -84,7 +85,7 @@ func createTagMap(cfg *Config) map[string]LogFunc {
 			return output.WriteString(c.Get(velocity.HeaderUserAgent))
 		},
 		TagBody: func(output Buffer, c velocity.Ctx, _ *Data, _ string) (int, error) {
-			return output.Write(c.Body())
+			return cfg.writeBody(output, c.Body())
 		},
 		TagBytesReceived: func(output Buffer, c velocity.Ctx, _ *Data, _ string) (int, error) {
 			return appendInt(output, c.Request().Header.ContentLength())
@@ -96,7 +97,7 @@ func createTagMap(cfg *Config) map[string]LogFunc {
 			return output.WriteString(c.Route().Path)
 		},
 		TagResBody: func(output Buffer, c velocity.Ctx, _ *Data, _ string) (int, error) {
-			return output.Write(c.Response().Body())
+			return cfg.writeBody(output, c.Response().Body())
 		},
 		TagReqHeaders: func(output Buffer, c velocity.Ctx, _ *Data, _ string) (int, error) {
 			out := make(map[string][]string, 0)
@@ -106,12 +107,32 @@ func createTagMap(cfg *Config) map[string]LogFunc {
 
 			reqHeaders := make([]string, 0)
 			for k, v := range out {
+				if cfg.isRedacted(k) {
+					v = []string{redactedValue}
+				}
 				reqHeaders = append(reqHeaders, k+"="+strings.Join(v, ","))
 			}
 			return output.Write([]byte(strings.Join(reqHeaders, "&")))
 		},
 		TagQueryStringParams: func(output Buffer, c velocity.Ctx, _ *Data, _ string) (int, error) {
-			return output.WriteString(c.Request().URI().QueryArgs().String())
+			args := c.Request().URI().QueryArgs()
+			if len(cfg.redact) == 0 {
+				return output.WriteString(args.String())
+			}
+			old := output.Len()
+			args.VisitAll(func(key, value []byte) {
+				if output.Len() > old {
+					_ = output.WriteByte('&') //nolint:errcheck // Buffer writes never fail
+				}
+				_, _ = output.Write(key)  //nolint:errcheck // Buffer writes never fail
+				_ = output.WriteByte('=') //nolint:errcheck // Buffer writes never fail
+				if cfg.isRedacted(utils.UnsafeString(key)) {
+					_, _ = output.WriteString(redactedValue) //nolint:errcheck // Buffer writes never fail
+				} else {
+					_, _ = output.Write(value) //nolint:errcheck // Buffer writes never fail
+				}
+			})
+			return output.Len() - old, nil
 		},
 
 		TagBlack: func(output Buffer, c velocity.Ctx, _ *Data, _ string) (int, error) {
@@ -152,19 +173,19 @@ func createTagMap(cfg *Config) map[string]LogFunc {
 			return output.WriteString("-")
 		},
 		TagReqHeader: func(output Buffer, c velocity.Ctx, _ *Data, extraParam string) (int, error) {
-			return output.WriteString(c.Get(extraParam))
+			return cfg.writeValue(output, extraParam, c.Get(extraParam))
 		},
 		TagRespHeader: func(output Buffer, c velocity.Ctx, _ *Data, extraParam string) (int, error) {
-			return output.WriteString(c.GetRespHeader(extraParam))
+			return cfg.writeValue(output, extraParam, c.GetRespHeader(extraParam))
 		},
 		TagQuery: func(output Buffer, c velocity.Ctx, _ *Data, extraParam string) (int, error) {
-			return output.WriteString(velocity.Query[string](c, extraParam))
+			return cfg.writeValue(output, extraParam, velocity.Query[string](c, extraParam))
 		},
 		TagForm: func(output Buffer, c velocity.Ctx, _ *Data, extraParam string) (int, error) {
-			return output.WriteString(c.FormValue(extraParam))
+			return cfg.writeValue(output, extraParam, c.FormValue(extraParam))
 		},
 		TagCookie: func(output Buffer, c velocity.Ctx, _ *Data, extraParam string) (int, error) {
-			return output.WriteString(c.Cookies(extraParam))
+			return cfg.writeValue(output, extraParam, c.Cookies(extraParam))
 		},
 		TagLocals: func(output Buffer, c velocity.Ctx, _ *Data, extraParam string) (int, error) {
 			switch v := c.Locals(extraParam).(type) {
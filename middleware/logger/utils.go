@@ -2,6 +2,8 @@ package logger
 
 import (
 	"io"
+	"path"
+	"strings"
 
 	"github.com/khulnasoft/velocity"
 	velocitylog "github.com/khulnasoft/velocity/log"
@@ -42,6 +44,42 @@ func statusColor(code int, colors velocity.Colors) string {
 	}
 }
 
+// isRedacted reports whether the value of the named header, query parameter,
+// form field or cookie has to be masked.
+func (cfg *Config) isRedacted(name string) bool {
+	if len(cfg.redact) == 0 {
+		return false
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range cfg.redact {
+		if ok, err := path.Match(pattern, name); ok && err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// writeValue writes the value of the named header, query parameter, form field or cookie.
+func (cfg *Config) writeValue(output Buffer, name, value string) (int, error) {
+	if value != "" && cfg.isRedacted(name) {
+		return output.WriteString(redactedValue)
+	}
+	return output.WriteString(value)
+}
+
+// writeBody writes a request or response body, truncated to MaxBodySize.
+func (cfg *Config) writeBody(output Buffer, body []byte) (int, error) {
+	if cfg.MaxBodySize <= 0 || len(body) <= cfg.MaxBodySize {
+		return output.Write(body)
+	}
+	n, err := output.Write(body[:cfg.MaxBodySize])
+	if err != nil {
+		return n, err
+	}
+	m, err := output.WriteString("...")
+	return n + m, err
+}
+
 type customLoggerWriter struct {
 	loggerInstance velocitylog.AllLogger
 	level          velocitylog.Level
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// Path is the path where the metrics are served.
+	//
+	// Optional. Default: "/metrics"
+	Path string
+
+	// Namespace is the prefix of all metric names.
+	//
+	// Optional. Default: "velocity"
+	Namespace string
+
+	// MultiprocessDir is a directory shared by all processes of the app.
+	// If set, every process writes its metrics to a file in the directory
+	// and the metrics of all processes are aggregated when they are served,
+	// which is required with Prefork. The directory is emptied when the
+	// middleware is created outside of a prefork child.
+	//
+	// Optional. Default: ""
+	MultiprocessDir string
+
+	// Buckets are the upper bounds of the latency histogram in seconds.
+	//
+	// Optional. Default: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	Buckets []float64
+
+	// FlushInterval is the interval in which a process writes its metrics
+	// to the MultiprocessDir.
+	//
+	// Optional. Default: 1 * time.Second
+	FlushInterval time.Duration
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:          nil,
+	Path:          "/metrics",
+	Namespace:     "velocity",
+	Buckets:       []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	FlushInterval: 1 * time.Second,
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Path == "" {
+		cfg.Path = ConfigDefault.Path
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = ConfigDefault.Namespace
+	}
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = ConfigDefault.Buckets
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = ConfigDefault.FlushInterval
+	}
+	return cfg
+}
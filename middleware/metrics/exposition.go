@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// contentType is the content type of the Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// writeText appends the snapshot in the Prometheus text exposition format
+// https://prometheus.io/docs/instrumenting/exposition_formats/#text-based-format
+func writeText(buf []byte, namespace string, buckets []float64, snap snapshot) []byte {
+	snap.sort()
+	prefix := namespace + "_http_"
+
+	name := prefix + "requests_total"
+	buf = writeHeader(buf, name, "counter", "Total number of HTTP requests.")
+	for i := range snap.Series {
+		s := &snap.Series[i]
+		buf = writeSample(buf, name, s.seriesKey, "", "", float64(s.Count))
+	}
+
+	name = prefix + "request_duration_seconds"
+	buf = writeHeader(buf, name, "histogram", "Latency of HTTP requests in seconds.")
+	for i := range snap.Series {
+		s := &snap.Series[i]
+		var cumulative uint64
+		for j, bound := range buckets {
+			if j < len(s.Buckets) {
+				cumulative += s.Buckets[j]
+			}
+			buf = writeSample(buf, name+"_bucket", s.seriesKey, "le", formatFloat(bound), float64(cumulative))
+		}
+		buf = writeSample(buf, name+"_bucket", s.seriesKey, "le", "+Inf", float64(s.Count))
+		buf = writeSample(buf, name+"_sum", s.seriesKey, "", "", s.DurationSum)
+		buf = writeSample(buf, name+"_count", s.seriesKey, "", "", float64(s.Count))
+	}
+
+	name = prefix + "requests_in_flight"
+	buf = writeHeader(buf, name, "gauge", "Number of HTTP requests currently being served.")
+	methods := make([]string, 0, len(snap.InFlight))
+	for method := range snap.InFlight {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		buf = append(buf, name...)
+		buf = append(buf, `{method="`...)
+		buf = appendLabelValue(buf, method)
+		buf = append(buf, `"} `...)
+		buf = strconv.AppendInt(buf, snap.InFlight[method], 10)
+		buf = append(buf, '\n')
+	}
+
+	name = prefix + "request_size_bytes"
+	buf = writeHeader(buf, name, "summary", "Size of HTTP request bodies in bytes.")
+	for i := range snap.Series {
+		s := &snap.Series[i]
+		buf = writeSample(buf, name+"_sum", s.seriesKey, "", "", s.RequestSizeSum)
+		buf = writeSample(buf, name+"_count", s.seriesKey, "", "", float64(s.Count))
+	}
+
+	name = prefix + "response_size_bytes"
+	buf = writeHeader(buf, name, "summary", "Size of HTTP response bodies in bytes.")
+	for i := range snap.Series {
+		s := &snap.Series[i]
+		buf = writeSample(buf, name+"_sum", s.seriesKey, "", "", s.ResponseSizeSum)
+		buf = writeSample(buf, name+"_count", s.seriesKey, "", "", float64(s.Count))
+	}
+
+	return buf
+}
+
+// writeHeader appends the HELP and TYPE lines of a metric
+func writeHeader(buf []byte, name, typ, help string) []byte {
+	buf = append(buf, "# HELP "...)
+	buf = append(buf, name...)
+	buf = append(buf, ' ')
+	buf = append(buf, help...)
+	buf = append(buf, "\n# TYPE "...)
+	buf = append(buf, name...)
+	buf = append(buf, ' ')
+	buf = append(buf, typ...)
+	return append(buf, '\n')
+}
+
+// writeSample appends a sample with the labels of the series and an optional extra label
+func writeSample(buf []byte, name string, key seriesKey, extraName, extraValue string, value float64) []byte {
+	buf = append(buf, name...)
+	buf = append(buf, `{method="`...)
+	buf = appendLabelValue(buf, key.Method)
+	buf = append(buf, `",route="`...)
+	buf = appendLabelValue(buf, key.Route)
+	buf = append(buf, `",status="`...)
+	buf = appendLabelValue(buf, key.Status)
+	buf = append(buf, '"')
+	if extraName != "" {
+		buf = append(buf, ',')
+		buf = append(buf, extraName...)
+		buf = append(buf, `="`...)
+		buf = appendLabelValue(buf, extraValue)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, "} "...)
+	buf = append(buf, formatFloat(value)...)
+	return append(buf, '\n')
+}
+
+// appendLabelValue escapes backslashes, double quotes and line feeds
+func appendLabelValue(buf []byte, value string) []byte {
+	if !strings.ContainsAny(value, "\\\"\n") {
+		return append(buf, value...)
+	}
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\':
+			buf = append(buf, `\\`...)
+		case '"':
+			buf = append(buf, `\"`...)
+		case '\n':
+			buf = append(buf, `\n`...)
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
+// formatFloat formats a sample value the way Prometheus clients do
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
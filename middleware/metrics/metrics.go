@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/log"
+	"github.com/khulnasoft/velocity/utils"
+)
+
+// unmatchedRoute is the route label of requests which did not match any
+// handler route, to keep arbitrary paths out of the labels.
+const unmatchedRoute = "unmatched"
+
+// statusClasses are the values of the status label
+var statusClasses = [...]string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	reg := newRegistry(cfg.Buckets)
+
+	var mp *multiprocess
+	if cfg.MultiprocessDir != "" {
+		var err error
+		if mp, err = newMultiprocess(cfg.MultiprocessDir, cfg.FlushInterval, reg); err != nil {
+			panic(err)
+		}
+	}
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Serve the metrics
+		if c.Path() == cfg.Path && (c.Method() == velocity.MethodGet || c.Method() == velocity.MethodHead) {
+			snap := reg.snapshot()
+			if mp != nil {
+				var err error
+				if snap, err = mp.collect(); err != nil {
+					log.Errorf("metrics: failed to aggregate processes: %v", err)
+				}
+			}
+			c.Set(velocity.HeaderContentType, contentType)
+			return c.Send(writeText(nil, cfg.Namespace, cfg.Buckets, snap))
+		}
+
+		method := utils.CopyString(c.Method())
+		reg.begin(method)
+		start := time.Now()
+
+		// Continue stack
+		chainErr := c.Next()
+
+		duration := time.Since(start).Seconds()
+
+		status := c.Response().StatusCode()
+		if chainErr != nil {
+			status = velocity.StatusInternalServerError
+			var velocityErr *velocity.Error
+			if errors.As(chainErr, &velocityErr) {
+				status = velocityErr.Code
+			}
+		}
+
+		// The route is only known after the handlers ran. Requests which only
+		// passed middlewares share one label, so unknown paths add no series.
+		route := unmatchedRoute
+		if c.Matched() {
+			route = c.Route().Path
+		}
+
+		responseSize := len(c.Response().Body())
+		if c.Response().IsBodyStream() {
+			responseSize = max(c.Response().Header.ContentLength(), 0)
+		}
+
+		reg.end(seriesKey{
+			Method: method,
+			Route:  route,
+			Status: statusClass(status),
+		}, duration, len(c.Request().Body()), responseSize)
+
+		return chainErr
+	}
+}
+
+// statusClass returns the class of the status code, e.g. "2xx"
+func statusClass(status int) string {
+	if status < 100 || status >= 600 {
+		return "unknown"
+	}
+	return statusClasses[status/100-1]
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func scrape(t *testing.T, app *velocity.App, path string) string {
+	t.Helper()
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, path, nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, contentType, resp.Header.Get(velocity.HeaderContentType))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+// go test -run Test_Metrics
+func Test_Metrics(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{Buckets: []float64{0.1, 1}}))
+
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		return c.SendString("hello")
+	})
+	app.Post("/users", func(c velocity.Ctx) error {
+		return c.Status(velocity.StatusCreated).Send(c.Body())
+	})
+	app.Get("/fail", func(_ velocity.Ctx) error {
+		return velocity.ErrServiceUnavailable
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/fail", "/missing/1", "/missing/2"} {
+		_, err := app.Test(httptest.NewRequest(velocity.MethodGet, path, nil))
+		require.NoError(t, err)
+	}
+	_, err := app.Test(httptest.NewRequest(velocity.MethodPost, "/users", strings.NewReader("abc")))
+	require.NoError(t, err)
+
+	body := scrape(t, app, "/metrics")
+
+	require.Contains(t, body, "# TYPE velocity_http_requests_total counter\n")
+	require.Contains(t, body, `velocity_http_requests_total{method="GET",route="/users/:id",status="2xx"} 2`+"\n")
+	require.Contains(t, body, `velocity_http_requests_total{method="POST",route="/users",status="2xx"} 1`+"\n")
+	require.Contains(t, body, `velocity_http_requests_total{method="GET",route="/fail",status="5xx"} 1`+"\n")
+	require.Contains(t, body, `velocity_http_requests_total{method="GET",route="unmatched",status="4xx"} 2`+"\n")
+	require.NotContains(t, body, "/users/1")
+	require.NotContains(t, body, "/missing")
+
+	require.Contains(t, body, "# TYPE velocity_http_request_duration_seconds histogram\n")
+	require.Contains(t, body, `velocity_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="2xx",le="0.1"} 2`+"\n")
+	require.Contains(t, body, `velocity_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="2xx",le="1"} 2`+"\n")
+	require.Contains(t, body, `velocity_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="2xx",le="+Inf"} 2`+"\n")
+	require.Contains(t, body, `velocity_http_request_duration_seconds_count{method="GET",route="/users/:id",status="2xx"} 2`+"\n")
+
+	require.Contains(t, body, "# TYPE velocity_http_requests_in_flight gauge\n")
+	require.Contains(t, body, `velocity_http_requests_in_flight{method="GET"} 0`+"\n")
+
+	require.Contains(t, body, `velocity_http_request_size_bytes_sum{method="POST",route="/users",status="2xx"} 3`+"\n")
+	require.Contains(t, body, `velocity_http_response_size_bytes_sum{method="GET",route="/users/:id",status="2xx"} 10`+"\n")
+	require.Contains(t, body, `velocity_http_response_size_bytes_count{method="GET",route="/users/:id",status="2xx"} 2`+"\n")
+}
+
+// go test -run Test_Metrics_Config
+func Test_Metrics_Config(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{
+		Path:      "/internal/metrics",
+		Namespace: "shop",
+		Next: func(c velocity.Ctx) bool {
+			return c.Path() == "/health"
+		},
+	}))
+	app.Get("/health", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	}).Name("home")
+
+	for _, path := range []string{"/", "/health"} {
+		_, err := app.Test(httptest.NewRequest(velocity.MethodGet, path, nil))
+		require.NoError(t, err)
+	}
+
+	body := scrape(t, app, "/internal/metrics")
+	require.Contains(t, body, `shop_http_requests_total{method="GET",route="/",status="2xx"} 1`+"\n")
+	require.NotContains(t, body, "/health")
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/metrics", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusNotFound, resp.StatusCode)
+}
+
+// go test -run Test_Metrics_Multiprocess
+func Test_Metrics_Multiprocess(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	stale := filepath.Join(dir, filePrefix+"1"+fileSuffix)
+	require.NoError(t, os.WriteFile(stale, []byte("{}"), 0o600))
+
+	app := velocity.New()
+	app.Use(New(Config{MultiprocessDir: dir, Buckets: []float64{1}}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	// Files of previous runs are removed
+	require.NoFileExists(t, stale)
+
+	_, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+
+	// Another process which served two requests and has one in flight
+	other, err := json.Marshal(snapshot{
+		InFlight: map[string]int64{velocity.MethodGet: 1},
+		Series: []series{{
+			seriesKey:   seriesKey{Method: velocity.MethodGet, Route: "/", Status: "2xx"},
+			Buckets:     []uint64{2},
+			Count:       2,
+			DurationSum: 0.5,
+		}},
+	})
+	require.NoError(t, err)
+	otherFile := filepath.Join(dir, filePrefix+"2"+fileSuffix)
+	require.NoError(t, os.WriteFile(otherFile, other, 0o600))
+
+	body := scrape(t, app, "/metrics")
+	require.Contains(t, body, `velocity_http_requests_total{method="GET",route="/",status="2xx"} 3`+"\n")
+	require.Contains(t, body, `velocity_http_request_duration_seconds_bucket{method="GET",route="/",status="2xx",le="1"} 3`+"\n")
+	require.Contains(t, body, `velocity_http_requests_in_flight{method="GET"} 1`+"\n")
+	require.FileExists(t, filepath.Join(dir, filePrefix+strconv.Itoa(os.Getpid())+fileSuffix))
+
+	// Requests in flight of processes which stopped updating are ignored
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(otherFile, old, old))
+	body = scrape(t, app, "/metrics")
+	require.Contains(t, body, `velocity_http_requests_total{method="GET",route="/",status="2xx"} 3`+"\n")
+	require.Contains(t, body, `velocity_http_requests_in_flight{method="GET"} 0`+"\n")
+}
+
+// go test -run Test_StatusClass
+func Test_StatusClass(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "1xx", statusClass(101))
+	require.Equal(t, "2xx", statusClass(204))
+	require.Equal(t, "3xx", statusClass(304))
+	require.Equal(t, "4xx", statusClass(404))
+	require.Equal(t, "5xx", statusClass(599))
+	require.Equal(t, "unknown", statusClass(600))
+	require.Equal(t, "unknown", statusClass(0))
+}
+
+// go test -run Test_AppendLabelValue
+func Test_AppendLabelValue(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, `/plain`, string(appendLabelValue(nil, "/plain")))
+	require.Equal(t, `a\\b\"c\nd`, string(appendLabelValue(nil, "a\\b\"c\nd")))
+}
+
+// go test -v -run=^$ -bench=Benchmark_Metrics -benchmem -count=4
+func Benchmark_Metrics(b *testing.B) {
+	app := velocity.New()
+	app.Use(New())
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod(velocity.MethodGet)
+	fctx.Request.SetRequestURI("/users/1")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		h(fctx)
+	}
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/log"
+)
+
+// Names of the files in the multiprocess directory
+const (
+	filePrefix = "velocity-metrics-"
+	fileSuffix = ".json"
+)
+
+// multiprocess shares the metrics of the processes of an app through files
+type multiprocess struct {
+	registry *registry
+	dir      string
+	file     string
+	interval time.Duration
+}
+
+// newMultiprocess prepares the directory and starts writing the metrics of
+// the process periodically. Outside of prefork children, the files of
+// previous runs are removed.
+func newMultiprocess(dir string, interval time.Duration, r *registry) (*multiprocess, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("metrics: failed to create directory: %w", err)
+	}
+	if !velocity.IsChild() {
+		files, err := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileSuffix))
+		if err != nil {
+			return nil, fmt.Errorf("metrics: failed to list files: %w", err)
+		}
+		for _, file := range files {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("metrics: failed to remove stale file: %w", err)
+			}
+		}
+	}
+
+	mp := &multiprocess{
+		registry: r,
+		dir:      dir,
+		file:     filepath.Join(dir, filePrefix+strconv.Itoa(os.Getpid())+fileSuffix),
+		interval: interval,
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := mp.write(mp.registry.snapshot()); err != nil {
+				log.Errorf("metrics: %v", err)
+			}
+		}
+	}()
+
+	return mp, nil
+}
+
+// write stores the snapshot of the process atomically
+func (mp *multiprocess) write(snap snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+	tmp, err := os.CreateTemp(mp.dir, ".tmp-"+filePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()           //nolint:errcheck // the write error is returned
+		_ = os.Remove(tmp.Name()) //nolint:errcheck // the write error is returned
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name()) //nolint:errcheck // the close error is returned
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), mp.file); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return nil
+}
+
+// collect returns the aggregated metrics of all processes. The snapshot of
+// the current process is written first, so it is always up to date. The
+// counters of exited processes are kept, but their requests in flight are
+// ignored once their file is not updated anymore.
+func (mp *multiprocess) collect() (snapshot, error) {
+	own := mp.registry.snapshot()
+	if err := mp.write(own); err != nil {
+		return own, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(mp.dir, filePrefix+"*"+fileSuffix))
+	if err != nil {
+		return own, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	stale := time.Now().Add(-3 * mp.interval)
+	others := make([]snapshot, 0, len(files))
+	for _, file := range files {
+		if file == mp.file || !strings.HasSuffix(file, fileSuffix) {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			continue // removed in the meantime
+		}
+		data, err := os.ReadFile(file) //nolint:gosec // the file is in the configured directory
+		if err != nil {
+			continue
+		}
+		var snap snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			log.Warnf("metrics: ignoring invalid file %s: %v", file, err)
+			continue
+		}
+		if info.ModTime().Before(stale) {
+			snap.InFlight = nil
+		}
+		others = append(others, snap)
+	}
+
+	own.merge(others...)
+	return own, nil
+}
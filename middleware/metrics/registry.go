@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// seriesKey identifies the metrics of one label combination
+type seriesKey struct {
+	Method string `json:"method"`
+	Route  string `json:"route"`
+	Status string `json:"status"`
+}
+
+// series holds the metrics of one label combination
+type series struct {
+	seriesKey
+	Buckets         []uint64 `json:"buckets"`
+	Count           uint64   `json:"count"`
+	DurationSum     float64  `json:"duration_sum"`
+	RequestSizeSum  float64  `json:"request_size_sum"`
+	ResponseSizeSum float64  `json:"response_size_sum"`
+}
+
+// snapshot is a copy of all metrics of a registry. It is also the format
+// of the files written to the multiprocess directory.
+type snapshot struct {
+	InFlight map[string]int64 `json:"in_flight"`
+	Series   []series         `json:"series"`
+}
+
+// registry collects the metrics of the process
+type registry struct {
+	series   map[seriesKey]*series
+	inFlight map[string]int64
+	buckets  []float64
+	mu       sync.Mutex
+}
+
+func newRegistry(buckets []float64) *registry {
+	return &registry{
+		series:   make(map[seriesKey]*series),
+		inFlight: make(map[string]int64),
+		buckets:  buckets,
+	}
+}
+
+// begin counts a request in flight
+func (r *registry) begin(method string) {
+	r.mu.Lock()
+	r.inFlight[method]++
+	r.mu.Unlock()
+}
+
+// end counts a finished request
+func (r *registry) end(key seriesKey, duration float64, requestSize, responseSize int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inFlight[key.Method]--
+
+	s, ok := r.series[key]
+	if !ok {
+		s = &series{seriesKey: key, Buckets: make([]uint64, len(r.buckets))}
+		r.series[key] = s
+	}
+	s.Count++
+	s.DurationSum += duration
+	s.RequestSizeSum += float64(requestSize)
+	s.ResponseSizeSum += float64(responseSize)
+	// Buckets are not cumulative here, they are summed up on exposition
+	for i, bound := range r.buckets {
+		if duration <= bound {
+			s.Buckets[i]++
+			break
+		}
+	}
+}
+
+// snapshot copies the metrics
+func (r *registry) snapshot() snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snap := snapshot{
+		InFlight: make(map[string]int64, len(r.inFlight)),
+		Series:   make([]series, 0, len(r.series)),
+	}
+	for method, n := range r.inFlight {
+		snap.InFlight[method] = n
+	}
+	for _, s := range r.series {
+		c := *s
+		c.Buckets = append([]uint64(nil), s.Buckets...)
+		snap.Series = append(snap.Series, c)
+	}
+	return snap
+}
+
+// merge adds the metrics of other snapshots, e.g. of other processes.
+// Series with a different number of buckets are skipped.
+func (s *snapshot) merge(others ...snapshot) {
+	index := make(map[seriesKey]int, len(s.Series))
+	for i := range s.Series {
+		index[s.Series[i].seriesKey] = i
+	}
+
+	for _, other := range others {
+		for method, n := range other.InFlight {
+			s.InFlight[method] += n
+		}
+		for _, o := range other.Series {
+			i, ok := index[o.seriesKey]
+			if !ok {
+				o.Buckets = append([]uint64(nil), o.Buckets...)
+				index[o.seriesKey] = len(s.Series)
+				s.Series = append(s.Series, o)
+				continue
+			}
+			t := &s.Series[i]
+			if len(t.Buckets) != len(o.Buckets) {
+				continue
+			}
+			t.Count += o.Count
+			t.DurationSum += o.DurationSum
+			t.RequestSizeSum += o.RequestSizeSum
+			t.ResponseSizeSum += o.ResponseSizeSum
+			for j := range o.Buckets {
+				t.Buckets[j] += o.Buckets[j]
+			}
+		}
+	}
+}
+
+// sort orders the series by their labels for a stable output
+func (s *snapshot) sort() {
+	sort.Slice(s.Series, func(i, j int) bool {
+		a, b := s.Series[i].seriesKey, s.Series[j].seriesKey
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Status < b.Status
+	})
+}
@@ -0,0 +1,172 @@
+package oidc
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/client"
+	"github.com/khulnasoft/velocity/log"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// Client requests the discovery document, the JWKS and the tokens
+	// from the identity provider.
+	//
+	// Optional. Default: client.New() with a timeout of 10 seconds
+	Client *client.Client
+
+	// Unauthenticated is called for requests without a valid login.
+	//
+	// Optional. Default: starts the login for GET and HEAD requests and
+	// returns velocity.ErrUnauthorized otherwise
+	Unauthenticated velocity.Handler
+
+	// ErrorHandler is called when the login, the token refresh or the
+	// identity provider fail.
+	//
+	// Optional. Default: logs the error and returns velocity.ErrBadGateway if the
+	// identity provider is unavailable and velocity.ErrUnauthorized otherwise
+	ErrorHandler velocity.ErrorHandler
+
+	// Issuer is the issuer URL of the identity provider, from which the
+	// discovery document is fetched, e.g. "https://accounts.example.com".
+	//
+	// Required.
+	Issuer string
+
+	// ClientID is the client ID registered at the identity provider.
+	//
+	// Required.
+	ClientID string
+
+	// ClientSecret is the client secret of confidential clients. Public
+	// clients rely on PKCE only.
+	//
+	// Optional. Default: ""
+	ClientSecret string
+
+	// RedirectURL is the absolute callback URL registered at the identity
+	// provider. The middleware handles the requests to its path.
+	//
+	// Required.
+	RedirectURL string
+
+	// LoginPath starts the login. The "redirect" query parameter is the
+	// local path the user is sent to after the login.
+	//
+	// Optional. Default: "/auth/login"
+	LoginPath string
+
+	// LogoutPath ends the session and, if the identity provider supports
+	// it, the session at the identity provider.
+	//
+	// Optional. Default: "/auth/logout"
+	LogoutPath string
+
+	// PostLogoutRedirectURL is where the user is sent after the logout.
+	// It needs to be registered at the identity provider for RP-initiated logout.
+	//
+	// Optional. Default: "/"
+	PostLogoutRedirectURL string
+
+	// Scopes are the scopes requested from the identity provider,
+	// "openid" is always requested.
+	//
+	// Optional. Default: []string{"openid", "profile", "email"}
+	Scopes []string
+
+	// RefreshBefore refreshes the tokens with the refresh token this long
+	// before the access token expires.
+	//
+	// Optional. Default: 1 * time.Minute
+	RefreshBefore time.Duration
+
+	// ClockSkew is the tolerated clock difference to the identity provider
+	// when validating the times of the ID token.
+	//
+	// Optional. Default: 1 * time.Minute
+	ClockSkew time.Duration
+
+	// callbackPath is the path of the RedirectURL
+	callbackPath string
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:                  nil,
+	ErrorHandler:          defaultErrorHandler,
+	LoginPath:             "/auth/login",
+	LogoutPath:            "/auth/logout",
+	PostLogoutRedirectURL: "/",
+	Scopes:                []string{"openid", "profile", "email"},
+	RefreshBefore:         1 * time.Minute,
+	ClockSkew:             1 * time.Minute,
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		panic("velocity: oidc middleware requires an Issuer, a ClientID and a RedirectURL")
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		panic("velocity: oidc middleware requires an Issuer, a ClientID and a RedirectURL")
+	}
+	redirectURL, err := url.Parse(cfg.RedirectURL)
+	if err != nil || !redirectURL.IsAbs() {
+		panic("velocity: oidc middleware requires an absolute RedirectURL")
+	}
+	cfg.callbackPath = redirectURL.Path
+	if cfg.callbackPath == "" {
+		cfg.callbackPath = "/"
+	}
+
+	if cfg.Client == nil {
+		cfg.Client = client.New().SetTimeout(10 * time.Second)
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = ConfigDefault.ErrorHandler
+	}
+	if cfg.LoginPath == "" {
+		cfg.LoginPath = ConfigDefault.LoginPath
+	}
+	if cfg.LogoutPath == "" {
+		cfg.LogoutPath = ConfigDefault.LogoutPath
+	}
+	if cfg.PostLogoutRedirectURL == "" {
+		cfg.PostLogoutRedirectURL = ConfigDefault.PostLogoutRedirectURL
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = ConfigDefault.Scopes
+	}
+	if cfg.RefreshBefore <= 0 {
+		cfg.RefreshBefore = ConfigDefault.RefreshBefore
+	}
+	if cfg.ClockSkew <= 0 {
+		cfg.ClockSkew = ConfigDefault.ClockSkew
+	}
+
+	return cfg
+}
+
+// defaultErrorHandler distinguishes an unavailable identity provider from failed logins
+func defaultErrorHandler(_ velocity.Ctx, err error) error {
+	log.Warnf("oidc: %v", err)
+	if errors.Is(err, ErrProviderUnavailable) {
+		return velocity.ErrBadGateway
+	}
+	return velocity.ErrUnauthorized
+}
@@ -0,0 +1,235 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// jwtHeader is the JOSE header of a signed ID token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwk is a public key of the JWKS of the provider
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwks is the JSON Web Key Set of the provider
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// errUnknownKey is returned when no key of the JWKS matches the token
+var errUnknownKey = errors.New("unknown signing key")
+
+// publicKey decodes the RSA or EC public key. Other key types return nil.
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus of key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent of key %q", k.Kid)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q of key %q", k.Crv, k.Kid)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid EC point of key %q", k.Kid)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("invalid EC point of key %q", k.Kid)
+		}
+		return key, nil
+	default:
+		return nil, nil
+	}
+}
+
+// verifyJWT verifies the signature of the compact JWS with the keys, which
+// are looked up by the kid of the token, and returns its claims.
+func verifyJWT(token string, keys func(kid string) []crypto.PublicKey) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+
+	hash, err := algorithmHash(header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1])) //nolint:errcheck // Never fails
+	digest := h.Sum(nil)
+
+	verified := false
+	for _, key := range keys(header.Kid) {
+		if verifySignature(header.Alg, hash, key, digest, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("invalid token signature")
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(rawClaims, &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	return claims, nil
+}
+
+// algorithmHash returns the hash of the asymmetric signing algorithms.
+// Symmetric algorithms and "none" are rejected.
+func algorithmHash(alg string) (crypto.Hash, error) {
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		return crypto.SHA256, nil
+	case "RS384", "PS384", "ES384":
+		return crypto.SHA384, nil
+	case "RS512", "PS512", "ES512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+}
+
+// verifySignature verifies the signature of the digest with the key
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, digest, signature []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] == 'R' {
+			return rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+		}
+		if alg[0] == 'P' {
+			return rsa.VerifyPSS(k, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+	case *ecdsa.PublicKey:
+		// The signature is the concatenation of r and s, see RFC 7518
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[0] != 'E' || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+// validateClaims validates the claims of an ID token of the provider for the client
+func validateClaims(claims map[string]any, issuer, clientID, nonce string, now time.Time, skew time.Duration) error {
+	if iss, _ := claims["iss"].(string); iss != issuer { //nolint:errcheck // Checked by the comparison
+		return fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if sub, _ := claims["sub"].(string); sub == "" { //nolint:errcheck // Checked by the comparison
+		return errors.New("missing subject")
+	}
+
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	found := false
+	for _, aud := range audiences {
+		if aud == clientID {
+			found = true
+		}
+	}
+	if !found {
+		return errors.New("token not issued for this client")
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != clientID {
+		return fmt.Errorf("unexpected authorized party %q", azp)
+	}
+
+	exp, ok := numericDate(claims, "exp")
+	if !ok {
+		return errors.New("missing expiration")
+	}
+	if !now.Before(exp.Add(skew)) {
+		return errors.New("token expired")
+	}
+	if iat, ok := numericDate(claims, "iat"); ok && iat.After(now.Add(skew)) {
+		return errors.New("token issued in the future")
+	}
+
+	if nonce != "" {
+		claimed, _ := claims["nonce"].(string) //nolint:errcheck // Checked by the comparison
+		if subtle.ConstantTimeCompare([]byte(claimed), []byte(nonce)) != 1 {
+			return errors.New("invalid nonce")
+		}
+	}
+	return nil
+}
+
+// numericDate returns the time of a NumericDate claim
+func numericDate(claims map[string]any, name string) (time.Time, bool) {
+	value, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(value), 0), true
+}
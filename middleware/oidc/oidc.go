@@ -0,0 +1,471 @@
+// Package oidc provides an OpenID Connect relying party, which logs users in
+// with the authorization code flow of an identity provider and keeps their
+// tokens in the session.
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/session"
+)
+
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey int
+
+// The keys for the values in context
+const (
+	tokenKey contextKey = iota
+)
+
+// The keys of the tokens and the pending login in the session
+const (
+	sessionAccessToken  = "oidc_access_token"
+	sessionRefreshToken = "oidc_refresh_token"
+	sessionIDToken      = "oidc_id_token"
+	sessionExpiry       = "oidc_expiry"
+	sessionClaims       = "oidc_claims"
+	sessionState        = "oidc_state"
+	sessionNonce        = "oidc_nonce"
+	sessionVerifier     = "oidc_verifier"
+	sessionRedirect     = "oidc_redirect"
+)
+
+// Errors passed to the ErrorHandler
+var (
+	// ErrProviderUnavailable is returned when the identity provider cannot be reached
+	// or responds unexpectedly.
+	ErrProviderUnavailable = errors.New("oidc: identity provider unavailable")
+	// ErrLoginFailed is returned when the identity provider redirects back with an error.
+	ErrLoginFailed = errors.New("oidc: login failed")
+	// ErrInvalidState is returned when the state of the callback does not match the login.
+	ErrInvalidState = errors.New("oidc: invalid state")
+	// ErrInvalidIDToken is returned when the ID token fails the verification.
+	ErrInvalidIDToken = errors.New("oidc: invalid ID token")
+	// ErrTokenRequest is returned when the token endpoint rejects a code or refresh token.
+	ErrTokenRequest = errors.New("oidc: token request failed")
+	// ErrSessionMissing is returned when the session middleware is not registered before.
+	ErrSessionMissing = errors.New("oidc: the session middleware must be registered before the oidc middleware")
+)
+
+// Token holds the tokens of the logged in user and the claims of the ID token.
+type Token struct {
+	// Expiry is the expiration of the access token.
+	Expiry time.Time
+	// Claims are the claims of the ID token, e.g. "email" or "name".
+	Claims map[string]any
+	// AccessToken authorizes requests to APIs on behalf of the user.
+	AccessToken string
+	// RefreshToken renews the tokens, if the identity provider issued one.
+	RefreshToken string
+	// IDToken is the signed ID token.
+	IDToken string
+	// Subject identifies the user at the identity provider.
+	Subject string
+}
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	rp := &relyingParty{
+		provider:  &provider{client: cfg.Client, cfg: &cfg},
+		cfg:       &cfg,
+		refreshes: make(map[string]*refreshCall),
+	}
+	if cfg.Unauthenticated == nil {
+		cfg.Unauthenticated = rp.unauthenticated
+	}
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		sess := session.FromContext(c)
+		if sess == nil {
+			return ErrSessionMissing
+		}
+
+		switch c.Path() {
+		case cfg.LoginPath:
+			return rp.login(c, sess, c.Query("redirect"))
+		case cfg.callbackPath:
+			return rp.callback(c, sess)
+		case cfg.LogoutPath:
+			return rp.logout(c, sess)
+		}
+
+		token := loadToken(sess)
+		if token == nil {
+			return cfg.Unauthenticated(c)
+		}
+
+		if time.Until(token.Expiry) < cfg.RefreshBefore {
+			if token.RefreshToken == "" && time.Now().After(token.Expiry) {
+				clearToken(sess)
+				return cfg.Unauthenticated(c)
+			}
+			if token.RefreshToken != "" {
+				refreshed, err := rp.refreshShared(token)
+				if errors.Is(err, ErrTokenRequest) {
+					// Another request of the session may have redeemed the refresh token
+					if stored := reloadToken(sess); stored != nil && stored.RefreshToken != token.RefreshToken &&
+						time.Now().Before(stored.Expiry) {
+						refreshed, err = stored, nil
+					}
+				}
+				if err != nil {
+					clearToken(sess)
+					if errors.Is(err, ErrProviderUnavailable) {
+						return cfg.ErrorHandler(c, err)
+					}
+					return cfg.Unauthenticated(c)
+				}
+				token = refreshed
+				storeToken(sess, token)
+			}
+		}
+
+		c.Locals(tokenKey, token)
+		return c.Next()
+	}
+}
+
+// TokenFromContext returns the tokens of the logged in user.
+// returns nil if the user is not logged in
+func TokenFromContext(c velocity.Ctx) *Token {
+	token, ok := c.Locals(tokenKey).(*Token)
+	if !ok {
+		return nil
+	}
+	return token
+}
+
+// refreshGrace is how long the result of a refresh is shared with the
+// requests of the session which still carry the redeemed refresh token
+const refreshGrace = 10 * time.Second
+
+// relyingParty implements the login, callback, refresh and logout
+type relyingParty struct {
+	provider  *provider
+	cfg       *Config
+	refreshes map[string]*refreshCall
+	mu        sync.Mutex
+}
+
+// refreshCall is a refresh in flight or done within the refreshGrace
+type refreshCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// unauthenticated starts the login of browsers navigating to a page and
+// rejects other requests, e.g. of scripts, which cannot follow the login
+func (rp *relyingParty) unauthenticated(c velocity.Ctx) error {
+	if c.Method() != velocity.MethodGet && c.Method() != velocity.MethodHead {
+		return velocity.ErrUnauthorized
+	}
+	return rp.login(c, session.FromContext(c), c.OriginalURL())
+}
+
+// login redirects to the authorization endpoint with a new state, nonce
+// and PKCE code challenge, which are kept in the session for the callback
+func (rp *relyingParty) login(c velocity.Ctx, sess *session.Middleware, redirect string) error {
+	doc, err := rp.provider.discover()
+	if err != nil {
+		return rp.cfg.ErrorHandler(c, err)
+	}
+
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+	sess.Set(sessionState, state)
+	sess.Set(sessionNonce, nonce)
+	sess.Set(sessionVerifier, verifier)
+	sess.Set(sessionRedirect, localRedirect(redirect))
+
+	scopes := rp.cfg.Scopes
+	if !containsScope(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {rp.cfg.ClientID},
+		"redirect_uri":          {rp.cfg.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	c.Set(velocity.HeaderCacheControl, "no-store")
+	return c.Redirect().To(appendQuery(doc.AuthorizationEndpoint, query))
+}
+
+// callback exchanges the authorization code for the tokens, verifies the
+// ID token and logs the user in
+func (rp *relyingParty) callback(c velocity.Ctx, sess *session.Middleware) error {
+	// The pending login can only be completed once
+	state, _ := sess.Get(sessionState).(string)       //nolint:errcheck // Checked below
+	nonce, _ := sess.Get(sessionNonce).(string)       //nolint:errcheck // Checked below
+	verifier, _ := sess.Get(sessionVerifier).(string) //nolint:errcheck // Checked below
+	redirect, _ := sess.Get(sessionRedirect).(string) //nolint:errcheck // Checked below
+	for _, key := range []string{sessionState, sessionNonce, sessionVerifier, sessionRedirect} {
+		sess.Delete(key)
+	}
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(state)) != 1 {
+		return rp.cfg.ErrorHandler(c, ErrInvalidState)
+	}
+	if loginErr := c.Query("error"); loginErr != "" {
+		return rp.cfg.ErrorHandler(c, fmt.Errorf("%w: %s %s", ErrLoginFailed, loginErr, c.Query("error_description")))
+	}
+	code := c.Query("code")
+	if code == "" {
+		return rp.cfg.ErrorHandler(c, fmt.Errorf("%w: missing code", ErrLoginFailed))
+	}
+
+	tokens, err := rp.provider.token(map[string]string{
+		"grant_type":    "authorization_code",
+		"code":          code,
+		"redirect_uri":  rp.cfg.RedirectURL,
+		"code_verifier": verifier,
+	})
+	if err != nil {
+		return rp.cfg.ErrorHandler(c, err)
+	}
+	if tokens.IDToken == "" {
+		return rp.cfg.ErrorHandler(c, fmt.Errorf("%w: missing ID token", ErrInvalidIDToken))
+	}
+	claims, err := rp.provider.verifyIDToken(tokens.IDToken, nonce)
+	if err != nil {
+		return rp.cfg.ErrorHandler(c, err)
+	}
+
+	// A new session ID prevents session fixation
+	if err := sess.Regenerate(); err != nil {
+		return err
+	}
+	storeToken(sess, newToken(tokens, claims))
+
+	return c.Redirect().To(localRedirect(redirect))
+}
+
+// refreshShared refreshes the tokens once for all concurrent requests with the
+// same refresh token, as identity providers rotating refresh tokens only
+// redeem them once
+func (rp *relyingParty) refreshShared(token *Token) (*Token, error) {
+	rp.mu.Lock()
+	call, ok := rp.refreshes[token.RefreshToken]
+	if !ok {
+		call = &refreshCall{done: make(chan struct{})}
+		rp.refreshes[token.RefreshToken] = call
+	}
+	rp.mu.Unlock()
+
+	if !ok {
+		call.token, call.err = rp.refresh(token)
+		close(call.done)
+
+		forget := func() {
+			rp.mu.Lock()
+			delete(rp.refreshes, token.RefreshToken)
+			rp.mu.Unlock()
+		}
+		if call.err != nil {
+			forget()
+		} else {
+			time.AfterFunc(refreshGrace, forget)
+		}
+	}
+
+	<-call.done
+	if call.err != nil {
+		return nil, call.err
+	}
+	refreshed := *call.token
+	return &refreshed, nil
+}
+
+// refresh renews the tokens with the refresh token. The identity provider
+// may rotate the refresh token and issue a new ID token of the same subject.
+func (rp *relyingParty) refresh(token *Token) (*Token, error) {
+	tokens, err := rp.provider.token(map[string]string{
+		"grant_type":    "refresh_token",
+		"refresh_token": token.RefreshToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims
+	if tokens.IDToken != "" {
+		if claims, err = rp.provider.verifyIDToken(tokens.IDToken, ""); err != nil {
+			return nil, err
+		}
+		if sub, _ := claims["sub"].(string); sub != token.Subject { //nolint:errcheck // Checked by the comparison
+			return nil, fmt.Errorf("%w: subject changed on refresh", ErrInvalidIDToken)
+		}
+	} else {
+		tokens.IDToken = token.IDToken
+	}
+	if tokens.RefreshToken == "" {
+		tokens.RefreshToken = token.RefreshToken
+	}
+	return newToken(tokens, claims), nil
+}
+
+// logout destroys the session and redirects to the end session endpoint of
+// the identity provider, if it supports RP-initiated logout
+func (rp *relyingParty) logout(c velocity.Ctx, sess *session.Middleware) error {
+	idToken, _ := sess.Get(sessionIDToken).(string) //nolint:errcheck // Checked below
+	if err := sess.Destroy(); err != nil {
+		return err
+	}
+
+	c.Set(velocity.HeaderCacheControl, "no-store")
+	doc, err := rp.provider.discover()
+	if err != nil || doc.EndSessionEndpoint == "" || idToken == "" {
+		return c.Redirect().To(rp.cfg.PostLogoutRedirectURL)
+	}
+
+	query := url.Values{
+		"id_token_hint": {idToken},
+		"client_id":     {rp.cfg.ClientID},
+	}
+	if postLogout, err := url.Parse(rp.cfg.PostLogoutRedirectURL); err == nil && postLogout.IsAbs() {
+		query.Set("post_logout_redirect_uri", rp.cfg.PostLogoutRedirectURL)
+	}
+	return c.Redirect().To(appendQuery(doc.EndSessionEndpoint, query))
+}
+
+// newToken creates the token of the response of the token endpoint. Without
+// an expires_in, the access token is assumed to expire with the ID token.
+func newToken(tokens *tokenResponse, claims map[string]any) *Token {
+	token := &Token{
+		Claims:       claims,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      tokens.IDToken,
+	}
+	token.Subject, _ = claims["sub"].(string) //nolint:errcheck // Validated with the ID token
+	if tokens.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
+	} else if exp, ok := numericDate(claims, "exp"); ok {
+		token.Expiry = exp
+	}
+	return token
+}
+
+// storeToken stores the token in the session
+func storeToken(sess *session.Middleware, token *Token) {
+	claims, err := json.Marshal(token.Claims)
+	if err != nil {
+		claims = []byte("{}")
+	}
+	sess.Set(sessionAccessToken, token.AccessToken)
+	sess.Set(sessionRefreshToken, token.RefreshToken)
+	sess.Set(sessionIDToken, token.IDToken)
+	// The codecs decode numbers into different types, so the expiry is kept as string
+	sess.Set(sessionExpiry, strconv.FormatInt(token.Expiry.Unix(), 10))
+	sess.Set(sessionClaims, string(claims))
+}
+
+// loadToken loads the token from the session, or returns nil if the user is not logged in
+func loadToken(sess *session.Middleware) *Token {
+	return decodeToken(sess.Get)
+}
+
+// reloadToken loads the token from the session store, as saved by the last
+// request of the session, or returns nil if it cannot be loaded
+func reloadToken(sess *session.Middleware) *Token {
+	stored, err := sess.Store().GetByID(sess.ID())
+	if err != nil {
+		return nil
+	}
+	defer stored.Release()
+	return decodeToken(stored.Get)
+}
+
+// decodeToken decodes the token from the session values
+func decodeToken(get func(key any) any) *Token {
+	accessToken, ok := get(sessionAccessToken).(string)
+	if !ok || accessToken == "" {
+		return nil
+	}
+	token := &Token{AccessToken: accessToken}
+	token.RefreshToken, _ = get(sessionRefreshToken).(string) //nolint:errcheck // Optional
+	token.IDToken, _ = get(sessionIDToken).(string)           //nolint:errcheck // Optional
+	expiry, _ := get(sessionExpiry).(string)                  //nolint:errcheck // Checked by the parsing
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return nil
+	}
+	token.Expiry = time.Unix(unix, 0)
+	claims, _ := get(sessionClaims).(string) //nolint:errcheck // Checked by the decoding
+	if err := json.Unmarshal([]byte(claims), &token.Claims); err != nil {
+		return nil
+	}
+	token.Subject, _ = token.Claims["sub"].(string) //nolint:errcheck // Validated with the ID token
+	return token
+}
+
+// clearToken removes the token from the session
+func clearToken(sess *session.Middleware) {
+	for _, key := range []string{sessionAccessToken, sessionRefreshToken, sessionIDToken, sessionExpiry, sessionClaims} {
+		sess.Delete(key)
+	}
+}
+
+// localRedirect only allows redirects to local paths, to prevent open redirects
+func localRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return "/"
+	}
+	return redirect
+}
+
+// appendQuery appends the query to the endpoint, which may have a query already
+func appendQuery(endpoint string, query url.Values) string {
+	if strings.Contains(endpoint, "?") {
+		return endpoint + "&" + query.Encode()
+	}
+	return endpoint + "?" + query.Encode()
+}
+
+// containsScope reports whether the scope is requested
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// randomString returns a random string with 256 bits of entropy, used as
+// state, nonce and PKCE code verifier
+func randomString() string {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -0,0 +1,595 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/client"
+	"github.com/khulnasoft/velocity/middleware/session"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+const (
+	testIssuer      = "http://idp.example.com"
+	testClientID    = "app"
+	testSecret      = "secret"
+	testRedirectURL = "http://app.example.com/auth/callback"
+)
+
+// fakeProvider is an in-process identity provider
+type fakeProvider struct {
+	key     *rsa.PrivateKey
+	codes   map[string]url.Values
+	claims  map[string]any
+	refresh string
+	// refreshed counts the refresh token grants, including rejected ones
+	refreshed int
+	expiresIn int64
+	// delay delays the refresh token grants
+	delay time.Duration
+	mu    sync.Mutex
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// startProvider starts the identity provider and returns a client connected to it
+func startProvider(t *testing.T, endSession bool) (*fakeProvider, *client.Client) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp := &fakeProvider{
+		key:       key,
+		codes:     make(map[string]url.Values),
+		claims:    map[string]any{"email": "jane@example.com"},
+		expiresIn: 3600,
+	}
+
+	app := velocity.New()
+	app.Get("/.well-known/openid-configuration", func(c velocity.Ctx) error {
+		doc := map[string]string{
+			"issuer":                 testIssuer,
+			"authorization_endpoint": testIssuer + "/authorize",
+			"token_endpoint":         testIssuer + "/token",
+			"jwks_uri":               testIssuer + "/jwks",
+		}
+		if endSession {
+			doc["end_session_endpoint"] = testIssuer + "/logout"
+		}
+		return c.JSON(doc)
+	})
+	app.Get("/jwks", func(c velocity.Ctx) error {
+		return c.JSON(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	app.Post("/token", func(c velocity.Ctx) error {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+
+		if c.Get(velocity.HeaderAuthorization) != "Basic "+base64.StdEncoding.EncodeToString([]byte(testClientID+":"+testSecret)) {
+			return c.Status(velocity.StatusUnauthorized).JSON(map[string]string{"error": "invalid_client"})
+		}
+
+		var nonce string
+		switch c.FormValue("grant_type") {
+		case "authorization_code":
+			login, ok := idp.codes[c.FormValue("code")]
+			delete(idp.codes, c.FormValue("code"))
+			challenge := sha256.Sum256([]byte(c.FormValue("code_verifier")))
+			if !ok || c.FormValue("redirect_uri") != testRedirectURL ||
+				login.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+				return c.Status(velocity.StatusBadRequest).JSON(map[string]string{"error": "invalid_grant"})
+			}
+			nonce = login.Get("nonce")
+		case "refresh_token":
+			idp.refreshed++
+			time.Sleep(idp.delay)
+			if c.FormValue("refresh_token") != idp.refresh {
+				return c.Status(velocity.StatusBadRequest).JSON(map[string]string{"error": "invalid_grant"})
+			}
+		default:
+			return c.Status(velocity.StatusBadRequest).JSON(map[string]string{"error": "unsupported_grant_type"})
+		}
+
+		claims := map[string]any{
+			"iss": testIssuer,
+			"sub": "jane",
+			"aud": testClientID,
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"iat": time.Now().Unix(),
+		}
+		if nonce != "" {
+			claims["nonce"] = nonce
+		}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		idp.refresh = randomString()
+		return c.JSON(map[string]any{
+			"access_token":  randomString(),
+			"token_type":    "Bearer",
+			"refresh_token": idp.refresh,
+			"id_token":      signRS256(t, key, "key-1", claims),
+			"expires_in":    idp.expiresIn,
+		})
+	})
+
+	ln := fasthttputil.NewInmemoryListener()
+	go func() {
+		require.NoError(t, app.Listener(ln, velocity.ListenConfig{DisableStartupMessage: true}))
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, app.Shutdown())
+	})
+
+	cc := client.New().SetDial(func(_ string) (net.Conn, error) {
+		return ln.Dial()
+	})
+	return idp, cc
+}
+
+// authorize logs the user in at the identity provider and returns the callback URL
+func (idp *fakeProvider) authorize(t *testing.T, location string) string {
+	t.Helper()
+	u, err := url.Parse(location)
+	require.NoError(t, err)
+	query := u.Query()
+	require.Equal(t, testIssuer+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	require.Equal(t, "code", query.Get("response_type"))
+	require.Equal(t, testClientID, query.Get("client_id"))
+	require.Equal(t, testRedirectURL, query.Get("redirect_uri"))
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+
+	code := randomString()
+	idp.mu.Lock()
+	idp.codes[code] = query
+	idp.mu.Unlock()
+	return "/auth/callback?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+}
+
+func newTestApp(t *testing.T, cc *client.Client, config ...Config) *velocity.App {
+	t.Helper()
+	cfg := Config{
+		Client:       cc,
+		Issuer:       testIssuer,
+		ClientID:     testClientID,
+		ClientSecret: testSecret,
+		RedirectURL:  testRedirectURL,
+	}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	return newTestAppWithSession(t, session.Config{}, cfg)
+}
+
+func newTestAppWithSession(t *testing.T, sessionCfg session.Config, cfg Config) *velocity.App {
+	t.Helper()
+	app := velocity.New()
+	app.Use(session.New(sessionCfg))
+	app.Use(New(cfg))
+	app.Get("/profile", func(c velocity.Ctx) error {
+		token := TokenFromContext(c)
+		return c.SendString(token.Subject + " " + token.Claims["email"].(string)) //nolint:forcetypeassert,errcheck // Test
+	})
+	app.Post("/profile", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusNoContent)
+	})
+	return app
+}
+
+// browser follows the requests of a user with the session cookie
+type browser struct {
+	t      *testing.T
+	app    *velocity.App
+	cookie string
+}
+
+func (b *browser) do(method, target string) *http.Response {
+	b.t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	if b.cookie != "" {
+		req.Header.Set(velocity.HeaderCookie, "session_id="+b.cookie)
+	}
+	resp, err := b.app.Test(req)
+	require.NoError(b.t, err)
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "session_id" {
+			b.cookie = cookie.Value
+		}
+	}
+	return resp
+}
+
+func (b *browser) login(idp *fakeProvider) {
+	b.t.Helper()
+	resp := b.do(velocity.MethodGet, "/auth/login?redirect=/profile")
+	require.Equal(b.t, velocity.StatusFound, resp.StatusCode)
+	resp = b.do(velocity.MethodGet, idp.authorize(b.t, resp.Header.Get(velocity.HeaderLocation)))
+	require.Equal(b.t, velocity.StatusFound, resp.StatusCode)
+	require.Equal(b.t, "/profile", resp.Header.Get(velocity.HeaderLocation))
+}
+
+func body(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	raw, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(raw)
+}
+
+func Test_OIDC_Login(t *testing.T) {
+	t.Parallel()
+	idp, cc := startProvider(t, false)
+	b := &browser{t: t, app: newTestApp(t, cc)}
+
+	// Browsers are sent to the login
+	resp := b.do(velocity.MethodGet, "/profile?tab=1")
+	require.Equal(t, velocity.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get(velocity.HeaderLocation))
+	require.NoError(t, err)
+	require.Equal(t, "openid profile email", location.Query().Get("scope"))
+	require.NotEmpty(t, location.Query().Get("nonce"))
+	anonymous := b.cookie
+
+	resp = b.do(velocity.MethodGet, idp.authorize(t, location.String()))
+	require.Equal(t, velocity.StatusFound, resp.StatusCode)
+	require.Equal(t, "/profile?tab=1", resp.Header.Get(velocity.HeaderLocation))
+	require.NotEqual(t, anonymous, b.cookie)
+
+	resp = b.do(velocity.MethodGet, "/profile")
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, "jane jane@example.com", body(t, resp))
+
+	// The code can only be used once
+	resp = b.do(velocity.MethodGet, "/auth/callback?code=x&state=y")
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+}
+
+func Test_OIDC_Codecs(t *testing.T) {
+	t.Parallel()
+
+	for _, codec := range []session.Codec{session.GobCodec{}, session.MsgpCodec{}, session.JSONCodec{}, session.CBORCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			t.Parallel()
+			idp, cc := startProvider(t, false)
+			b := &browser{t: t, app: newTestAppWithSession(t, session.Config{Codec: codec}, Config{
+				Client:       cc,
+				Issuer:       testIssuer,
+				ClientID:     testClientID,
+				ClientSecret: testSecret,
+				RedirectURL:  testRedirectURL,
+			})}
+			b.login(idp)
+
+			for i := 0; i < 2; i++ {
+				resp := b.do(velocity.MethodGet, "/profile")
+				require.Equal(t, velocity.StatusOK, resp.StatusCode)
+				require.Equal(t, "jane jane@example.com", body(t, resp))
+			}
+			// The expiry survives the codec, so the tokens are not refreshed
+			require.Zero(t, idp.refreshed)
+		})
+	}
+}
+
+func Test_OIDC_Unauthenticated(t *testing.T) {
+	t.Parallel()
+	_, cc := startProvider(t, false)
+	b := &browser{t: t, app: newTestApp(t, cc)}
+
+	resp := b.do(velocity.MethodPost, "/profile")
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+}
+
+func Test_OIDC_InvalidState(t *testing.T) {
+	t.Parallel()
+	idp, cc := startProvider(t, false)
+	b := &browser{t: t, app: newTestApp(t, cc)}
+
+	resp := b.do(velocity.MethodGet, "/auth/login")
+	callback := idp.authorize(t, resp.Header.Get(velocity.HeaderLocation))
+	u, err := url.Parse(callback)
+	require.NoError(t, err)
+	query := u.Query()
+	query.Set("state", "forged")
+
+	resp = b.do(velocity.MethodGet, "/auth/callback?"+query.Encode())
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+	// The pending login is consumed
+	resp = b.do(velocity.MethodGet, callback)
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+}
+
+func Test_OIDC_LoginError(t *testing.T) {
+	t.Parallel()
+	_, cc := startProvider(t, false)
+	b := &browser{t: t, app: newTestApp(t, cc)}
+
+	resp := b.do(velocity.MethodGet, "/auth/login")
+	location, err := url.Parse(resp.Header.Get(velocity.HeaderLocation))
+	require.NoError(t, err)
+	resp = b.do(velocity.MethodGet, "/auth/callback?error=access_denied&state="+location.Query().Get("state"))
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+}
+
+func Test_OIDC_InvalidNonce(t *testing.T) {
+	t.Parallel()
+	idp, cc := startProvider(t, false)
+	b := &browser{t: t, app: newTestApp(t, cc)}
+
+	resp := b.do(velocity.MethodGet, "/auth/login")
+	location, err := url.Parse(resp.Header.Get(velocity.HeaderLocation))
+	require.NoError(t, err)
+	query := location.Query()
+	query.Set("nonce", "replayed")
+	location.RawQuery = query.Encode()
+
+	resp = b.do(velocity.MethodGet, idp.authorize(t, location.String()))
+	require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+}
+
+func Test_OIDC_Refresh(t *testing.T) {
+	t.Parallel()
+	idp, cc := startProvider(t, false)
+	b := &browser{t: t, app: newTestApp(t, cc)}
+
+	// The access token expires within RefreshBefore
+	idp.expiresIn = 30
+	b.login(idp)
+
+	resp := b.do(velocity.MethodGet, "/profile")
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, 1, idp.refreshed)
+
+	// The rotated refresh token is stored
+	resp = b.do(velocity.MethodGet, "/profile")
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Equal(t, 2, idp.refreshed)
+
+	// A rejected refresh token ends the login
+	idp.mu.Lock()
+	idp.refresh = "revoked"
+	idp.mu.Unlock()
+	resp = b.do(velocity.MethodGet, "/profile")
+	require.Equal(t, velocity.StatusFound, resp.StatusCode)
+	require.True(t, strings.HasPrefix(resp.Header.Get(velocity.HeaderLocation), testIssuer+"/authorize?"))
+}
+
+func Test_OIDC_Refresh_Concurrent(t *testing.T) {
+	t.Parallel()
+	idp, cc := startProvider(t, false)
+	b := &browser{t: t, app: newTestApp(t, cc)}
+
+	idp.expiresIn = 30
+	idp.delay = 100 * time.Millisecond
+	b.login(idp)
+
+	// The refresh token is redeemed once for all requests of the session
+	var wg sync.WaitGroup
+	statuses := make([]int, 5)
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(velocity.MethodGet, "/profile", nil)
+			req.Header.Set(velocity.HeaderCookie, "session_id="+b.cookie)
+			resp, err := b.app.Test(req)
+			if err == nil {
+				statuses[i] = resp.StatusCode
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, status := range statuses {
+		require.Equal(t, velocity.StatusOK, status)
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	require.Equal(t, 1, idp.refreshed)
+}
+
+func Test_OIDC_Logout(t *testing.T) {
+	t.Parallel()
+
+	t.Run("end session endpoint", func(t *testing.T) {
+		t.Parallel()
+		idp, cc := startProvider(t, true)
+		b := &browser{t: t, app: newTestApp(t, cc, Config{
+			Client:                cc,
+			Issuer:                testIssuer,
+			ClientID:              testClientID,
+			ClientSecret:          testSecret,
+			RedirectURL:           testRedirectURL,
+			PostLogoutRedirectURL: "http://app.example.com/bye",
+		})}
+		b.login(idp)
+
+		resp := b.do(velocity.MethodGet, "/auth/logout")
+		require.Equal(t, velocity.StatusFound, resp.StatusCode)
+		location, err := url.Parse(resp.Header.Get(velocity.HeaderLocation))
+		require.NoError(t, err)
+		require.Equal(t, "/logout", location.Path)
+		require.NotEmpty(t, location.Query().Get("id_token_hint"))
+		require.Equal(t, "http://app.example.com/bye", location.Query().Get("post_logout_redirect_uri"))
+
+		resp = b.do(velocity.MethodPost, "/profile")
+		require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("local", func(t *testing.T) {
+		t.Parallel()
+		idp, cc := startProvider(t, false)
+		b := &browser{t: t, app: newTestApp(t, cc)}
+		b.login(idp)
+
+		resp := b.do(velocity.MethodGet, "/auth/logout")
+		require.Equal(t, velocity.StatusFound, resp.StatusCode)
+		require.Equal(t, "/", resp.Header.Get(velocity.HeaderLocation))
+
+		resp = b.do(velocity.MethodPost, "/profile")
+		require.Equal(t, velocity.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func Test_OIDC_ProviderUnavailable(t *testing.T) {
+	t.Parallel()
+	cc := client.New().SetDial(func(_ string) (net.Conn, error) {
+		return nil, net.ErrClosed
+	})
+	b := &browser{t: t, app: newTestApp(t, cc)}
+
+	resp := b.do(velocity.MethodGet, "/profile")
+	require.Equal(t, velocity.StatusBadGateway, resp.StatusCode)
+}
+
+func Test_OIDC_SessionMissing(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+	app.Use(New(Config{Issuer: testIssuer, ClientID: testClientID, RedirectURL: testRedirectURL}))
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusInternalServerError, resp.StatusCode)
+}
+
+func Test_OIDC_Config(t *testing.T) {
+	t.Parallel()
+	require.PanicsWithValue(t, "velocity: oidc middleware requires an Issuer, a ClientID and a RedirectURL", func() {
+		New()
+	})
+	require.PanicsWithValue(t, "velocity: oidc middleware requires an Issuer, a ClientID and a RedirectURL", func() {
+		New(Config{Issuer: testIssuer, RedirectURL: testRedirectURL})
+	})
+	require.PanicsWithValue(t, "velocity: oidc middleware requires an absolute RedirectURL", func() {
+		New(Config{Issuer: testIssuer, ClientID: testClientID, RedirectURL: "/auth/callback"})
+	})
+
+	cfg := configDefault(Config{Issuer: testIssuer, ClientID: testClientID, RedirectURL: testRedirectURL})
+	require.Equal(t, "/auth/callback", cfg.callbackPath)
+	require.Equal(t, "/auth/login", cfg.LoginPath)
+	require.NotNil(t, cfg.Client)
+}
+
+func Test_OIDC_LocalRedirect(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "/profile?tab=1", localRedirect("/profile?tab=1"))
+	require.Equal(t, "/", localRedirect("https://evil.example.com"))
+	require.Equal(t, "/", localRedirect("//evil.example.com"))
+	require.Equal(t, "/", localRedirect("/\\evil.example.com"))
+	require.Equal(t, "/", localRedirect(""))
+}
+
+func Test_VerifyJWT(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sign := func(alg string, signer func(digest []byte) []byte) string {
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"` + alg + `"}`))
+		payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"jane"}`))
+		digest := sha256.Sum256([]byte(header + "." + payload))
+		return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(signer(digest[:]))
+	}
+	keys := func(_ string) []crypto.PublicKey {
+		return []crypto.PublicKey{&rsaKey.PublicKey, &ecKey.PublicKey}
+	}
+
+	ps256 := sign("PS256", func(digest []byte) []byte {
+		signature, err := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA256, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		require.NoError(t, err)
+		return signature
+	})
+	claims, err := verifyJWT(ps256, keys)
+	require.NoError(t, err)
+	require.Equal(t, "jane", claims["sub"])
+
+	es256 := sign("ES256", func(digest []byte) []byte {
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest)
+		require.NoError(t, err)
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature
+	})
+	_, err = verifyJWT(es256, keys)
+	require.NoError(t, err)
+
+	// The algorithm of the header must match the key
+	_, err = verifyJWT("eyJhbGciOiJQUzI1NiJ9"+es256[strings.Index(es256, "."):], keys)
+	require.Error(t, err)
+	_, err = verifyJWT(sign("none", func([]byte) []byte { return nil }), keys)
+	require.ErrorContains(t, err, "unsupported signing algorithm")
+	_, err = verifyJWT(sign("HS256", func([]byte) []byte { return []byte("mac") }), keys)
+	require.ErrorContains(t, err, "unsupported signing algorithm")
+	_, err = verifyJWT("not.a.token", keys)
+	require.Error(t, err)
+}
+
+func Test_ValidateClaims(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   testIssuer,
+			"sub":   "jane",
+			"aud":   []any{"other", testClientID},
+			"azp":   testClientID,
+			"exp":   float64(now.Add(time.Hour).Unix()),
+			"iat":   float64(now.Unix()),
+			"nonce": "n",
+		}
+	}
+	require.NoError(t, validateClaims(valid(), testIssuer, testClientID, "n", now, time.Minute))
+
+	tests := map[string]func(claims map[string]any){
+		"issuer":   func(claims map[string]any) { claims["iss"] = "http://evil.example.com" },
+		"subject":  func(claims map[string]any) { delete(claims, "sub") },
+		"audience": func(claims map[string]any) { claims["aud"] = "other" },
+		"azp":      func(claims map[string]any) { claims["azp"] = "other" },
+		"expired":  func(claims map[string]any) { claims["exp"] = float64(now.Add(-2 * time.Minute).Unix()) },
+		"future":   func(claims map[string]any) { claims["iat"] = float64(now.Add(time.Hour).Unix()) },
+		"nonce":    func(claims map[string]any) { claims["nonce"] = "m" },
+	}
+	for name, modify := range tests {
+		claims := valid()
+		modify(claims)
+		require.Error(t, validateClaims(claims, testIssuer, testClientID, "n", now, time.Minute), name)
+	}
+}
@@ -0,0 +1,224 @@
+package oidc
+
+import (
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/client"
+)
+
+// minJWKSRefresh limits fetching the JWKS for unknown key IDs
+const minJWKSRefresh = time.Minute
+
+// discovery is the subset of the provider metadata used by the middleware
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// tokenResponse is the successful response of the token endpoint
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// tokenError is the error response of the token endpoint
+type tokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// provider fetches and caches the metadata and keys of the identity provider
+type provider struct {
+	client      *client.Client
+	doc         *discovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+	cfg         *Config
+	mu          sync.Mutex
+	keysMu      sync.RWMutex
+}
+
+// discover returns the provider metadata, which is fetched once
+func (p *provider) discover() (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.doc != nil {
+		return p.doc, nil
+	}
+
+	doc := new(discovery)
+	if err := p.getJSON(strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", doc); err != nil {
+		return nil, err
+	}
+	if doc.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("%w: discovered issuer %q does not match %q", ErrProviderUnavailable, doc.Issuer, p.cfg.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete discovery document", ErrProviderUnavailable)
+	}
+	p.doc = doc
+	return doc, nil
+}
+
+// getJSON fetches and decodes a JSON document of the provider
+func (p *provider) getJSON(uri string, v any) error {
+	req := p.client.R().SetHeader(velocity.HeaderAccept, velocity.MIMEApplicationJSON)
+	resp, err := req.Get(uri)
+	if err != nil {
+		client.ReleaseRequest(req)
+		return fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	defer resp.Close()
+
+	if resp.StatusCode() != velocity.StatusOK {
+		return fmt.Errorf("%w: %s responded with status %d", ErrProviderUnavailable, uri, resp.StatusCode())
+	}
+	if err := resp.JSON(v); err != nil {
+		return fmt.Errorf("%w: invalid response of %s: %w", ErrProviderUnavailable, uri, err)
+	}
+	return nil
+}
+
+// verificationKeys returns the signing keys with the key ID, fetching the
+// JWKS again if the key is unknown, e.g. after the provider rotated its keys
+func (p *provider) verificationKeys(kid string) []crypto.PublicKey {
+	keys := p.lookupKeys(kid)
+	if len(keys) > 0 {
+		return keys
+	}
+
+	p.keysMu.Lock()
+	fetch := time.Since(p.keysFetched) >= minJWKSRefresh || p.keys == nil
+	if fetch {
+		p.keysFetched = time.Now()
+	}
+	p.keysMu.Unlock()
+	if !fetch {
+		return nil
+	}
+
+	if err := p.fetchKeys(); err != nil {
+		return nil
+	}
+	return p.lookupKeys(kid)
+}
+
+// lookupKeys returns the cached keys with the key ID, or all keys if the token has none
+func (p *provider) lookupKeys(kid string) []crypto.PublicKey {
+	p.keysMu.RLock()
+	defer p.keysMu.RUnlock()
+
+	if kid != "" {
+		if key, ok := p.keys[kid]; ok {
+			return []crypto.PublicKey{key}
+		}
+		return nil
+	}
+	keys := make([]crypto.PublicKey, 0, len(p.keys))
+	for _, key := range p.keys {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// fetchKeys fetches the signing keys of the JWKS of the provider
+func (p *provider) fetchKeys() error {
+	doc, err := p.discover()
+	if err != nil {
+		return err
+	}
+	var set jwks
+	if err := p.getJSON(doc.JWKSURI, &set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for i := range set.Keys {
+		if set.Keys[i].Use == "enc" {
+			continue
+		}
+		key, err := set.Keys[i].publicKey()
+		if err != nil || key == nil {
+			continue
+		}
+		keys[set.Keys[i].Kid] = key
+	}
+
+	p.keysMu.Lock()
+	p.keys = keys
+	p.keysMu.Unlock()
+	return nil
+}
+
+// verifyIDToken verifies the signature and the claims of the ID token.
+// The nonce is only checked if it is not empty.
+func (p *provider) verifyIDToken(idToken, nonce string) (map[string]any, error) {
+	if _, err := p.discover(); err != nil {
+		return nil, err
+	}
+	claims, err := verifyJWT(idToken, p.verificationKeys)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+	if err := validateClaims(claims, p.cfg.Issuer, p.cfg.ClientID, nonce, time.Now(), p.cfg.ClockSkew); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+	return claims, nil
+}
+
+// token requests tokens from the token endpoint, authenticating the client
+// with HTTP Basic authentication if it has a secret
+func (p *provider) token(form map[string]string) (*tokenResponse, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form["client_id"] = p.cfg.ClientID
+	req := p.client.R().
+		SetHeader(velocity.HeaderAccept, velocity.MIMEApplicationJSON).
+		SetFormDataWithMap(form)
+	if p.cfg.ClientSecret != "" {
+		credentials := url.QueryEscape(p.cfg.ClientID) + ":" + url.QueryEscape(p.cfg.ClientSecret)
+		req.SetHeader(velocity.HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	resp, err := req.Post(doc.TokenEndpoint)
+	if err != nil {
+		client.ReleaseRequest(req)
+		return nil, fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	defer resp.Close()
+
+	if resp.StatusCode() != velocity.StatusOK {
+		var tokenErr tokenError
+		if err := resp.JSON(&tokenErr); err != nil || tokenErr.Error == "" {
+			return nil, fmt.Errorf("%w: token endpoint responded with status %d", ErrProviderUnavailable, resp.StatusCode())
+		}
+		return nil, fmt.Errorf("%w: %s %s", ErrTokenRequest, tokenErr.Error, tokenErr.ErrorDescription)
+	}
+
+	tokens := new(tokenResponse)
+	if err := resp.JSON(tokens); err != nil {
+		return nil, fmt.Errorf("%w: invalid token response: %w", ErrProviderUnavailable, err)
+	}
+	if tokens.AccessToken == "" {
+		return nil, fmt.Errorf("%w: response without access token", ErrTokenRequest)
+	}
+	return tokens, nil
+}
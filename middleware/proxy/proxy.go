@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/middleware/servertiming"
 	"github.com/khulnasoft/velocity/utils"
 
 	"github.com/valyala/fasthttp"
@@ -186,7 +187,10 @@ func doAction(
 	}
 
 	req.Header.Del(velocity.HeaderConnection)
-	if err := action(cli, req, res); err != nil {
+	stop := servertiming.Start(c, "proxy", "Upstream")
+	err := action(cli, req, res)
+	stop()
+	if err != nil {
 		return err
 	}
 	res.Header.Del(velocity.HeaderConnection)
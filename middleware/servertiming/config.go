@@ -0,0 +1,70 @@
+package servertiming
+
+import (
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// Allow decides whether the timings are exposed to the client.
+	// It replaces AllowIPs if set.
+	//
+	// Optional. Default: nil
+	Allow func(c velocity.Ctx) bool
+
+	// AllowIPs lists the IP addresses and CIDR ranges of the clients the
+	// timings are exposed to, e.g. "127.0.0.1" or "10.0.0.0/8".
+	// By default, the timings are exposed to loopback clients only.
+	// Use Allow to expose them to all clients.
+	//
+	// Optional. Default: []string{"127.0.0.0/8", "::1"}
+	AllowIPs []string
+
+	// TotalName is the name of the metric measuring the total handler time.
+	//
+	// Optional. Default: "total"
+	TotalName string
+
+	// TimingAllowOrigin is the value of the Timing-Allow-Origin header,
+	// which exposes the timings to scripts of other origins.
+	//
+	// Optional. Default: ""
+	TimingAllowOrigin string
+
+	// DisableTotal disables the metric measuring the total handler time.
+	//
+	// Optional. Default: false
+	DisableTotal bool
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:      nil,
+	AllowIPs:  []string{"127.0.0.0/8", "::1"},
+	TotalName: "total",
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		return ConfigDefault
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Allow == nil && len(cfg.AllowIPs) == 0 {
+		cfg.AllowIPs = ConfigDefault.AllowIPs
+	}
+	if cfg.TotalName == "" {
+		cfg.TotalName = ConfigDefault.TotalName
+	}
+	return cfg
+}
@@ -0,0 +1,234 @@
+package servertiming
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// HeaderServerTiming is the response header carrying the timings
+// https://www.w3.org/TR/server-timing/
+const HeaderServerTiming = "Server-Timing"
+
+// HeaderTimingAllowOrigin exposes the timings to scripts of other origins
+const HeaderTimingAllowOrigin = "Timing-Allow-Origin"
+
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey int
+
+// The keys for the values in context
+const (
+	timingsKey contextKey = iota
+)
+
+// Metric is a named duration of the Server-Timing header.
+type Metric struct {
+	Name        string
+	Description string
+	Duration    time.Duration
+}
+
+// timings collects the metrics of a request
+type timings struct {
+	metrics []Metric
+	mu      sync.Mutex
+}
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	allow := cfg.Allow
+	if allow == nil {
+		allow = allowIPs(cfg.AllowIPs)
+	}
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Timings of untrusted clients are not even recorded
+		if !allow(c) {
+			return c.Next()
+		}
+
+		t := &timings{}
+		c.Locals(timingsKey, t)
+		start := time.Now()
+
+		// Continue stack
+		err := c.Next()
+
+		if !cfg.DisableTotal {
+			t.add(Metric{Name: cfg.TotalName, Duration: time.Since(start)})
+		}
+
+		t.mu.Lock()
+		header := appendHeader(nil, t.metrics)
+		t.mu.Unlock()
+
+		if len(header) > 0 {
+			c.Response().Header.Add(HeaderServerTiming, string(header))
+			if cfg.TimingAllowOrigin != "" {
+				c.Set(HeaderTimingAllowOrigin, cfg.TimingAllowOrigin)
+			}
+		}
+
+		return err
+	}
+}
+
+// Record adds a named duration to the Server-Timing header. It does nothing
+// if the middleware is not used or the client is not allowed to see timings,
+// so handlers and middlewares can always call it.
+func Record(c velocity.Ctx, name string, duration time.Duration, description ...string) {
+	t, ok := c.Locals(timingsKey).(*timings)
+	if !ok {
+		return
+	}
+	metric := Metric{Name: name, Duration: duration}
+	if len(description) > 0 {
+		metric.Description = description[0]
+	}
+	t.add(metric)
+}
+
+// Start starts measuring a named duration and returns a function which
+// stops the measurement and adds it to the Server-Timing header.
+//
+//	stop := servertiming.Start(c, "db", "Load user")
+//	user, err := loadUser(id)
+//	stop()
+func Start(c velocity.Ctx, name string, description ...string) func() {
+	t, ok := c.Locals(timingsKey).(*timings)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		metric := Metric{Name: name, Duration: time.Since(start)}
+		if len(description) > 0 {
+			metric.Description = description[0]
+		}
+		t.add(metric)
+	}
+}
+
+// Metrics returns a copy of the metrics recorded for the request,
+// or nil if the middleware is not used or the client is not allowed.
+func Metrics(c velocity.Ctx) []Metric {
+	t, ok := c.Locals(timingsKey).(*timings)
+	if !ok {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Metric(nil), t.metrics...)
+}
+
+func (t *timings) add(metric Metric) {
+	t.mu.Lock()
+	t.metrics = append(t.metrics, metric)
+	t.mu.Unlock()
+}
+
+// appendHeader appends the metrics in the format of the Server-Timing header,
+// e.g. `db;dur=53.2;desc="Load user", total;dur=61.05`.
+// Metrics with invalid names are skipped.
+func appendHeader(buf []byte, metrics []Metric) []byte {
+	for _, metric := range metrics {
+		if !isToken(metric.Name) {
+			continue
+		}
+		if len(buf) > 0 {
+			buf = append(buf, ", "...)
+		}
+		buf = append(buf, metric.Name...)
+		if metric.Duration > 0 {
+			buf = append(buf, ";dur="...)
+			buf = strconv.AppendFloat(buf, float64(metric.Duration.Microseconds())/1000, 'f', -1, 64)
+		}
+		if metric.Description != "" {
+			buf = append(buf, ";desc="...)
+			buf = appendQuoted(buf, metric.Description)
+		}
+	}
+	return buf
+}
+
+// isToken reports whether the name is a token as defined by RFC 9110
+func isToken(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// appendQuoted appends a quoted string, dropping control characters
+func appendQuoted(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c < ' ' || c == 0x7f:
+			// not allowed in headers
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return append(buf, '"')
+}
+
+// allowIPs returns a predicate matching the client IP against the list
+func allowIPs(list []string) func(c velocity.Ctx) bool {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, entry := range list {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				panic(fmt.Sprintf("servertiming: invalid IP %q", entry))
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			panic(fmt.Sprintf("servertiming: invalid CIDR %q", entry))
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return func(c velocity.Ctx) bool {
+		ip := net.ParseIP(c.IP())
+		if ip == nil {
+			return false
+		}
+		for _, ipNet := range nets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,201 @@
+package servertiming
+
+import (
+	"net"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// testClientIPs allows the client IP of app.Test
+var testClientIPs = []string{"0.0.0.0"}
+
+// go test -run Test_ServerTiming
+func Test_ServerTiming(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{AllowIPs: testClientIPs}))
+	app.Get("/", func(c velocity.Ctx) error {
+		stop := Start(c, "db", `Load "user"`)
+		time.Sleep(2 * time.Millisecond)
+		stop()
+		Record(c, "render", 1500*time.Microsecond)
+		Record(c, "miss", 0)
+		Record(c, "invalid name", time.Millisecond)
+
+		require.Len(t, Metrics(c), 4)
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Regexp(t,
+		regexp.MustCompile(`^db;dur=\d+(\.\d+)?;desc="Load \\"user\\"", render;dur=1\.5, miss, total;dur=\d+(\.\d+)?$`),
+		resp.Header.Get(HeaderServerTiming))
+	require.Empty(t, resp.Header.Get(HeaderTimingAllowOrigin))
+}
+
+// go test -run Test_ServerTiming_Error
+func Test_ServerTiming_Error(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{AllowIPs: testClientIPs, TotalName: "app", TimingAllowOrigin: "*"}))
+	app.Get("/", func(_ velocity.Ctx) error {
+		return velocity.ErrTeapot
+	})
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusTeapot, resp.StatusCode)
+	require.Regexp(t, regexp.MustCompile(`^app;dur=\d+(\.\d+)?$`), resp.Header.Get(HeaderServerTiming))
+	require.Equal(t, "*", resp.Header.Get(HeaderTimingAllowOrigin))
+}
+
+// go test -run Test_ServerTiming_Allow
+func Test_ServerTiming_Allow(t *testing.T) {
+	t.Parallel()
+
+	var recorded []Metric
+	handler := func(c velocity.Ctx) error {
+		Record(c, "db", time.Millisecond)
+		recorded = Metrics(c)
+		return c.SendStatus(velocity.StatusOK)
+	}
+
+	// app.Test uses 0.0.0.0 as client IP
+	allowed := velocity.New()
+	allowed.Use(New(Config{AllowIPs: []string{"10.0.0.1", "0.0.0.0/8"}}))
+	allowed.Get("/", handler)
+
+	resp, err := allowed.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Contains(t, resp.Header.Get(HeaderServerTiming), "db;dur=1")
+	require.Len(t, recorded, 1)
+
+	denied := velocity.New()
+	denied.Use(New(Config{AllowIPs: []string{"10.0.0.0/8", "::1"}}))
+	denied.Get("/", handler)
+
+	resp, err = denied.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Empty(t, resp.Header.Get(HeaderServerTiming))
+	require.Nil(t, recorded)
+
+	// By default, the timings are exposed to loopback clients only
+	defaults := velocity.New()
+	defaults.Use(New())
+	defaults.Get("/", handler)
+
+	resp, err = defaults.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Empty(t, resp.Header.Get(HeaderServerTiming))
+	require.Nil(t, recorded)
+
+	for _, ip := range []string{"127.0.0.1", "::1"} {
+		fctx := &fasthttp.RequestCtx{}
+		fctx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP(ip)}, nil)
+		defaults.Handler()(fctx)
+		require.Contains(t, string(fctx.Response.Header.Peek(HeaderServerTiming)), "db;dur=1", ip)
+	}
+
+	custom := velocity.New()
+	custom.Use(New(Config{
+		AllowIPs: []string{"10.0.0.0/8"},
+		Allow: func(c velocity.Ctx) bool {
+			return c.Get("X-Debug-Token") == "secret"
+		},
+	}))
+	custom.Get("/", handler)
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set("X-Debug-Token", "secret")
+	resp, err = custom.Test(req)
+	require.NoError(t, err)
+	require.Contains(t, resp.Header.Get(HeaderServerTiming), "db;dur=1")
+
+	require.PanicsWithValue(t, `servertiming: invalid IP "nope"`, func() {
+		New(Config{AllowIPs: []string{"nope"}})
+	})
+	require.PanicsWithValue(t, `servertiming: invalid CIDR "10.0.0.0/99"`, func() {
+		New(Config{AllowIPs: []string{"10.0.0.0/99"}})
+	})
+}
+
+// go test -run Test_ServerTiming_Next
+func Test_ServerTiming_Next(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{
+		Next: func(_ velocity.Ctx) bool {
+			return true
+		},
+	}))
+	app.Get("/", func(c velocity.Ctx) error {
+		Start(c, "db")()
+		Record(c, "db", time.Millisecond)
+		require.Nil(t, Metrics(c))
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Empty(t, resp.Header.Get(HeaderServerTiming))
+}
+
+// go test -run Test_ServerTiming_DisableTotal
+func Test_ServerTiming_DisableTotal(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{AllowIPs: testClientIPs, DisableTotal: true}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	_, ok := resp.Header[HeaderServerTiming]
+	require.False(t, ok)
+}
+
+// go test -run Test_AppendHeader
+func Test_AppendHeader(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, `a;dur=0.001, b;desc="x\\y"`, string(appendHeader(nil, []Metric{
+		{Name: "a", Duration: time.Microsecond},
+		{Name: "b", Description: "x\\\ny"},
+		{Name: "c,d"},
+		{Name: ""},
+	})))
+}
+
+// go test -v -run=^$ -bench=Benchmark_ServerTiming -benchmem -count=4
+func Benchmark_ServerTiming(b *testing.B) {
+	app := velocity.New()
+	app.Use(New(Config{AllowIPs: testClientIPs}))
+	app.Get("/", func(c velocity.Ctx) error {
+		Record(c, "db", time.Millisecond, "Query")
+		return c.SendStatus(velocity.StatusOK)
+	})
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod(velocity.MethodGet)
+	fctx.Request.SetRequestURI("/")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		h(fctx)
+	}
+}
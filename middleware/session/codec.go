@@ -0,0 +1,324 @@
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/khulnasoft/velocity/utils"
+	"github.com/tinylib/msgp/msgp"
+)
+
+var (
+	// ErrUnsupportedKey is returned by the codecs which only support string keys.
+	ErrUnsupportedKey = errors.New("session keys must be strings")
+	// ErrUnknownCodec is returned when the session data was encoded by an unknown codec.
+	ErrUnknownCodec = errors.New("unknown session codec")
+)
+
+// Codec encodes and decodes the session data. The name of the codec is
+// stored in the envelope of the encoded data, so sessions encoded by another
+// codec can still be decoded after the codec was changed.
+type Codec interface {
+	// Name identifies the codec in the envelope, it must not be longer than 255 bytes.
+	Name() string
+	// Marshal encodes the session data.
+	Marshal(data map[any]any) ([]byte, error)
+	// Unmarshal decodes the session data.
+	Unmarshal(raw []byte) (map[any]any, error)
+}
+
+const (
+	// envelopeMarker starts the envelope of the session data. A gob stream
+	// never starts with a zero byte, so it distinguishes the envelope from
+	// the session data which was gob-encoded without an envelope.
+	envelopeMarker byte = 0x00
+	// envelopeVersion is the version of the envelope format:
+	// marker, version, length of the codec name, codec name, encoded data.
+	envelopeVersion byte = 1
+
+	// absExpirationName is the key of the absolute expiration in the encoded
+	// data, as Unix time in seconds, so other languages can read it.
+	absExpirationName = "velocity.absolute_expiration"
+)
+
+// Session pool for reusing byte buffers.
+var byteBufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// codecs are the built-in codecs, which can always be decoded
+var codecs = map[string]Codec{
+	GobCodec{}.Name():  GobCodec{},
+	MsgpCodec{}.Name(): MsgpCodec{},
+	JSONCodec{}.Name(): JSONCodec{},
+	CBORCodec{}.Name(): CBORCodec{},
+}
+
+// encode encodes the session data with the codec of the store and wraps it into an envelope
+func (s *Store) encode(data map[any]any) ([]byte, error) {
+	// Other languages cannot read the key type of the absolute expiration
+	if absExpiration, ok := data[absExpirationKey].(time.Time); ok {
+		wire := make(map[any]any, len(data))
+		for key, value := range data {
+			wire[key] = value
+		}
+		delete(wire, absExpirationKey)
+		wire[absExpirationName] = absExpiration.Unix()
+		data = wire
+	}
+
+	name := s.Codec.Name()
+	raw, err := s.Codec.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := make([]byte, 0, 3+len(name)+len(raw))
+	encoded = append(encoded, envelopeMarker, envelopeVersion, byte(len(name)))
+	encoded = append(encoded, name...)
+	return append(encoded, raw...), nil
+}
+
+// decode unwraps the envelope and decodes the session data with the codec it
+// names. Data without an envelope was encoded with gob by earlier versions.
+func (s *Store) decode(encoded []byte) (map[any]any, error) {
+	if len(encoded) == 0 || encoded[0] != envelopeMarker {
+		return GobCodec{}.Unmarshal(encoded)
+	}
+	if len(encoded) < 3 || encoded[1] != envelopeVersion || len(encoded) < 3+int(encoded[2]) {
+		return nil, errors.New("invalid session envelope")
+	}
+	name := string(encoded[3 : 3+encoded[2]])
+
+	codec, ok := codecs[name]
+	if name == s.Codec.Name() {
+		codec, ok = s.Codec, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+
+	data, err := codec.Unmarshal(encoded[3+encoded[2]:])
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := data[absExpirationName]; ok {
+		delete(data, absExpirationName)
+		if unix, ok := toInt64(value); ok {
+			data[absExpirationKey] = time.Unix(unix, 0)
+		}
+	}
+	return data, nil
+}
+
+// toInt64 converts the number types the codecs decode integers into
+func toInt64(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true //nolint:gosec // Unix time
+	case float64:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// stringKeys converts the session data for the codecs which only support string keys
+func stringKeys(data map[any]any) (map[string]any, error) {
+	m := make(map[string]any, len(data))
+	for key, value := range data {
+		k, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w, got %T", ErrUnsupportedKey, key)
+		}
+		m[k] = value
+	}
+	return m, nil
+}
+
+// anyKeys converts the decoded data of the codecs which only support string keys
+func anyKeys(m map[string]any) map[any]any {
+	data := make(map[any]any, len(m))
+	for key, value := range m {
+		data[key] = value
+	}
+	return data
+}
+
+// GobCodec encodes the session data with encoding/gob. It supports keys and
+// values of any type, but custom types must be registered with
+// Store.RegisterType, and the data can only be read by Go.
+type GobCodec struct{}
+
+// Name returns "gob".
+func (GobCodec) Name() string {
+	return "gob"
+}
+
+// Marshal encodes the session data with gob.
+func (GobCodec) Marshal(data map[any]any) ([]byte, error) {
+	byteBuffer := byteBufferPool.Get().(*bytes.Buffer) //nolint:forcetypeassert,errcheck // We store nothing else in the pool
+	defer byteBufferPool.Put(byteBuffer)
+	defer byteBuffer.Reset()
+	if err := gob.NewEncoder(byteBuffer).Encode(&data); err != nil {
+		return nil, err
+	}
+	// Copy the data in buffer
+	encodedBytes := make([]byte, byteBuffer.Len())
+	copy(encodedBytes, byteBuffer.Bytes())
+	return encodedBytes, nil
+}
+
+// Unmarshal decodes the session data with gob.
+func (GobCodec) Unmarshal(raw []byte) (map[any]any, error) {
+	var data map[any]any
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = make(map[any]any)
+	}
+	return data, nil
+}
+
+// MsgpCodec encodes the session data with MessagePack. Keys must be strings,
+// and values are decoded into the generic types of msgp, e.g. int64 for all
+// signed integers. Types implementing msgp.Marshaler and msgp extensions are supported.
+type MsgpCodec struct{}
+
+// Name returns "msgp".
+func (MsgpCodec) Name() string {
+	return "msgp"
+}
+
+// Marshal encodes the session data with MessagePack.
+func (MsgpCodec) Marshal(data map[any]any) ([]byte, error) {
+	m, err := stringKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	return msgp.AppendMapStrIntf(nil, m)
+}
+
+// Unmarshal decodes the session data with MessagePack.
+func (MsgpCodec) Unmarshal(raw []byte) (map[any]any, error) {
+	m, _, err := msgp.ReadMapStrIntfBytes(raw, nil)
+	if err != nil {
+		return nil, err
+	}
+	return anyKeys(m), nil
+}
+
+// JSONCodec encodes the session data as JSON object. Keys must be strings,
+// and values are decoded into the generic types of encoding/json, e.g.
+// float64 for all numbers and map[string]any for objects.
+type JSONCodec struct {
+	// Encoder encodes the data.
+	//
+	// Optional. Default: json.Marshal
+	Encoder utils.JSONMarshal
+
+	// Decoder decodes the data.
+	//
+	// Optional. Default: json.Unmarshal
+	Decoder utils.JSONUnmarshal
+}
+
+// Name returns "json".
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+// Marshal encodes the session data as JSON.
+func (c JSONCodec) Marshal(data map[any]any) ([]byte, error) {
+	m, err := stringKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	encoder := c.Encoder
+	if encoder == nil {
+		encoder = json.Marshal
+	}
+	return encoder(m)
+}
+
+// Unmarshal decodes the session data from JSON.
+func (c JSONCodec) Unmarshal(raw []byte) (map[any]any, error) {
+	decoder := c.Decoder
+	if decoder == nil {
+		decoder = json.Unmarshal
+	}
+	var m map[string]any
+	if err := decoder(raw, &m); err != nil {
+		return nil, err
+	}
+	return anyKeys(m), nil
+}
+
+// cborDecMode decodes CBOR maps into map[string]any like the other codecs
+var cborDecMode = func() cbor.DecMode {
+	mode, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]any(nil))}.DecMode()
+	if err != nil {
+		panic(fmt.Sprintf("session: failed to create CBOR decoder: %v", err))
+	}
+	return mode
+}()
+
+// CBORCodec encodes the session data with CBOR. Keys must be strings, and
+// values are decoded into the generic types of fxamacker/cbor, e.g. uint64
+// for positive and int64 for negative integers.
+type CBORCodec struct {
+	// Encoder encodes the data.
+	//
+	// Optional. Default: cbor.Marshal
+	Encoder utils.CBORMarshal
+
+	// Decoder decodes the data.
+	//
+	// Optional. Default: cbor.Unmarshal, decoding maps into map[string]any
+	Decoder utils.CBORUnmarshal
+}
+
+// Name returns "cbor".
+func (CBORCodec) Name() string {
+	return "cbor"
+}
+
+// Marshal encodes the session data with CBOR.
+func (c CBORCodec) Marshal(data map[any]any) ([]byte, error) {
+	m, err := stringKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	encoder := c.Encoder
+	if encoder == nil {
+		encoder = cbor.Marshal
+	}
+	return encoder(m)
+}
+
+// Unmarshal decodes the session data from CBOR.
+func (c CBORCodec) Unmarshal(raw []byte) (map[any]any, error) {
+	decoder := c.Decoder
+	if decoder == nil {
+		decoder = cborDecMode.Unmarshal
+	}
+	var m map[string]any
+	if err := decoder(raw, &m); err != nil {
+		return nil, err
+	}
+	return anyKeys(m), nil
+}
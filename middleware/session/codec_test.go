@@ -0,0 +1,194 @@
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// saveSession saves a session with the values and returns its ID
+func saveSession(t *testing.T, app *velocity.App, store *Store, values map[string]any) string {
+	t.Helper()
+
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	sess, err := store.Get(ctx)
+	require.NoError(t, err)
+	defer sess.Release()
+	for key, value := range values {
+		sess.Set(key, value)
+	}
+	require.NoError(t, sess.Save())
+	return sess.ID()
+}
+
+// go test -run Test_Codecs
+func Test_Codecs(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	values := map[string]any{
+		"name":  "john",
+		"admin": true,
+		"roles": []any{"reader", "writer"},
+	}
+
+	for _, tc := range []struct {
+		codec Codec
+		id    any // the decoded type of integers
+	}{
+		{codec: GobCodec{}, id: 42},
+		{codec: MsgpCodec{}, id: int64(42)},
+		{codec: JSONCodec{}, id: float64(42)},
+		{codec: CBORCodec{}, id: uint64(42)},
+	} {
+		t.Run(tc.codec.Name(), func(t *testing.T) {
+			t.Parallel()
+
+			store := NewStore(Config{Codec: tc.codec, AbsoluteTimeout: time.Hour})
+			store.RegisterType([]any{}) // only used by gob
+			id := saveSession(t, app, store, map[string]any{
+				"name":  values["name"],
+				"admin": values["admin"],
+				"roles": values["roles"],
+				"id":    42,
+			})
+
+			raw, err := store.Storage.Get(id)
+			require.NoError(t, err)
+			require.Equal(t, []byte{envelopeMarker, envelopeVersion, byte(len(tc.codec.Name()))}, raw[:3])
+			require.Equal(t, tc.codec.Name(), string(raw[3:3+len(tc.codec.Name())]))
+
+			sess, err := store.GetByID(id)
+			require.NoError(t, err)
+			defer sess.Release()
+			for key, value := range values {
+				require.Equal(t, value, sess.Get(key), key)
+			}
+			require.Equal(t, tc.id, sess.Get("id"))
+			require.WithinDuration(t, time.Now().Add(time.Hour), sess.absExpiration(), 2*time.Second)
+			require.Nil(t, sess.Get(absExpirationName))
+		})
+	}
+}
+
+// go test -run Test_Codec_Migration
+func Test_Codec_Migration(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	storage := memory.New()
+
+	id := saveSession(t, app, NewStore(Config{Storage: storage, AbsoluteTimeout: time.Hour}), map[string]any{"name": "john"})
+
+	// The sessions of the previous codec are still decoded
+	store := NewStore(Config{Storage: storage, Codec: JSONCodec{}, AbsoluteTimeout: time.Hour})
+	sess, err := store.GetByID(id)
+	require.NoError(t, err)
+	require.Equal(t, "john", sess.Get("name"))
+	expiration := sess.absExpiration()
+	require.False(t, expiration.IsZero())
+
+	// And encoded with the new codec when saved
+	require.NoError(t, sess.Save())
+	sess.Release()
+
+	raw, err := storage.Get(id)
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(raw[3+len("json"):], &decoded))
+	require.Equal(t, map[string]any{
+		"name":            "john",
+		absExpirationName: float64(expiration.Unix()),
+	}, decoded)
+}
+
+// go test -run Test_Codec_Legacy
+func Test_Codec_Legacy(t *testing.T) {
+	t.Parallel()
+
+	store := NewStore(Config{Codec: MsgpCodec{}, AbsoluteTimeout: time.Hour})
+
+	// Session data encoded with gob, before the envelope was introduced
+	expiration := time.Now().Add(time.Hour).Round(0)
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(map[any]any{
+		"name":           "john",
+		absExpirationKey: expiration,
+	}))
+	require.NoError(t, store.Storage.Set("legacy", buf.Bytes(), 0))
+
+	sess, err := store.GetByID("legacy")
+	require.NoError(t, err)
+	defer sess.Release()
+	require.Equal(t, "john", sess.Get("name"))
+	require.True(t, expiration.Equal(sess.absExpiration()))
+}
+
+// customCodec is a custom codec for the tests
+type customCodec struct {
+	JSONCodec
+}
+
+func (customCodec) Name() string {
+	return "custom"
+}
+
+// go test -run Test_Codec_Errors
+func Test_Codec_Errors(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+
+	t.Run("unsupported key", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+		defer app.ReleaseCtx(ctx)
+
+		for _, codec := range []Codec{MsgpCodec{}, JSONCodec{}, CBORCodec{}} {
+			sess, err := NewStore(Config{Codec: codec}).Get(ctx)
+			require.NoError(t, err)
+			sess.Set(1, "one")
+			require.ErrorIs(t, sess.Save(), ErrUnsupportedKey, codec.Name())
+			sess.Release()
+		}
+	})
+
+	t.Run("unknown codec", func(t *testing.T) {
+		t.Parallel()
+
+		storage := memory.New()
+		id := saveSession(t, app, NewStore(Config{Storage: storage, Codec: customCodec{}}), map[string]any{"name": "john"})
+
+		sess, err := NewStore(Config{Storage: storage, Codec: customCodec{}}).GetByID(id)
+		require.NoError(t, err)
+		require.Equal(t, "john", sess.Get("name"))
+		sess.Release()
+
+		// Custom codecs are only decoded by stores using them
+		_, err = NewStore(Config{Storage: storage}).GetByID(id)
+		require.ErrorIs(t, err, ErrUnknownCodec)
+	})
+
+	t.Run("invalid envelope", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewStore()
+		require.NoError(t, store.Storage.Set("invalid", []byte{envelopeMarker, envelopeVersion + 1, 0}, 0))
+		_, err := store.GetByID("invalid")
+		require.ErrorContains(t, err, "invalid session envelope")
+
+		require.NoError(t, store.Storage.Set("truncated", []byte{envelopeMarker, envelopeVersion, 4, 'j'}, 0))
+		_, err = store.GetByID("truncated")
+		require.ErrorContains(t, err, "invalid session envelope")
+	})
+}
@@ -25,6 +25,22 @@ type Config struct {
 	// Required.
 	Store *Store
 
+	// Codec encodes the session data. Sessions encoded by the built-in
+	// codecs can always be decoded, so the codec can be changed without
+	// invalidating the sessions.
+	//
+	// Optional. Default: GobCodec{}
+	Codec Codec
+
+	// CookieKeys enables the stateless cookie store: the session data is
+	// encrypted with AES-GCM and stored in the session cookies instead of the
+	// Storage. The keys are base64-encoded and 16, 24 or 32 bytes long when
+	// decoded. The first key encrypts, all keys decrypt, so keys are rotated by
+	// prepending a new key and removing the old one once its sessions expired.
+	//
+	// Optional. Default: nil
+	CookieKeys []string
+
 	// ErrorHandler defines a function to handle errors.
 	//
 	// Optional. Default: nil
@@ -57,6 +73,16 @@ type Config struct {
 	// Optional. Default: "Lax"
 	CookieSameSite string
 
+	// PrincipalKey is the session key holding the principal, e.g. "user_id".
+	// If set, the store maintains an index of the sessions of each principal
+	// in the Storage, which is required by Sessions, Revoke and RevokeAll.
+	// The index is only consistent within a single instance: its updates are
+	// serialized by a lock of the Store, so instances sharing the Storage may
+	// lose entries when they update the index of a principal concurrently.
+	//
+	// Optional. Default: ""
+	PrincipalKey string
+
 	// Source defines where to obtain the session ID.
 	source Source
 
@@ -70,6 +96,19 @@ type Config struct {
 	// Optional. Default: 30 * time.Minute
 	IdleTimeout time.Duration
 
+	// MaxSessionsPerPrincipal limits the number of concurrent sessions of a
+	// principal. When a principal exceeds it, its oldest sessions are deleted.
+	// It requires PrincipalKey.
+	//
+	// Optional. Default: 0 (unlimited)
+	MaxSessionsPerPrincipal int
+
+	// MaxCookieChunks is the maximum number of cookies the cookie store splits
+	// the session data into. Saving larger sessions fails with ErrCookieTooLarge.
+	//
+	// Optional. Default: 4
+	MaxCookieChunks int
+
 	// AbsoluteTimeout defines the maximum duration of the session before it expires.
 	//
 	// If set to 0, the session will not have an absolute timeout, and will expire after the idle timeout.
@@ -107,11 +146,13 @@ const (
 
 // ConfigDefault provides the default configuration.
 var ConfigDefault = Config{
-	IdleTimeout:  30 * time.Minute,
-	KeyLookup:    "cookie:session_id",
-	KeyGenerator: utils.UUIDv4,
-	source:       SourceCookie,
-	sessionName:  "session_id",
+	IdleTimeout:     30 * time.Minute,
+	KeyLookup:       "cookie:session_id",
+	KeyGenerator:    utils.UUIDv4,
+	Codec:           GobCodec{},
+	MaxCookieChunks: 4,
+	source:          SourceCookie,
+	sessionName:     "session_id",
 }
 
 // DefaultErrorHandler logs the error and sends a 500 status code.
@@ -165,6 +206,12 @@ func configDefault(config ...Config) Config {
 	if cfg.KeyGenerator == nil {
 		cfg.KeyGenerator = ConfigDefault.KeyGenerator
 	}
+	if cfg.Codec == nil {
+		cfg.Codec = ConfigDefault.Codec
+	}
+	if len(cfg.Codec.Name()) > 255 {
+		panic("[session] the name of the Codec must not be longer than 255 bytes")
+	}
 
 	// Parse KeyLookup into source and session name.
 	selectors := strings.Split(cfg.KeyLookup, ":")
@@ -184,5 +231,17 @@ func configDefault(config ...Config) Config {
 	}
 	cfg.sessionName = selectors[1]
 
+	if cfg.MaxCookieChunks <= 0 {
+		cfg.MaxCookieChunks = ConfigDefault.MaxCookieChunks
+	}
+	if len(cfg.CookieKeys) > 0 {
+		if cfg.source != SourceCookie {
+			panic("[session] CookieKeys require a KeyLookup with the cookie source")
+		}
+		if cfg.PrincipalKey != "" {
+			panic("[session] PrincipalKey requires a Storage and is not supported with CookieKeys")
+		}
+	}
+
 	return cfg
 }
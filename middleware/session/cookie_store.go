@@ -0,0 +1,222 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/utils"
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	// ErrCookieTooLarge is returned when the session data does not fit into MaxCookieChunks cookies.
+	ErrCookieTooLarge = errors.New("session data exceeds the size of the session cookies")
+	// ErrNotSupportedByCookieStore is returned by the store methods which require a Storage.
+	ErrNotSupportedByCookieStore = errors.New("not supported by the cookie session store")
+)
+
+const (
+	// cookieChunkSize is the maximum size of the value of one session cookie.
+	// Browsers limit cookies to 4096 bytes, which leaves room for the name and attributes.
+	cookieChunkSize = 3800
+	// cookieVersion is the version of the encrypted cookie payload
+	cookieVersion byte = 1
+	// cookieHeaderSize is the size of the version and the expiration
+	cookieHeaderSize = 1 + 8
+)
+
+// newCookieAEADs creates the ciphers of the cookie store. The keys are
+// base64-encoded AES keys of 16, 24 or 32 bytes.
+func newCookieAEADs(keys []string) []cipher.AEAD {
+	aeads := make([]cipher.AEAD, 0, len(keys))
+	for _, key := range keys {
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			panic("[session] CookieKeys must be base64-encoded")
+		}
+		block, err := aes.NewCipher(decoded)
+		if err != nil {
+			panic("[session] CookieKeys must be 16, 24 or 32 bytes long when decoded")
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(fmt.Sprintf("[session] failed to create cipher: %v", err))
+		}
+		aeads = append(aeads, aead)
+	}
+	return aeads
+}
+
+// isCookieStore reports whether the session data is stored in the cookies
+func (s *Store) isCookieStore() bool {
+	return len(s.aeads) > 0
+}
+
+// chunkName returns the name of the nth session cookie
+func (s *Store) chunkName(n int) string {
+	if n == 0 {
+		return s.sessionName
+	}
+	return s.sessionName + "." + strconv.Itoa(n)
+}
+
+// loadCookie decrypts the session cookies of the request. Invalid, tampered
+// and expired cookies are ignored, so a new session is created.
+//
+// Returns:
+//   - string: The session ID, empty if the cookies are missing or invalid.
+//   - []byte: The encoded session data.
+func (s *Store) loadCookie(c velocity.Ctx) (string, []byte) {
+	var value []byte
+	for n := 0; n < s.MaxCookieChunks; n++ {
+		chunk := c.Request().Header.Cookie(s.chunkName(n))
+		if len(chunk) == 0 {
+			break
+		}
+		value = append(value, chunk...)
+	}
+	if len(value) == 0 {
+		return "", nil
+	}
+
+	sealed := make([]byte, base64.RawURLEncoding.DecodedLen(len(value)))
+	n, err := base64.RawURLEncoding.Decode(sealed, value)
+	if err != nil {
+		return "", nil
+	}
+	sealed = sealed[:n]
+
+	// The first key which authenticates the cookie decrypts it, so keys can be rotated
+	ad := utils.UnsafeBytes(s.sessionName)
+	for _, aead := range s.aeads {
+		nonceSize := aead.NonceSize()
+		if len(sealed) < nonceSize {
+			continue
+		}
+		plain, err := aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], ad)
+		if err != nil {
+			continue
+		}
+		return parseCookiePayload(plain)
+	}
+	return "", nil
+}
+
+// parseCookiePayload splits the decrypted payload into the ID and the encoded data
+func parseCookiePayload(plain []byte) (string, []byte) {
+	if len(plain) < cookieHeaderSize || plain[0] != cookieVersion {
+		return "", nil
+	}
+	expires := int64(binary.BigEndian.Uint64(plain[1:cookieHeaderSize])) //nolint:gosec // Written by saveCookie
+	if time.Now().Unix() >= expires {
+		return "", nil
+	}
+	idLen, n := binary.Uvarint(plain[cookieHeaderSize:])
+	rest := plain[cookieHeaderSize+max(n, 0):]
+	if n <= 0 || idLen == 0 || idLen > uint64(len(rest)) {
+		return "", nil
+	}
+	return string(rest[:idLen]), rest[idLen:]
+}
+
+// saveCookie encrypts the session data with the first key and sets it as
+// session cookies, split into chunks if needed.
+func (s *Session) saveCookie(encoded []byte) error {
+	if s.ctx == nil {
+		return nil
+	}
+
+	plain := make([]byte, cookieHeaderSize, cookieHeaderSize+binary.MaxVarintLen64+len(s.id)+len(encoded))
+	plain[0] = cookieVersion
+	binary.BigEndian.PutUint64(plain[1:], uint64(time.Now().Add(s.idleTimeout).Unix())) //nolint:gosec // Not negative
+	plain = binary.AppendUvarint(plain, uint64(len(s.id)))
+	plain = append(plain, s.id...)
+	plain = append(plain, encoded...)
+
+	aead := s.config.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to read nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, plain, utils.UnsafeBytes(s.config.sessionName))
+	value := base64.RawURLEncoding.EncodeToString(sealed)
+
+	chunks := (len(value) + cookieChunkSize - 1) / cookieChunkSize
+	if chunks > s.config.MaxCookieChunks {
+		return fmt.Errorf("%w: %d bytes in %d cookies, at most %d allowed", ErrCookieTooLarge, len(value), chunks, s.config.MaxCookieChunks)
+	}
+
+	for n := 0; n < chunks; n++ {
+		s.setCookie(s.config.chunkName(n), value[n*cookieChunkSize:min((n+1)*cookieChunkSize, len(value))])
+	}
+	// Expire the chunks of a larger previous session
+	for n := chunks; n < s.config.MaxCookieChunks; n++ {
+		name := s.config.chunkName(n)
+		if len(s.ctx.Request().Header.Cookie(name)) > 0 || len(s.ctx.Response().Header.PeekCookie(name)) > 0 {
+			s.expireCookie(name)
+		}
+	}
+	return nil
+}
+
+// delCookie expires all session cookies of the request
+func (s *Session) delCookie() {
+	for n := 1; n < s.config.MaxCookieChunks; n++ {
+		if name := s.config.chunkName(n); len(s.ctx.Request().Header.Cookie(name)) > 0 {
+			s.ctx.Request().Header.DelCookie(name)
+			s.ctx.Response().Header.DelCookie(name)
+			s.expireCookie(name)
+		}
+	}
+}
+
+// setCookie sets a session cookie with the configured attributes
+func (s *Session) setCookie(name, value string) {
+	fcookie := fasthttp.AcquireCookie()
+	fcookie.SetKey(name)
+	fcookie.SetValue(value)
+	// Cookies are also session cookies if they do not specify the Expires or Max-Age attribute.
+	// refer: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Set-Cookie
+	if !s.config.CookieSessionOnly {
+		fcookie.SetMaxAge(int(s.idleTimeout.Seconds()))
+		fcookie.SetExpire(time.Now().Add(s.idleTimeout))
+	}
+	s.writeCookie(fcookie)
+}
+
+// expireCookie deletes a session cookie from the client
+func (s *Session) expireCookie(name string) {
+	fcookie := fasthttp.AcquireCookie()
+	fcookie.SetKey(name)
+	fcookie.SetMaxAge(-1)
+	fcookie.SetExpire(time.Now().Add(-1 * time.Minute))
+	s.writeCookie(fcookie)
+}
+
+// writeCookie applies the configured attributes and sets the cookie on the response
+func (s *Session) writeCookie(fcookie *fasthttp.Cookie) {
+	fcookie.SetPath(s.config.CookiePath)
+	fcookie.SetDomain(s.config.CookieDomain)
+	fcookie.SetSecure(s.config.CookieSecure)
+	fcookie.SetHTTPOnly(s.config.CookieHTTPOnly)
+
+	switch utils.ToLower(s.config.CookieSameSite) {
+	case "strict":
+		fcookie.SetSameSite(fasthttp.CookieSameSiteStrictMode)
+	case "none":
+		fcookie.SetSameSite(fasthttp.CookieSameSiteNoneMode)
+	default:
+		fcookie.SetSameSite(fasthttp.CookieSameSiteLaxMode)
+	}
+
+	s.ctx.Response().Header.SetCookie(fcookie)
+	fasthttp.ReleaseCookie(fcookie)
+}
@@ -0,0 +1,308 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// cookieKey returns a random base64-encoded AES-256 key
+func cookieKey(t *testing.T) string {
+	t.Helper()
+
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// saveCookieSession loads the session from the cookies, applies fn,
+// saves the session and returns the cookies of the response by name
+func saveCookieSession(t *testing.T, app *velocity.App, store *Store, cookies map[string]string, fn func(sess *Session)) map[string]*fasthttp.Cookie {
+	t.Helper()
+
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+	for name, value := range cookies {
+		ctx.Request().Header.SetCookie(name, value)
+	}
+
+	sess, err := store.Get(ctx)
+	require.NoError(t, err)
+	defer sess.Release()
+	fn(sess)
+	require.NoError(t, sess.Save())
+
+	result := make(map[string]*fasthttp.Cookie)
+	ctx.Response().Header.VisitAllCookie(func(key, value []byte) {
+		cookie := &fasthttp.Cookie{}
+		require.NoError(t, cookie.ParseBytes(value))
+		result[string(key)] = cookie
+	})
+	return result
+}
+
+// expired reports whether the cookie deletes the cookie of the client.
+// The session cookies of the tests are not session-only, so they have a Max-Age.
+func expired(cookie *fasthttp.Cookie) bool {
+	return cookie.MaxAge() <= 0
+}
+
+// values returns the values of the cookies which were not expired
+func values(cookies map[string]*fasthttp.Cookie) map[string]string {
+	result := make(map[string]string)
+	for name, cookie := range cookies {
+		if !expired(cookie) {
+			result[name] = string(cookie.Value())
+		}
+	}
+	return result
+}
+
+// go test -run Test_CookieStore
+func Test_CookieStore(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	store := NewStore(Config{CookieKeys: []string{cookieKey(t)}})
+
+	var id string
+	cookies := saveCookieSession(t, app, store, nil, func(sess *Session) {
+		require.True(t, sess.Fresh())
+		sess.Set("name", "john")
+		id = sess.ID()
+	})
+	require.Len(t, cookies, 1)
+	cookie := cookies["session_id"]
+	require.NotNil(t, cookie)
+	require.NotContains(t, string(cookie.Value()), "john")
+	require.Equal(t, int(ConfigDefault.IdleTimeout.Seconds()), cookie.MaxAge())
+
+	// Nothing is stored in the storage
+	raw, err := store.Storage.Get(id)
+	require.NoError(t, err)
+	require.Nil(t, raw)
+
+	saveCookieSession(t, app, store, values(cookies), func(sess *Session) {
+		require.False(t, sess.Fresh())
+		require.Equal(t, id, sess.ID())
+		require.Equal(t, "john", sess.Get("name"))
+	})
+
+	// Tampered cookies start a new session
+	tampered := []byte(values(cookies)["session_id"])
+	tampered[len(tampered)/2] ^= 1
+	saveCookieSession(t, app, store, map[string]string{"session_id": string(tampered)}, func(sess *Session) {
+		require.True(t, sess.Fresh())
+		require.Nil(t, sess.Get("name"))
+	})
+
+	// Cookies of another session name are not accepted
+	other := NewStore(Config{CookieKeys: store.CookieKeys, KeyLookup: "cookie:other"})
+	saveCookieSession(t, app, other, map[string]string{"other": values(cookies)["session_id"]}, func(sess *Session) {
+		require.True(t, sess.Fresh())
+	})
+
+	// Store methods which require the storage are not supported
+	require.ErrorIs(t, store.Delete(id), ErrNotSupportedByCookieStore)
+	require.ErrorIs(t, store.Reset(), ErrNotSupportedByCookieStore)
+	_, err = store.GetByID(id)
+	require.ErrorIs(t, err, ErrNotSupportedByCookieStore)
+}
+
+// go test -run Test_CookieStore_KeyRotation
+func Test_CookieStore_KeyRotation(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	oldKey, newKey := cookieKey(t), cookieKey(t)
+
+	cookies := saveCookieSession(t, app, NewStore(Config{CookieKeys: []string{oldKey}}), nil, func(sess *Session) {
+		sess.Set("name", "john")
+	})
+
+	// The new key encrypts, the old key still decrypts
+	rotated := NewStore(Config{CookieKeys: []string{newKey, oldKey}})
+	cookies = saveCookieSession(t, app, rotated, values(cookies), func(sess *Session) {
+		require.False(t, sess.Fresh())
+		require.Equal(t, "john", sess.Get("name"))
+	})
+
+	// The old key is removed
+	saveCookieSession(t, app, NewStore(Config{CookieKeys: []string{newKey}}), values(cookies), func(sess *Session) {
+		require.False(t, sess.Fresh())
+		require.Equal(t, "john", sess.Get("name"))
+	})
+	saveCookieSession(t, app, NewStore(Config{CookieKeys: []string{oldKey}}), values(cookies), func(sess *Session) {
+		require.True(t, sess.Fresh())
+	})
+}
+
+// go test -run Test_CookieStore_Chunks
+func Test_CookieStore_Chunks(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	store := NewStore(Config{CookieKeys: []string{cookieKey(t)}})
+
+	// Random data cannot be compressed
+	large := make([]byte, 4000)
+	_, err := rand.Read(large)
+	require.NoError(t, err)
+	value := hex.EncodeToString(large)
+
+	cookies := saveCookieSession(t, app, store, nil, func(sess *Session) {
+		sess.Set("large", value)
+	})
+	require.Len(t, cookies, 3)
+	for _, name := range []string{"session_id", "session_id.1", "session_id.2"} {
+		require.Contains(t, cookies, name)
+		require.LessOrEqual(t, len(cookies[name].Value()), cookieChunkSize)
+	}
+
+	// Shrinking the session expires the chunks which are not needed anymore
+	cookies = saveCookieSession(t, app, store, values(cookies), func(sess *Session) {
+		require.False(t, sess.Fresh())
+		require.Equal(t, value, sess.Get("large"))
+		sess.Delete("large")
+	})
+	require.Len(t, cookies, 3)
+	require.False(t, expired(cookies["session_id"]))
+	require.True(t, expired(cookies["session_id.1"]))
+	require.True(t, expired(cookies["session_id.2"]))
+
+	// Destroying the session expires all chunks
+	large = append(large, large[:1500]...)
+	value = hex.EncodeToString(large)
+	cookies = saveCookieSession(t, app, store, nil, func(sess *Session) {
+		sess.Set("large", value)
+	})
+	require.Len(t, cookies, 4)
+
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+	for name, value := range values(cookies) {
+		ctx.Request().Header.SetCookie(name, value)
+	}
+	sess, err := store.Get(ctx)
+	require.NoError(t, err)
+	defer sess.Release()
+	require.Equal(t, value, sess.Get("large"))
+	require.NoError(t, sess.Destroy())
+	for _, name := range []string{"session_id", "session_id.1", "session_id.2", "session_id.3"} {
+		require.Contains(t, string(ctx.Response().Header.PeekCookie(name)), "max-age=0", name)
+	}
+}
+
+// go test -run Test_CookieStore_TooLarge
+func Test_CookieStore_TooLarge(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	store := NewStore(Config{CookieKeys: []string{cookieKey(t)}, MaxCookieChunks: 1})
+
+	large := make([]byte, cookieChunkSize)
+	_, err := rand.Read(large)
+	require.NoError(t, err)
+
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+	sess, err := store.Get(ctx)
+	require.NoError(t, err)
+	defer sess.Release()
+	sess.Set("large", large)
+	require.ErrorIs(t, sess.Save(), ErrCookieTooLarge)
+	require.Empty(t, ctx.Response().Header.PeekCookie("session_id"))
+}
+
+// go test -run Test_CookieStore_Middleware
+func Test_CookieStore_Middleware(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use(New(Config{CookieKeys: []string{cookieKey(t)}}))
+	app.Get("/", func(c velocity.Ctx) error {
+		sess := FromContext(c)
+		visits, _ := sess.Get("visits").(int) //nolint:errcheck // Zero on the first visit
+		sess.Set("visits", visits+1)
+		return c.SendString(strconv.Itoa(visits + 1))
+	})
+
+	h := app.Handler()
+	var cookie []byte
+	for visits := 1; visits <= 3; visits++ {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(velocity.MethodGet)
+		if cookie != nil {
+			ctx.Request.Header.SetCookieBytesKV([]byte("session_id"), cookie)
+		}
+		h(ctx)
+		require.Equal(t, velocity.StatusOK, ctx.Response.StatusCode())
+
+		require.Equal(t, strconv.Itoa(visits), string(ctx.Response.Body()))
+
+		cookie = append(cookie[:0], ctx.Response.Header.PeekCookie("session_id")...)
+		require.NotEmpty(t, cookie)
+		fcookie := &fasthttp.Cookie{}
+		require.NoError(t, fcookie.ParseBytes(cookie))
+		cookie = append(cookie[:0], fcookie.Value()...)
+	}
+}
+
+// go test -run Test_parseCookiePayload
+func Test_parseCookiePayload(t *testing.T) {
+	t.Parallel()
+
+	payload := func(version byte, expires time.Time, id, data string) []byte {
+		plain := make([]byte, cookieHeaderSize)
+		plain[0] = version
+		binary.BigEndian.PutUint64(plain[1:], uint64(expires.Unix())) //nolint:gosec // Not negative
+		plain = binary.AppendUvarint(plain, uint64(len(id)))
+		plain = append(plain, id...)
+		return append(plain, data...)
+	}
+	future := time.Now().Add(time.Hour)
+
+	id, raw := parseCookiePayload(payload(cookieVersion, future, "id", "data"))
+	require.Equal(t, "id", id)
+	require.Equal(t, []byte("data"), raw)
+
+	for name, plain := range map[string][]byte{
+		"expired": payload(cookieVersion, time.Now().Add(-time.Second), "id", "data"),
+		"version": payload(cookieVersion+1, future, "id", "data"),
+		"empty":   payload(cookieVersion, future, "", "data"),
+		"short":   payload(cookieVersion, future, "id", "")[:cookieHeaderSize+2],
+		"header":  {cookieVersion},
+	} {
+		id, raw := parseCookiePayload(plain)
+		require.Empty(t, id, name)
+		require.Nil(t, raw, name)
+	}
+}
+
+// go test -run Test_CookieStore_Config
+func Test_CookieStore_Config(t *testing.T) {
+	t.Parallel()
+
+	require.PanicsWithValue(t, "[session] CookieKeys must be base64-encoded", func() {
+		NewStore(Config{CookieKeys: []string{"not base64!"}})
+	})
+	require.PanicsWithValue(t, "[session] CookieKeys must be 16, 24 or 32 bytes long when decoded", func() {
+		NewStore(Config{CookieKeys: []string{base64.StdEncoding.EncodeToString([]byte("short"))}})
+	})
+	require.PanicsWithValue(t, "[session] CookieKeys require a KeyLookup with the cookie source", func() {
+		NewStore(Config{CookieKeys: []string{cookieKey(t)}, KeyLookup: "header:session_id"})
+	})
+	require.PanicsWithValue(t, "[session] PrincipalKey requires a Storage and is not supported with CookieKeys", func() {
+		NewStore(Config{CookieKeys: []string{cookieKey(t)}, PrincipalKey: "user_id"})
+	})
+	require.Equal(t, 4, NewStore(Config{CookieKeys: []string{cookieKey(t)}}).MaxCookieChunks)
+}
@@ -0,0 +1,281 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrIndexDisabled is returned by the index methods of a store without PrincipalKey.
+var ErrIndexDisabled = errors.New("session index is disabled, set Config.PrincipalKey")
+
+// indexKeyPrefix prefixes the storage keys of the session index. The index of
+// a principal is a single key, as the Storage cannot list the keys of a prefix,
+// and it is updated by read-modify-write under indexMu. Storage has no
+// transactions, so the index is only consistent within a single instance.
+const indexKeyPrefix = "session_index:"
+
+// lastSeenResolution limits how often the last seen time of a session is
+// written to the index, so not every request writes the index.
+const lastSeenResolution = time.Minute
+
+// SessionInfo describes a session of a principal in the session index.
+//
+//nolint:revive // SessionInfo is clearer than Info in the docs of other packages
+type SessionInfo struct {
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ID        string    `json:"id"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// Sessions returns the sessions of a principal, oldest first. Expired and
+// deleted sessions are removed from the index.
+//
+// Parameters:
+//   - principal: The principal, i.e. the value of PrincipalKey in its sessions.
+//
+// Returns:
+//   - []SessionInfo: The sessions of the principal.
+//   - error: An error if the index is disabled or cannot be read.
+//
+// Usage:
+//
+//	sessions, err := store.Sessions("john")
+func (s *Store) Sessions(principal string) ([]SessionInfo, error) {
+	if s.PrincipalKey == "" {
+		return nil, ErrIndexDisabled
+	}
+
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	entries, err := s.loadIndex(principal)
+	if err != nil {
+		return nil, err
+	}
+	alive, err := s.pruneIndex(entries)
+	if err != nil {
+		return nil, err
+	}
+	if len(alive) != len(entries) {
+		if err := s.saveIndex(principal, alive); err != nil {
+			return nil, err
+		}
+	}
+	return alive, nil
+}
+
+// Revoke deletes a session of a principal from the storage and the index.
+//
+// Parameters:
+//   - principal: The principal of the session.
+//   - id: The ID of the session.
+//
+// Returns:
+//   - error: An error if the index is disabled or the deletion fails.
+//
+// Usage:
+//
+//	err := store.Revoke("john", id)
+func (s *Store) Revoke(principal, id string) error {
+	if s.PrincipalKey == "" {
+		return ErrIndexDisabled
+	}
+	if id == "" {
+		return ErrEmptySessionID
+	}
+
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	entries, err := s.loadIndex(principal)
+	if err != nil {
+		return err
+	}
+	if err := s.Storage.Delete(id); err != nil {
+		return err
+	}
+	return s.saveIndex(principal, slices.DeleteFunc(entries, func(e SessionInfo) bool {
+		return e.ID == id
+	}))
+}
+
+// RevokeAll deletes all sessions of a principal, e.g. to log out everywhere
+// after a password change. The sessions listed in except are kept, e.g. the
+// current session.
+//
+// Parameters:
+//   - principal: The principal of the sessions.
+//   - except: The IDs of the sessions to keep.
+//
+// Returns:
+//   - error: An error if the index is disabled or the deletion fails.
+//
+// Usage:
+//
+//	err := store.RevokeAll("john", sess.ID())
+func (s *Store) RevokeAll(principal string, except ...string) error {
+	if s.PrincipalKey == "" {
+		return ErrIndexDisabled
+	}
+
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	entries, err := s.loadIndex(principal)
+	if err != nil {
+		return err
+	}
+
+	kept := entries[:0]
+	for _, entry := range entries {
+		if slices.Contains(except, entry.ID) {
+			kept = append(kept, entry)
+			continue
+		}
+		if err := s.Storage.Delete(entry.ID); err != nil {
+			return err
+		}
+	}
+	return s.saveIndex(principal, kept)
+}
+
+// indexSession adds the session to the index of its principal or updates it.
+// If the principal exceeds MaxSessionsPerPrincipal, its oldest sessions are deleted.
+func (s *Store) indexSession(principal string, info SessionInfo) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	entries, err := s.loadIndex(principal)
+	if err != nil {
+		return err
+	}
+
+	if i := slices.IndexFunc(entries, func(e SessionInfo) bool { return e.ID == info.ID }); i >= 0 {
+		entry := &entries[i]
+		if info.LastSeen.Sub(entry.LastSeen) < lastSeenResolution && !info.ExpiresAt.After(entry.ExpiresAt.Add(lastSeenResolution)) {
+			return nil
+		}
+		entry.LastSeen = info.LastSeen
+		entry.ExpiresAt = info.ExpiresAt
+		if info.IP != "" {
+			entry.IP = info.IP
+			entry.UserAgent = info.UserAgent
+		}
+		return s.saveIndex(principal, entries)
+	}
+
+	// Only count the sessions which still exist
+	if entries, err = s.pruneIndex(entries); err != nil {
+		return err
+	}
+	info.CreatedAt = info.LastSeen
+	entries = append(entries, info)
+
+	// Evict the oldest sessions
+	if s.MaxSessionsPerPrincipal > 0 {
+		for len(entries) > s.MaxSessionsPerPrincipal {
+			if err := s.Storage.Delete(entries[0].ID); err != nil {
+				return err
+			}
+			entries = entries[1:]
+		}
+	}
+
+	return s.saveIndex(principal, entries)
+}
+
+// unindexSession removes the session from the index of its principal
+func (s *Store) unindexSession(principal, id string) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	entries, err := s.loadIndex(principal)
+	if err != nil {
+		return err
+	}
+	n := len(entries)
+	entries = slices.DeleteFunc(entries, func(e SessionInfo) bool {
+		return e.ID == id
+	})
+	if len(entries) == n {
+		return nil
+	}
+	return s.saveIndex(principal, entries)
+}
+
+// pruneIndex returns the entries whose sessions did not expire and still exist
+func (s *Store) pruneIndex(entries []SessionInfo) ([]SessionInfo, error) {
+	now := time.Now()
+	alive := make([]SessionInfo, 0, len(entries))
+	for _, entry := range entries {
+		if now.After(entry.ExpiresAt) {
+			continue
+		}
+		raw, err := s.Storage.Get(entry.ID)
+		if err != nil {
+			return nil, err
+		}
+		if raw != nil {
+			alive = append(alive, entry)
+		}
+	}
+	return alive, nil
+}
+
+func (s *Store) loadIndex(principal string) ([]SessionInfo, error) {
+	raw, err := s.Storage.Get(indexKeyPrefix + principal)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	var entries []SessionInfo
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode session index: %w", err)
+	}
+	return entries, nil
+}
+
+// saveIndex stores the entries, sorted by their creation. The index
+// expires together with the session that expires last.
+func (s *Store) saveIndex(principal string, entries []SessionInfo) error {
+	key := indexKeyPrefix + principal
+	if len(entries) == 0 {
+		return s.Storage.Delete(key)
+	}
+
+	slices.SortStableFunc(entries, func(a, b SessionInfo) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	var expires time.Time
+	for _, entry := range entries {
+		if entry.ExpiresAt.After(expires) {
+			expires = entry.ExpiresAt
+		}
+	}
+
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode session index: %w", err)
+	}
+	// Sessions are extended without updating the index within the resolution
+	return s.Storage.Set(key, raw, time.Until(expires)+lastSeenResolution)
+}
+
+// principal returns the principal of the session, or an empty string
+// if the index is disabled or the session is not authenticated
+func (s *Session) principal() string {
+	if s.config.PrincipalKey == "" {
+		return ""
+	}
+	value := s.Get(s.config.PrincipalKey)
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
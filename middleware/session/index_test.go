@@ -0,0 +1,234 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+// login creates a saved session of the principal and returns its ID
+func login(t *testing.T, app *velocity.App, store *Store, principal, userAgent string) string {
+	t.Helper()
+
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+	ctx.Request().Header.SetUserAgent(userAgent)
+
+	sess, err := store.Get(ctx)
+	require.NoError(t, err)
+	defer sess.Release()
+
+	sess.Set("user_id", principal)
+	require.NoError(t, sess.Save())
+	return sess.ID()
+}
+
+// exists reports whether the session is in the storage
+func exists(t *testing.T, store *Store, id string) bool {
+	t.Helper()
+
+	raw, err := store.Storage.Get(id)
+	require.NoError(t, err)
+	return raw != nil
+}
+
+// go test -run Test_Store_Sessions
+func Test_Store_Sessions(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	store := NewStore(Config{PrincipalKey: "user_id"})
+
+	first := login(t, app, store, "john", "laptop")
+	second := login(t, app, store, "john", "phone")
+	other := login(t, app, store, "jane", "tablet")
+
+	sessions, err := store.Sessions("john")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, first, sessions[0].ID)
+	require.Equal(t, "laptop", sessions[0].UserAgent)
+	require.Equal(t, "0.0.0.0", sessions[0].IP)
+	require.False(t, sessions[0].CreatedAt.IsZero())
+	require.Equal(t, sessions[0].CreatedAt, sessions[0].LastSeen)
+	require.WithinDuration(t, time.Now().Add(ConfigDefault.IdleTimeout), sessions[0].ExpiresAt, time.Minute)
+	require.Equal(t, second, sessions[1].ID)
+	require.Equal(t, "phone", sessions[1].UserAgent)
+
+	// Saving again within the resolution doesn't rewrite the index
+	sess, err := store.GetByID(first)
+	require.NoError(t, err)
+	require.NoError(t, sess.Save())
+	sess.Release()
+	sessions, err = store.Sessions("john")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	// Deleted sessions are pruned
+	require.NoError(t, store.Delete(second))
+	sessions, err = store.Sessions("john")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+
+	sessions, err = store.Sessions("jane")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, other, sessions[0].ID)
+
+	sessions, err = store.Sessions("nobody")
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+}
+
+// go test -run Test_Store_Revoke
+func Test_Store_Revoke(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	store := NewStore(Config{PrincipalKey: "user_id"})
+
+	first := login(t, app, store, "john", "laptop")
+	second := login(t, app, store, "john", "phone")
+	current := login(t, app, store, "john", "tablet")
+	other := login(t, app, store, "jane", "tablet")
+
+	require.NoError(t, store.Revoke("john", first))
+	require.False(t, exists(t, store, first))
+
+	// Log out everywhere but the current session
+	require.NoError(t, store.RevokeAll("john", current))
+	require.False(t, exists(t, store, second))
+	require.True(t, exists(t, store, current))
+	require.True(t, exists(t, store, other))
+
+	sessions, err := store.Sessions("john")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, current, sessions[0].ID)
+
+	require.NoError(t, store.RevokeAll("john"))
+	require.False(t, exists(t, store, current))
+	sessions, err = store.Sessions("john")
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+
+	require.ErrorIs(t, store.Revoke("john", ""), ErrEmptySessionID)
+}
+
+// go test -run Test_Store_MaxSessionsPerPrincipal
+func Test_Store_MaxSessionsPerPrincipal(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	store := NewStore(Config{PrincipalKey: "user_id", MaxSessionsPerPrincipal: 2})
+
+	first := login(t, app, store, "john", "laptop")
+	time.Sleep(time.Millisecond)
+	second := login(t, app, store, "john", "phone")
+	time.Sleep(time.Millisecond)
+	third := login(t, app, store, "john", "tablet")
+	login(t, app, store, "jane", "tablet")
+
+	// The oldest session was evicted
+	require.False(t, exists(t, store, first))
+	require.True(t, exists(t, store, second))
+	require.True(t, exists(t, store, third))
+
+	sessions, err := store.Sessions("john")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, second, sessions[0].ID)
+	require.Equal(t, third, sessions[1].ID)
+
+	// Sessions which were deleted otherwise don't count
+	require.NoError(t, store.Delete(second))
+	fourth := login(t, app, store, "john", "desktop")
+	require.True(t, exists(t, store, third))
+	require.True(t, exists(t, store, fourth))
+}
+
+// go test -run Test_Session_Index
+func Test_Session_Index(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	store := NewStore(Config{PrincipalKey: "user_id"})
+
+	t.Run("destroy", func(t *testing.T) {
+		t.Parallel()
+
+		id := login(t, app, store, "destroy", "laptop")
+		sess, err := store.GetByID(id)
+		require.NoError(t, err)
+		require.NoError(t, sess.Destroy())
+		sess.Release()
+
+		raw, err := store.Storage.Get(indexKeyPrefix + "destroy")
+		require.NoError(t, err)
+		require.Nil(t, raw)
+	})
+
+	t.Run("regenerate", func(t *testing.T) {
+		t.Parallel()
+
+		id := login(t, app, store, "regenerate", "laptop")
+		sess, err := store.GetByID(id)
+		require.NoError(t, err)
+		require.NoError(t, sess.Regenerate())
+		require.NoError(t, sess.Save())
+		newID := sess.ID()
+		sess.Release()
+
+		sessions, err := store.Sessions("regenerate")
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		require.Equal(t, newID, sessions[0].ID)
+		require.NotEqual(t, id, newID)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		t.Parallel()
+
+		id := login(t, app, store, "reset", "laptop")
+		sess, err := store.GetByID(id)
+		require.NoError(t, err)
+		require.NoError(t, sess.Reset())
+		require.NoError(t, sess.Save())
+		sess.Release()
+
+		sessions, err := store.Sessions("reset")
+		require.NoError(t, err)
+		require.Empty(t, sessions)
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+		defer app.ReleaseCtx(ctx)
+		sess, err := store.Get(ctx)
+		require.NoError(t, err)
+		sess.Set("cart", 3)
+		require.NoError(t, sess.Save())
+		sess.Release()
+
+		sessions, err := store.Sessions("")
+		require.NoError(t, err)
+		require.Empty(t, sessions)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewStore()
+		login(t, app, store, "john", "laptop")
+
+		_, err := store.Sessions("john")
+		require.ErrorIs(t, err, ErrIndexDisabled)
+		require.ErrorIs(t, store.Revoke("john", "id"), ErrIndexDisabled)
+		require.ErrorIs(t, store.RevokeAll("john"), ErrIndexDisabled)
+	})
+}
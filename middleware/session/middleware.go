@@ -288,6 +288,22 @@ func (m *Middleware) Reset() error {
 	return m.Session.Reset()
 }
 
+// Regenerate generates a new session ID and deletes the old one from storage,
+// keeping the session data, e.g. after a login to prevent session fixation.
+//
+// Returns:
+//   - error: An error if the regeneration fails.
+//
+// Usage:
+//
+//	err := m.Regenerate()
+func (m *Middleware) Regenerate() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.Session.Regenerate()
+}
+
 // Store returns the session store.
 //
 // Returns:
@@ -451,3 +451,64 @@ func Test_Session_Middleware_Store(t *testing.T) {
 	h(ctx)
 	require.Equal(t, velocity.StatusOK, ctx.Response.StatusCode())
 }
+
+func Test_Session_Middleware_Regenerate(t *testing.T) {
+	t.Parallel()
+	app := velocity.New()
+
+	app.Use(New())
+
+	app.Post("/set", func(c velocity.Ctx) error {
+		FromContext(c).Set("key", "value")
+		return c.SendStatus(velocity.StatusOK)
+	})
+	app.Post("/regenerate", func(c velocity.Ctx) error {
+		if err := FromContext(c).Regenerate(); err != nil {
+			return c.SendStatus(velocity.StatusInternalServerError)
+		}
+		return c.SendStatus(velocity.StatusOK)
+	})
+	app.Get("/get", func(c velocity.Ctx) error {
+		value, ok := FromContext(c).Get("key").(string)
+		if !ok {
+			return c.SendStatus(velocity.StatusNotFound)
+		}
+		return c.SendString(value)
+	})
+
+	h := app.Handler()
+	request := func(method, uri, id string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(uri)
+		if id != "" {
+			ctx.Request.Header.SetCookie("session_id", id)
+		}
+		h(ctx)
+		return ctx
+	}
+	sessionID := func(ctx *fasthttp.RequestCtx) string {
+		cookie := fasthttp.AcquireCookie()
+		defer fasthttp.ReleaseCookie(cookie)
+		cookie.SetKey("session_id")
+		require.True(t, ctx.Response.Header.Cookie(cookie))
+		return string(cookie.Value())
+	}
+
+	ctx := request(velocity.MethodPost, "/set", "")
+	require.Equal(t, velocity.StatusOK, ctx.Response.StatusCode())
+	oldID := sessionID(ctx)
+
+	ctx = request(velocity.MethodPost, "/regenerate", oldID)
+	require.Equal(t, velocity.StatusOK, ctx.Response.StatusCode())
+	newID := sessionID(ctx)
+	require.NotEqual(t, oldID, newID)
+
+	// The data moved to the new session ID
+	ctx = request(velocity.MethodGet, "/get", newID)
+	require.Equal(t, velocity.StatusOK, ctx.Response.StatusCode())
+	require.Equal(t, "value", string(ctx.Response.Body()))
+
+	ctx = request(velocity.MethodGet, "/get", oldID)
+	require.Equal(t, velocity.StatusNotFound, ctx.Response.StatusCode())
+}
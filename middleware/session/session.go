@@ -1,8 +1,6 @@
 package session
 
 import (
-	"bytes"
-	"encoding/gob"
 	"fmt"
 	"sync"
 	"time"
@@ -30,13 +28,6 @@ const (
 	absExpirationKey absExpirationKeyType = iota
 )
 
-// Session pool for reusing byte buffers.
-var byteBufferPool = sync.Pool{
-	New: func() any {
-		return new(bytes.Buffer)
-	},
-}
-
 var sessionPool = sync.Pool{
 	New: func() any {
 		return &Session{}
@@ -187,6 +178,8 @@ func (s *Session) Destroy() error {
 		return nil
 	}
 
+	principal := s.principal()
+
 	// Reset local data
 	s.data.Reset()
 
@@ -194,12 +187,18 @@ func (s *Session) Destroy() error {
 	defer s.mu.RUnlock()
 
 	// Use external Storage if exist
-	if err := s.config.Storage.Delete(s.id); err != nil {
-		return err
+	if !s.config.isCookieStore() {
+		if err := s.config.Storage.Delete(s.id); err != nil {
+			return err
+		}
 	}
 
 	// Expire session
 	s.delSession()
+
+	if principal != "" {
+		return s.config.unindexSession(principal, s.id)
+	}
 	return nil
 }
 
@@ -212,12 +211,23 @@ func (s *Session) Destroy() error {
 //
 //	err := s.Regenerate()
 func (s *Session) Regenerate() error {
+	principal := s.principal()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Delete old id from storage
-	if err := s.config.Storage.Delete(s.id); err != nil {
-		return err
+	if !s.config.isCookieStore() {
+		if err := s.config.Storage.Delete(s.id); err != nil {
+			return err
+		}
+	}
+
+	// The new id is indexed when the session is saved
+	if principal != "" {
+		if err := s.config.unindexSession(principal, s.id); err != nil {
+			return err
+		}
 	}
 
 	// Generate a new session, and set session.fresh to true
@@ -235,6 +245,8 @@ func (s *Session) Regenerate() error {
 //
 //	err := s.Reset()
 func (s *Session) Reset() error {
+	principal := s.principal()
+
 	// Reset local data
 	if s.data != nil {
 		s.data.Reset()
@@ -247,8 +259,16 @@ func (s *Session) Reset() error {
 	s.idleTimeout = 0
 
 	// Delete old id from storage
-	if err := s.config.Storage.Delete(s.id); err != nil {
-		return err
+	if !s.config.isCookieStore() {
+		if err := s.config.Storage.Delete(s.id); err != nil {
+			return err
+		}
+	}
+
+	if principal != "" {
+		if err := s.config.unindexSession(principal, s.id); err != nil {
+			return err
+		}
 	}
 
 	// Expire session
@@ -307,9 +327,6 @@ func (s *Session) saveSession() error {
 		s.idleTimeout = s.config.IdleTimeout
 	}
 
-	// Update client cookie
-	s.setSession()
-
 	// Encode session data
 	s.data.RLock()
 	encodedBytes, err := s.encodeSessionData()
@@ -318,8 +335,36 @@ func (s *Session) saveSession() error {
 		return fmt.Errorf("failed to encode data: %w", err)
 	}
 
+	// The cookie store keeps the data in the client cookies
+	if s.config.isCookieStore() {
+		return s.saveCookie(encodedBytes)
+	}
+
+	// Update client cookie
+	s.setSession()
+
 	// Pass copied bytes with session id to provider
-	return s.config.Storage.Set(s.id, encodedBytes, s.idleTimeout)
+	if err := s.config.Storage.Set(s.id, encodedBytes, s.idleTimeout); err != nil {
+		return err
+	}
+
+	// Index the session of its principal
+	if principal := s.principal(); principal != "" {
+		now := time.Now()
+		info := SessionInfo{
+			ID:        s.id,
+			LastSeen:  now,
+			ExpiresAt: now.Add(s.idleTimeout),
+		}
+		if s.ctx != nil {
+			info.IP = utils.CopyString(s.ctx.IP())
+			info.UserAgent = utils.CopyString(s.ctx.Get(velocity.HeaderUserAgent))
+		}
+		if err := s.config.indexSession(principal, info); err != nil {
+			return fmt.Errorf("failed to index session: %w", err)
+		}
+	}
+	return nil
 }
 
 // Keys retrieves all keys in the current session.
@@ -398,6 +443,9 @@ func (s *Session) delSession() {
 	} else {
 		s.ctx.Request().Header.DelCookie(s.config.sessionName)
 		s.ctx.Response().Header.DelCookie(s.config.sessionName)
+		if s.config.isCookieStore() {
+			s.delCookie()
+		}
 
 		fcookie := fasthttp.AcquireCookie()
 		fcookie.SetKey(s.config.sessionName)
@@ -434,41 +482,28 @@ func (s *Session) delSession() {
 //
 //	err := s.decodeSessionData(rawData)
 func (s *Session) decodeSessionData(rawData []byte) error {
-	byteBuffer := byteBufferPool.Get().(*bytes.Buffer) //nolint:forcetypeassert,errcheck // We store nothing else in the pool
-	defer byteBufferPool.Put(byteBuffer)
-	defer byteBuffer.Reset()
-	_, _ = byteBuffer.Write(rawData)
-	decCache := gob.NewDecoder(byteBuffer)
-	if err := decCache.Decode(&s.data.Data); err != nil {
+	data, err := s.config.decode(rawData)
+	if err != nil {
 		return fmt.Errorf("failed to decode session data: %w", err)
 	}
+	s.data.Data = data
 	return nil
 }
 
-// encodeSessionData encodes session data to raw bytes
-//
-// Parameters:
-//   - rawData: The raw byte data to encode.
+// encodeSessionData encodes session data to raw bytes with the codec of the store
 //
 // Returns:
+//   - []byte: The encoded session data.
 //   - error: An error if the encoding fails.
 //
 // Usage:
 //
-//	err := s.encodeSessionData(rawData)
+//	encodedBytes, err := s.encodeSessionData()
 func (s *Session) encodeSessionData() ([]byte, error) {
-	byteBuffer := byteBufferPool.Get().(*bytes.Buffer) //nolint:forcetypeassert,errcheck // We store nothing else in the pool
-	defer byteBufferPool.Put(byteBuffer)
-	defer byteBuffer.Reset()
-	encCache := gob.NewEncoder(byteBuffer)
-	if err := encCache.Encode(&s.data.Data); err != nil {
+	encodedBytes, err := s.config.encode(s.data.Data)
+	if err != nil {
 		return nil, fmt.Errorf("failed to encode session data: %w", err)
 	}
-	// Copy the bytes
-	// Copy the data in buffer
-	encodedBytes := make([]byte, byteBuffer.Len())
-	copy(encodedBytes, byteBuffer.Bytes())
-
 	return encodedBytes, nil
 }
 
@@ -1,14 +1,17 @@
 package session
 
 import (
+	"crypto/cipher"
 	"encoding/gob"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/khulnasoft/velocity"
 	"github.com/khulnasoft/velocity/internal/storage/memory"
 	"github.com/khulnasoft/velocity/log"
+	"github.com/khulnasoft/velocity/middleware/servertiming"
 	"github.com/khulnasoft/velocity/utils"
 )
 
@@ -29,6 +32,8 @@ const (
 
 type Store struct {
 	Config
+	aeads   []cipher.AEAD // ciphers of the cookie store, the first one encrypts
+	indexMu sync.Mutex    // serializes the updates of the session index, within this process only
 }
 
 // New creates a new session store with the provided configuration.
@@ -53,7 +58,11 @@ func NewStore(config ...Config) *Store {
 	store := &Store{
 		Config: cfg,
 	}
+	if len(cfg.CookieKeys) > 0 {
+		store.aeads = newCookieAEADs(cfg.CookieKeys)
+	}
 
+	// Sessions encoded without an envelope hold the absolute expiration under its own key type
 	if cfg.AbsoluteTimeout > 0 {
 		store.RegisterType(absExpirationKey)
 		store.RegisterType(time.Time{})
@@ -63,6 +72,7 @@ func NewStore(config ...Config) *Store {
 }
 
 // RegisterType registers a custom type for encoding/decoding into any storage provider.
+// It is only required by the GobCodec, the other codecs decode the values into generic types.
 //
 // Parameters:
 //   - i: The custom type to register.
@@ -119,18 +129,24 @@ func (s *Store) Get(c velocity.Ctx) (*Session, error) {
 //	    // handle error
 //	}
 func (s *Store) getSession(c velocity.Ctx) (*Session, error) {
+	defer servertiming.Start(c, "session", "Session load")()
+
 	var rawData []byte
 	var err error
 
 	id, ok := c.Locals(sessionIDContextKey).(string)
 	if !ok {
-		id = s.getSessionID(c)
+		if s.isCookieStore() {
+			id, rawData = s.loadCookie(c)
+		} else {
+			id = s.getSessionID(c)
+		}
 	}
 
 	fresh := ok // Assume the session is fresh if the ID is found in locals
 
 	// Attempt to fetch session data if an ID is provided
-	if id != "" {
+	if id != "" && !s.isCookieStore() {
 		rawData, err = s.Storage.Get(id)
 		if err != nil {
 			return nil, err
@@ -230,6 +246,9 @@ func (s *Store) getSessionID(c velocity.Ctx) string {
 //	    // handle error
 //	}
 func (s *Store) Reset() error {
+	if s.isCookieStore() {
+		return ErrNotSupportedByCookieStore
+	}
 	return s.Storage.Reset()
 }
 
@@ -251,6 +270,9 @@ func (s *Store) Delete(id string) error {
 	if id == "" {
 		return ErrEmptySessionID
 	}
+	if s.isCookieStore() {
+		return ErrNotSupportedByCookieStore
+	}
 	return s.Storage.Delete(id)
 }
 
@@ -291,6 +313,9 @@ func (s *Store) GetByID(id string) (*Session, error) {
 	if id == "" {
 		return nil, ErrEmptySessionID
 	}
+	if s.isCookieStore() {
+		return nil, ErrNotSupportedByCookieStore
+	}
 
 	rawData, err := s.Storage.Get(id)
 	if err != nil {
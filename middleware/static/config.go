@@ -2,6 +2,7 @@ package static
 
 import (
 	"io/fs"
+	"regexp"
 	"time"
 
 	"github.com/khulnasoft/velocity"
@@ -30,6 +31,13 @@ type Config struct {
 	// Optional. Default: nil
 	NotFoundHandler velocity.Handler
 
+	// FingerprintPattern matches the base names of the fingerprinted files of
+	// ImmutableFingerprinted. Its first and last groups capture the name and
+	// the extension around the hash, e.g. `^(.+)\.[0-9a-f]{20}(\.[^/]+)$` for hashes of exactly 20 hex digits.
+	//
+	// Optional. Default: nil (see IsFingerprinted)
+	FingerprintPattern *regexp.Regexp `json:"-"`
+
 	// The names of the index files for serving a directory.
 	//
 	// Optional. Default: []string{"index.html"}.
@@ -53,7 +61,37 @@ type Config struct {
 	// Optional. Default: false
 	Compress bool `json:"compress"`
 
-	// When set to true, enables byte range requests.
+	// When set to true, precompressed siblings of the requested file
+	// (e.g. app.js.br, app.js.zst, app.js.gz) are served according to the
+	// Accept-Encoding request header. Works with any fs.FS, including embed.FS.
+	//
+	// Optional. Default: false
+	Precompressed bool `json:"precompressed"`
+
+	// When set to true, fingerprinted files, i.e. files whose name contains
+	// a content hash such as app.3f2a1b9c.js, are served with a long-lived
+	// immutable Cache-Control header instead of MaxAge. Configure the
+	// FingerprintPattern if other names may look like hashes.
+	//
+	// Optional. Default: false
+	ImmutableFingerprinted bool `json:"immutable_fingerprinted"`
+
+	// When set to true, requests for unknown paths without a file extension
+	// are answered with the index file, so that a single-page application can
+	// handle them with its client-side router. Missing assets (paths with an
+	// extension) still result in a 404.
+	//
+	// Optional. Default: false
+	SPA bool `json:"spa"`
+
+	// SPAExclude lists path prefixes which never fall back to the index file
+	// in SPA mode, e.g. []string{"/api"}.
+	//
+	// Optional. Default: nil
+	SPAExclude []string `json:"spa_exclude"`
+
+	// When set to true, enables byte range requests, including multiple
+	// ranges (multipart/byteranges) and If-Range preconditions.
 	//
 	// Optional. Default: false
 	ByteRange bool `json:"byte_range"`
@@ -0,0 +1,129 @@
+package static
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// fingerprintPattern matches file names which contain a content hash of
+// 8 to 64 hex digits in front of the extension, separated by a dot or a dash,
+// e.g. app.3f2a1b9c.js or logo-9f86d081884c7d65.svg.
+var fingerprintPattern = regexp.MustCompile(`^(.+)[.-]([0-9a-fA-F]{8,64})(\.[^/]+)$`)
+
+// IsFingerprinted reports whether the base name of the given path contains a
+// content hash. Hashes of digits or letters only are not considered content
+// hashes, as they are most likely dates or words, e.g. report-20240101.css.
+func IsFingerprinted(name string) bool {
+	return isFingerprinted(nil, name)
+}
+
+// isFingerprinted reports whether the base name of the given path is matched by the pattern
+func isFingerprinted(pattern *regexp.Regexp, name string) bool {
+	_, _, ok := splitFingerprint(pattern, path.Base(name))
+	return ok
+}
+
+// splitFingerprint returns the name and the extension around the content hash
+// of a fingerprinted base name, matched by the pattern or by default by fingerprintPattern
+func splitFingerprint(pattern *regexp.Regexp, base string) (string, string, bool) {
+	if pattern != nil {
+		match := pattern.FindStringSubmatch(base)
+		if len(match) < 3 {
+			return "", "", false
+		}
+		return match[1], match[len(match)-1], true
+	}
+
+	match := fingerprintPattern.FindStringSubmatch(base)
+	if match == nil || !strings.ContainsAny(match[2], "0123456789") ||
+		strings.Trim(match[2], "0123456789") == "" {
+		return "", "", false
+	}
+	return match[1], match[3], true
+}
+
+// Manifest maps logical asset names to their fingerprinted file names,
+// so that templates can reference assets without knowing their hashes.
+type Manifest struct {
+	assets map[string]string
+}
+
+// NewManifest walks fsys and maps the logical name of every fingerprinted file
+// (js/app.js) to its fingerprinted name (js/app.3f2a1b9c.js). Precompressed
+// siblings are ignored. If several versions of an asset exist, the most
+// recently modified one wins.
+//
+// The optional pattern replaces the detection of IsFingerprinted, like the
+// FingerprintPattern of the Config. Its first and last groups must capture the
+// name and the extension around the hash, e.g. `^(.+)\.[0-9a-f]{20}(\.[^/]+)$`.
+func NewManifest(fsys fs.FS, pattern ...*regexp.Regexp) (*Manifest, error) {
+	var fingerprint *regexp.Regexp
+	if len(pattern) > 0 {
+		fingerprint = pattern[0]
+		if fingerprint != nil && fingerprint.NumSubexp() < 2 {
+			return nil, errors.New("static: the fingerprint pattern must capture the name and the extension")
+		}
+	}
+
+	m := &Manifest{assets: make(map[string]string)}
+	modTimes := make(map[string]int64)
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || isPrecompressedSibling(name) {
+			return nil
+		}
+
+		dir, base := path.Split(name)
+		stem, ext, ok := splitFingerprint(fingerprint, base)
+		if !ok {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		logical := dir + stem + ext
+		if modTime, ok := modTimes[logical]; ok && modTime > info.ModTime().UnixNano() {
+			return nil
+		}
+		m.assets[logical] = name
+		modTimes[logical] = info.ModTime().UnixNano()
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("static: failed to build manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+// Path returns the fingerprinted name of the given logical asset name, or the
+// name itself if the asset is not fingerprinted. A leading slash is preserved,
+// so the result can be used as URL path: Path("/js/app.js") == "/js/app.3f2a1b9c.js".
+func (m *Manifest) Path(name string) string {
+	logical := strings.TrimPrefix(name, "/")
+	hashed, ok := m.assets[logical]
+	if !ok {
+		return name
+	}
+	return name[:len(name)-len(logical)] + hashed
+}
+
+// Assets returns a copy of the manifest as map of logical to fingerprinted names.
+func (m *Manifest) Assets() map[string]string {
+	assets := make(map[string]string, len(m.assets))
+	for logical, hashed := range m.assets {
+		assets[logical] = hashed
+	}
+	return assets
+}
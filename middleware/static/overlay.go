@@ -0,0 +1,133 @@
+package static
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// overlayFS searches an ordered list of filesystems in turn.
+type overlayFS []fs.FS
+
+// Overlay returns a fs.FS which looks up every file in the given layers in
+// order and returns the first match, e.g. Overlay(themeFS, baseFS) serves
+// files of the theme and falls back to the base for everything else.
+// Directory listings are merged across all layers.
+func Overlay(layers ...fs.FS) fs.FS {
+	return overlayFS(layers)
+}
+
+// Open implements fs.FS.
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	for _, layer := range o {
+		file, err := layer.Open(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		// Directories list the entries of all layers
+		info, err := file.Stat()
+		if err != nil || !info.IsDir() {
+			return file, nil //nolint:nilerr // the file reports the stat error itself
+		}
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			_ = file.Close() //nolint:errcheck // the ReadDir error is more relevant
+			return nil, err
+		}
+		return &overlayDir{File: file, entries: entries}, nil
+	}
+
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Stat implements fs.StatFS.
+func (o overlayFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	for _, layer := range o {
+		info, err := fs.Stat(layer, name)
+		if err == nil {
+			return info, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir implements fs.ReadDirFS. Entries of upper layers shadow entries of
+// the same name in lower layers.
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	var (
+		entries []fs.DirEntry
+		seen    = make(map[string]struct{})
+		found   bool
+	)
+	for _, layer := range o {
+		layerEntries, err := fs.ReadDir(layer, name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		found = true
+		for _, entry := range layerEntries {
+			if _, ok := seen[entry.Name()]; ok {
+				continue
+			}
+			seen[entry.Name()] = struct{}{}
+			entries = append(entries, entry)
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// overlayDir is a directory of the top-most layer listing the merged entries.
+type overlayDir struct {
+	fs.File
+	entries []fs.DirEntry
+	offset  int
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *overlayDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,140 @@
+package static
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/valyala/fasthttp"
+)
+
+// precompressedEncodings lists the supported precompressed siblings in order of preference.
+var precompressedEncodings = []struct {
+	encoding string
+	suffix   string
+}{
+	{encoding: velocity.StrBr, suffix: ".br"},
+	{encoding: velocity.StrZstd, suffix: ".zst"},
+	{encoding: velocity.StrGzip, suffix: ".gz"},
+}
+
+// isPrecompressedSibling reports whether name is the precompressed variant of another file.
+func isPrecompressedSibling(name string) bool {
+	for _, enc := range precompressedEncodings {
+		if strings.HasSuffix(name, enc.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveName resolves the request path to a file name in fsys,
+// using the first existing index file for directories.
+func resolveName(fsys fs.FS, requestPath string, prefixLen int, indexNames []string) (string, bool) {
+	if len(requestPath) < prefixLen {
+		return "", false
+	}
+	name := strings.TrimPrefix(requestPath[prefixLen:], "/")
+	isDir := name == "" || strings.HasSuffix(name, "/")
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return "", false
+	}
+	if !isDir {
+		return name, true
+	}
+
+	for _, index := range indexNames {
+		candidate := path.Join(name, index)
+		if _, err := fs.Stat(fsys, candidate); err == nil {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// servePrecompressed serves a precompressed sibling of name from fsys if the
+// client accepts its encoding. It reports whether a response was sent.
+func servePrecompressed(c velocity.Ctx, fsys fs.FS, name string) (bool, error) {
+	var suffix, encoding string
+	vary := false
+	for _, enc := range precompressedEncodings {
+		if _, err := fs.Stat(fsys, name+enc.suffix); err != nil {
+			continue
+		}
+		vary = true
+		if c.Request().Header.HasAcceptEncoding(enc.encoding) {
+			suffix, encoding = enc.suffix, enc.encoding
+			break
+		}
+	}
+
+	// The response depends on Accept-Encoding as soon as any sibling exists
+	if vary {
+		c.Vary(velocity.HeaderAcceptEncoding)
+	}
+	if encoding == "" {
+		return false, nil
+	}
+
+	file, err := fsys.Open(name + suffix)
+	if err != nil {
+		return false, nil //nolint:nilerr // fall back to the uncompressed file
+	}
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		_ = file.Close()  //nolint:errcheck // nothing left to report to
+		return false, nil //nolint:nilerr // fall back to the uncompressed file
+	}
+
+	if modTime := stat.ModTime(); !modTime.IsZero() {
+		modTime = modTime.UTC().Truncate(time.Second)
+		if since, err := fasthttp.ParseHTTPDate([]byte(c.Get(velocity.HeaderIfModifiedSince))); err == nil && !modTime.After(since) {
+			_ = file.Close() //nolint:errcheck // nothing left to report to
+			c.Status(velocity.StatusNotModified)
+			return true, nil
+		}
+		c.Set(velocity.HeaderLastModified, string(fasthttp.AppendHTTPDate(nil, modTime)))
+	}
+
+	setContentType(c, name)
+	c.Set(velocity.HeaderContentEncoding, encoding)
+	return true, c.SendStream(file, int(stat.Size()))
+}
+
+// serveRange answers a range request for name from fsys, including
+// multipart/byteranges and If-Range, via velocity.Ctx.SendStream.
+// It reports whether a response was sent.
+func serveRange(c velocity.Ctx, fsys fs.FS, name string) (bool, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return false, nil //nolint:nilerr // the file handler reports missing files
+	}
+	stat, err := file.Stat()
+	if _, seekable := file.(io.ReadSeeker); err != nil || stat.IsDir() || !seekable {
+		_ = file.Close()  //nolint:errcheck // nothing left to report to
+		return false, nil //nolint:nilerr // the file handler serves the request
+	}
+
+	setContentType(c, name)
+	if modTime := stat.ModTime(); !modTime.IsZero() {
+		c.Set(velocity.HeaderLastModified, string(fasthttp.AppendHTTPDate(nil, modTime.UTC())))
+	}
+	return true, c.SendStream(file, int(stat.Size()))
+}
+
+// setContentType mirrors fasthttp.FS, which derives the type from the extension as well.
+func setContentType(c velocity.Ctx, name string) {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = velocity.MIMEOctetStream
+	}
+	c.Set(velocity.HeaderContentType, contentType)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -14,6 +15,10 @@ import (
 	"github.com/valyala/fasthttp"
 )
 
+// immutableCacheControl is sent for fingerprinted files, which never change
+// under the same name and can therefore be cached for a year.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
 // New creates a new middleware handler.
 // The root argument specifies the root directory from which to serve static assets.
 //
@@ -24,12 +29,41 @@ func New(root string, cfg ...Config) velocity.Handler {
 	var createFS sync.Once
 	var fileHandler fasthttp.RequestHandler
 	var cacheControlValue string
+	var rootFS fs.FS
+	var prefixLen int
+	var indexPath string
+
+	if config.FingerprintPattern != nil && config.FingerprintPattern.NumSubexp() < 2 {
+		panic("static: FingerprintPattern must capture the name and the extension")
+	}
 
 	// adjustments for io/fs compatibility
 	if config.FS != nil && root == "" {
 		root = "."
 	}
 
+	// serve sends the precompressed sibling or the file itself for the given request path
+	serve := func(c velocity.Ctx, requestPath string) error {
+		if rootFS != nil {
+			if name, ok := resolveName(rootFS, requestPath, prefixLen, config.IndexNames); ok {
+				var (
+					served bool
+					err    error
+				)
+				if config.ByteRange && len(c.Request().Header.Peek(velocity.HeaderRange)) > 0 {
+					served, err = serveRange(c, rootFS, name)
+				} else if config.Precompressed {
+					served, err = servePrecompressed(c, rootFS, name)
+				}
+				if err != nil || served {
+					return err
+				}
+			}
+		}
+		fileHandler(c.RequestCtx())
+		return nil
+	}
+
 	return func(c velocity.Ctx) error {
 		// Don't execute middleware if Next returns true
 		if config.Next != nil && config.Next(c) {
@@ -52,11 +86,12 @@ func New(root string, cfg ...Config) velocity.Handler {
 				prefix = strings.Split(prefix, "*")[0]
 			}
 
-			prefixLen := len(prefix)
+			prefixLen = len(prefix)
 			if prefixLen > 1 && prefix[prefixLen-1:] == "/" {
 				// /john/ -> /john
 				prefixLen--
 			}
+			indexPath = strings.TrimSuffix(prefix[:prefixLen], "/") + "/"
 
 			fs := &fasthttp.FS{
 				Root:                   root,
@@ -111,10 +146,34 @@ func New(root string, cfg ...Config) velocity.Handler {
 			}
 
 			fileHandler = fs.NewRequestHandler()
+
+			// Precompressed siblings and range requests are served from the directory directly
+			if config.Precompressed || config.ByteRange {
+				if checkFile, err := isFile(root, config.FS); err == nil && !checkFile {
+					rootFS = dirFS(root, config.FS)
+				}
+			}
 		})
 
 		// Serve file
-		fileHandler(c.RequestCtx())
+		if err := serve(c, c.Path()); err != nil {
+			return err
+		}
+
+		// Fall back to the index file for client-side routes of single-page applications
+		if config.SPA && c.RequestCtx().Response.StatusCode() == velocity.StatusNotFound &&
+			isSPARoute(c.Path(), config.SPAExclude) {
+			originalPath := string(c.RequestCtx().URI().Path())
+
+			c.RequestCtx().Response.ResetBody()
+			c.RequestCtx().Response.SetStatusCode(velocity.StatusOK)
+			c.RequestCtx().URI().SetPath(indexPath)
+			err := serve(c, indexPath)
+			c.RequestCtx().URI().SetPath(originalPath)
+			if err != nil {
+				return err
+			}
+		}
 
 		// Sets the response Content-Disposition header to attachment if the Download option is true
 		if config.Download {
@@ -125,7 +184,9 @@ func New(root string, cfg ...Config) velocity.Handler {
 		status := c.RequestCtx().Response.StatusCode()
 
 		if status != velocity.StatusNotFound && status != velocity.StatusForbidden {
-			if len(cacheControlValue) > 0 {
+			if config.ImmutableFingerprinted && isFingerprinted(config.FingerprintPattern, c.Path()) {
+				c.RequestCtx().Response.Header.Set(velocity.HeaderCacheControl, immutableCacheControl)
+			} else if len(cacheControlValue) > 0 {
 				c.RequestCtx().Response.Header.Set(velocity.HeaderCacheControl, cacheControlValue)
 			}
 
@@ -151,6 +212,30 @@ func New(root string, cfg ...Config) velocity.Handler {
 	}
 }
 
+// isSPARoute reports whether the request path is a client-side route, i.e. it
+// does not look like an asset and is not excluded by one of the given prefixes.
+func isSPARoute(requestPath string, exclude []string) bool {
+	if path.Ext(requestPath) != "" {
+		return false
+	}
+	for _, prefix := range exclude {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// dirFS returns the served directory as fs.FS.
+// Like fasthttp.FS, a custom filesystem is always served from its root.
+func dirFS(root string, filesystem fs.FS) fs.FS {
+	if filesystem != nil {
+		return filesystem
+	}
+	return os.DirFS(root)
+}
+
 // isFile checks if the root is a file.
 func isFile(root string, filesystem fs.FS) (bool, error) {
 	var file fs.File
@@ -167,6 +252,7 @@ func isFile(root string, filesystem fs.FS) (bool, error) {
 			return false, fmt.Errorf("static: %w", err)
 		}
 	}
+	defer file.Close() //nolint:errcheck // only the stat is needed
 
 	stat, err := file.Stat()
 	if err != nil {
@@ -4,11 +4,16 @@ import (
 	"embed"
 	"io"
 	"io/fs"
+	"mime"
+	"mime/multipart"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/khulnasoft/velocity"
@@ -850,3 +855,397 @@ func Test_Static_Compress_WithFileSuffixes(t *testing.T) {
 		require.NoError(t, err, "File should exist")
 	}
 }
+
+func precompressedTestFS(t *testing.T) fs.FS {
+	t.Helper()
+	modTime := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sub, err := fs.Sub(fstest.MapFS{
+		"dist/index.html":              {Data: []byte("<html>index</html>"), ModTime: modTime},
+		"dist/index.html.gz":           {Data: []byte("gzip index"), ModTime: modTime},
+		"dist/js/app.3f2a1b9c.js":      {Data: []byte("console.log('app')"), ModTime: modTime},
+		"dist/js/app.3f2a1b9c.js.br":   {Data: []byte("brotli app"), ModTime: modTime},
+		"dist/js/app.3f2a1b9c.js.zst":  {Data: []byte("zstd app"), ModTime: modTime},
+		"dist/js/app.3f2a1b9c.js.gz":   {Data: []byte("gzip app"), ModTime: modTime},
+		"dist/js/app.0000aaaa.js":      {Data: []byte("old app"), ModTime: modTime.Add(-time.Hour)},
+		"dist/css/main-9f86d081.css":   {Data: []byte("body{}"), ModTime: modTime},
+		"dist/css/report-20240101.css": {Data: []byte("table{}"), ModTime: modTime},
+		"dist/robots.txt":              {Data: []byte("User-agent: *"), ModTime: modTime},
+	}, "dist")
+	require.NoError(t, err)
+	return sub
+}
+
+// go test -run Test_Static_Precompressed
+func Test_Static_Precompressed(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/assets*", New("", Config{
+		FS:            precompressedTestFS(t),
+		Precompressed: true,
+	}))
+
+	testCases := []struct {
+		path           string
+		acceptEncoding string
+		body           string
+		encoding       string
+		contentType    string
+		vary           string
+	}{
+		{path: "/assets/js/app.3f2a1b9c.js", acceptEncoding: "gzip, br, zstd", body: "brotli app", encoding: "br", contentType: velocity.MIMETextJavaScriptCharsetUTF8, vary: "Accept-Encoding"},
+		{path: "/assets/js/app.3f2a1b9c.js", acceptEncoding: "gzip, zstd", body: "zstd app", encoding: "zstd", contentType: velocity.MIMETextJavaScriptCharsetUTF8, vary: "Accept-Encoding"},
+		{path: "/assets/js/app.3f2a1b9c.js", acceptEncoding: "gzip", body: "gzip app", encoding: "gzip", contentType: velocity.MIMETextJavaScriptCharsetUTF8, vary: "Accept-Encoding"},
+		{path: "/assets/js/app.3f2a1b9c.js", acceptEncoding: "", body: "console.log('app')", encoding: "", contentType: velocity.MIMETextJavaScriptCharsetUTF8, vary: "Accept-Encoding"},
+		{path: "/assets/", acceptEncoding: "gzip", body: "gzip index", encoding: "gzip", contentType: velocity.MIMETextHTMLCharsetUTF8, vary: "Accept-Encoding"},
+		{path: "/assets/robots.txt", acceptEncoding: "gzip, br", body: "User-agent: *", encoding: "", contentType: velocity.MIMETextPlainCharsetUTF8, vary: ""},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(velocity.MethodGet, tc.path, nil)
+		if tc.acceptEncoding != "" {
+			req.Header.Set(velocity.HeaderAcceptEncoding, tc.acceptEncoding)
+		}
+
+		resp, err := app.Test(req)
+		require.NoError(t, err, "app.Test(req)")
+		require.Equal(t, 200, resp.StatusCode, tc.path)
+		require.Equal(t, tc.encoding, resp.Header.Get(velocity.HeaderContentEncoding), tc.path)
+		require.Equal(t, tc.contentType, resp.Header.Get(velocity.HeaderContentType), tc.path)
+		require.Equal(t, tc.vary, resp.Header.Get(velocity.HeaderVary), tc.path)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, tc.body, string(body), tc.path)
+	}
+
+	// Conditional requests are answered with 304
+	req := httptest.NewRequest(velocity.MethodGet, "/assets/js/app.3f2a1b9c.js", nil)
+	req.Header.Set(velocity.HeaderAcceptEncoding, "br")
+	req.Header.Set(velocity.HeaderIfModifiedSince, "Mon, 01 Jan 2024 00:00:00 GMT")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, velocity.StatusNotModified, resp.StatusCode)
+}
+
+// go test -run Test_Static_ImmutableFingerprinted
+func Test_Static_ImmutableFingerprinted(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/*", New("", Config{
+		FS:                     precompressedTestFS(t),
+		MaxAge:                 60,
+		ImmutableFingerprinted: true,
+	}))
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/css/main-9f86d081.css", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, 200, resp.StatusCode, "Status code")
+	require.Equal(t, "public, max-age=31536000, immutable", resp.Header.Get(velocity.HeaderCacheControl))
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/robots.txt", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, 200, resp.StatusCode, "Status code")
+	require.Equal(t, "public, max-age=60", resp.Header.Get(velocity.HeaderCacheControl))
+
+	// Date stamps are no content hashes
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/css/report-20240101.css", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, 200, resp.StatusCode, "Status code")
+	require.Equal(t, "public, max-age=60", resp.Header.Get(velocity.HeaderCacheControl))
+
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/css/main-00000000.css", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, 404, resp.StatusCode, "Status code")
+	require.Empty(t, resp.Header.Get(velocity.HeaderCacheControl))
+
+	// A custom pattern replaces the detection
+	custom := velocity.New()
+	custom.Get("/*", New("", Config{
+		FS:                     precompressedTestFS(t),
+		MaxAge:                 60,
+		ImmutableFingerprinted: true,
+		FingerprintPattern:     regexp.MustCompile(`^(.+)\.[0-9a-f]{8}(\.js)$`),
+	}))
+
+	resp, err = custom.Test(httptest.NewRequest(velocity.MethodGet, "/js/app.3f2a1b9c.js", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, "public, max-age=31536000, immutable", resp.Header.Get(velocity.HeaderCacheControl))
+
+	resp, err = custom.Test(httptest.NewRequest(velocity.MethodGet, "/css/main-9f86d081.css", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, "public, max-age=60", resp.Header.Get(velocity.HeaderCacheControl))
+
+	require.PanicsWithValue(t, "static: FingerprintPattern must capture the name and the extension", func() {
+		New("", Config{FingerprintPattern: regexp.MustCompile(`[0-9a-f]{8}`)})
+	})
+}
+
+// go test -run Test_Static_Manifest
+func Test_Static_Manifest(t *testing.T) {
+	t.Parallel()
+
+	manifest, err := NewManifest(precompressedTestFS(t))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"js/app.js":    "js/app.3f2a1b9c.js",
+		"css/main.css": "css/main-9f86d081.css",
+	}, manifest.Assets())
+
+	require.Equal(t, "/js/app.3f2a1b9c.js", manifest.Path("/js/app.js"))
+	require.Equal(t, "css/main-9f86d081.css", manifest.Path("css/main.css"))
+	require.Equal(t, "/robots.txt", manifest.Path("/robots.txt"))
+
+	require.True(t, IsFingerprinted("/js/app.3f2a1b9c.js"))
+	require.True(t, IsFingerprinted("main-9f86d081884c7d65.min.css"))
+	require.False(t, IsFingerprinted("/js/app.js"))
+	require.False(t, IsFingerprinted("main-settings.css"))
+	require.False(t, IsFingerprinted("/css/report-20240101.css"))
+	require.False(t, IsFingerprinted("logo-deadbeef.svg"))
+
+	// A custom pattern replaces the detection
+	manifest, err = NewManifest(precompressedTestFS(t), regexp.MustCompile(`^(.+)-([0-9]{8})(\.css)$`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"css/report.css": "css/report-20240101.css"}, manifest.Assets())
+
+	_, err = NewManifest(precompressedTestFS(t), regexp.MustCompile(`[0-9a-f]{8}`))
+	require.Error(t, err)
+}
+
+// go test -run Test_Static_SPA
+func Test_Static_SPA(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Use("/", New("", Config{
+		FS: fstest.MapFS{
+			"index.html":  {Data: []byte("<html>spa</html>")},
+			"js/app.js":   {Data: []byte("console.log('app')")},
+			"about/x.txt": {Data: []byte("x")},
+		},
+		SPA:        true,
+		SPAExclude: []string{"/api"},
+	}))
+	app.Get("/api/users", func(c velocity.Ctx) error {
+		return c.SendString("users")
+	})
+
+	testCases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{path: "/", status: 200, body: "<html>spa</html>"},
+		{path: "/js/app.js", status: 200, body: "console.log('app')"},
+		{path: "/users/42", status: 200, body: "<html>spa</html>"},
+		{path: "/settings/profile/", status: 200, body: "<html>spa</html>"},
+		{path: "/js/missing.js", status: 404, body: "Cannot GET /js/missing.js"},
+		{path: "/api/users", status: 200, body: "users"},
+		{path: "/api/missing", status: 404, body: "Cannot GET /api/missing"},
+	}
+
+	for _, tc := range testCases {
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, tc.path, nil))
+		require.NoError(t, err, "app.Test(req)")
+		require.Equal(t, tc.status, resp.StatusCode, tc.path)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, tc.body, string(body), tc.path)
+
+		if tc.body == "<html>spa</html>" {
+			require.Equal(t, velocity.MIMETextHTMLCharsetUTF8, resp.Header.Get(velocity.HeaderContentType), tc.path)
+		}
+	}
+}
+
+// go test -run Test_Static_SPA_Prefix
+func Test_Static_SPA_Prefix(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/web*", New("", Config{
+		FS: fstest.MapFS{
+			"index.html": {Data: []byte("<html>web</html>")},
+		},
+		SPA: true,
+	}))
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/web/dashboard", nil))
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, 200, resp.StatusCode, "Status code")
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "<html>web</html>", string(body))
+}
+
+// go test -run Test_Static_Overlay
+func Test_Static_Overlay(t *testing.T) {
+	t.Parallel()
+
+	theme := fstest.MapFS{
+		"css/style.css": {Data: []byte("theme style")},
+		"img/logo.svg":  {Data: []byte("theme logo")},
+	}
+	base := fstest.MapFS{
+		"index.html":    {Data: []byte("base index")},
+		"css/style.css": {Data: []byte("base style")},
+		"css/print.css": {Data: []byte("base print")},
+	}
+
+	app := velocity.New()
+	app.Get("/*", New("", Config{
+		FS:     Overlay(theme, base),
+		Browse: true,
+	}))
+
+	testCases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{path: "/css/style.css", status: 200, body: "theme style"},
+		{path: "/css/print.css", status: 200, body: "base print"},
+		{path: "/img/logo.svg", status: 200, body: "theme logo"},
+		{path: "/", status: 200, body: "base index"},
+		{path: "/missing.css", status: 404, body: "Cannot GET /missing.css"},
+	}
+
+	for _, tc := range testCases {
+		resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, tc.path, nil))
+		require.NoError(t, err, "app.Test(req)")
+		require.Equal(t, tc.status, resp.StatusCode, tc.path)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, tc.body, string(body), tc.path)
+	}
+
+	entries, err := fs.ReadDir(Overlay(theme, base), "css")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "print.css", entries[0].Name())
+	require.Equal(t, "style.css", entries[1].Name())
+
+	_, err = fs.Stat(Overlay(theme, base), "missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoError(t, fstest.TestFS(Overlay(theme, base), "css/style.css", "css/print.css", "img/logo.svg", "index.html"))
+}
+
+// go test -run Test_Static_MultiRange
+// openFilesFS counts the files of the FS which are not closed
+type openFilesFS struct {
+	fs.FS
+	open atomic.Int32
+}
+
+type countedFile struct {
+	fs.File
+	open *atomic.Int32
+}
+
+func (f *openFilesFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // passed on as is
+	}
+	f.open.Add(1)
+	return &countedFile{File: file, open: &f.open}, nil
+}
+
+func (f *countedFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence) //nolint:forcetypeassert,wrapcheck // fstest files are seekable
+}
+
+func (f *countedFile) Close() error {
+	f.open.Add(-1)
+	return f.File.Close() //nolint:wrapcheck // passed on as is
+}
+
+// go test -run Test_Static_Range_Close
+func Test_Static_Range_Close(t *testing.T) {
+	t.Parallel()
+
+	fsys := &openFilesFS{FS: fstest.MapFS{"video.txt": {Data: []byte("0123456789")}}}
+	app := velocity.New()
+	app.Get("/*", New("", Config{FS: fsys, ByteRange: true}))
+
+	for rangeHeader, status := range map[string]int{
+		"bytes=50-60":   velocity.StatusRequestedRangeNotSatisfiable,
+		"bytes=0-1":     velocity.StatusPartialContent,
+		"bytes=0-1,4-5": velocity.StatusPartialContent,
+	} {
+		req := httptest.NewRequest(velocity.MethodGet, "/video.txt", nil)
+		req.Header.Set(velocity.HeaderRange, rangeHeader)
+		resp, err := app.Test(req)
+		require.NoError(t, err, "app.Test(req)")
+		require.Equal(t, status, resp.StatusCode, rangeHeader)
+		_, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Zero(t, fsys.open.Load(), "%s leaks the file", rangeHeader)
+	}
+}
+
+func Test_Static_MultiRange(t *testing.T) {
+	t.Parallel()
+
+	app := velocity.New()
+	app.Get("/*", New("", Config{
+		FS: fstest.MapFS{
+			"video.txt": {Data: []byte("0123456789abcdefghij"), ModTime: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		ByteRange: true,
+	}))
+
+	req := httptest.NewRequest(velocity.MethodGet, "/video.txt", nil)
+	req.Header.Set(velocity.HeaderRange, "bytes=0-3,10-")
+
+	resp, err := app.Test(req)
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, velocity.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "bytes", resp.Header.Get(velocity.HeaderAcceptRanges))
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get(velocity.HeaderContentType))
+	require.NoError(t, err)
+	require.Equal(t, velocity.MIMEMultipartByteRanges, mediaType)
+
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+	for _, expected := range []string{"0123", "abcdefghij"} {
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, velocity.MIMETextPlainCharsetUTF8, part.Header.Get(velocity.HeaderContentType))
+
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(body))
+	}
+
+	// Single range
+	req = httptest.NewRequest(velocity.MethodGet, "/video.txt", nil)
+	req.Header.Set(velocity.HeaderRange, "bytes=-4")
+
+	resp, err = app.Test(req)
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, velocity.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "bytes 16-19/20", resp.Header.Get(velocity.HeaderContentRange))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "ghij", string(body))
+
+	// Range of a modified file
+	req = httptest.NewRequest(velocity.MethodGet, "/video.txt", nil)
+	req.Header.Set(velocity.HeaderRange, "bytes=0-3")
+	req.Header.Set(velocity.HeaderIfRange, "Tue, 02 Jan 2024 00:00:00 GMT")
+
+	resp, err = app.Test(req)
+	require.NoError(t, err, "app.Test(req)")
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "0123456789abcdefghij", string(body))
+}
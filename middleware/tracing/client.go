@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/client"
+)
+
+// InstrumentClient adds hooks to the client which start a client span for
+// every request, inject the traceparent and tracestate headers, and end the
+// span when the response is received. The span is a child of the span in the
+// context of the request, e.g. req.SetContext(c.Context()) in a handler.
+// Requests failing without a response are not exported.
+func InstrumentClient(cc *client.Client, tracer *Tracer) *client.Client {
+	return cc.
+		AddRequestHook(func(_ *client.Client, req *client.Request) error {
+			ctx := req.Context()
+			_, span := tracer.Start(ctx, "HTTP "+req.Method(),
+				WithSpanKind(SpanKindClient),
+				WithAttributes(
+					Attribute{Key: "http.request.method", Value: req.Method()},
+					Attribute{Key: "url.full", Value: req.URL()},
+				),
+			)
+
+			sc := span.SpanContext()
+			req.SetHeader(HeaderTraceparent, sc.Traceparent())
+			if sc.TraceState != "" {
+				req.SetHeader(HeaderTracestate, sc.TraceState)
+			}
+
+			// The span is kept aside, so it never becomes the parent of a retried request
+			req.SetContext(contextWithClientSpan(ctx, span))
+			return nil
+		}).
+		AddResponseHook(func(_ *client.Client, resp *client.Response, req *client.Request) error {
+			span := clientSpanFromContext(req.Context())
+			if span == nil {
+				return nil
+			}
+			status := resp.StatusCode()
+			span.SetAttributes(Attribute{Key: "http.response.status_code", Value: status})
+			if status >= velocity.StatusBadRequest {
+				span.SetStatus(StatusError, "")
+			}
+			span.End()
+			return nil
+		})
+}
@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"github.com/khulnasoft/velocity"
+)
+
+// Config defines the config for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default: nil
+	Next func(c velocity.Ctx) bool
+
+	// Tracer starts the server spans.
+	//
+	// Optional. Default: NewTracer()
+	Tracer *Tracer
+
+	// SpanName returns the name of the server span. It is called after the
+	// handlers, so c.Route() is the route which handled the request.
+	//
+	// Optional. Default: the route name, or the method and the route path
+	SpanName func(c velocity.Ctx) string
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	Next:     nil,
+	SpanName: defaultSpanName,
+}
+
+// Helper function to set default values
+func configDefault(config ...Config) Config {
+	// Return default config if nothing provided
+	if len(config) < 1 {
+		cfg := ConfigDefault
+		cfg.Tracer = NewTracer()
+		return cfg
+	}
+
+	// Override default config
+	cfg := config[0]
+
+	// Set default values
+	if cfg.Tracer == nil {
+		cfg.Tracer = NewTracer()
+	}
+	if cfg.SpanName == nil {
+		cfg.SpanName = ConfigDefault.SpanName
+	}
+	return cfg
+}
+
+// defaultSpanName names the span after the route, e.g. "GET /users/:id"
+func defaultSpanName(c velocity.Ctx) string {
+	route := c.Route()
+	if route.Name != "" {
+		return route.Name
+	}
+	return c.Method() + " " + route.Path
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// Exporter sends finished spans to a tracing backend.
+// ExportSpans is called for every sampled span when it ends and must be safe
+// for concurrent use, so exporters talking to a remote backend should batch.
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// noopExporter discards all spans
+type noopExporter struct{}
+
+func (noopExporter) ExportSpans(context.Context, []SpanData) error {
+	return nil
+}
+
+func (noopExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// InMemoryExporter keeps all spans in memory, e.g. for tests.
+type InMemoryExporter struct {
+	spans []SpanData
+	mu    sync.Mutex
+}
+
+// NewInMemoryExporter creates a new in-memory exporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpans implements Exporter.
+func (e *InMemoryExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, spans...)
+	e.mu.Unlock()
+	return nil
+}
+
+// Shutdown implements Exporter.
+func (*InMemoryExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// Spans returns a copy of the exported spans in the order they ended.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset removes all exported spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
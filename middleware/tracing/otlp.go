@@ -0,0 +1,311 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/client"
+	"github.com/khulnasoft/velocity/log"
+)
+
+// instrumentationScope is the scope name of all exported spans
+const instrumentationScope = "github.com/khulnasoft/velocity/middleware/tracing"
+
+// OTLPConfig defines the config for OTLPExporter.
+type OTLPConfig struct {
+	// Headers are sent with every export request, e.g. for authentication.
+	//
+	// Optional. Default: nil
+	Headers map[string]string
+
+	// Endpoint is the URL of the OTLP/HTTP traces endpoint of a collector.
+	//
+	// Optional. Default: "http://localhost:4318/v1/traces"
+	Endpoint string
+
+	// ServiceName is exported as the service.name resource attribute.
+	//
+	// Optional. Default: "velocity"
+	ServiceName string
+
+	// BatchSize is the number of spans which triggers an export.
+	//
+	// Optional. Default: 512
+	BatchSize int
+
+	// FlushInterval is the maximum time spans are buffered.
+	//
+	// Optional. Default: 5 * time.Second
+	FlushInterval time.Duration
+
+	// Timeout limits each export request.
+	//
+	// Optional. Default: 10 * time.Second
+	Timeout time.Duration
+}
+
+// OTLPConfigDefault is the default config of OTLPExporter.
+var OTLPConfigDefault = OTLPConfig{
+	Endpoint:      "http://localhost:4318/v1/traces",
+	ServiceName:   "velocity",
+	BatchSize:     512,
+	FlushInterval: 5 * time.Second,
+	Timeout:       10 * time.Second,
+}
+
+// otlpConfigDefault sets the config values if they are not set.
+func otlpConfigDefault(config ...OTLPConfig) OTLPConfig {
+	if len(config) < 1 {
+		return OTLPConfigDefault
+	}
+	cfg := config[0]
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = OTLPConfigDefault.Endpoint
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = OTLPConfigDefault.ServiceName
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = OTLPConfigDefault.BatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = OTLPConfigDefault.FlushInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = OTLPConfigDefault.Timeout
+	}
+	return cfg
+}
+
+// OTLPExporter buffers spans and sends them in batches to an OpenTelemetry
+// collector using OTLP/HTTP with the JSON encoding.
+type OTLPExporter struct {
+	client  *client.Client
+	flushCh chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	queue   []SpanData
+	cfg     OTLPConfig
+	mu      sync.Mutex
+	sendMu  sync.Mutex
+	once    sync.Once
+}
+
+// NewOTLPExporter creates a new exporter and starts its background flushing.
+func NewOTLPExporter(config ...OTLPConfig) *OTLPExporter {
+	cfg := otlpConfigDefault(config...)
+
+	e := &OTLPExporter{
+		client:  client.New().SetTimeout(cfg.Timeout),
+		flushCh: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		cfg:     cfg,
+	}
+	go e.run()
+
+	return e
+}
+
+// ExportSpans implements Exporter. The spans are sent in the background.
+func (e *OTLPExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	e.queue = append(e.queue, spans...)
+	full := len(e.queue) >= e.cfg.BatchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// ForceFlush sends all buffered spans.
+func (e *OTLPExporter) ForceFlush(ctx context.Context) error {
+	e.sendMu.Lock()
+	defer e.sendMu.Unlock()
+
+	for {
+		e.mu.Lock()
+		n := min(len(e.queue), e.cfg.BatchSize)
+		batch := e.queue[:n:n]
+		e.queue = e.queue[n:]
+		e.mu.Unlock()
+
+		if n == 0 {
+			return nil
+		}
+		if err := e.send(ctx, batch); err != nil {
+			return err
+		}
+	}
+}
+
+// Shutdown implements Exporter. It stops the background flushing and sends all buffered spans.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() {
+		close(e.done)
+	})
+	<-e.stopped
+	return e.ForceFlush(ctx)
+}
+
+// run flushes the queue periodically and when a batch is full.
+func (e *OTLPExporter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flushCh:
+		case <-e.done:
+			return
+		}
+		if err := e.ForceFlush(context.Background()); err != nil {
+			log.Errorf("tracing: failed to export spans: %v", err)
+		}
+	}
+}
+
+// send posts a batch of spans to the collector.
+func (e *OTLPExporter) send(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return fmt.Errorf("tracing: failed to encode spans: %w", err)
+	}
+
+	req := e.client.R().
+		SetContext(ctx).
+		SetHeader(velocity.HeaderContentType, velocity.MIMEApplicationJSON).
+		SetHeaders(e.cfg.Headers).
+		SetRawBody(body)
+
+	resp, err := req.Post(e.cfg.Endpoint)
+	if err != nil {
+		client.ReleaseRequest(req)
+		return fmt.Errorf("tracing: failed to send spans: %w", err)
+	}
+	defer resp.Close()
+
+	if status := resp.StatusCode(); status < velocity.StatusOK || status >= velocity.StatusMultipleChoices {
+		return fmt.Errorf("tracing: collector responded with status %d", status)
+	}
+	return nil
+}
+
+// OTLP/JSON message types
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type (
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		TraceState        string         `json:"traceState,omitempty"`
+		Name              string         `json:"name"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+		Kind              SpanKind       `json:"kind"`
+	}
+	otlpStatus struct {
+		Message string     `json:"message,omitempty"`
+		Code    StatusCode `json:"code"`
+	}
+	otlpKeyValue struct {
+		Value otlpAnyValue `json:"value"`
+		Key   string       `json:"key"`
+	}
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// payload converts the spans into an OTLP/JSON request.
+func (e *OTLPExporter) payload(spans []SpanData) otlpTraces {
+	out := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		out[i] = otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			TraceState:        span.SpanContext.TraceState,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			out[i].ParentSpanID = span.ParentSpanID.String()
+		}
+	}
+
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes([]Attribute{
+			{Key: "service.name", Value: e.cfg.ServiceName},
+		})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: instrumentationScope},
+			Spans: out,
+		}},
+	}}}
+}
+
+// otlpAttributes converts attributes into OTLP key-values.
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpAnyValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			value.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		out = append(out, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return out
+}
@@ -0,0 +1,163 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// W3C Trace Context headers
+// https://www.w3.org/TR/trace-context/
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// Limits of the tracestate header
+const (
+	maxTracestateLen     = 512
+	maxTracestateMembers = 32
+)
+
+// ErrInvalidTraceparent is returned by ParseTraceparent for malformed headers.
+var ErrInvalidTraceparent = errors.New("tracing: invalid traceparent")
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// String returns the lower-case hex encoding of the ID.
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid reports whether the ID is not all zeros.
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the lower-case hex encoding of the ID.
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid reports whether the ID is not all zeros.
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// TraceFlags are the flags of the traceparent header.
+type TraceFlags byte
+
+// FlagsSampled marks a trace which is recorded by the caller.
+const FlagsSampled TraceFlags = 0x01
+
+// SpanContext is the part of a span which is propagated to other services.
+type SpanContext struct {
+	TraceState string
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      TraceFlags
+	Remote     bool
+}
+
+// IsValid reports whether the trace and the span ID are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the sampled flag is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagsSampled != 0
+}
+
+// Traceparent returns the value of the traceparent header.
+func (sc SpanContext) Traceparent() string {
+	b := make([]byte, 0, 55)
+	b = append(b, "00-"...)
+	b = hex.AppendEncode(b, sc.TraceID[:])
+	b = append(b, '-')
+	b = hex.AppendEncode(b, sc.SpanID[:])
+	b = append(b, '-')
+	b = hex.AppendEncode(b, []byte{byte(sc.Flags)})
+	return string(b)
+}
+
+// ParseTraceparent parses the value of a traceparent header.
+// Headers of future versions are accepted as long as they start
+// with the fields of version 00.
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+	version, ok := decodeHex(value[:2])
+	if !ok || version[0] == 0xff {
+		return sc, ErrInvalidTraceparent
+	}
+	if len(value) > 55 && (version[0] == 0 || value[55] != '-') {
+		return sc, ErrInvalidTraceparent
+	}
+
+	traceID, ok := decodeHex(value[3:35])
+	if !ok {
+		return sc, ErrInvalidTraceparent
+	}
+	spanID, ok := decodeHex(value[36:52])
+	if !ok {
+		return sc, ErrInvalidTraceparent
+	}
+	flags, ok := decodeHex(value[53:55])
+	if !ok {
+		return sc, ErrInvalidTraceparent
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = TraceFlags(flags[0])
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// decodeHex decodes lower-case hex only, as required by the specification.
+func decodeHex(s string) ([]byte, bool) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return nil, false
+		}
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// parseTracestate returns the normalized tracestate header,
+// or an empty string if the header is invalid.
+func parseTracestate(value string) string {
+	if value == "" || len(value) > maxTracestateLen {
+		return ""
+	}
+
+	members := strings.Split(value, ",")
+	out := make([]string, 0, len(members))
+	for _, member := range members {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(member, "=")
+		if !ok || key == "" || val == "" || strings.ContainsAny(key, " \t") {
+			return ""
+		}
+		out = append(out, member)
+	}
+	if len(out) > maxTracestateMembers {
+		return ""
+	}
+	return strings.Join(out, ",")
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"sync"
+	"time"
+)
+
+// SpanKind describes the relationship of a span to its parent and children.
+type SpanKind int
+
+// Span kinds, matching the values of OpenTelemetry
+const (
+	SpanKindInternal SpanKind = iota + 1
+	SpanKindServer
+	SpanKindClient
+)
+
+// StatusCode is the status of a span.
+type StatusCode int
+
+// Status codes, matching the values of OpenTelemetry
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+// Attribute is a key-value pair describing a span.
+// Values are strings, bools, ints, int64s and float64s.
+type Attribute struct {
+	Value any
+	Key   string
+}
+
+// SpanData is the snapshot of a finished span passed to the exporter.
+type SpanData struct {
+	Start         time.Time
+	End           time.Time
+	Name          string
+	StatusMessage string
+	Attributes    []Attribute
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	Kind          SpanKind
+	Status        StatusCode
+}
+
+// Span is a timed operation of a trace. All methods are safe for concurrent
+// use and can be called on a nil span.
+type Span struct {
+	tracer    *Tracer
+	data      SpanData
+	mu        sync.Mutex
+	recording bool
+	ended     bool
+}
+
+// SpanContext returns the propagated part of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// IsRecording reports whether the span is exported when it ends.
+func (s *Span) IsRecording() bool {
+	return s != nil && s.recording
+}
+
+// SetName replaces the name of the span.
+func (s *Span) SetName(name string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	s.data.Name = name
+	s.mu.Unlock()
+}
+
+// SetAttributes adds attributes to the span, replacing attributes with the same key.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+outer:
+	for _, attr := range attrs {
+		for i := range s.data.Attributes {
+			if s.data.Attributes[i].Key == attr.Key {
+				s.data.Attributes[i].Value = attr.Value
+				continue outer
+			}
+		}
+		s.data.Attributes = append(s.data.Attributes, attr)
+	}
+}
+
+// SetStatus sets the status of the span. The message is only kept for StatusError.
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Status = code
+	s.data.StatusMessage = ""
+	if code == StatusError {
+		s.data.StatusMessage = message
+	}
+}
+
+// RecordError marks the span as failed with the error.
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+	s.SetAttributes(Attribute{Key: "exception.message", Value: err.Error()})
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End finishes the span and passes it to the exporter of the tracer.
+// Only the first call has an effect.
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	data.Attributes = append([]Attribute(nil), s.data.Attributes...)
+	s.mu.Unlock()
+
+	s.tracer.export(data)
+}
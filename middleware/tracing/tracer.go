@@ -0,0 +1,228 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/khulnasoft/velocity/log"
+)
+
+// The contextKey type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey int
+
+// The keys for the values in context
+const (
+	spanKey contextKey = iota
+	clientSpanKey
+)
+
+// TracerConfig defines the config for Tracer.
+type TracerConfig struct {
+	// Exporter receives all finished spans which are sampled.
+	//
+	// Optional. Default: an exporter discarding all spans
+	Exporter Exporter
+
+	// SampleRatio is the fraction of new traces which are sampled. Traces
+	// continued from a parent keep the sampling decision of the parent.
+	//
+	// Optional. Default: 1
+	SampleRatio float64
+}
+
+// TracerConfigDefault is the default config of Tracer.
+var TracerConfigDefault = TracerConfig{
+	Exporter:    noopExporter{},
+	SampleRatio: 1,
+}
+
+// Tracer starts spans and passes them to its exporter when they end.
+type Tracer struct {
+	exporter    Exporter
+	sampleRatio float64
+}
+
+// NewTracer creates a new tracer.
+func NewTracer(config ...TracerConfig) *Tracer {
+	cfg := TracerConfigDefault
+	if len(config) > 0 {
+		cfg = config[0]
+		if cfg.Exporter == nil {
+			cfg.Exporter = TracerConfigDefault.Exporter
+		}
+		if cfg.SampleRatio <= 0 {
+			cfg.SampleRatio = TracerConfigDefault.SampleRatio
+		}
+	}
+
+	return &Tracer{
+		exporter:    cfg.Exporter,
+		sampleRatio: cfg.SampleRatio,
+	}
+}
+
+// spanConfig holds the options of Tracer.Start
+type spanConfig struct {
+	attributes []Attribute
+	parent     SpanContext
+	kind       SpanKind
+	hasParent  bool
+}
+
+// SpanOption configures a span started by Tracer.Start.
+type SpanOption func(*spanConfig)
+
+// WithSpanKind sets the kind of the span. The default is SpanKindInternal.
+func WithSpanKind(kind SpanKind) SpanOption {
+	return func(cfg *spanConfig) {
+		cfg.kind = kind
+	}
+}
+
+// WithParent sets the parent of the span, e.g. a remote span context
+// extracted from a traceparent header, instead of the span of the context.
+func WithParent(parent SpanContext) SpanOption {
+	return func(cfg *spanConfig) {
+		cfg.parent = parent
+		cfg.hasParent = true
+	}
+}
+
+// WithAttributes adds attributes to the span.
+func WithAttributes(attrs ...Attribute) SpanOption {
+	return func(cfg *spanConfig) {
+		cfg.attributes = append(cfg.attributes, attrs...)
+	}
+}
+
+// Start starts a span which is a child of the span in the context,
+// and returns a copy of the context carrying the new span.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	cfg := spanConfig{kind: SpanKindInternal}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if !cfg.hasParent {
+		cfg.parent = SpanFromContext(ctx).SpanContext()
+	}
+
+	sc := SpanContext{SpanID: newSpanID()}
+	if cfg.parent.IsValid() {
+		sc.TraceID = cfg.parent.TraceID
+		sc.TraceState = cfg.parent.TraceState
+		sc.Flags = cfg.parent.Flags & FlagsSampled
+	} else {
+		sc.TraceID = newTraceID()
+		if t.shouldSample(sc.TraceID) {
+			sc.Flags = FlagsSampled
+		}
+	}
+
+	span := &Span{
+		tracer:    t,
+		recording: sc.IsSampled(),
+		data: SpanData{
+			Name:        name,
+			Kind:        cfg.kind,
+			SpanContext: sc,
+			Start:       time.Now(),
+			Attributes:  cfg.attributes,
+		},
+	}
+	if cfg.parent.IsValid() {
+		span.data.ParentSpanID = cfg.parent.SpanID
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Shutdown flushes and shuts down the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.exporter.Shutdown(ctx)
+}
+
+// shouldSample decides about new traces based on the trace ID,
+// so that all services sampling with the same ratio agree.
+func (t *Tracer) shouldSample(traceID TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	return binary.BigEndian.Uint64(traceID[8:]) < uint64(t.sampleRatio*math.MaxUint64)
+}
+
+// export passes a finished span to the exporter.
+func (t *Tracer) export(data SpanData) {
+	if err := t.exporter.ExportSpans(context.Background(), []SpanData{data}); err != nil {
+		log.Errorf("tracing: failed to export span: %v", err)
+	}
+}
+
+// ContextWithSpan returns a copy of the context carrying the span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey, span)
+}
+
+// SpanFromContext returns the span of the context, or nil if there is none.
+// Supported context types:
+// - velocity.Ctx: Retrieves the span from c.Context()
+// - context.Context: Retrieves the span from context values
+func SpanFromContext(c any) *Span {
+	var ctx context.Context
+	switch v := c.(type) {
+	case interface{ Context() context.Context }:
+		ctx = v.Context()
+	case context.Context:
+		ctx = v
+	}
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey).(*Span) //nolint:errcheck // nil if there is no span
+	return span
+}
+
+// contextWithClientSpan stores the span of an outgoing request.
+func contextWithClientSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, clientSpanKey, span)
+}
+
+// clientSpanFromContext returns the span of an outgoing request.
+func clientSpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(clientSpanKey).(*Span) //nolint:errcheck // nil if there is no span
+	return span
+}
+
+// LogExtractor adds the trace ID of the span in the context to log messages.
+// Register it with log.RegisterContextExtractor.
+func LogExtractor(ctx context.Context) (string, any, bool) {
+	sc := SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return "", nil, false
+	}
+	return "trace-id", sc.TraceID.String(), true
+}
+
+// newTraceID returns a random trace ID.
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:]) //nolint:errcheck // crypto/rand never fails
+	}
+	return id
+}
+
+// newSpanID returns a random span ID.
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:]) //nolint:errcheck // crypto/rand never fails
+	}
+	return id
+}
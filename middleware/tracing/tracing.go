@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"errors"
+
+	"github.com/khulnasoft/velocity"
+)
+
+// New creates a new middleware handler
+func New(config ...Config) velocity.Handler {
+	// Set default config
+	cfg := configDefault(config...)
+
+	// Return new handler
+	return func(c velocity.Ctx) error {
+		// Don't execute middleware if Next returns true
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		// Continue the trace of the caller, if any
+		opts := []SpanOption{
+			WithSpanKind(SpanKindServer),
+			WithAttributes(
+				Attribute{Key: "http.request.method", Value: c.Method()},
+				Attribute{Key: "url.path", Value: c.Path()},
+				Attribute{Key: "server.address", Value: c.Hostname()},
+				Attribute{Key: "client.address", Value: c.IP()},
+				Attribute{Key: "user_agent.original", Value: c.Get(velocity.HeaderUserAgent)},
+			),
+		}
+		if parent, err := ParseTraceparent(c.Get(HeaderTraceparent)); err == nil {
+			parent.Remote = true
+			parent.TraceState = parseTracestate(c.Get(HeaderTracestate))
+			opts = append(opts, WithParent(parent))
+		}
+
+		ctx, span := cfg.Tracer.Start(c.Context(), c.Method()+" "+c.Path(), opts...)
+		c.SetContext(ctx)
+
+		// Continue stack
+		chainErr := c.Next()
+
+		// The route is only known after the handlers ran
+		span.SetName(cfg.SpanName(c))
+		span.SetAttributes(Attribute{Key: "http.route", Value: c.Route().Path})
+
+		status := c.Response().StatusCode()
+		if chainErr != nil {
+			status = velocity.StatusInternalServerError
+			var velocityErr *velocity.Error
+			if errors.As(chainErr, &velocityErr) {
+				status = velocityErr.Code
+			}
+		}
+		span.SetAttributes(Attribute{Key: "http.response.status_code", Value: status})
+		if status >= velocity.StatusInternalServerError {
+			if chainErr != nil {
+				span.RecordError(chainErr)
+			} else {
+				span.SetStatus(StatusError, "")
+			}
+		}
+		span.End()
+
+		return chainErr
+	}
+}
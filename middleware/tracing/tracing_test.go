@@ -0,0 +1,370 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/velocity"
+	"github.com/khulnasoft/velocity/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+)
+
+func startServer(t *testing.T, app *velocity.App) string {
+	t.Helper()
+
+	ln, err := net.Listen(velocity.NetworkTCP4, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, app.Listener(ln, velocity.ListenConfig{DisableStartupMessage: true}))
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, app.Shutdown())
+	})
+
+	return "http://" + ln.Addr().String()
+}
+
+func attribute(span SpanData, key string) any {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return nil
+}
+
+// go test -run Test_Tracing
+func Test_Tracing(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{Tracer: NewTracer(TracerConfig{Exporter: exporter})}))
+
+	var handlerSpan *Span
+	app.Get("/users/:id", func(c velocity.Ctx) error {
+		handlerSpan = SpanFromContext(c)
+		return c.SendString("ok")
+	})
+	app.Get("/named", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusServiceUnavailable)
+	}).Name("named-route")
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/users/42", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	require.Equal(t, "GET /users/:id", span.Name)
+	require.Equal(t, SpanKindServer, span.Kind)
+	require.Equal(t, StatusUnset, span.Status)
+	require.Equal(t, "/users/42", attribute(span, "url.path"))
+	require.Equal(t, "/users/:id", attribute(span, "http.route"))
+	require.Equal(t, velocity.StatusOK, attribute(span, "http.response.status_code"))
+	require.False(t, span.ParentSpanID.IsValid())
+	require.True(t, span.SpanContext.IsSampled())
+	require.Equal(t, span.SpanContext, handlerSpan.SpanContext())
+	require.False(t, span.End.Before(span.Start))
+
+	exporter.Reset()
+	resp, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/named", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusServiceUnavailable, resp.StatusCode)
+
+	spans = exporter.Spans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "named-route", spans[0].Name)
+	require.Equal(t, StatusError, spans[0].Status)
+}
+
+// go test -run Test_Tracing_Error
+func Test_Tracing_Error(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{Tracer: NewTracer(TracerConfig{Exporter: exporter})}))
+
+	app.Get("/bad", func(_ velocity.Ctx) error {
+		return velocity.ErrBadRequest
+	})
+	app.Get("/fail", func(_ velocity.Ctx) error {
+		return errors.New("boom")
+	})
+
+	_, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/bad", nil))
+	require.NoError(t, err)
+	_, err = app.Test(httptest.NewRequest(velocity.MethodGet, "/fail", nil))
+	require.NoError(t, err)
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 2)
+	require.Equal(t, velocity.StatusBadRequest, attribute(spans[0], "http.response.status_code"))
+	require.Equal(t, StatusUnset, spans[0].Status)
+	require.Equal(t, velocity.StatusInternalServerError, attribute(spans[1], "http.response.status_code"))
+	require.Equal(t, StatusError, spans[1].Status)
+	require.Equal(t, "boom", spans[1].StatusMessage)
+	require.Equal(t, "boom", attribute(spans[1], "exception.message"))
+}
+
+// go test -run Test_Tracing_Propagation
+func Test_Tracing_Propagation(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{Tracer: NewTracer(TracerConfig{Exporter: exporter})}))
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendString(SpanFromContext(c).SpanContext().Traceparent())
+	})
+
+	req := httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set(HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(HeaderTracestate, "vendor=value, other=1")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", spans[0].ParentSpanID.String())
+	require.Equal(t, "vendor=value,other=1", spans[0].SpanContext.TraceState)
+	require.NotEqual(t, "00f067aa0ba902b7", spans[0].SpanContext.SpanID.String())
+
+	// Unsampled parents are not exported
+	exporter.Reset()
+	req = httptest.NewRequest(velocity.MethodGet, "/", nil)
+	req.Header.Set(HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusOK, resp.StatusCode)
+	require.Empty(t, exporter.Spans())
+}
+
+// go test -run Test_Tracing_Next
+func Test_Tracing_Next(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	app := velocity.New()
+	app.Use(New(Config{
+		Next: func(_ velocity.Ctx) bool {
+			return true
+		},
+		Tracer: NewTracer(TracerConfig{Exporter: exporter}),
+	}))
+
+	resp, err := app.Test(httptest.NewRequest(velocity.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusNotFound, resp.StatusCode)
+	require.Empty(t, exporter.Spans())
+}
+
+// go test -run Test_Tracing_SampleRatio
+func Test_Tracing_SampleRatio(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(TracerConfig{Exporter: exporter, SampleRatio: 0.5})
+
+	for i := 0; i < 1000; i++ {
+		_, span := tracer.Start(context.Background(), "span")
+		span.End()
+	}
+	n := len(exporter.Spans())
+	require.Greater(t, n, 350)
+	require.Less(t, n, 650)
+}
+
+// go test -run Test_ParseTraceparent
+func Test_ParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: true},
+		{value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", valid: true},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", valid: false},
+		{value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: false},
+		{value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", valid: false},
+		{value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", valid: false},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", valid: false},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", valid: false},
+		{value: "", valid: false},
+	}
+
+	for _, tt := range tests {
+		sc, err := ParseTraceparent(tt.value)
+		if !tt.valid {
+			require.ErrorIs(t, err, ErrInvalidTraceparent, tt.value)
+			continue
+		}
+		require.NoError(t, err, tt.value)
+		require.Equal(t, tt.value[2:55], sc.Traceparent()[2:], tt.value)
+	}
+}
+
+// go test -run Test_InstrumentClient
+func Test_InstrumentClient(t *testing.T) {
+	t.Parallel()
+
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(TracerConfig{Exporter: exporter})
+
+	backend := velocity.New()
+	backend.Use(New(Config{Tracer: tracer}))
+	backend.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusTeapot)
+	})
+	addr := startServer(t, backend)
+
+	cc := InstrumentClient(client.New(), tracer)
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	resp, err := cc.R().SetContext(ctx).Get(addr)
+	require.NoError(t, err)
+	require.Equal(t, velocity.StatusTeapot, resp.StatusCode())
+	resp.Close()
+	parent.End()
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 3)
+	server, clientSpan, root := spans[0], spans[1], spans[2]
+
+	require.Equal(t, SpanKindServer, server.Kind)
+	require.Equal(t, SpanKindClient, clientSpan.Kind)
+	require.Equal(t, "HTTP GET", clientSpan.Name)
+	require.Equal(t, velocity.StatusTeapot, attribute(clientSpan, "http.response.status_code"))
+	require.Equal(t, StatusError, clientSpan.Status)
+
+	require.Equal(t, root.SpanContext.TraceID, clientSpan.SpanContext.TraceID)
+	require.Equal(t, root.SpanContext.TraceID, server.SpanContext.TraceID)
+	require.Equal(t, root.SpanContext.SpanID, clientSpan.ParentSpanID)
+	require.Equal(t, clientSpan.SpanContext.SpanID, server.ParentSpanID)
+}
+
+// go test -run Test_OTLPExporter
+func Test_OTLPExporter(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		payloads []otlpTraces
+	)
+	collector := velocity.New()
+	collector.Post("/v1/traces", func(c velocity.Ctx) error {
+		if c.Get("X-Api-Key") != "secret" {
+			return c.SendStatus(velocity.StatusUnauthorized)
+		}
+		var payload otlpTraces
+		if err := json.Unmarshal(c.Body(), &payload); err != nil {
+			return err
+		}
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+		return c.SendStatus(velocity.StatusOK)
+	})
+	addr := startServer(t, collector)
+
+	exporter := NewOTLPExporter(OTLPConfig{
+		Endpoint:      addr + "/v1/traces",
+		ServiceName:   "test-service",
+		Headers:       map[string]string{"X-Api-Key": "secret"},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	tracer := NewTracer(TracerConfig{Exporter: exporter})
+
+	for i := 0; i < 3; i++ {
+		_, span := tracer.Start(context.Background(), "span", WithAttributes(
+			Attribute{Key: "int", Value: i},
+			Attribute{Key: "bool", Value: true},
+		))
+		span.End()
+	}
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var spans []otlpSpan
+	for _, payload := range payloads {
+		require.Len(t, payload.ResourceSpans, 1)
+		rs := payload.ResourceSpans[0]
+		require.Equal(t, "service.name", rs.Resource.Attributes[0].Key)
+		require.Equal(t, "test-service", *rs.Resource.Attributes[0].Value.StringValue)
+		require.Equal(t, instrumentationScope, rs.ScopeSpans[0].Scope.Name)
+		spans = append(spans, rs.ScopeSpans[0].Spans...)
+	}
+	require.Len(t, spans, 3)
+	require.Len(t, spans[0].TraceID, 32)
+	require.Len(t, spans[0].SpanID, 16)
+	require.Equal(t, SpanKindInternal, spans[0].Kind)
+	require.Equal(t, "2", *spans[2].Attributes[0].Value.IntValue)
+	require.True(t, *spans[2].Attributes[1].Value.BoolValue)
+}
+
+// go test -run Test_OTLPExporter_Status
+func Test_OTLPExporter_Status(t *testing.T) {
+	t.Parallel()
+
+	collector := velocity.New()
+	collector.Post("/v1/traces", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusServiceUnavailable)
+	})
+	addr := startServer(t, collector)
+
+	exporter := NewOTLPExporter(OTLPConfig{Endpoint: addr + "/v1/traces", FlushInterval: time.Hour})
+	require.NoError(t, exporter.ExportSpans(context.Background(), []SpanData{{Name: "span"}}))
+	require.EqualError(t, exporter.Shutdown(context.Background()), "tracing: collector responded with status 503")
+}
+
+// go test -run Test_LogExtractor
+func Test_LogExtractor(t *testing.T) {
+	t.Parallel()
+
+	_, _, ok := LogExtractor(context.Background())
+	require.False(t, ok)
+
+	ctx, span := NewTracer().Start(context.Background(), "span")
+	key, value, ok := LogExtractor(ctx)
+	require.True(t, ok)
+	require.Equal(t, "trace-id", key)
+	require.Equal(t, span.SpanContext().TraceID.String(), value)
+}
+
+// go test -v -run=^$ -bench=Benchmark_Tracing -benchmem -count=4
+func Benchmark_Tracing(b *testing.B) {
+	app := velocity.New()
+	app.Use(New())
+	app.Get("/", func(c velocity.Ctx) error {
+		return c.SendStatus(velocity.StatusOK)
+	})
+	h := app.Handler()
+
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod(velocity.MethodGet)
+	fctx.Request.SetRequestURI("/")
+	fctx.Request.Header.Set(HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		h(fctx)
+	}
+}
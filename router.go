@@ -63,6 +63,12 @@ type Route struct {
 	root  bool   // Path equals '/'
 }
 
+// IsUse reports whether the route was registered with Use and matches the
+// path prefix, e.g. a middleware or a static file server.
+func (r *Route) IsUse() bool {
+	return r.use
+}
+
 func (r *Route) match(detectionPath, path string, params *[maxParams]string) bool {
 	// root detectionPath check
 	if r.root && len(detectionPath) == 1 && detectionPath[0] == '/' {